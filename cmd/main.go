@@ -2,6 +2,8 @@ package main
 
 import (
     "context"
+    "crypto/x509"
+    "encoding/pem"
     "fmt"
     "os"
     "os/signal"
@@ -9,10 +11,13 @@ import (
     "time"
 
     "github.com/joho/godotenv"
+    "github.com/mohammedteir/telegram-video-downloader-bot/internal/buildinfo"
     "github.com/mohammedteir/telegram-video-downloader-bot/internal/config"
     "github.com/mohammedteir/telegram-video-downloader-bot/internal/database"
     "github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
     "github.com/mohammedteir/telegram-video-downloader-bot/internal/handlers"
+    "github.com/mohammedteir/telegram-video-downloader-bot/internal/health"
+    "github.com/mohammedteir/telegram-video-downloader-bot/internal/i18n"
     "github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
 
     "gopkg.in/telebot.v3"
@@ -28,6 +33,10 @@ func main() {
         fmt.Printf("Error loading configuration: %v\n", err)
         os.Exit(1)
     }
+    if err := cfg.Validate(); err != nil {
+        fmt.Printf("Invalid configuration:\n%v\n", err)
+        os.Exit(1)
+    }
 
     // Initialize logger
     logger, err := utils.NewLogger(cfg.Log.Enabled, cfg.Log.Path)
@@ -63,11 +72,16 @@ func main() {
         os.Exit(1)
     }
 
-    logger.Info("Starting Telegram Video Downloader Bot")
+    logger.Info("Starting Telegram Video Downloader Bot %s", buildinfo.Get())
     
     
  // ✅ Step: Check and install external dependencies (yt-dlp, aria2c, ffmpeg)
-depChecker := utils.NewDependencyChecker()
+depChecker := utils.NewDependencyChecker().WithMinVersions(map[string]string{
+    "yt-dlp":  cfg.Dependencies.MinYtDlp,
+    "ffmpeg":  cfg.Dependencies.MinFFmpeg,
+    "ffprobe": cfg.Dependencies.MinFFmpeg,
+    "aria2c":  cfg.Dependencies.MinAria2c,
+})
 
 // Check dependencies and get their paths
 results, err := depChecker.CheckDependencies()
@@ -94,7 +108,7 @@ if err != nil {
 // ✅ Log final status of each dependency
 for dep, installed := range results {
     if installed {
-        logger.Info("✅ Dependency '%s' is installed", dep)
+        logger.Info("✅ Dependency '%s' is installed (version %s)", dep, depChecker.GetDependencyVersions()[dep])
     } else {
         logger.Warn("⚠️ Dependency '%s' is still missing after attempted installation", dep)
         fmt.Printf("⚠️ Dependency '%s' is still missing!\n", dep)
@@ -112,12 +126,34 @@ for dep, installed := range results {
 ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 defer cancel()
 
-mongoClient, err := database.NewMongoClient(ctx, cfg.MongoDB.URI)
+// The connection is retried while MongoDB starts, the retries bound how long that takes
+mongoClient, err := database.NewMongoClient(context.Background(), cfg.MongoDB.URI, database.MongoOptions{
+    WriteConcern:           cfg.MongoDB.WriteConcern,
+    Journal:                cfg.MongoDB.Journal,
+    ReadPreference:         cfg.MongoDB.ReadPreference,
+    MaxPoolSize:            uint64(cfg.MongoDB.MaxPoolSize),
+    MinPoolSize:            uint64(cfg.MongoDB.MinPoolSize),
+    ConnectTimeout:         time.Duration(cfg.MongoDB.ConnectTimeout) * time.Second,
+    ServerSelectionTimeout: time.Duration(cfg.MongoDB.ServerTimeout) * time.Second,
+    SocketTimeout:          time.Duration(cfg.MongoDB.SocketTimeout) * time.Second,
+    ConnectRetries:         cfg.MongoDB.ConnectRetries,
+    WriteRetries:           cfg.MongoDB.WriteRetries,
+    Logger:                 enhancedLogger,
+})
 if err != nil {
     logger.Error("Failed to connect to MongoDB: %v", err)
     fmt.Printf("Failed to connect to MongoDB: %v\n", err)
     os.Exit(1)
 }
+writeConcern, readPreference := mongoClient.EffectiveSettings()
+logger.Info("MongoDB write concern: %s, read preference: %s", writeConcern, readPreference)
+
+// Log when MongoDB goes away and comes back while the bot runs
+if cfg.MongoDB.HealthInterval > 0 {
+    healthCtx, stopHealthCheck := context.WithCancel(context.Background())
+    defer stopHealthCheck()
+    go mongoClient.StartHealthCheck(healthCtx, time.Duration(cfg.MongoDB.HealthInterval)*time.Second)
+}
 
 // Graceful MongoDB disconnect with new context
 defer func() {
@@ -141,12 +177,12 @@ defer func() {
     userRepo := database.NewUserRepository(mongoClient, cfg.MongoDB.Database, enhancedLogger)
     
     // Initialize downloader, passing the dependency paths
-    videoDownloader := downloader.NewVideoDownloader(cfg.Download.TempDir, enhancedLogger, 3, depChecker.GetDependencyPaths()) // Use getter method here
+    videoDownloader := downloader.NewVideoDownloader(cfg.Download.TempDir, enhancedLogger, 3, depChecker.GetDependencyPaths(), cfg.Download.Proxy) // Use getter method here
 
     // Initialize Telegram bot
     bot, err := telebot.NewBot(telebot.Settings{
         Token:  cfg.Telegram.Token,
-        Poller: &telebot.LongPoller{Timeout: 10 * time.Second},
+        Poller: newPoller(cfg),
     })
     if err != nil {
         logger.Error("Failed to create Telegram bot: %v", err)
@@ -154,12 +190,70 @@ defer func() {
         os.Exit(1)
     }
 
+    // Send errors to a Telegram chat as well, in batches to stay within its rate limits
+    if cfg.Log.Alerts {
+        alertChat := &telebot.Chat{ID: cfg.Log.AlertChat}
+        forwarder := utils.NewLogForwarder(func(text string) error {
+            _, err := bot.Send(alertChat, text, telebot.NoPreview)
+            return err
+        }, time.Duration(cfg.Log.AlertBatch)*time.Second)
+        logger.WithForwarder(forwarder)
+        enhancedLogger.WithForwarder(forwarder)
+
+        forwarderCtx, stopForwarder := context.WithCancel(context.Background())
+        defer stopForwarder()
+        go forwarder.Run(forwarderCtx)
+    }
+
+    // Initialize the global kill-switch shared by all instances through Redis
+    killSwitch := utils.NewKillSwitch(cfg.KillSwitch.Key, cfg.KillSwitch.CacheTTL, redisClient.GetClient(), enhancedLogger)
+
+    // Load the interface strings, edited language files are picked up without a restart
+    languageManager, err := i18n.NewLanguageManager(cfg.Languages.Path, cfg.Languages.Default, enhancedLogger)
+    if err != nil {
+        logger.Error("Failed to load language files: %v", err)
+        fmt.Printf("Failed to load language files: %v\n", err)
+        os.Exit(1)
+    }
+    languageManager.WithFallbacks(cfg.Languages.Fallbacks)
+    // Incomplete translations show fallback text to users, development builds refuse to start with them
+    if validation := languageManager.Validate(); !validation.Complete() && cfg.Log.Development {
+        logger.Error("Language files are missing translations, see the warnings above")
+        fmt.Printf("Language files are missing translations, see the warnings above\n")
+        os.Exit(1)
+    }
+    if err := languageManager.StartWatcher(); err != nil {
+        logger.Warn("Failed to watch language files, changes need a restart: %v", err)
+    }
+    defer languageManager.StopWatcher()
+
     // Initialize handlers
     // NEW: Pass depChecker.GetDependencyPaths() to NewBotHandler
-    handler := handlers.NewBotHandler(bot, userRepo, redisClient, cfg, logger, depChecker.GetDependencyPaths())
+    handler := handlers.NewBotHandler(bot, userRepo, redisClient, cfg, logger, depChecker.GetDependencyPaths(), killSwitch, languageManager)
     handler.RegisterHandlers()
 
+    // Start the download workers, resuming downloads interrupted by a restart
+    workerCtx, stopWorkers := context.WithCancel(context.Background())
+    defer stopWorkers()
+    handler.StartQueue(workerCtx)
+
+    // Start the health endpoint when an address is configured
+    if cfg.Health.Addr != "" {
+        healthServer := health.NewServer(cfg.Health.Addr, killSwitch, logger).
+            WithPool("mongodb", mongoClient.PoolStats).
+            WithPool("redis", redisClient.PoolStats).
+            WithDownloads(handler.DownloadStats, cfg.Health.MaxQueued).
+            WithMetrics(handler.Metrics())
+        go healthServer.Start()
+        defer func() {
+            shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+            defer shutdownCancel()
+            healthServer.Shutdown(shutdownCtx)
+        }()
+    }
+
     // Start the bot
+    logger.Info("Receiving updates with %s", cfg.Telegram.Mode)
     logger.Info("Bot started successfully")
     fmt.Println("Bot started successfully")
 
@@ -174,9 +268,33 @@ defer func() {
             if err := videoDownloader.CleanupDownloads(24 * time.Hour); err != nil {
                 logger.Error("Failed to clean up old downloads: %v", err)
             }
+            cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 5*time.Minute)
+            handler.CleanupResults(cleanupCtx)
+            cleanupCancel()
         }
     }()
 
+    // Keep yt-dlp up to date, a stale version breaks downloads whenever a site changes
+    if cfg.YtDlp.AutoUpdate && cfg.YtDlp.UpdateInterval > 0 {
+        go func() {
+            ticker := time.NewTicker(time.Duration(cfg.YtDlp.UpdateInterval) * time.Hour)
+            defer ticker.Stop()
+            for {
+                select {
+                case <-workerCtx.Done():
+                    return
+                case <-ticker.C:
+                }
+                before, after, err := depChecker.UpdateYtDlp(workerCtx)
+                if err != nil {
+                    logger.Error("Failed to update yt-dlp, keeping version %s: %v", before, err)
+                    continue
+                }
+                logger.Info("Updated yt-dlp from version %s to %s", before, after)
+            }
+        }()
+    }
+
     // Wait for termination signal
     quit := make(chan os.Signal, 1)
     signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -185,5 +303,56 @@ defer func() {
     // Graceful shutdown
     logger.Info("Shutting down bot...")
     fmt.Println("Shutting down bot...")
+    // Downloads still waiting for a worker are left pending and resumed on the next start
+    handler.StopQueue()
+    if cfg.Telegram.Mode == "webhook" {
+        // Runs after the bot stopped so Telegram doesn't deliver updates to an instance that is gone
+        defer func() {
+            if err := bot.RemoveWebhook(); err != nil {
+                logger.Error("Failed to remove webhook: %v", err)
+            }
+        }()
+    }
     defer bot.Stop()
 }
+
+// newPoller returns the webhook poller when webhook mode is configured, long polling otherwise
+func newPoller(cfg *config.Config) telebot.Poller {
+    if cfg.Telegram.Mode != "webhook" {
+        return &telebot.LongPoller{Timeout: 10 * time.Second}
+    }
+
+    webhook := &telebot.Webhook{
+        Listen:   cfg.Telegram.WebhookListen,
+        Endpoint: &telebot.WebhookEndpoint{PublicURL: cfg.Telegram.WebhookURL},
+    }
+    if cfg.Telegram.WebhookCert != "" {
+        webhook.TLS = &telebot.WebhookTLS{
+            Key:  cfg.Telegram.WebhookKey,
+            Cert: cfg.Telegram.WebhookCert,
+        }
+        // Telegram only needs to be sent certificates it can't verify itself
+        if isSelfSigned(cfg.Telegram.WebhookCert) {
+            webhook.Endpoint.Cert = cfg.Telegram.WebhookCert
+        }
+    }
+    return webhook
+}
+
+// isSelfSigned reports whether the first certificate of a PEM file is signed by its own key
+func isSelfSigned(certFile string) bool {
+    data, err := os.ReadFile(certFile)
+    if err != nil {
+        return false
+    }
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return false
+    }
+    cert, err := x509.ParseCertificate(block.Bytes)
+    if err != nil {
+        return false
+    }
+    // Checked directly, as self-signed server certificates aren't always marked as CAs
+    return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
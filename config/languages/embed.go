@@ -0,0 +1,10 @@
+// Package languages embeds the language files shipped with the bot, written out to an empty languages directory
+// on the first run
+package languages
+
+import "embed"
+
+// Files holds the shipped <code>.json language files
+//
+//go:embed *.json
+var Files embed.FS
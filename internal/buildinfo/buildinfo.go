@@ -0,0 +1,69 @@
+package buildinfo
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// unknown is reported for the build details that weren't set, such as in a plain go build or go run
+const unknown = "dev"
+
+// Set at build time with -ldflags, e.g.
+//
+//	go build -ldflags "-X github.com/mohammedteir/telegram-video-downloader-bot/internal/buildinfo.Version=v1.2.0
+//	  -X github.com/mohammedteir/telegram-video-downloader-bot/internal/buildinfo.Commit=$(git rev-parse --short HEAD)
+//	  -X github.com/mohammedteir/telegram-video-downloader-bot/internal/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+// Info describes the build of the running bot
+type Info struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build of the running bot. The commit and date that weren't set with -ldflags are taken from the
+// version control information Go embeds when building from a git checkout, and are "dev" when there is none.
+func Get() Info {
+	once.Do(func() {
+		info = Info{Version: Version, Commit: Commit, Date: Date}
+		if build, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range build.Settings {
+				switch {
+				case setting.Key == "vcs.revision" && info.Commit == "":
+					info.Commit = shortCommit(setting.Value)
+				case setting.Key == "vcs.time" && info.Date == "":
+					info.Date = setting.Value
+				}
+			}
+		}
+		for _, value := range []*string{&info.Version, &info.Commit, &info.Date} {
+			if *value == "" {
+				*value = unknown
+			}
+		}
+	})
+	return info
+}
+
+// String formats the build for logs, e.g. "v1.2.0 (commit 1a2b3c4, built 2024-05-01T10:00:00Z)"
+func (i Info) String() string {
+	return i.Version + " (commit " + i.Commit + ", built " + i.Date + ")"
+}
+
+// shortCommit shortens a commit hash to the length git shows by default
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
@@ -1,10 +1,17 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/joho/godotenv"
 	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
 	"github.com/spf13/viper"
@@ -13,19 +20,56 @@ import (
 // Config holds all configuration for the application
 type Config struct {
 	Telegram struct {
-		Token string `mapstructure:"token"`
+		Token         string  `mapstructure:"token"`
+		EditRate      float64 `mapstructure:"edit_rate"`      // max status message edits per second across all downloads, 0 disables the limit
+		EditBurst     int     `mapstructure:"edit_burst"`     // edits allowed in a burst above the rate
+		Mode          string  `mapstructure:"mode"`           // how updates are received: polling or webhook
+		WebhookURL    string  `mapstructure:"webhook_url"`    // public HTTPS URL Telegram sends updates to
+		WebhookListen string  `mapstructure:"webhook_listen"` // listen address of the webhook server
+		WebhookCert   string  `mapstructure:"webhook_cert"`   // TLS certificate, optional when TLS ends at a proxy
+		WebhookKey    string  `mapstructure:"webhook_key"`    // TLS private key matching the certificate
 	} `mapstructure:"telegram"`
 	MongoDB struct {
-		URI      string `mapstructure:"uri"`
-		Database string `mapstructure:"database"`
+		URI            string `mapstructure:"uri"`
+		Database       string `mapstructure:"database"`
+		WriteConcern   string `mapstructure:"write_concern"`   // "majority" or a number of nodes, empty keeps the URI or server default
+		Journal        bool   `mapstructure:"journal"`         // wait for writes to reach the on-disk journal
+		ReadPreference string `mapstructure:"read_preference"` // primary, primaryPreferred, secondary, secondaryPreferred or nearest
+		MaxPoolSize    int    `mapstructure:"max_pool_size"`   // connections per server
+		MinPoolSize    int    `mapstructure:"min_pool_size"`   // connections kept open per server
+		ConnectTimeout int    `mapstructure:"connect_timeout"` // in seconds
+		ServerTimeout  int    `mapstructure:"server_timeout"`  // in seconds, for finding a server to run an operation on
+		SocketTimeout  int    `mapstructure:"socket_timeout"`  // in seconds, for a single read or write
+		ConnectRetries int    `mapstructure:"connect_retries"` // times the first connection is tried again when MongoDB isn't up yet
+		WriteRetries   int    `mapstructure:"write_retries"`   // times a write is tried again after a transient error such as a failover, 0 disables it
+		HealthInterval int    `mapstructure:"health_interval"` // in seconds, how often the connection is checked, 0 disables the check
 	} `mapstructure:"mongodb"`
 	Redis struct {
 		URI string `mapstructure:"uri"`
 	} `mapstructure:"redis"`
 	Download struct {
-		TempDir string `mapstructure:"temp_dir"`
-		Retries int    `mapstructure:"retries"`
-		Timeout int    `mapstructure:"timeout"` // in seconds
+		TempDir          string                         `mapstructure:"temp_dir"`
+		Retries          int                            `mapstructure:"retries"`
+		Timeout          int                            `mapstructure:"timeout"`            // in seconds
+		ResendWindow     int                            `mapstructure:"resend_window"`      // in seconds, 0 disables resending previous downloads
+		ResultRetention  int                            `mapstructure:"result_retention"`   // in days, download results are deleted after this long, 0 keeps them
+		MaxUploadSize    int64                          `mapstructure:"max_upload_size"`    // in bytes, Telegram bots can upload up to 50 MB
+		SplitOversized   bool                           `mapstructure:"split_oversized"`    // send videos over the upload limit in a smaller format, or else split into parts
+		MaxPlaylistSize  int                            `mapstructure:"max_playlist_size"`  // max entries downloaded from a playlist, 0 disables playlists
+		MaxURLsPerMsg    int                            `mapstructure:"max_urls_per_msg"`   // max links queued from a single message, 0 removes the cap
+		AllowedHosts     []string                       `mapstructure:"allowed_hosts"`      // sites links are accepted from, subdomains included, empty allows all
+		CookiesFile      string                         `mapstructure:"cookies_file"`       // Netscape cookies file passed to yt-dlp, optional
+		UserCookiesDir   string                         `mapstructure:"user_cookies_dir"`   // where cookies uploaded with /setcookies are stored
+		Proxy            string                         `mapstructure:"proxy"`              // HTTP or SOCKS proxy for downloads, defaults to HTTPS_PROXY/HTTP_PROXY
+		MinFreeSpace     int64                          `mapstructure:"min_free_space"`     // in bytes, downloads are refused below this much free space in TempDir
+		MaxDirectSize    int64                          `mapstructure:"max_direct_size"`    // in bytes, links to media files over this size are not downloaded, 0 removes the limit
+		HostOptions      map[string]HostDownloadOptions `mapstructure:"host_options"`       // download options per site, subdomains included, override user preferences
+		BreakerLimit     int                            `mapstructure:"breaker_limit"`      // consecutive failures that pause downloads from a site, 0 never pauses them
+		BreakerWindow    int                            `mapstructure:"breaker_window"`     // in seconds, failures further apart than this aren't consecutive
+		BreakerCooldown  int                            `mapstructure:"breaker_cooldown"`   // in seconds, how long downloads from a failing site are paused
+		MaxFileNameLen   int                            `mapstructure:"max_file_name_len"`  // longest name of a sent file in characters, extension included, 0 keeps any length
+		FileNameTemplate string                         `mapstructure:"file_name_template"` // name of sent files with {title}, {id}, {uploader} and {ext}, empty for generic names
+		FileCacheTTL     int                            `mapstructure:"file_cache_ttl"`     // in hours, the same download is sent by its Telegram file IDs for this long, 0 disables it
 	} `mapstructure:"download"`
 	Log struct {
 		Enabled      bool           `mapstructure:"enabled"`
@@ -41,17 +85,88 @@ type Config struct {
 		StackTraces  bool           `mapstructure:"stack_traces"`  // include stack traces for errors
 		Development  bool           `mapstructure:"development"`   // development mode
 		RotationTime int            `mapstructure:"rotation_time"` // hours
+		Alerts       bool           `mapstructure:"alerts"`        // also send errors to AlertChat
+		AlertChat    int64          `mapstructure:"alert_chat"`    // Telegram channel or chat errors are sent to, the bot must be able to post in it
+		AlertBatch   int            `mapstructure:"alert_batch"`   // in seconds, errors are collected and sent at most this often
 	} `mapstructure:"log"`
 	RateLimit struct {
-		Enabled     bool `mapstructure:"enabled"`
-		RequestsMax int  `mapstructure:"requests_max"` // max requests per time window
-		TimeWindow  int  `mapstructure:"time_window"`  // time window in seconds
-		UserLimit   bool `mapstructure:"user_limit"`   // limit per user instead of globally
+		Enabled       bool    `mapstructure:"enabled"`
+		RequestsMax   int     `mapstructure:"requests_max"`   // max downloads per time window
+		TimeWindow    int     `mapstructure:"time_window"`    // time window of downloads in seconds
+		CommandMax    int     `mapstructure:"command_max"`    // max lightweight commands like /help per time window, counted apart from downloads
+		CommandWindow int     `mapstructure:"command_window"` // time window of commands in seconds
+		UserLimit     bool    `mapstructure:"user_limit"`     // limit per user instead of globally
+		Algorithm     string  `mapstructure:"algorithm"`      // sliding_window or token_bucket
+		RefillRate    float64 `mapstructure:"refill_rate"`    // token bucket: downloads allowed per second once the burst is used, 0 spreads requests_max over time_window
+		Burst         int     `mapstructure:"burst"`          // token bucket: downloads allowed at once, 0 uses requests_max
 	} `mapstructure:"rate_limit"`
 	Languages struct {
-		Path    string `mapstructure:"path"`
-		Default string `mapstructure:"default"`
+		Path      string              `mapstructure:"path"`
+		Default   string              `mapstructure:"default"`
+		Fallbacks map[string][]string `mapstructure:"fallbacks"` // fallback chain per language, e.g. pt-BR: [pt, es]
 	} `mapstructure:"languages"`
+	KillSwitch struct {
+		Key      string `mapstructure:"key"`       // Redis key that disables downloads on all instances when set
+		CacheTTL int    `mapstructure:"cache_ttl"` // in seconds
+	} `mapstructure:"kill_switch"`
+	Worker struct {
+		PoolSize       int     `mapstructure:"pool_size"`       // downloads processed at the same time
+		QueueSize      int     `mapstructure:"queue_size"`      // downloads waiting for a worker before new ones are refused
+		DurationWeight float64 `mapstructure:"duration_weight"` // priority lost per minute of video, cheaper downloads run first
+		SizeWeight     float64 `mapstructure:"size_weight"`     // priority lost per estimated MB, 0 ignores the size
+		MaxPerUser     int     `mapstructure:"max_per_user"`    // downloads of a chat queued or running at the same time, 0 removes the limit
+	} `mapstructure:"worker"`
+	Health struct {
+		Addr            string `mapstructure:"addr"`             // listen address of the /healthz endpoint, empty disables the server
+		MaxQueued       int    `mapstructure:"max_queued"`       // queued downloads from which /healthz reports a backlog, 0 never does
+		DownloadMetrics bool   `mapstructure:"download_metrics"` // also serve download counters and duration histograms at /metrics
+	} `mapstructure:"health"`
+	YtDlp struct {
+		AutoUpdate     bool `mapstructure:"auto_update"`     // update yt-dlp in the background to keep extractors working
+		UpdateInterval int  `mapstructure:"update_interval"` // in hours
+	} `mapstructure:"ytdlp"`
+	Dependencies struct {
+		MinYtDlp  string `mapstructure:"min_ytdlp"`  // oldest accepted yt-dlp version
+		MinFFmpeg string `mapstructure:"min_ffmpeg"` // oldest accepted ffmpeg and ffprobe version
+		MinAria2c string `mapstructure:"min_aria2c"` // oldest accepted aria2c version
+	} `mapstructure:"dependencies"`
+	Admin struct {
+		ChatIDs            []int64 `mapstructure:"chat_ids"`              // chats allowed to use admin commands
+		MetricsResetOnRead bool    `mapstructure:"metrics_reset_on_read"` // /metrics resets the counters it shows instead of counting since startup
+	} `mapstructure:"admin"`
+	Group struct {
+		DeleteCommands bool `mapstructure:"delete_commands"` // in groups, delete the message asking for a download once its files are sent
+		DeleteStatus   bool `mapstructure:"delete_status"`   // in groups, delete the status message of a download once its files are sent
+	} `mapstructure:"group"`
+	UI struct {
+		PlainText bool `mapstructure:"plain_text"` // strip emoji from the messages and buttons sent to users
+	} `mapstructure:"ui"`
+	Translate struct {
+		URL     string `mapstructure:"url"`     // LibreTranslate compatible API translating subtitles, empty only uses the translations of the video's host
+		APIKey  string `mapstructure:"api_key"` // optional for self-hosted instances
+		Timeout int    `mapstructure:"timeout"` // in seconds, per request
+	} `mapstructure:"translate"`
+	Features struct {
+		Zip           bool   `mapstructure:"zip"`            // /zip sends the files of the last download as one archive
+		Thumb         bool   `mapstructure:"thumb"`          // /thumb sends the thumbnail of a video
+		Metadata      bool   `mapstructure:"metadata"`       // /metadata adds the info JSON and description files to downloads
+		TranslateSubs bool   `mapstructure:"translate_subs"` // /translatesubs gets subtitles translated into the user's language
+		UserCookies   bool   `mapstructure:"user_cookies"`   // /setcookies lets users download with their own cookies
+		AudioTracks   bool   `mapstructure:"audio_tracks"`   // /tracks downloads another audio track of a video
+		Clips         bool   `mapstructure:"clips"`          // a time range after a link downloads only that clip
+		ChangeQuality bool   `mapstructure:"change_quality"` // the Change quality button downloads a video again in another quality
+		Uploads       bool   `mapstructure:"uploads"`        // videos sent to the bot get their audio extracted
+		OverridesKey  string `mapstructure:"overrides_key"`  // Redis hash of the flags admins turned on or off with /features
+		CacheTTL      int    `mapstructure:"cache_ttl"`      // in seconds, how long the overrides are cached
+	} `mapstructure:"features"`
+}
+
+// HostDownloadOptions overrides how videos from a site are downloaded
+type HostDownloadOptions struct {
+	Format    string            `mapstructure:"format" json:"format"`         // yt-dlp format selector, e.g. "bv*[height<=720]+ba/b"
+	ExtraArgs []string          `mapstructure:"extra_args" json:"extra_args"` // extra yt-dlp arguments, e.g. ["--sleep-requests", "1"]
+	Referer   string            `mapstructure:"referer" json:"referer"`       // Referer header for hotlink-protected sites, replaces the built-in default
+	Headers   map[string]string `mapstructure:"headers" json:"headers"`       // HTTP headers sent with every request to the site, e.g. {"Origin": "https://example.com"}
 }
 
 // LoadConfig loads configuration from environment variables and config files
@@ -75,9 +190,38 @@ func LoadConfig() (*Config, error) {
 	}
 
 	// Set defaults
+	viper.SetDefault("telegram.edit_rate", 20)
+	viper.SetDefault("telegram.edit_burst", 20)
+	viper.SetDefault("telegram.mode", "polling")
+	viper.SetDefault("telegram.webhook_listen", ":8443")
+	
+	viper.SetDefault("mongodb.max_pool_size", 10)
+	viper.SetDefault("mongodb.min_pool_size", 1)
+	viper.SetDefault("mongodb.connect_timeout", 30)
+	viper.SetDefault("mongodb.server_timeout", 30)
+	viper.SetDefault("mongodb.socket_timeout", 30)
+	viper.SetDefault("mongodb.connect_retries", 5)
+	viper.SetDefault("mongodb.write_retries", 3)
+	viper.SetDefault("mongodb.health_interval", 30)
+	
 	viper.SetDefault("download.temp_dir", "./tmp/video_downloader")
 	viper.SetDefault("download.retries", 3)
 	viper.SetDefault("download.timeout", 300) // 5 minutes
+	viper.SetDefault("download.resend_window", 3600) // 1 hour
+	viper.SetDefault("download.result_retention", 30)
+	viper.SetDefault("download.max_upload_size", 50*1024*1024) // 50 MB
+	viper.SetDefault("download.split_oversized", false)
+	viper.SetDefault("download.max_playlist_size", 20)
+	viper.SetDefault("download.max_urls_per_msg", 5)
+	viper.SetDefault("download.user_cookies_dir", "./data/cookies")
+	viper.SetDefault("download.min_free_space", 1024*1024*1024) // 1 GB
+	viper.SetDefault("download.max_direct_size", 2*1024*1024*1024) // 2 GB
+	viper.SetDefault("download.breaker_limit", 5)
+	viper.SetDefault("download.breaker_window", 300) // 5 minutes
+	viper.SetDefault("download.breaker_cooldown", 120) // 2 minutes
+	viper.SetDefault("download.max_file_name_len", 100)
+	viper.SetDefault("download.file_name_template", "{title}.{ext}")
+	viper.SetDefault("download.file_cache_ttl", 168) // 1 week
 	
 	viper.SetDefault("log.enabled", true)
 	viper.SetDefault("log.path", "./logs/bot.log")
@@ -92,41 +236,78 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("log.stack_traces", true)
 	viper.SetDefault("log.development", false)
 	viper.SetDefault("log.rotation_time", 24)
+	viper.SetDefault("log.alerts", false)
+	viper.SetDefault("log.alert_batch", 10)
 	
 	viper.SetDefault("rate_limit.enabled", true)
 	viper.SetDefault("rate_limit.requests_max", 10)
 	viper.SetDefault("rate_limit.time_window", 60) // 1 minute
+	viper.SetDefault("rate_limit.command_max", 30)
+	viper.SetDefault("rate_limit.command_window", 60)
+	viper.SetDefault("rate_limit.algorithm", "sliding_window")
 	viper.SetDefault("rate_limit.user_limit", true)
 	
 	viper.SetDefault("languages.path", "./config/languages")
 	viper.SetDefault("languages.default", "en")
+	
+	viper.SetDefault("kill_switch.key", "bot:kill_switch")
+	viper.SetDefault("kill_switch.cache_ttl", 5)
+	
+	viper.SetDefault("worker.pool_size", 3)
+	viper.SetDefault("worker.queue_size", 100)
+	viper.SetDefault("worker.duration_weight", 1)
+	viper.SetDefault("worker.size_weight", 0.1)
+	viper.SetDefault("worker.max_per_user", 2)
+	
+	viper.SetDefault("ytdlp.auto_update", true)
+	viper.SetDefault("ytdlp.update_interval", 24)
+	
+	viper.SetDefault("dependencies.min_ytdlp", "2023.03.04")
+	viper.SetDefault("dependencies.min_ffmpeg", "4.0")
+	viper.SetDefault("dependencies.min_aria2c", "1.30.0")
+	
+	viper.SetDefault("health.addr", "")
+	viper.SetDefault("health.max_queued", 0)
+	viper.SetDefault("health.download_metrics", false)
+	viper.SetDefault("group.delete_commands", false)
+	viper.SetDefault("group.delete_status", false)
+	viper.SetDefault("ui.plain_text", false)
+	viper.SetDefault("translate.timeout", 30)
+	for _, name := range FeatureNames {
+		viper.SetDefault("features."+name, true)
+	}
+	viper.SetDefault("features.overrides_key", "bot:features")
+	viper.SetDefault("features.cache_ttl", 5)
 
 	// Environment variables take precedence
 	viper.AutomaticEnv()
 	viper.SetEnvPrefix("APP")
 
-	// Map environment variables to config fields
-	viper.BindEnv("telegram.token", "TELEGRAM_TOKEN")
-	viper.BindEnv("mongodb.uri", "MONGODB_URI")
-	viper.BindEnv("mongodb.database", "MONGODB_DATABASE")
-	viper.BindEnv("redis.uri", "REDIS_URI")
-	viper.BindEnv("download.temp_dir", "DOWNLOAD_TEMP_DIR")
-	viper.BindEnv("download.retries", "DOWNLOAD_RETRIES")
-	viper.BindEnv("download.timeout", "DOWNLOAD_TIMEOUT")
-	viper.BindEnv("log.enabled", "LOG_ENABLED")
-	viper.BindEnv("log.path", "LOG_PATH")
-	viper.BindEnv("log.level", "LOG_LEVEL")
-	viper.BindEnv("rate_limit.enabled", "RATE_LIMIT_ENABLED")
-	viper.BindEnv("rate_limit.requests_max", "RATE_LIMIT_REQUESTS_MAX")
-	viper.BindEnv("rate_limit.time_window", "RATE_LIMIT_TIME_WINDOW")
-	viper.BindEnv("languages.path", "LANGUAGES_PATH")
-	viper.BindEnv("languages.default", "LANGUAGES_DEFAULT")
+	// Map environment variables to config fields, e.g. DOWNLOAD_TEMP_DIR to download.temp_dir. They take
+	// precedence over the config file, which takes precedence over the defaults.
+	bindEnvs(reflect.TypeOf(Config{}), "")
+	viper.BindEnv("rate_limit.requests_max", "RATE_LIMIT_REQUESTS_MAX", "RATE_LIMIT_MAX")
+	for _, name := range FeatureNames {
+		viper.BindEnv("features."+name, "FEATURE_"+strings.ToUpper(name))
+	}
 
 	// Unmarshal config
 if err := viper.Unmarshal(config); err != nil {
     return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 }
 
+// Maps such as the host options can't be expressed as a plain environment variable, they are set as JSON instead
+if hostOptions := os.Getenv("DOWNLOAD_HOST_OPTIONS"); hostOptions != "" {
+    if err := json.Unmarshal([]byte(hostOptions), &config.Download.HostOptions); err != nil {
+        return nil, fmt.Errorf("invalid DOWNLOAD_HOST_OPTIONS, expected a JSON object of host to options: %w", err)
+    }
+}
+if fallbacks := os.Getenv("LANGUAGES_FALLBACKS"); fallbacks != "" {
+    if err := json.Unmarshal([]byte(fallbacks), &config.Languages.Fallbacks); err != nil {
+        return nil, fmt.Errorf("invalid LANGUAGES_FALLBACKS, expected a JSON object of language to fallback languages: %w", err)
+    }
+}
+
 // Convert relative download path to absolute
 absTempDir, err := filepath.Abs(config.Download.TempDir)
 if err != nil {
@@ -134,17 +315,6 @@ if err != nil {
 }
 config.Download.TempDir = absTempDir
 
-// Validate required fields
-if config.Telegram.Token == "" {
-    return nil, fmt.Errorf("telegram token is required")
-}
-if config.MongoDB.URI == "" {
-    return nil, fmt.Errorf("mongodb URI is required")
-}
-if config.MongoDB.Database == "" {
-    return nil, fmt.Errorf("mongodb database name is required")
-}
-
 // Ensure download directory exists
 if err := os.MkdirAll(config.Download.TempDir, 0755); err != nil {
     return nil, fmt.Errorf("failed to create download directory: %w", err)
@@ -165,3 +335,174 @@ if err := os.MkdirAll(config.Download.TempDir, 0755); err != nil {
 
 	return config, nil
 }
+
+// bindEnvs binds every field of a config section to the environment variable named after its key, e.g.
+// download.temp_dir to DOWNLOAD_TEMP_DIR. Maps are left out, they are read from JSON variables by LoadConfig.
+func bindEnvs(section reflect.Type, prefix string) {
+	for i := 0; i < section.NumField(); i++ {
+		field := section.Field(i)
+		key := prefix + field.Tag.Get("mapstructure")
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			bindEnvs(field.Type, key+".")
+		case reflect.Map:
+		default:
+			viper.BindEnv(key, strings.ToUpper(strings.ReplaceAll(key, ".", "_")))
+		}
+	}
+}
+
+// Validate checks the configuration for missing and invalid settings, so the bot fails on startup with a clear
+// message instead of deep inside its initialization. It reports every problem found, one per line.
+func (c *Config) Validate() error {
+	var problems []error
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if c.Telegram.Token == "" {
+		problem("telegram token is required, set TELEGRAM_TOKEN")
+	}
+	switch c.Telegram.Mode {
+	case "polling":
+	case "webhook":
+		if c.Telegram.WebhookURL == "" {
+			problem("telegram webhook URL is required in webhook mode")
+		}
+		if (c.Telegram.WebhookCert == "") != (c.Telegram.WebhookKey == "") {
+			problem("telegram webhook cert and key must be set together")
+		}
+	default:
+		problem("unknown telegram mode %q, expected polling or webhook", c.Telegram.Mode)
+	}
+	if c.Telegram.EditRate < 0 {
+		problem("telegram edit rate can't be negative")
+	}
+
+	if c.MongoDB.URI == "" {
+		problem("mongodb URI is required")
+	} else if err := validateMongoURI(c.MongoDB.URI); err != nil {
+		problem("invalid mongodb URI: %v", err)
+	}
+	if c.MongoDB.Database == "" {
+		problem("mongodb database name is required")
+	}
+	if w := c.MongoDB.WriteConcern; w != "" && w != "majority" {
+		n, err := strconv.Atoi(w)
+		if err != nil || n < 0 {
+			problem("invalid mongodb write concern %q, expected majority or a number of nodes", w)
+		} else if n == 0 && c.MongoDB.Journal {
+			problem("mongodb journal requires a write concern of at least 1")
+		}
+	}
+	if c.MongoDB.MaxPoolSize < 1 || c.MongoDB.MinPoolSize < 0 {
+		problem("mongodb max pool size must be at least 1 and min pool size can't be negative")
+	} else if c.MongoDB.MinPoolSize > c.MongoDB.MaxPoolSize {
+		problem("mongodb min pool size %d is larger than max pool size %d", c.MongoDB.MinPoolSize, c.MongoDB.MaxPoolSize)
+	}
+	if c.MongoDB.WriteRetries < 0 {
+		problem("mongodb write retries can't be negative")
+	}
+	if c.MongoDB.ConnectTimeout <= 0 || c.MongoDB.ServerTimeout <= 0 || c.MongoDB.SocketTimeout <= 0 {
+		problem("mongodb timeouts must be positive")
+	}
+	switch strings.ToLower(c.MongoDB.ReadPreference) {
+	case "", "primary", "primarypreferred", "secondary", "secondarypreferred", "nearest":
+	default:
+		problem("unknown mongodb read preference %q", c.MongoDB.ReadPreference)
+	}
+
+	if c.Redis.URI == "" {
+		problem("redis URI is required")
+	} else if _, err := redis.ParseURL(c.Redis.URI); err != nil {
+		problem("invalid redis URI: %v", err)
+	}
+
+	if c.RateLimit.Enabled && (c.RateLimit.RequestsMax < 1 || c.RateLimit.TimeWindow < 1) {
+		problem("rate limit requests max and time window must be at least 1")
+	}
+	if c.RateLimit.Enabled && (c.RateLimit.CommandMax < 1 || c.RateLimit.CommandWindow < 1) {
+		problem("rate limit command max and window must be at least 1")
+	}
+	switch c.RateLimit.Algorithm {
+	case "sliding_window", "token_bucket":
+	default:
+		problem("unknown rate limit algorithm %q, expected sliding_window or token_bucket", c.RateLimit.Algorithm)
+	}
+	if c.RateLimit.RefillRate < 0 || c.RateLimit.Burst < 0 {
+		problem("rate limit refill rate and burst can't be negative")
+	}
+
+	if c.Log.Alerts && (c.Log.AlertChat == 0 || c.Log.AlertBatch < 1) {
+		problem("log alerts need an alert chat and a batch interval of at least 1 second")
+	}
+	if c.Download.BreakerLimit > 0 && c.Download.BreakerCooldown < 1 {
+		problem("download breaker cooldown must be at least 1 second")
+	}
+	if c.Health.MaxQueued < 0 {
+		problem("health max queued can't be negative")
+	}
+	if c.Worker.PoolSize < 1 || c.Worker.QueueSize < 1 {
+		problem("worker pool size and queue size must be at least 1")
+	}
+	if c.Worker.MaxPerUser < 0 {
+		problem("worker max per user can't be negative")
+	}
+	if c.Download.FileCacheTTL < 0 {
+		problem("download file cache TTL can't be negative")
+	}
+	if c.Download.MaxFileNameLen < 0 {
+		problem("download max file name length can't be negative")
+	}
+	if c.Download.FileNameTemplate != "" && !strings.Contains(c.Download.FileNameTemplate, "{title}") {
+		problem("download file name template must contain {title}")
+	}
+
+	// Reject headers that would be sent malformed
+	for host, options := range c.Download.HostOptions {
+		if err := utils.ValidateHeader("Referer", options.Referer); err != nil {
+			problem("invalid referer of host %s: %v", host, err)
+		}
+		for name, value := range options.Headers {
+			if err := utils.ValidateHeader(name, value); err != nil {
+				problem("invalid header of host %s: %v", host, err)
+			}
+		}
+	}
+
+	if err := checkWritable(c.Download.TempDir); err != nil {
+		problem("download directory %s is not writable: %v", c.Download.TempDir, err)
+	}
+	if c.Download.CookiesFile != "" {
+		if _, err := os.Stat(c.Download.CookiesFile); err != nil {
+			problem("cookies file %s is not accessible: %v", c.Download.CookiesFile, err)
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// validateMongoURI checks that a MongoDB connection string has a MongoDB scheme and a host, without resolving it
+func validateMongoURI(uri string) error {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "mongodb" && parsed.Scheme != "mongodb+srv" {
+		return fmt.Errorf("scheme must be mongodb or mongodb+srv, got %q", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return errors.New("no host")
+	}
+	return nil
+}
+
+// checkWritable reports whether files can be created in a directory
+func checkWritable(dir string) error {
+	file, err := os.CreateTemp(dir, ".write_check_*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// validConfig returns a configuration Validate accepts, downloading into a temporary directory
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+
+	c := &Config{}
+	c.Telegram.Token = "123:abc"
+	c.Telegram.Mode = "polling"
+	c.MongoDB.URI = "mongodb://localhost:27017"
+	c.MongoDB.Database = "bot"
+	c.MongoDB.MaxPoolSize = 10
+	c.MongoDB.MinPoolSize = 1
+	c.MongoDB.ConnectTimeout = 30
+	c.MongoDB.ServerTimeout = 30
+	c.MongoDB.SocketTimeout = 30
+	c.Redis.URI = "redis://localhost:6379/0"
+	c.RateLimit.Enabled = true
+	c.RateLimit.RequestsMax = 10
+	c.RateLimit.TimeWindow = 60
+	c.RateLimit.CommandMax = 30
+	c.RateLimit.CommandWindow = 60
+	c.RateLimit.Algorithm = "sliding_window"
+	c.Download.TempDir = t.TempDir()
+	c.Download.BreakerLimit = 5
+	c.Download.BreakerCooldown = 120
+	c.Download.FileNameTemplate = "{title}.{ext}"
+	c.Worker.PoolSize = 3
+	c.Worker.QueueSize = 100
+	return c
+}
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	if err := validConfig(t).Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"missing token", func(c *Config) { c.Telegram.Token = "" }, "telegram token is required"},
+		{"unknown mode", func(c *Config) { c.Telegram.Mode = "push" }, `unknown telegram mode "push"`},
+		{"webhook without URL", func(c *Config) { c.Telegram.Mode = "webhook" }, "telegram webhook URL is required"},
+		{"webhook cert without key", func(c *Config) {
+			c.Telegram.Mode = "webhook"
+			c.Telegram.WebhookURL = "https://bot.example.com/hook"
+			c.Telegram.WebhookCert = "cert.pem"
+		}, "webhook cert and key must be set together"},
+		{"negative edit rate", func(c *Config) { c.Telegram.EditRate = -1 }, "telegram edit rate can't be negative"},
+		{"missing mongodb URI", func(c *Config) { c.MongoDB.URI = "" }, "mongodb URI is required"},
+		{"mongodb URI of another scheme", func(c *Config) { c.MongoDB.URI = "postgres://localhost" }, "invalid mongodb URI"},
+		{"mongodb URI without host", func(c *Config) { c.MongoDB.URI = "mongodb://" }, "invalid mongodb URI: no host"},
+		{"missing mongodb database", func(c *Config) { c.MongoDB.Database = "" }, "mongodb database name is required"},
+		{"unknown write concern", func(c *Config) { c.MongoDB.WriteConcern = "all" }, `invalid mongodb write concern "all"`},
+		{"negative write concern", func(c *Config) { c.MongoDB.WriteConcern = "-1" }, `invalid mongodb write concern "-1"`},
+		{"journal without acknowledged writes", func(c *Config) {
+			c.MongoDB.WriteConcern = "0"
+			c.MongoDB.Journal = true
+		}, "mongodb journal requires a write concern of at least 1"},
+		{"no connections", func(c *Config) { c.MongoDB.MaxPoolSize = 0 }, "mongodb max pool size must be at least 1"},
+		{"min pool larger than max", func(c *Config) { c.MongoDB.MinPoolSize = 20 }, "min pool size 20 is larger than max pool size 10"},
+		{"negative write retries", func(c *Config) { c.MongoDB.WriteRetries = -1 }, "mongodb write retries can't be negative"},
+		{"zero timeout", func(c *Config) { c.MongoDB.SocketTimeout = 0 }, "mongodb timeouts must be positive"},
+		{"unknown read preference", func(c *Config) { c.MongoDB.ReadPreference = "fastest" }, `unknown mongodb read preference "fastest"`},
+		{"missing redis URI", func(c *Config) { c.Redis.URI = "" }, "redis URI is required"},
+		{"invalid redis URI", func(c *Config) { c.Redis.URI = "http://localhost:6379" }, "invalid redis URI"},
+		{"no downloads allowed", func(c *Config) { c.RateLimit.RequestsMax = 0 }, "rate limit requests max and time window must be at least 1"},
+		{"no commands allowed", func(c *Config) { c.RateLimit.CommandWindow = 0 }, "rate limit command max and window must be at least 1"},
+		{"unknown algorithm", func(c *Config) { c.RateLimit.Algorithm = "leaky_bucket" }, `unknown rate limit algorithm "leaky_bucket"`},
+		{"negative refill rate", func(c *Config) {
+			c.RateLimit.Algorithm = "token_bucket"
+			c.RateLimit.RefillRate = -1
+		}, "rate limit refill rate and burst can't be negative"},
+		{"alerts without chat", func(c *Config) {
+			c.Log.Alerts = true
+			c.Log.AlertBatch = 10
+		}, "log alerts need an alert chat"},
+		{"breaker without cooldown", func(c *Config) { c.Download.BreakerCooldown = 0 }, "download breaker cooldown must be at least 1 second"},
+		{"negative max queued", func(c *Config) { c.Health.MaxQueued = -1 }, "health max queued can't be negative"},
+		{"no workers", func(c *Config) { c.Worker.PoolSize = 0 }, "worker pool size and queue size must be at least 1"},
+		{"no queue", func(c *Config) { c.Worker.QueueSize = 0 }, "worker pool size and queue size must be at least 1"},
+		{"negative max per user", func(c *Config) { c.Worker.MaxPerUser = -1 }, "worker max per user can't be negative"},
+		{"negative file cache TTL", func(c *Config) { c.Download.FileCacheTTL = -1 }, "download file cache TTL can't be negative"},
+		{"negative file name length", func(c *Config) { c.Download.MaxFileNameLen = -1 }, "download max file name length can't be negative"},
+		{"file name template without title", func(c *Config) { c.Download.FileNameTemplate = "{id}.{ext}" }, "download file name template must contain {title}"},
+		{"referer with a line break", func(c *Config) {
+			c.Download.HostOptions = map[string]HostDownloadOptions{"example.com": {Referer: "https://example.com\r\nX-Injected: 1"}}
+		}, "invalid referer of host example.com"},
+		{"invalid header name", func(c *Config) {
+			c.Download.HostOptions = map[string]HostDownloadOptions{"example.com": {Headers: map[string]string{"Bad Header": "1"}}}
+		}, "invalid header of host example.com"},
+		{"missing download directory", func(c *Config) { c.Download.TempDir = filepath.Join(c.Download.TempDir, "missing") }, "is not writable"},
+		{"missing cookies file", func(c *Config) { c.Download.CookiesFile = filepath.Join(c.Download.TempDir, "cookies.txt") }, "is not accessible"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig(t)
+			tt.modify(c)
+
+			err := c.Validate()
+			if err == nil {
+				t.Fatalf("Validate() = nil, want an error containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %q, want an error containing %q", err, tt.want)
+			}
+			// Only the invalid setting is reported
+			if lines := strings.Count(err.Error(), "\n") + 1; lines != 1 {
+				t.Errorf("Validate() reported %d problems, want 1:\n%v", lines, err)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	c := validConfig(t)
+	c.Telegram.Token = ""
+	c.MongoDB.Database = ""
+	c.Redis.URI = ""
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want an error")
+	}
+	for _, want := range []string{"telegram token is required", "mongodb database name is required", "redis URI is required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %q, want it to contain %q", err, want)
+		}
+	}
+}
+
+// loadConfigIn runs LoadConfig in a directory holding the given config file, restoring the working directory and
+// the global viper state afterwards
+func loadConfigIn(t *testing.T, configYAML string) *Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(configYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		viper.Reset()
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestLoadConfigEnvOverridesFile(t *testing.T) {
+	envDir := t.TempDir()
+	t.Setenv("DOWNLOAD_TEMP_DIR", envDir)
+	t.Setenv("TELEGRAM_MODE", "webhook")
+	t.Setenv("RATE_LIMIT_MAX", "7")
+	t.Setenv("FEATURE_ZIP", "false")
+
+	config := loadConfigIn(t, `
+telegram:
+  mode: polling
+  edit_rate: 5
+download:
+  temp_dir: ./from_file
+  retries: 9
+rate_limit:
+  requests_max: 3
+features:
+  zip: true
+`)
+
+	if config.Download.TempDir != envDir {
+		t.Errorf("Download.TempDir = %q, want %q of the environment", config.Download.TempDir, envDir)
+	}
+	if config.Telegram.Mode != "webhook" {
+		t.Errorf("Telegram.Mode = %q, want %q of the environment", config.Telegram.Mode, "webhook")
+	}
+	if config.RateLimit.RequestsMax != 7 {
+		t.Errorf("RateLimit.RequestsMax = %d, want 7 of the RATE_LIMIT_MAX alias", config.RateLimit.RequestsMax)
+	}
+	if config.Features.Zip {
+		t.Error("Features.Zip = true, want false of FEATURE_ZIP")
+	}
+
+	// Settings without an environment variable keep the value of the file, or else the default
+	if config.Telegram.EditRate != 5 {
+		t.Errorf("Telegram.EditRate = %v, want 5 of the file", config.Telegram.EditRate)
+	}
+	if config.Download.Retries != 9 {
+		t.Errorf("Download.Retries = %d, want 9 of the file", config.Download.Retries)
+	}
+	if config.Download.Timeout != 300 {
+		t.Errorf("Download.Timeout = %d, want the default 300", config.Download.Timeout)
+	}
+}
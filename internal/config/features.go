@@ -0,0 +1,64 @@
+package config
+
+// Names of the feature flags in Config.Features, as used in config.yaml, FEATURE_* environment variables and /features
+const (
+	FeatureZip           = "zip"
+	FeatureThumb         = "thumb"
+	FeatureMetadata      = "metadata"
+	FeatureTranslateSubs = "translate_subs"
+	FeatureUserCookies   = "user_cookies"
+	FeatureAudioTracks   = "audio_tracks"
+	FeatureClips         = "clips"
+	FeatureChangeQuality = "change_quality"
+	FeatureUploads       = "uploads"
+)
+
+// FeatureNames lists every feature flag
+var FeatureNames = []string{
+	FeatureZip,
+	FeatureThumb,
+	FeatureMetadata,
+	FeatureTranslateSubs,
+	FeatureUserCookies,
+	FeatureAudioTracks,
+	FeatureClips,
+	FeatureChangeQuality,
+	FeatureUploads,
+}
+
+// FeatureEnabled reports whether a feature is turned on in the configuration, false for unknown features.
+// Admins can override it at runtime, see utils.FeatureFlags.
+func (c *Config) FeatureEnabled(name string) bool {
+	switch name {
+	case FeatureZip:
+		return c.Features.Zip
+	case FeatureThumb:
+		return c.Features.Thumb
+	case FeatureMetadata:
+		return c.Features.Metadata
+	case FeatureTranslateSubs:
+		return c.Features.TranslateSubs
+	case FeatureUserCookies:
+		return c.Features.UserCookies
+	case FeatureAudioTracks:
+		return c.Features.AudioTracks
+	case FeatureClips:
+		return c.Features.Clips
+	case FeatureChangeQuality:
+		return c.Features.ChangeQuality
+	case FeatureUploads:
+		return c.Features.Uploads
+	default:
+		return false
+	}
+}
+
+// IsFeature reports whether a name is a feature flag
+func IsFeature(name string) bool {
+	for _, feature := range FeatureNames {
+		if feature == name {
+			return true
+		}
+	}
+	return false
+}
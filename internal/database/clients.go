@@ -2,49 +2,266 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
 	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
+// ErrDatabaseUnavailable is matched by the errors of repository methods that failed because MongoDB couldn't be
+// reached, as opposed to errors of the operation itself
+var ErrDatabaseUnavailable = errors.New("database unavailable")
+
 // MongoClient wraps the MongoDB client
 type MongoClient struct {
-	client *mongo.Client
+	client       *mongo.Client
+	writeConcern *writeconcern.WriteConcern
+	readPref     *readpref.ReadPref
+	poolMonitor  *mongoPoolMonitor
+	logger       *utils.EnhancedLogger
+	writeRetries int         // times a write failing with a transient error is tried again
+	unavailable  atomic.Bool // set while the health check can't reach the server
+}
+
+// Connection settings used when MongoOptions leaves them at zero
+const (
+	defaultMongoMaxPoolSize = 10
+	defaultMongoTimeout     = 30 * time.Second
+	// mongoPingTimeout bounds each connection check, so a server that isn't up yet is tried again soon
+	mongoPingTimeout = 10 * time.Second
+)
+
+// MongoOptions contains the pool, durability and read routing settings of the MongoDB client.
+// Empty write concern and read preference keep the settings of the URI, or the driver defaults.
+// A zero max pool size and zero timeouts use the defaults above.
+type MongoOptions struct {
+	WriteConcern           string        // "majority" or the number of nodes that must acknowledge writes
+	Journal                bool          // wait for writes to reach the on-disk journal
+	ReadPreference         string        // primary, primaryPreferred, secondary, secondaryPreferred or nearest
+	MaxPoolSize            uint64        // connections per server
+	MinPoolSize            uint64        // connections kept open per server, 0 closes idle ones
+	ConnectTimeout         time.Duration // for establishing a connection
+	ServerSelectionTimeout time.Duration // for finding a server to run an operation on
+	SocketTimeout          time.Duration // for a single read or write on a connection
+	ConnectRetries         int           // times the first connection is tried again, for servers that start after the bot
+	WriteRetries           int           // times a write failing with a transient error, e.g. during failover, is tried again
+	Logger                 *utils.EnhancedLogger
 }
 
 // NewMongoClient creates a new MongoDB client with improved connection handling
-func NewMongoClient(ctx context.Context, uri string) (*MongoClient, error) {
+func NewMongoClient(ctx context.Context, uri string, mongoOpts MongoOptions) (*MongoClient, error) {
 	// Set client options with additional connection settings
 	clientOptions := options.Client().ApplyURI(uri)
 	
 	// Add connection timeout and other settings for better reliability
-	clientOptions.SetConnectTimeout(30 * time.Second)
-	clientOptions.SetServerSelectionTimeout(30 * time.Second)
-	clientOptions.SetSocketTimeout(30 * time.Second)
-	clientOptions.SetMaxPoolSize(10)
-	clientOptions.SetMinPoolSize(1)
+	clientOptions.SetConnectTimeout(durationOrDefault(mongoOpts.ConnectTimeout, defaultMongoTimeout))
+	clientOptions.SetServerSelectionTimeout(durationOrDefault(mongoOpts.ServerSelectionTimeout, defaultMongoTimeout))
+	clientOptions.SetSocketTimeout(durationOrDefault(mongoOpts.SocketTimeout, defaultMongoTimeout))
+	
+	maxPoolSize, minPoolSize := mongoOpts.MaxPoolSize, mongoOpts.MinPoolSize
+	if maxPoolSize == 0 {
+		maxPoolSize = defaultMongoMaxPoolSize
+	}
+	if minPoolSize > maxPoolSize {
+		return nil, fmt.Errorf("mongodb min pool size %d is larger than max pool size %d", minPoolSize, maxPoolSize)
+	}
+	clientOptions.SetMaxPoolSize(maxPoolSize)
+	clientOptions.SetMinPoolSize(minPoolSize)
+	
+	if err := applyMongoOptions(clientOptions, mongoOpts); err != nil {
+		return nil, err
+	}
+	
+	// Track pool usage so the pool size can be tuned from the metrics
+	poolMonitor := &mongoPoolMonitor{}
+	clientOptions.SetPoolMonitor(&event.PoolMonitor{Event: poolMonitor.handle})
+	
+	// Connect to MongoDB, waiting for a server that isn't up yet
+	retryOpts := utils.DefaultRetryOptions().WithMaxRetries(mongoOpts.ConnectRetries).WithLogger(mongoOpts.Logger)
+	client, err := utils.RetryWithContextAndResult(ctx, func() (*mongo.Client, error) {
+		return connectMongo(ctx, clientOptions)
+	}, retryOpts)
+	if err != nil {
+		return nil, err
+	}
 	
-	// Connect to MongoDB
+	return &MongoClient{
+		client:       client,
+		writeConcern: clientOptions.WriteConcern,
+		readPref:     clientOptions.ReadPreference,
+		poolMonitor:  poolMonitor,
+		logger:       mongoOpts.Logger,
+		writeRetries: mongoOpts.WriteRetries,
+	}, nil
+}
+
+// connectMongo creates a client and checks that it reaches the server
+func connectMongo(ctx context.Context, clientOptions *options.ClientOptions) (*mongo.Client, error) {
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		return nil, err
 	}
 	
-	// Check the connection with a longer timeout
-	pingCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	pingCtx, cancel := context.WithTimeout(ctx, mongoPingTimeout)
 	defer cancel()
 	
-	err = client.Ping(pingCtx, nil)
-	if err != nil {
+	if err := client.Ping(pingCtx, nil); err != nil {
 		client.Disconnect(ctx)
 		return nil, err
 	}
+	return client, nil
+}
+
+// StartHealthCheck pings the server every interval until the context is done, logging when it becomes
+// unreachable and when it can be reached again. The driver reconnects by itself, while the server is down the
+// pings back off like the first connection.
+func (m *MongoClient) StartHealthCheck(ctx context.Context, interval time.Duration) {
+	retryOpts := utils.DefaultRetryOptions().WithMaxWait(interval).WithResetAfterSuccess(true)
+	utils.RetryForever(ctx, func() error {
+		pingCtx, cancel := context.WithTimeout(ctx, mongoPingTimeout)
+		err := m.client.Ping(pingCtx, nil)
+		cancel()
+		if ctx.Err() != nil {
+			return nil
+		}
 	
-	return &MongoClient{
-		client: client,
-	}, nil
+		if err != nil {
+			if !m.unavailable.Swap(true) && m.logger != nil {
+				m.logger.Error("MongoDB is unreachable, reconnecting: %v", err)
+			}
+			return err
+		}
+		if m.unavailable.Swap(false) && m.logger != nil {
+			m.logger.Info("Reconnected to MongoDB")
+		}
+	
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+		}
+		return nil
+	}, retryOpts)
+}
+
+// Available reports whether the last health check reached the server
+func (m *MongoClient) Available() bool {
+	return !m.unavailable.Load()
+}
+
+// unavailableError is an error of the driver caused by the server being unreachable
+type unavailableError struct {
+	err error
+}
+
+func (e *unavailableError) Error() string {
+	return "database unavailable: " + e.err.Error()
+}
+
+// Unwrap keeps the driver's error, transactions are retried based on its labels
+func (e *unavailableError) Unwrap() error {
+	return e.err
+}
+
+func (e *unavailableError) Is(target error) bool {
+	return target == ErrDatabaseUnavailable
+}
+
+// dbError marks errors caused by MongoDB being unreachable so they match ErrDatabaseUnavailable,
+// other errors are returned as they are
+func dbError(err error) error {
+	if err == nil || errors.Is(err, ErrDatabaseUnavailable) {
+		return err
+	}
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) || errors.Is(err, mongo.ErrClientDisconnected) {
+		return &unavailableError{err: err}
+	}
+	return err
+}
+
+// durationOrDefault returns d, or def when d isn't set
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// applyMongoOptions overrides the write concern and read preference of the URI with the configured ones
+func applyMongoOptions(clientOptions *options.ClientOptions, mongoOpts MongoOptions) error {
+	if mongoOpts.WriteConcern != "" || mongoOpts.Journal {
+		// Start from the URI's write concern so setting only one of w and journal keeps the other
+		wc := &writeconcern.WriteConcern{}
+		if clientOptions.WriteConcern != nil {
+			*wc = *clientOptions.WriteConcern
+		}
+
+		switch mongoOpts.WriteConcern {
+		case "":
+		case "majority":
+			wc.W = "majority"
+		default:
+			w, err := strconv.Atoi(mongoOpts.WriteConcern)
+			if err != nil || w < 0 {
+				return fmt.Errorf("invalid write concern %q, expected majority or a number of nodes", mongoOpts.WriteConcern)
+			}
+			wc.W = w
+		}
+
+		if mongoOpts.Journal {
+			journal := true
+			wc.Journal = &journal
+			if wc.W == 0 {
+				return fmt.Errorf("journaled writes need a write concern of at least 1")
+			}
+		}
+		clientOptions.SetWriteConcern(wc)
+	}
+
+	if mongoOpts.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(mongoOpts.ReadPreference)
+		if err != nil {
+			return err
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return err
+		}
+		clientOptions.SetReadPreference(rp)
+	}
+
+	return nil
+}
+
+// EffectiveSettings describes the write concern and read preference the client uses
+func (m *MongoClient) EffectiveSettings() (writeConcern string, readPreference string) {
+	writeConcern = "server default"
+	if wc := m.writeConcern; wc != nil {
+		writeConcern = "w=server default"
+		if wc.W != nil {
+			writeConcern = fmt.Sprintf("w=%v", wc.W)
+		}
+		if wc.Journal != nil {
+			writeConcern += fmt.Sprintf(", j=%t", *wc.Journal)
+		}
+	}
+
+	// The driver reads from the primary unless told otherwise
+	readPreference = readpref.PrimaryMode.String()
+	if m.readPref != nil {
+		readPreference = m.readPref.Mode().String()
+	}
+
+	return writeConcern, readPreference
 }
 
 // GetCollection returns a MongoDB collection
@@ -52,6 +269,11 @@ func (m *MongoClient) GetCollection(database, collection string) *mongo.Collecti
 	return m.client.Database(database).Collection(collection)
 }
 
+// StartSession starts a session for running transactions
+func (m *MongoClient) StartSession() (mongo.Session, error) {
+	return m.client.StartSession()
+}
+
 // Disconnect closes the MongoDB connection
 func (m *MongoClient) Disconnect(ctx context.Context) error {
 	return m.client.Disconnect(ctx)
@@ -103,3 +325,13 @@ func (r *RedisClient) Get(ctx context.Context, key string) (string, error) {
 	return r.client.Get(ctx, key).Result()
 }
 
+// Delete deletes keys from Redis
+func (r *RedisClient) Delete(ctx context.Context, keys ...string) error {
+	return r.client.Del(ctx, keys...).Err()
+}
+
+
+// GetClient returns the underlying Redis client
+func (r *RedisClient) GetClient() *redis.Client {
+	return r.client
+}
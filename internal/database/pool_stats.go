@@ -0,0 +1,69 @@
+package database
+
+import (
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// PoolStats contains the connection pool statistics of a database client
+type PoolStats struct {
+	InUse     int64  `json:"in_use"`     // connections checked out of the pool
+	Idle      int64  `json:"idle"`       // open connections waiting in the pool
+	WaitCount uint64 `json:"wait_count"` // checkouts that found no idle connection, since startup
+}
+
+// mongoPoolMonitor keeps the connection pool statistics of a MongoDB client up to date from pool events
+type mongoPoolMonitor struct {
+	open      atomic.Int64
+	inUse     atomic.Int64
+	waitCount atomic.Uint64
+}
+
+// handle updates the statistics with a pool event
+func (m *mongoPoolMonitor) handle(evt *event.PoolEvent) {
+	switch evt.Type {
+	case event.ConnectionCreated:
+		m.open.Add(1)
+	case event.ConnectionClosed:
+		m.open.Add(-1)
+	case event.GetStarted:
+		// Approximate, a connection may be returned while the checkout starts
+		if m.open.Load()-m.inUse.Load() <= 0 {
+			m.waitCount.Add(1)
+		}
+	case event.GetSucceeded:
+		m.inUse.Add(1)
+	case event.ConnectionReturned:
+		m.inUse.Add(-1)
+	}
+}
+
+// stats returns the current statistics
+func (m *mongoPoolMonitor) stats() PoolStats {
+	inUse := m.inUse.Load()
+	idle := m.open.Load() - inUse
+	if idle < 0 {
+		idle = 0
+	}
+	return PoolStats{
+		InUse:     inUse,
+		Idle:      idle,
+		WaitCount: m.waitCount.Load(),
+	}
+}
+
+// PoolStats returns the connection pool statistics of the MongoDB client, summed over all servers
+func (m *MongoClient) PoolStats() PoolStats {
+	return m.poolMonitor.stats()
+}
+
+// PoolStats returns the connection pool statistics of the Redis client
+func (r *RedisClient) PoolStats() PoolStats {
+	stats := r.client.PoolStats()
+	return PoolStats{
+		InUse:     int64(stats.TotalConns) - int64(stats.IdleConns),
+		Idle:      int64(stats.IdleConns),
+		WaitCount: uint64(stats.Misses), // no free connection in the pool, one was dialed or waited for
+	}
+}
@@ -2,6 +2,9 @@ package database
 
 import (
 	"context"
+	"errors"
+	"regexp"
+	"sync/atomic"
 	"time"
 
 	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
@@ -52,7 +55,7 @@ func (r *UserRepository) FindUserByChatID(ctx context.Context, chatID int64) (*m
 			return nil, nil
 		}
 		r.logger.Error("Error finding user by chat ID %d: %v", chatID, err)
-		return nil, err
+		return nil, dbError(err)
 	}
 	
 	return &user, nil
@@ -69,7 +72,7 @@ func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) (*mo
 	result, err := collection.InsertOne(ctx, user)
 	if err != nil {
 		r.logger.Error("Error creating user: %v", err)
-		return nil, err
+		return nil, dbError(err)
 	}
 	
 	user.ID = result.InsertedID.(primitive.ObjectID)
@@ -90,7 +93,7 @@ func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) erro
 	if err != nil {
 		r.logger.Error("Error updating user %s: %v", user.ID.Hex(), err)
 	}
-	return err
+	return dbError(err)
 }
 
 // UpdateUserLanguage updates a user's interface and caption language
@@ -115,7 +118,7 @@ func (r *UserRepository) UpdateUserLanguage(ctx context.Context, chatID int64, i
 		r.logger.Info("Updated language settings for chat ID %d: interface=%s, caption=%s", 
 			chatID, interfaceLanguage, captionLanguage)
 	}
-	return err
+	return dbError(err)
 }
 
 // UpdateUserInterfaceLanguage updates a user's interface language
@@ -137,7 +140,7 @@ func (r *UserRepository) UpdateUserInterfaceLanguage(ctx context.Context, chatID
 	} else {
 		r.logger.Info("Updated interface language for chat ID %d: %s", chatID, language)
 	}
-	return err
+	return dbError(err)
 }
 
 // UpdateUserCaptionLanguage updates a user's caption language
@@ -159,7 +162,228 @@ func (r *UserRepository) UpdateUserCaptionLanguage(ctx context.Context, chatID i
 	} else {
 		r.logger.Info("Updated caption language for chat ID %d: %s", chatID, language)
 	}
-	return err
+	return dbError(err)
+}
+
+// UpdateUserBurnLanguage updates the language of the subtitles burned into a user's videos
+func (r *UserRepository) UpdateUserBurnLanguage(ctx context.Context, chatID int64, language string) error {
+	collection := r.GetUserCollection()
+	
+	filter := bson.M{"chat_id": chatID}
+	update := bson.M{
+		"$set": bson.M{
+			"burn_language": language,
+			"updated_at":    time.Now(),
+			"last_activity": time.Now(),
+		},
+	}
+	
+	_, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		r.logger.Error("Error updating burn language for chat ID %d: %v", chatID, err)
+	} else {
+		r.logger.Info("Updated burn language for chat ID %d: %s", chatID, language)
+	}
+	return dbError(err)
+}
+
+// UpdateUserFileLanguage updates the language of the subtitle file sent to a user
+func (r *UserRepository) UpdateUserFileLanguage(ctx context.Context, chatID int64, language string) error {
+	collection := r.GetUserCollection()
+	
+	filter := bson.M{"chat_id": chatID}
+	update := bson.M{
+		"$set": bson.M{
+			"file_language": language,
+			"updated_at":    time.Now(),
+			"last_activity": time.Now(),
+		},
+	}
+	
+	_, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		r.logger.Error("Error updating file language for chat ID %d: %v", chatID, err)
+	} else {
+		r.logger.Info("Updated file language for chat ID %d: %s", chatID, language)
+	}
+	return dbError(err)
+}
+
+// UpdateUserIncludeMetadata updates whether a user receives metadata files with downloads
+func (r *UserRepository) UpdateUserIncludeMetadata(ctx context.Context, chatID int64, enabled bool) error {
+	collection := r.GetUserCollection()
+	
+	filter := bson.M{"chat_id": chatID}
+	update := bson.M{
+		"$set": bson.M{
+			"include_metadata": enabled,
+			"updated_at":       time.Now(),
+			"last_activity":    time.Now(),
+		},
+	}
+	
+	_, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		r.logger.Error("Error updating metadata setting for chat ID %d: %v", chatID, err)
+	} else {
+		r.logger.Info("Updated metadata setting for chat ID %d: %t", chatID, enabled)
+	}
+	return dbError(err)
+}
+
+// UpdateUserQuietMode updates whether a user gets one summary of their downloads instead of a message per download
+func (r *UserRepository) UpdateUserQuietMode(ctx context.Context, chatID int64, enabled bool) error {
+	collection := r.GetUserCollection()
+	
+	filter := bson.M{"chat_id": chatID}
+	update := bson.M{
+		"$set": bson.M{
+			"quiet_mode":    enabled,
+			"updated_at":    time.Now(),
+			"last_activity": time.Now(),
+		},
+	}
+	
+	_, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		r.logger.Error("Error updating quiet mode for chat ID %d: %v", chatID, err)
+	} else {
+		r.logger.Info("Updated quiet mode for chat ID %d: %t", chatID, enabled)
+	}
+	return dbError(err)
+}
+
+// UpdateUserZipPlaylists updates whether a user receives playlist downloads as zip archives
+func (r *UserRepository) UpdateUserZipPlaylists(ctx context.Context, chatID int64, enabled bool) error {
+	collection := r.GetUserCollection()
+	
+	filter := bson.M{"chat_id": chatID}
+	update := bson.M{
+		"$set": bson.M{
+			"zip_playlists": enabled,
+			"updated_at":    time.Now(),
+			"last_activity": time.Now(),
+		},
+	}
+	
+	_, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		r.logger.Error("Error updating zip setting for chat ID %d: %v", chatID, err)
+	} else {
+		r.logger.Info("Updated zip setting for chat ID %d: %t", chatID, enabled)
+	}
+	return dbError(err)
+}
+
+// UpdateUserSendAsDocument updates whether a user receives videos as documents
+func (r *UserRepository) UpdateUserSendAsDocument(ctx context.Context, chatID int64, enabled bool) error {
+	collection := r.GetUserCollection()
+	
+	filter := bson.M{"chat_id": chatID}
+	update := bson.M{
+		"$set": bson.M{
+			"send_as_document": enabled,
+			"updated_at":       time.Now(),
+			"last_activity":    time.Now(),
+		},
+	}
+	
+	_, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		r.logger.Error("Error updating send as document setting for chat ID %d: %v", chatID, err)
+	} else {
+		r.logger.Info("Updated send as document setting for chat ID %d: %t", chatID, enabled)
+	}
+	return dbError(err)
+}
+
+// UpdateUserAudioPreferences updates the format and bitrate a user receives extracted audio in
+func (r *UserRepository) UpdateUserAudioPreferences(ctx context.Context, chatID int64, format, bitrate string) error {
+	collection := r.GetUserCollection()
+	
+	filter := bson.M{"chat_id": chatID}
+	update := bson.M{
+		"$set": bson.M{
+			"audio_format":  format,
+			"audio_bitrate": bitrate,
+			"updated_at":    time.Now(),
+			"last_activity": time.Now(),
+		},
+	}
+	
+	_, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		r.logger.Error("Error updating audio preferences for chat ID %d: %v", chatID, err)
+	} else {
+		r.logger.Info("Updated audio preferences for chat ID %d: %s %s", chatID, format, bitrate)
+	}
+	return dbError(err)
+}
+
+// UpdateUserNotifyOnReady updates whether finished downloads are offered with buttons instead of sent right away
+func (r *UserRepository) UpdateUserNotifyOnReady(ctx context.Context, chatID int64, enabled bool) error {
+	collection := r.GetUserCollection()
+	
+	filter := bson.M{"chat_id": chatID}
+	update := bson.M{
+		"$set": bson.M{
+			"notify_on_ready": enabled,
+			"updated_at":      time.Now(),
+			"last_activity":   time.Now(),
+		},
+	}
+	
+	_, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		r.logger.Error("Error updating notify on ready for chat ID %d: %v", chatID, err)
+	} else {
+		r.logger.Info("Updated notify on ready for chat ID %d: %v", chatID, enabled)
+	}
+	return dbError(err)
+}
+
+// UpdateUserTranslateSubtitles updates whether subtitles are machine-translated when a video has none in the user's languages
+func (r *UserRepository) UpdateUserTranslateSubtitles(ctx context.Context, chatID int64, enabled bool) error {
+	collection := r.GetUserCollection()
+	
+	filter := bson.M{"chat_id": chatID}
+	update := bson.M{
+		"$set": bson.M{
+			"translate_subtitles": enabled,
+			"updated_at":          time.Now(),
+			"last_activity":       time.Now(),
+		},
+	}
+	
+	_, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		r.logger.Error("Error updating subtitle translation for chat ID %d: %v", chatID, err)
+	} else {
+		r.logger.Info("Updated subtitle translation for chat ID %d: %v", chatID, enabled)
+	}
+	return dbError(err)
+}
+
+// UpdateUserDownloadMode updates whether a user's links are downloaded as video or audio
+func (r *UserRepository) UpdateUserDownloadMode(ctx context.Context, chatID int64, mode string) error {
+	collection := r.GetUserCollection()
+	
+	filter := bson.M{"chat_id": chatID}
+	update := bson.M{
+		"$set": bson.M{
+			"download_mode": mode,
+			"updated_at":    time.Now(),
+			"last_activity": time.Now(),
+		},
+	}
+	
+	_, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		r.logger.Error("Error updating download mode for chat ID %d: %v", chatID, err)
+	} else {
+		r.logger.Info("Updated download mode for chat ID %d: %s", chatID, mode)
+	}
+	return dbError(err)
 }
 
 // UpdateUserActivity updates a user's last activity timestamp and increments request count
@@ -181,7 +405,26 @@ func (r *UserRepository) UpdateUserActivity(ctx context.Context, chatID int64) e
 	if err != nil {
 		r.logger.Error("Error updating user activity for chat ID %d: %v", chatID, err)
 	}
-	return err
+	return dbError(err)
+}
+
+// CountUsers counts all users and the users active since the given time
+func (r *UserRepository) CountUsers(ctx context.Context, activeSince time.Time) (int64, int64, error) {
+	collection := r.GetUserCollection()
+	
+	total, err := collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		r.logger.Error("Error counting users: %v", err)
+		return 0, 0, dbError(err)
+	}
+	
+	active, err := collection.CountDocuments(ctx, bson.M{"last_activity": bson.M{"$gte": activeSince}})
+	if err != nil {
+		r.logger.Error("Error counting active users: %v", err)
+		return 0, 0, dbError(err)
+	}
+	
+	return total, active, nil
 }
 
 // ResetUserRateLimit resets a user's rate limit
@@ -203,7 +446,7 @@ func (r *UserRepository) ResetUserRateLimit(ctx context.Context, chatID int64, r
 	} else {
 		r.logger.Info("Reset rate limit for chat ID %d, next reset at %v", chatID, resetTime)
 	}
-	return err
+	return dbError(err)
 }
 
 // DownloadRepository handles download request and result operations
@@ -211,6 +454,9 @@ type DownloadRepository struct {
 	client   *MongoClient
 	database string
 	logger   *utils.EnhancedLogger
+
+	// noTransactions is set once the server turned out not to support transactions, e.g. a standalone mongod
+	noTransactions atomic.Bool
 }
 
 // NewDownloadRepository creates a new download repository
@@ -236,23 +482,55 @@ func (r *DownloadRepository) GetResultCollection() *mongo.Collection {
 func (r *DownloadRepository) CreateDownloadRequest(ctx context.Context, request *models.DownloadRequest) (*models.DownloadRequest, error) {
 	collection := r.GetRequestCollection()
 	
-	result, err := collection.InsertOne(ctx, request)
+	// Choose the ID up front so a retried insert can tell it was stored by an attempt whose reply was lost
+	if request.ID.IsZero() {
+		request.ID = primitive.NewObjectID()
+	}
+	attempts := 0
+	err := r.client.retryWrite(ctx, func() error {
+		attempts++
+		_, err := collection.InsertOne(ctx, request)
+		if attempts > 1 && mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return err
+	})
 	if err != nil {
 		r.logger.Error("Error creating download request: %v", err)
-		return nil, err
+		return nil, dbError(err)
 	}
 	
-	request.ID = result.InsertedID.(primitive.ObjectID)
 	r.logger.Info("Created download request %s for chat ID %d: %s", 
 		request.ID.Hex(), request.ChatID, request.URL)
 	return request, nil
 }
 
+// log returns the logger of an operation carried by the context, e.g. one tagging the lines of a download with its
+// request ID, or the repository's logger
+func (r *DownloadRepository) log(ctx context.Context) *utils.EnhancedLogger {
+	return utils.LoggerFromContext(ctx, r.logger)
+}
+
 // UpdateDownloadRequestStatus updates a download request status
 func (r *DownloadRepository) UpdateDownloadRequestStatus(ctx context.Context, requestID primitive.ObjectID, status string) error {
 	collection := r.GetRequestCollection()
 	
 	filter := bson.M{"_id": requestID}
+	err := r.client.retryWrite(ctx, func() error {
+		_, err := collection.UpdateOne(ctx, filter, statusUpdate(status))
+		return err
+	})
+	if err != nil {
+		r.log(ctx).Error("Error updating download request status %s to %s: %v", 
+			requestID.Hex(), status, err)
+	} else {
+		r.log(ctx).Info("Updated download request %s status to %s", requestID.Hex(), status)
+	}
+	return dbError(err)
+}
+
+// statusUpdate returns the update that sets a download request status
+func statusUpdate(status string) bson.M {
 	update := bson.M{
 		"$set": bson.M{
 			"status":     status,
@@ -264,15 +542,34 @@ func (r *DownloadRepository) UpdateDownloadRequestStatus(ctx context.Context, re
 	if status == "completed" {
 		update["$set"].(bson.M)["completed_at"] = time.Now()
 	}
+	return update
+}
+
+// CancelRequests marks the download requests that are still pending or processing as cancelled and returns how many were
+func (r *DownloadRepository) CancelRequests(ctx context.Context, requestIDs []primitive.ObjectID) (int64, error) {
+	if len(requestIDs) == 0 {
+		return 0, nil
+	}
+	collection := r.GetRequestCollection()
 	
-	_, err := collection.UpdateOne(ctx, filter, update)
+	// Requests that finished in the meantime keep their status
+	filter := bson.M{
+		"_id":    bson.M{"$in": requestIDs},
+		"status": bson.M{"$in": []string{"pending", "processing"}},
+	}
+	var result *mongo.UpdateResult
+	err := r.client.retryWrite(ctx, func() error {
+		var err error
+		result, err = collection.UpdateMany(ctx, filter, statusUpdate("cancelled"))
+		return err
+	})
 	if err != nil {
-		r.logger.Error("Error updating download request status %s to %s: %v", 
-			requestID.Hex(), status, err)
-	} else {
-		r.logger.Info("Updated download request %s status to %s", requestID.Hex(), status)
+		r.logger.Error("Error cancelling %d download requests: %v", len(requestIDs), err)
+		return 0, dbError(err)
 	}
-	return err
+	
+	r.logger.Info("Cancelled %d of %d download requests", result.ModifiedCount, len(requestIDs))
+	return result.ModifiedCount, nil
 }
 
 // UpdateDownloadRequestRetry updates a download request retry count and error reason
@@ -290,33 +587,525 @@ func (r *DownloadRepository) UpdateDownloadRequestRetry(ctx context.Context, req
 		},
 	}
 	
+	// Not retried after transient errors, the count would be increased again when the first attempt was stored
 	result, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
-		r.logger.Error("Error updating download request retry %s: %v", requestID.Hex(), err)
-		return err
+		r.log(ctx).Error("Error updating download request retry %s: %v", requestID.Hex(), err)
+		return dbError(err)
 	}
 	
-	r.logger.Info("Updated download request %s retry count, matched: %d, modified: %d", 
+	r.log(ctx).Info("Updated download request %s retry count, matched: %d, modified: %d", 
 		requestID.Hex(), result.MatchedCount, result.ModifiedCount)
 	return nil
 }
 
-// CreateDownloadResult creates a new download result
-func (r *DownloadRepository) CreateDownloadResult(ctx context.Context, result *models.DownloadResult) (*models.DownloadResult, error) {
+// GetDownloadRequestByID gets a download request by its ID
+func (r *DownloadRepository) GetDownloadRequestByID(ctx context.Context, requestID primitive.ObjectID) (*models.DownloadRequest, error) {
+	collection := r.GetRequestCollection()
+	
+	var request models.DownloadRequest
+	err := collection.FindOne(ctx, bson.M{"_id": requestID}).Decode(&request)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		r.logger.Error("Error finding download request %s: %v", requestID.Hex(), err)
+		return nil, dbError(err)
+	}
+	
+	return &request, nil
+}
+
+// FindRequestsByCorrelationID gets the download requests with a correlation ID, newest first
+func (r *DownloadRepository) FindRequestsByCorrelationID(ctx context.Context, correlationID string) ([]*models.DownloadRequest, error) {
+	collection := r.GetRequestCollection()
+	
+	filter := bson.M{"correlation_id": correlationID}
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		r.logger.Error("Error finding download requests by correlation ID %s: %v", correlationID, err)
+		return nil, dbError(err)
+	}
+	defer cursor.Close(ctx)
+	
+	var requests []*models.DownloadRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		r.logger.Error("Error decoding download requests: %v", err)
+		return nil, dbError(err)
+	}
+	
+	return requests, nil
+}
+
+// GetLatestRequestByChatID gets the most recent download request of a chat
+func (r *DownloadRepository) GetLatestRequestByChatID(ctx context.Context, chatID int64) (*models.DownloadRequest, error) {
+	collection := r.GetRequestCollection()
+	
+	var request models.DownloadRequest
+	filter := bson.M{"chat_id": chatID}
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	
+	err := collection.FindOne(ctx, filter, findOptions).Decode(&request)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		r.logger.Error("Error finding latest download request for chat ID %d: %v", chatID, err)
+		return nil, dbError(err)
+	}
+	
+	return &request, nil
+}
+
+// FindRequestsByStatus gets the download requests with any of the given statuses, oldest first
+func (r *DownloadRepository) FindRequestsByStatus(ctx context.Context, statuses []string) ([]*models.DownloadRequest, error) {
+	collection := r.GetRequestCollection()
+	
+	filter := bson.M{"status": bson.M{"$in": statuses}}
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		r.logger.Error("Error finding download requests by status: %v", err)
+		return nil, dbError(err)
+	}
+	defer cursor.Close(ctx)
+	
+	var requests []*models.DownloadRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		r.logger.Error("Error decoding download requests: %v", err)
+		return nil, dbError(err)
+	}
+	
+	return requests, nil
+}
+
+// SearchFilter selects the download requests SearchRequests returns, zero fields match every request
+type SearchFilter struct {
+	URL    string    // part of the URL, matched case-insensitively
+	Status string    // pending, processing, completed, failed or cancelled
+	ChatID int64     // chat that requested the download
+	From   time.Time // created at or after this time
+	To     time.Time // created before this time
+}
+
+// SearchRequests gets a page of the download requests matching a filter, newest first. URLs are matched with an
+// unanchored regular expression, which no index can serve, so the status and date range narrow the requests
+// scanned through their indexes.
+func (r *DownloadRepository) SearchRequests(ctx context.Context, filter SearchFilter, limit int64, skip int64) ([]*models.DownloadRequest, error) {
+	collection := r.GetRequestCollection()
+	
+	query := bson.M{}
+	if filter.URL != "" {
+		query["url"] = primitive.Regex{Pattern: regexp.QuoteMeta(filter.URL), Options: "i"}
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.ChatID != 0 {
+		query["chat_id"] = filter.ChatID
+	}
+	createdAt := bson.M{}
+	if !filter.From.IsZero() {
+		createdAt["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		createdAt["$lt"] = filter.To
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+	
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(limit).
+		SetSkip(skip)
+	
+	cursor, err := collection.Find(ctx, query, findOptions)
+	if err != nil {
+		r.logger.Error("Error searching download requests: %v", err)
+		return nil, dbError(err)
+	}
+	defer cursor.Close(ctx)
+	
+	var requests []*models.DownloadRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		r.logger.Error("Error decoding download requests: %v", err)
+		return nil, dbError(err)
+	}
+	
+	return requests, nil
+}
+
+// CountByDay counts the download requests created per UTC day since the given time, keyed by "2006-01-02".
+// Days without downloads are included with a count of zero.
+func (r *DownloadRepository) CountByDay(ctx context.Context, since time.Time) (map[string]int64, error) {
+	collection := r.GetRequestCollection()
+	
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"created_at": bson.M{"$gte": since},
+			"source":     bson.M{"$ne": "thumb"},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"$dateToString": bson.M{
+				"format":   "%Y-%m-%d",
+				"date":     "$created_at",
+				"timezone": "UTC",
+			}},
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+	
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		r.logger.Error("Error counting download requests by day: %v", err)
+		return nil, dbError(err)
+	}
+	defer cursor.Close(ctx)
+	
+	var buckets []struct {
+		Day   string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &buckets); err != nil {
+		r.logger.Error("Error decoding download counts: %v", err)
+		return nil, dbError(err)
+	}
+	
+	// Zero-fill every day of the range so gaps show up in charts
+	counts := make(map[string]int64)
+	now := time.Now().UTC()
+	for day := since.UTC().Truncate(24 * time.Hour); !day.After(now); day = day.AddDate(0, 0, 1) {
+		counts[day.Format("2006-01-02")] = 0
+	}
+	for _, bucket := range buckets {
+		counts[bucket.Day] = bucket.Count
+	}
+	
+	return counts, nil
+}
+
+// CountByStatus counts the download requests created since the given time per status
+func (r *DownloadRepository) CountByStatus(ctx context.Context, since time.Time) (map[string]int64, error) {
+	collection := r.GetRequestCollection()
+	
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"created_at": bson.M{"$gte": since},
+			"source":     bson.M{"$ne": "thumb"},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$status",
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+	
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		r.logger.Error("Error counting download requests by status: %v", err)
+		return nil, dbError(err)
+	}
+	defer cursor.Close(ctx)
+	
+	var buckets []struct {
+		Status string `bson:"_id"`
+		Count  int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &buckets); err != nil {
+		r.logger.Error("Error decoding download counts: %v", err)
+		return nil, dbError(err)
+	}
+	
+	counts := make(map[string]int64)
+	for _, bucket := range buckets {
+		counts[bucket.Status] = bucket.Count
+	}
+	return counts, nil
+}
+
+// ChatDownloadCount is the number of downloads requested by a chat
+type ChatDownloadCount struct {
+	ChatID int64 `bson:"_id"`
+	Count  int64 `bson:"count"`
+}
+
+// TopChats returns the chats that requested the most downloads since the given time, most downloads first
+func (r *DownloadRepository) TopChats(ctx context.Context, since time.Time, limit int64) ([]ChatDownloadCount, error) {
+	collection := r.GetRequestCollection()
+	
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"created_at": bson.M{"$gte": since},
+			"source":     bson.M{"$ne": "thumb"},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$chat_id",
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+	
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		r.logger.Error("Error finding the most active chats: %v", err)
+		return nil, dbError(err)
+	}
+	defer cursor.Close(ctx)
+	
+	var chats []ChatDownloadCount
+	if err := cursor.All(ctx, &chats); err != nil {
+		r.logger.Error("Error decoding the most active chats: %v", err)
+		return nil, dbError(err)
+	}
+	return chats, nil
+}
+
+// EnsureRequestIndexes creates the indexes date-range statistics, /lookup and /search match download requests with
+func (r *DownloadRepository) EnsureRequestIndexes(ctx context.Context) error {
+	collection := r.GetRequestCollection()
+	
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "correlation_id", Value: 1}},
+			Options: options.Index().SetName("correlation_id").SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("status_created_at"),
+		},
+	})
+	if err != nil {
+		r.logger.Error("Error creating indexes on download requests: %v", err)
+		return dbError(err)
+	}
+	
+	r.logger.Info("Ensured indexes on download requests creation time, correlation ID and status")
+	return nil
+}
+
+// EnsureResultIndexes creates the unique index on download_results.request_id that keeps one result per request
+func (r *DownloadRepository) EnsureResultIndexes(ctx context.Context) error {
+	collection := r.GetResultCollection()
+	
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "request_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("request_id_unique"),
+	})
+	if err != nil {
+		// Fails when duplicate results were stored before the index existed
+		r.logger.Error("Error creating unique index on download results request ID: %v", err)
+		return dbError(err)
+	}
+	
+	r.logger.Info("Ensured unique index on download results request ID")
+	return nil
+}
+
+// EnsureResultTTLIndex creates the TTL index that has MongoDB delete download results older than the retention.
+// An existing index with another retention is updated.
+func (r *DownloadRepository) EnsureResultTTLIndex(ctx context.Context, retention time.Duration) error {
 	collection := r.GetResultCollection()
+	expireAfter := int32(retention.Seconds())
 	
-	insertResult, err := collection.InsertOne(ctx, result)
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(expireAfter).SetName("created_at_ttl"),
+	})
+	if cmdErr, ok := err.(mongo.CommandError); ok && (cmdErr.Code == 85 || cmdErr.Code == 86) {
+		// IndexOptionsConflict or IndexKeySpecsConflict, the retention was changed since the index was created
+		err = collection.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: collection.Name()},
+			{Key: "index", Value: bson.D{
+				{Key: "name", Value: "created_at_ttl"},
+				{Key: "expireAfterSeconds", Value: expireAfter},
+			}},
+		}).Err()
+	}
 	if err != nil {
-		r.logger.Error("Error creating download result: %v", err)
-		return nil, err
+		r.logger.Error("Error creating TTL index on download results: %v", err)
+		return dbError(err)
 	}
 	
-	result.ID = insertResult.InsertedID.(primitive.ObjectID)
-	r.logger.Info("Created download result %s for request %s", 
+	r.logger.Info("Ensured TTL index on download results, expiring after %s", retention)
+	return nil
+}
+
+// DeleteOldResults deletes the download results created before a time and returns how many were deleted
+func (r *DownloadRepository) DeleteOldResults(ctx context.Context, before time.Time) (int64, error) {
+	collection := r.GetResultCollection()
+	
+	res, err := collection.DeleteMany(ctx, bson.M{"created_at": bson.M{"$lt": before}})
+	if err != nil {
+		r.logger.Error("Error deleting download results older than %s: %v", before, err)
+		return 0, dbError(err)
+	}
+	
+	return res.DeletedCount, nil
+}
+
+// FindResultsWithFiles gets the download results whose files aren't flagged as removed from disk yet
+func (r *DownloadRepository) FindResultsWithFiles(ctx context.Context) ([]*models.DownloadResult, error) {
+	collection := r.GetResultCollection()
+	
+	cursor, err := collection.Find(ctx, bson.M{"files_expired": bson.M{"$ne": true}})
+	if err != nil {
+		r.logger.Error("Error finding download results with files: %v", err)
+		return nil, dbError(err)
+	}
+	defer cursor.Close(ctx)
+	
+	var results []*models.DownloadResult
+	if err := cursor.All(ctx, &results); err != nil {
+		r.logger.Error("Error decoding download results: %v", err)
+		return nil, dbError(err)
+	}
+	
+	return results, nil
+}
+
+// MarkResultsExpired flags download results whose files were removed from disk
+func (r *DownloadRepository) MarkResultsExpired(ctx context.Context, resultIDs []primitive.ObjectID) (int64, error) {
+	if len(resultIDs) == 0 {
+		return 0, nil
+	}
+	collection := r.GetResultCollection()
+	
+	var res *mongo.UpdateResult
+	err := r.client.retryWrite(ctx, func() error {
+		var err error
+		res, err = collection.UpdateMany(ctx,
+			bson.M{"_id": bson.M{"$in": resultIDs}},
+			bson.M{"$set": bson.M{"files_expired": true}},
+		)
+		return err
+	})
+	if err != nil {
+		r.logger.Error("Error marking download results as expired: %v", err)
+		return 0, dbError(err)
+	}
+	
+	return res.ModifiedCount, nil
+}
+
+// CreateDownloadResult stores the download result of a request. Processing a request again
+// updates its existing result instead of creating a second one.
+func (r *DownloadRepository) CreateDownloadResult(ctx context.Context, result *models.DownloadResult) (*models.DownloadResult, error) {
+	// The upsert matches the request's result, trying it again can't store a second one
+	err := r.client.retryWrite(ctx, func() error {
+		return r.upsertResult(ctx, result)
+	})
+	if err != nil {
+		r.log(ctx).Error("Error creating download result: %v", err)
+		return nil, dbError(err)
+	}
+	
+	r.log(ctx).Info("Created download result %s for request %s", 
 		result.ID.Hex(), result.RequestID.Hex())
 	return result, nil
 }
 
+// upsertResult inserts or replaces the result of the request, setting result.ID to the stored result's ID
+func (r *DownloadRepository) upsertResult(ctx context.Context, result *models.DownloadResult) error {
+	fields, err := bson.Marshal(result)
+	if err != nil {
+		return dbError(err)
+	}
+	var set bson.M
+	if err := bson.Unmarshal(fields, &set); err != nil {
+		return dbError(err)
+	}
+	// The ID of an existing result is kept, a new one gets the chosen ID or a generated one
+	delete(set, "_id")
+	id := result.ID
+	if id.IsZero() {
+		id = primitive.NewObjectID()
+	}
+	
+	filter := bson.M{"request_id": result.RequestID}
+	update := bson.M{
+		"$set":         set,
+		"$setOnInsert": bson.M{"_id": id},
+	}
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"_id": 1})
+	
+	var stored struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := r.GetResultCollection().FindOneAndUpdate(ctx, filter, update, opts).Decode(&stored); err != nil {
+		return dbError(err)
+	}
+	result.ID = stored.ID
+	return nil
+}
+
+// CompleteWithResult marks a download request completed and stores its result in one transaction,
+// so a crash can't leave a completed request without a result. Servers without transactions get
+// the two writes one after the other.
+func (r *DownloadRepository) CompleteWithResult(ctx context.Context, requestID primitive.ObjectID, result *models.DownloadResult) (*models.DownloadResult, error) {
+	result.RequestID = requestID
+	// Choose the ID up front so a retried transaction doesn't insert a second result,
+	// a result already stored for the request keeps its ID
+	result.ID = primitive.NewObjectID()
+	
+	if !r.noTransactions.Load() {
+		err := r.completeInTransaction(ctx, requestID, result)
+		if err == nil {
+			r.log(ctx).Info("Completed download request %s with result %s", requestID.Hex(), result.ID.Hex())
+			return result, nil
+		}
+		if !isTransactionUnsupported(err) {
+			r.log(ctx).Error("Error completing download request %s: %v", requestID.Hex(), err)
+			return nil, dbError(err)
+		}
+		r.noTransactions.Store(true)
+		r.log(ctx).Warn("MongoDB doesn't support transactions, completing downloads with sequential writes: %v", err)
+	}
+	
+	if _, err := r.CreateDownloadResult(ctx, result); err != nil {
+		return nil, dbError(err)
+	}
+	if err := r.UpdateDownloadRequestStatus(ctx, requestID, "completed"); err != nil {
+		return nil, dbError(err)
+	}
+	return result, nil
+}
+
+// completeInTransaction inserts a download result and marks its request completed in a transaction
+func (r *DownloadRepository) completeInTransaction(ctx context.Context, requestID primitive.ObjectID, result *models.DownloadResult) error {
+	session, err := r.client.StartSession()
+	if err != nil {
+		return dbError(err)
+	}
+	defer session.EndSession(ctx)
+	
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if err := r.upsertResult(sessCtx, result); err != nil {
+			return nil, dbError(err)
+		}
+		_, err := r.GetRequestCollection().UpdateOne(sessCtx, bson.M{"_id": requestID}, statusUpdate("completed"))
+		return nil, dbError(err)
+	})
+	return dbError(err)
+}
+
+// isTransactionUnsupported reports whether an error means the deployment can't run transactions
+func isTransactionUnsupported(err error) bool {
+	// IllegalOperation: "Transaction numbers are only allowed on a replica set member or mongos"
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(20)
+}
+
 // GetDownloadResultByRequestID gets a download result by request ID
 func (r *DownloadRepository) GetDownloadResultByRequestID(ctx context.Context, requestID primitive.ObjectID) (*models.DownloadResult, error) {
 	collection := r.GetResultCollection()
@@ -331,12 +1120,108 @@ func (r *DownloadRepository) GetDownloadResultByRequestID(ctx context.Context, r
 		}
 		r.logger.Error("Error finding download result by request ID %s: %v", 
 			requestID.Hex(), err)
-		return nil, err
+		return nil, dbError(err)
 	}
 	
 	return &result, nil
 }
 
+// GetDownloadResultByID gets a download result by its ID
+func (r *DownloadRepository) GetDownloadResultByID(ctx context.Context, resultID primitive.ObjectID) (*models.DownloadResult, error) {
+	collection := r.GetResultCollection()
+	
+	var result models.DownloadResult
+	filter := bson.M{"_id": resultID}
+	
+	err := collection.FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		r.logger.Error("Error finding download result %s: %v", resultID.Hex(), err)
+		return nil, dbError(err)
+	}
+	
+	return &result, nil
+}
+
+// FindRecentResultByChatAndURL gets the newest download result for a URL in a chat created after since
+func (r *DownloadRepository) FindRecentResultByChatAndURL(ctx context.Context, chatID int64, url string, since time.Time) (*models.DownloadResult, error) {
+	collection := r.GetResultCollection()
+	
+	var result models.DownloadResult
+	filter := bson.M{
+		"chat_id": chatID,
+		"url":     url,
+		"created_at": bson.M{
+			"$gte": since,
+		},
+	}
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	
+	err := collection.FindOne(ctx, filter, findOptions).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		r.logger.Error("Error finding recent download result for chat ID %d: %v", chatID, err)
+		return nil, dbError(err)
+	}
+	
+	return &result, nil
+}
+
+// GetResultsByChatID gets a page of the download results of a chat, newest first
+func (r *DownloadRepository) GetResultsByChatID(ctx context.Context, chatID int64, limit int64, skip int64) ([]*models.DownloadResult, error) {
+	collection := r.GetResultCollection()
+	
+	filter := bson.M{"chat_id": chatID}
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(limit).
+		SetSkip(skip)
+	
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		r.logger.Error("Error finding download results for chat ID %d: %v", chatID, err)
+		return nil, dbError(err)
+	}
+	defer cursor.Close(ctx)
+	
+	var results []*models.DownloadResult
+	if err := cursor.All(ctx, &results); err != nil {
+		r.logger.Error("Error decoding download results: %v", err)
+		return nil, dbError(err)
+	}
+	
+	return results, nil
+}
+
+// UpdateDownloadResultFileIDs stores the Telegram file IDs of a sent download result
+func (r *DownloadRepository) UpdateDownloadResultFileIDs(ctx context.Context, result *models.DownloadResult) error {
+	collection := r.GetResultCollection()
+	
+	filter := bson.M{"_id": result.ID}
+	update := bson.M{
+		"$set": bson.M{
+			"video_file_id":          result.VideoFileID,
+			"video_with_sub_file_id": result.VideoWithSubFileID,
+			"audio_file_id":          result.AudioFileID,
+			"subtitle_file_id":       result.SubtitleFileID,
+			"thumbnail_file_id":      result.ThumbnailFileID,
+		},
+	}
+	
+	err := r.client.retryWrite(ctx, func() error {
+		_, err := collection.UpdateOne(ctx, filter, update)
+		return err
+	})
+	if err != nil {
+		r.logger.Error("Error updating file IDs for download result %s: %v", result.ID.Hex(), err)
+	}
+	return dbError(err)
+}
+
 // ErrorLogRepository handles error logging operations
 type ErrorLogRepository struct {
 	client   *MongoClient
@@ -365,12 +1250,29 @@ func (r *ErrorLogRepository) LogError(ctx context.Context, errorLog *models.Erro
 	_, err := collection.InsertOne(ctx, errorLog)
 	if err != nil {
 		r.logger.Error("Error inserting error log: %v", err)
-		return err
+		return dbError(err)
 	}
 	
 	return nil
 }
 
+// EnsureIndexes creates the index error logs are looked up by correlation ID with
+func (r *ErrorLogRepository) EnsureIndexes(ctx context.Context) error {
+	collection := r.GetErrorLogCollection()
+	
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "correlation_id", Value: 1}},
+		Options: options.Index().SetName("correlation_id").SetSparse(true),
+	})
+	if err != nil {
+		r.logger.Error("Error creating index on error logs correlation ID: %v", err)
+		return dbError(err)
+	}
+	
+	r.logger.Info("Ensured index on error logs correlation ID")
+	return nil
+}
+
 // GetErrorLogs gets error logs with optional filtering
 func (r *ErrorLogRepository) GetErrorLogs(ctx context.Context, filter bson.M, limit int64) ([]*models.ErrorLog, error) {
 	collection := r.GetErrorLogCollection()
@@ -385,14 +1287,85 @@ func (r *ErrorLogRepository) GetErrorLogs(ctx context.Context, filter bson.M, li
 	cursor, err := collection.Find(ctx, filter, findOptions)
 	if err != nil {
 		r.logger.Error("Error finding error logs: %v", err)
-		return nil, err
+		return nil, dbError(err)
 	}
 	defer cursor.Close(ctx)
 	
 	var logs []*models.ErrorLog
 	if err := cursor.All(ctx, &logs); err != nil {
 		r.logger.Error("Error decoding error logs: %v", err)
-		return nil, err
+		return nil, dbError(err)
+	}
+	
+	return logs, nil
+}
+
+// GetErrorLogsForRequests gets the error logs of download requests, by their correlation IDs or request IDs, newest first
+func (r *ErrorLogRepository) GetErrorLogsForRequests(ctx context.Context, correlationIDs []string, requestIDs []primitive.ObjectID, limit int64) ([]*models.ErrorLog, error) {
+	if len(correlationIDs) == 0 && len(requestIDs) == 0 {
+		return nil, nil
+	}
+	
+	filter := bson.M{"$or": bson.A{
+		bson.M{"correlation_id": bson.M{"$in": correlationIDs}},
+		bson.M{"request_id": bson.M{"$in": requestIDs}},
+	}}
+	return r.GetErrorLogs(ctx, filter, limit)
+}
+
+// AuditLogRepository handles the append-only log of admin actions
+type AuditLogRepository struct {
+	client   *MongoClient
+	database string
+	logger   *utils.EnhancedLogger
+}
+
+// NewAuditLogRepository creates a new audit log repository
+func NewAuditLogRepository(client *MongoClient, database string, logger *utils.EnhancedLogger) *AuditLogRepository {
+	return &AuditLogRepository{
+		client:   client,
+		database: database,
+		logger:   logger,
+	}
+}
+
+// GetAuditLogCollection returns the audit logs collection
+func (r *AuditLogRepository) GetAuditLogCollection() *mongo.Collection {
+	return r.client.GetCollection(r.database, "audit_logs")
+}
+
+// LogAction appends an admin action to the audit log
+func (r *AuditLogRepository) LogAction(ctx context.Context, auditLog *models.AuditLog) error {
+	collection := r.GetAuditLogCollection()
+	
+	_, err := collection.InsertOne(ctx, auditLog)
+	if err != nil {
+		r.logger.Error("Error inserting audit log for admin %d: %v", auditLog.AdminID, err)
+		return dbError(err)
+	}
+	
+	return nil
+}
+
+// GetRecentActions gets the latest admin actions, newest first
+func (r *AuditLogRepository) GetRecentActions(ctx context.Context, limit int64) ([]*models.AuditLog, error) {
+	collection := r.GetAuditLogCollection()
+	
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	findOptions.SetLimit(limit)
+	
+	cursor, err := collection.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		r.logger.Error("Error finding audit logs: %v", err)
+		return nil, dbError(err)
+	}
+	defer cursor.Close(ctx)
+	
+	var logs []*models.AuditLog
+	if err := cursor.All(ctx, &logs); err != nil {
+		r.logger.Error("Error decoding audit logs: %v", err)
+		return nil, dbError(err)
 	}
 	
 	return logs, nil
@@ -437,7 +1410,7 @@ func (r *RateLimitRepository) GetRateLimit(ctx context.Context, chatID int64) (*
 			return nil, nil
 		}
 		r.logger.Error("Error finding rate limit for chat ID %d: %v", chatID, err)
-		return nil, err
+		return nil, dbError(err)
 	}
 	
 	return &entry, nil
@@ -465,7 +1438,7 @@ func (r *RateLimitRepository) CreateOrUpdateRateLimit(ctx context.Context, chatI
 	result, err := collection.UpdateOne(ctx, filter, update, opts)
 	if err != nil {
 		r.logger.Error("Error updating rate limit for chat ID %d: %v", chatID, err)
-		return err
+		return dbError(err)
 	}
 	
 	if result.UpsertedCount > 0 {
@@ -490,7 +1463,7 @@ func (r *RateLimitRepository) CleanupExpiredRateLimits(ctx context.Context) (int
 	result, err := collection.DeleteMany(ctx, filter)
 	if err != nil {
 		r.logger.Error("Error cleaning up expired rate limits: %v", err)
-		return 0, err
+		return 0, dbError(err)
 	}
 	
 	if result.DeletedCount > 0 {
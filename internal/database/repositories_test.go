@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestDatabase connects to the MongoDB server at MONGODB_TEST_URI and returns the client with the name of a
+// database of the test's own, dropped once it ends. Tests are skipped without a server to run against.
+func newTestDatabase(t *testing.T) (*MongoClient, string, *utils.EnhancedLogger) {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	logger, err := utils.NewEnhancedLogger(&utils.EnhancedLoggerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client, err := NewMongoClient(ctx, uri, MongoOptions{Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database := fmt.Sprintf("vidybot_test_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		client.client.Database(database).Drop(ctx)
+		client.Disconnect(ctx)
+	})
+	return client, database, logger
+}
+
+func TestCreateDownloadResultTwiceKeepsOneDocument(t *testing.T) {
+	client, database, logger := newTestDatabase(t)
+	repo := NewDownloadRepository(client, database, logger)
+	ctx := context.Background()
+	if err := repo.EnsureResultIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	requestID := primitive.NewObjectID()
+	first, err := repo.CreateDownloadResult(ctx, &models.DownloadResult{RequestID: requestID, ChatID: 1, FileSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := repo.CreateDownloadResult(ctx, &models.DownloadResult{RequestID: requestID, ChatID: 1, FileSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := repo.GetResultCollection().CountDocuments(ctx, bson.M{"request_id": requestID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("stored %d results for the request, want 1", count)
+	}
+	if second.ID != first.ID {
+		t.Errorf("second result got ID %s, want the first one's %s", second.ID.Hex(), first.ID.Hex())
+	}
+	stored, err := repo.GetDownloadResultByRequestID(ctx, requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.FileSize != 2 {
+		t.Errorf("stored result has FileSize %d, want the second one's 2", stored.FileSize)
+	}
+}
+
+func TestDownloadStatistics(t *testing.T) {
+	client, database, logger := newTestDatabase(t)
+	repo := NewDownloadRepository(client, database, logger)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	requests := []models.DownloadRequest{
+		{ChatID: 1, Status: "completed", CreatedAt: now},
+		{ChatID: 1, Status: "completed", CreatedAt: now},
+		{ChatID: 1, Status: "failed", CreatedAt: today.Add(-time.Hour)},
+		{ChatID: 2, Status: "completed", CreatedAt: today.Add(-time.Hour)},
+		{ChatID: 3, Status: "completed", CreatedAt: now, Source: "thumb"}, // thumbnails aren't downloads
+		{ChatID: 3, Status: "failed", CreatedAt: today.AddDate(0, 0, -5)}, // before the range
+	}
+	for i := range requests {
+		if _, err := repo.CreateDownloadRequest(ctx, &requests[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	since := today.AddDate(0, 0, -1)
+
+	byStatus, err := repo.CountByStatus(ctx, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int64{"completed": 3, "failed": 1}; !reflect.DeepEqual(byStatus, want) {
+		t.Errorf("CountByStatus() = %v, want %v", byStatus, want)
+	}
+
+	byDay, err := repo.CountByDay(ctx, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{
+		since.Format("2006-01-02"): 2,
+		today.Format("2006-01-02"): 2,
+	}
+	if !reflect.DeepEqual(byDay, want) {
+		t.Errorf("CountByDay() = %v, want %v", byDay, want)
+	}
+
+	top, err := repo.TopChats(ctx, since, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []ChatDownloadCount{{ChatID: 1, Count: 3}}; !reflect.DeepEqual(top, want) {
+		t.Errorf("TopChats() = %v, want %v", top, want)
+	}
+}
+
+func TestCountUsers(t *testing.T) {
+	client, database, logger := newTestDatabase(t)
+	repo := NewUserRepository(client, database, logger)
+	ctx := context.Background()
+
+	if _, err := repo.CreateUser(ctx, &models.User{ChatID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	idle := &models.User{ID: primitive.NewObjectID(), ChatID: 2, LastActivity: time.Now().AddDate(0, 0, -30)}
+	if _, err := repo.GetUserCollection().InsertOne(ctx, idle); err != nil {
+		t.Fatal(err)
+	}
+
+	total, active, err := repo.CountUsers(ctx, time.Now().AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || active != 1 {
+		t.Errorf("CountUsers() = %d, %d, want 2 users, 1 active", total, active)
+	}
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Backoff of writes retried after a transient error, long enough to outlast a replica set election
+const (
+	writeRetryInitialWait = 500 * time.Millisecond
+	writeRetryMaxWait     = 5 * time.Second
+)
+
+// transientErrorCodes are the server errors of a replica set changing or restarting its primary
+var transientErrorCodes = []int{
+	91,    // ShutdownInProgress
+	189,   // PrimarySteppedDown
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// isTransientError reports whether a write failed for a reason that is likely gone after a short wait, such as
+// a lost connection or a primary stepping down during failover. Errors of the write itself, e.g. duplicate keys,
+// and writes whose context ended are permanent.
+func isTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if mongo.IsDuplicateKeyError(err) || errors.Is(err, mongo.ErrClientDisconnected) {
+		return false
+	}
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+
+	var labeled mongo.LabeledError
+	if errors.As(err, &labeled) && (labeled.HasErrorLabel("RetryableWriteError") || labeled.HasErrorLabel("TransientTransactionError")) {
+		return true
+	}
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		for _, code := range transientErrorCodes {
+			if serverErr.HasErrorCode(code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retryWrite runs a write and tries it again with a growing wait while it fails with a transient error, up to
+// the configured number of retries. The driver retries a write once by itself, which a failover often outlasts.
+// Writes in a transaction must not be retried this way, the transaction is retried as a whole.
+func (m *MongoClient) retryWrite(ctx context.Context, write func() error) error {
+	retryOpts := utils.DefaultRetryOptions().
+		WithMaxRetries(m.writeRetries).
+		WithInitialWait(writeRetryInitialWait).
+		WithMaxWait(writeRetryMaxWait).
+		WithLogger(utils.LoggerFromContext(ctx, m.logger)).
+		WithIsRetryable(isTransientError)
+	return utils.RetryWithContext(ctx, write, retryOpts)
+}
@@ -0,0 +1,130 @@
+package downloader
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// zipEntryOverhead is a generous estimate of the headers zip adds around every stored file
+const zipEntryOverhead = 1024
+
+// ArchiveEntry is a file to add to a zip archive under the given name
+type ArchiveEntry struct {
+	Path string
+	Name string
+}
+
+// Archive is a zip archive written by CreateZipArchives
+type Archive struct {
+	Path    string
+	Entries int // number of entries in the archive
+}
+
+// CreateZipArchives streams the entries into zip archives in outputDir, starting a new archive whenever
+// the next entry would push the current one over maxSize. Entries larger than maxSize on their own are skipped.
+// It returns the archives with the number of entries each contains.
+func CreateZipArchives(entries []ArchiveEntry, outputDir string, baseName string, maxSize int64) ([]Archive, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	var archives []Archive
+	var current *zipArchive
+
+	for _, entry := range entries {
+		size, err := FileSize(entry.Path)
+		if err != nil {
+			continue
+		}
+
+		entrySize := size + zipEntryOverhead + int64(len(entry.Name))
+		if maxSize > 0 && entrySize > maxSize {
+			continue
+		}
+
+		// Start a new archive when this entry doesn't fit in the current one
+		if current == nil || (maxSize > 0 && current.size+entrySize > maxSize) {
+			if current != nil {
+				if err := current.close(); err != nil {
+					return archives, err
+				}
+			}
+
+			path := filepath.Join(outputDir, fmt.Sprintf("%s_%d.zip", baseName, len(archives)+1))
+			current, err = newZipArchive(path)
+			if err != nil {
+				return archives, err
+			}
+			archives = append(archives, Archive{Path: path})
+		}
+
+		if err := current.add(entry); err != nil {
+			current.close()
+			return archives, err
+		}
+		current.size += entrySize
+		archives[len(archives)-1].Entries++
+	}
+
+	if current != nil {
+		if err := current.close(); err != nil {
+			return archives, err
+		}
+	}
+
+	return archives, nil
+}
+
+// zipArchive is a zip file being written to disk
+type zipArchive struct {
+	file   *os.File
+	writer *zip.Writer
+	size   int64 // estimated size of the archive so far
+}
+
+// newZipArchive creates an empty zip archive at path
+func newZipArchive(path string) (*zipArchive, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	return &zipArchive{
+		file:   file,
+		writer: zip.NewWriter(file),
+	}, nil
+}
+
+// add copies a file into the archive without compressing it, videos are already compressed
+func (a *zipArchive) add(entry ArchiveEntry) error {
+	src, err := os.Open(entry.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", entry.Path, err)
+	}
+	defer src.Close()
+
+	dst, err := a.writer.CreateHeader(&zip.FileHeader{
+		Name:   entry.Name,
+		Method: zip.Store,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", entry.Name, err)
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", entry.Name, err)
+	}
+	return nil
+}
+
+// close finishes the archive and closes its file
+func (a *zipArchive) close() error {
+	if err := a.writer.Close(); err != nil {
+		a.file.Close()
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return a.file.Close()
+}
@@ -0,0 +1,47 @@
+package downloader
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateZipArchivesCountsEntriesPerArchive(t *testing.T) {
+	dir := t.TempDir()
+	var entries []ArchiveEntry
+	for _, name := range []string{"a.mp4", "b.mp4", "c.mp4", "huge.mp4"} {
+		size := 1000
+		if name == "huge.mp4" {
+			size = 5000
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, ArchiveEntry{Path: path, Name: name})
+	}
+
+	// Two entries of about 2 KB each fit in an archive, the huge one fits in none
+	archives, err := CreateZipArchives(entries, filepath.Join(dir, "zip"), "playlist", 4500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 2 {
+		t.Fatalf("got %d archives, want 2", len(archives))
+	}
+	for i, want := range []int{2, 1} {
+		if archives[i].Entries != want {
+			t.Errorf("archive %d has Entries %d, want %d", i+1, archives[i].Entries, want)
+		}
+		r, err := zip.OpenReader(archives[i].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r.File) != want {
+			t.Errorf("archive %d holds %d files, want %d", i+1, len(r.File), want)
+		}
+		r.Close()
+	}
+}
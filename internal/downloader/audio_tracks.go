@@ -0,0 +1,89 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+)
+
+// AllAudioTracks is the DownloadOptions.AudioTrack that downloads every audio track as a separate file
+const AllAudioTracks = "all"
+
+// AudioFile is a downloaded audio track
+type AudioFile struct {
+	Language string
+	Path     string
+}
+
+// audioLanguagePattern matches the language tags yt-dlp reports, e.g. "en", "pt-BR" or "es-419"
+var audioLanguagePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// ValidAudioTrack reports whether a DownloadOptions.AudioTrack is AllAudioTracks or a language tag
+func ValidAudioTrack(track string) bool {
+	return track == AllAudioTracks || audioLanguagePattern.MatchString(track)
+}
+
+// ListAudioTracks probes a video and returns the languages of its audio tracks, in the order yt-dlp lists them.
+// Videos with a single audio track or without language information return no languages.
+func (d *VideoDownloader) ListAudioTracks(ctx context.Context, url string, cookiesFile string) ([]string, error) {
+	info, err := d.probe(ctx, url, cookiesFile)
+	if err != nil || info == nil {
+		return nil, err
+	}
+
+	var languages []string
+	seen := make(map[string]bool)
+	for _, format := range info.Formats {
+		// Only audio-only formats are separate tracks, muxed formats carry the default track
+		if format.VCodec != "none" || format.ACodec == "" || format.ACodec == "none" {
+			continue
+		}
+		if !audioLanguagePattern.MatchString(format.Language) || seen[format.Language] {
+			continue
+		}
+		seen[format.Language] = true
+		languages = append(languages, format.Language)
+	}
+	return languages, nil
+}
+
+// extractAllAudioTracks extracts every audio track of a video to its own file and adds them to the result.
+// The first track also becomes the result's audio file. Videos without separate tracks get the default track.
+func (d *VideoDownloader) extractAllAudioTracks(ctx context.Context, url string, opts DownloadOptions, downloadPath string, result *DownloadResult) error {
+	languages, err := d.ListAudioTracks(ctx, url, opts.CookiesFile)
+	if err != nil {
+		return err
+	}
+	if len(languages) < 2 {
+		d.log(ctx).Info("No separate audio tracks for %s, extracting the default track", url)
+		return utils.RetryWithContext(ctx, func() error {
+			var err error
+			result.AudioPath, err = d.extractAudioTrack(ctx, url, opts, "", "audio"+opts.clipSuffix(), downloadPath)
+			return err
+		}, d.retryOpts)
+	}
+
+	d.log(ctx).Info("Extracting %d audio tracks from %s", len(languages), url)
+	for i, language := range languages {
+		var audioPath string
+		err := utils.RetryWithContext(ctx, func() error {
+			var err error
+			audioPath, err = d.extractAudioTrack(ctx, url, opts, language, fmt.Sprintf("audio_%d", i+1)+opts.clipSuffix(), downloadPath)
+			return err
+		}, d.retryOpts)
+		if err != nil {
+			// Keep the tracks extracted so far
+			d.log(ctx).Warn("Failed to extract %s audio track: %v", language, err)
+			continue
+		}
+		result.AudioTracks = append(result.AudioTracks, AudioFile{Language: language, Path: audioPath})
+	}
+
+	if len(result.AudioTracks) == 0 {
+		return fmt.Errorf("no audio track of %s could be extracted", url)
+	}
+	result.AudioPath = result.AudioTracks[0].Path
+	return nil
+}
@@ -0,0 +1,210 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// States of the circuit breaker of a site
+const (
+	BreakerClosed   = "closed"    // downloads run
+	BreakerOpen     = "open"      // downloads are refused until the cooldown ends
+	BreakerHalfOpen = "half-open" // one download tests whether the site recovered, others are refused meanwhile
+)
+
+// ErrPlatformUnavailable is returned without downloading when the downloads from a site kept failing recently
+var ErrPlatformUnavailable = errors.New("site temporarily unavailable")
+
+// PlatformUnavailableError tells which site downloads are paused for and until when
+type PlatformUnavailableError struct {
+	Platform string
+	RetryAt  time.Time
+}
+
+func (e *PlatformUnavailableError) Error() string {
+	return fmt.Sprintf("%s: %s, retrying after %s", ErrPlatformUnavailable, e.Platform, e.RetryAt.Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrPlatformUnavailable) match
+func (e *PlatformUnavailableError) Is(target error) bool {
+	return target == ErrPlatformUnavailable
+}
+
+// BreakerStatus is the state of the circuit breaker of a site
+type BreakerStatus struct {
+	Platform string
+	State    string
+	Failures int       // consecutive failures counted
+	RetryAt  time.Time // when an open breaker lets a download test the site again
+}
+
+// platformAliases maps hosts to the site they belong to, for sites with several domains
+var platformAliases = map[string]string{
+	"youtu.be":             "youtube.com",
+	"youtube-nocookie.com": "youtube.com",
+	"twitter.com":          "x.com",
+	"fb.watch":             "facebook.com",
+	"vm.tiktok.com":        "tiktok.com",
+}
+
+// PlatformOf returns the site a URL belongs to, e.g. "youtube.com" for https://m.youtube.com/watch?v=...
+func PlatformOf(url string) string {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, prefix := range []string{"www.", "m.", "mobile.", "music."} {
+		host = strings.TrimPrefix(host, prefix)
+	}
+	if platform, ok := platformAliases[host]; ok {
+		return platform
+	}
+	return host
+}
+
+// circuitBreakers stop downloads from a site after repeated failures, so an outage of the site doesn't make
+// every download wait for its retries. The zero value lets every download through.
+type circuitBreakers struct {
+	mu        sync.Mutex
+	threshold int           // consecutive failures that open the breaker of a site, 0 disables the breakers
+	window    time.Duration // failures further apart than this start the count over
+	cooldown  time.Duration // how long an open breaker refuses downloads before testing the site
+	sites     map[string]*siteBreaker
+}
+
+// siteBreaker is the circuit breaker of one site
+type siteBreaker struct {
+	state       string
+	failures    int
+	lastFailure time.Time
+	openedAt    time.Time
+	testing     bool // the test download of a half-open breaker is running
+}
+
+// WithCircuitBreaker stops downloads from a site for cooldown after threshold consecutive failures, each less than
+// window after the previous one. Then one download tests whether the site recovered. A threshold of 0 disables it.
+func (d *VideoDownloader) WithCircuitBreaker(threshold int, window, cooldown time.Duration) *VideoDownloader {
+	d.breakers.mu.Lock()
+	defer d.breakers.mu.Unlock()
+
+	d.breakers.threshold = threshold
+	d.breakers.window = window
+	d.breakers.cooldown = cooldown
+	return d
+}
+
+// BreakerStatuses returns the circuit breakers of the sites with recent failures, sorted by site
+func (d *VideoDownloader) BreakerStatuses() []BreakerStatus {
+	b := &d.breakers
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var statuses []BreakerStatus
+	for platform, site := range b.sites {
+		if site.state == BreakerClosed && site.failures == 0 {
+			continue
+		}
+		status := BreakerStatus{Platform: platform, State: site.state, Failures: site.failures}
+		if site.state == BreakerOpen {
+			status.RetryAt = site.openedAt.Add(b.cooldown)
+			if !time.Now().Before(status.RetryAt) {
+				status.State = BreakerHalfOpen
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Platform < statuses[j].Platform })
+	return statuses
+}
+
+// allow returns a PlatformUnavailableError when downloads from a site are paused. Every allowed download must be
+// followed by a call to record.
+func (b *circuitBreakers) allow(platform string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || platform == "" {
+		return nil
+	}
+	site := b.site(platform)
+
+	switch site.state {
+	case BreakerOpen:
+		retryAt := site.openedAt.Add(b.cooldown)
+		if time.Now().Before(retryAt) {
+			return &PlatformUnavailableError{Platform: platform, RetryAt: retryAt}
+		}
+		// The cooldown is over, this download tests the site
+		site.state = BreakerHalfOpen
+		site.testing = true
+	case BreakerHalfOpen:
+		if site.testing {
+			return &PlatformUnavailableError{Platform: platform, RetryAt: time.Now().Add(b.cooldown)}
+		}
+		site.testing = true
+	}
+	return nil
+}
+
+// record updates the breaker of a site with the outcome of a download. Successes close it, failures of the site
+// count towards opening it, and other errors such as a removed video or a cancellation leave it as it is.
+func (b *circuitBreakers) record(platform string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || platform == "" {
+		return
+	}
+	site := b.site(platform)
+	testing := site.testing
+	site.testing = false
+
+	switch {
+	case err == nil:
+		site.state = BreakerClosed
+		site.failures = 0
+	case isOutageError(err):
+		now := time.Now()
+		if b.window > 0 && now.Sub(site.lastFailure) > b.window {
+			site.failures = 0
+		}
+		site.failures++
+		site.lastFailure = now
+
+		// A failed test opens the breaker again right away
+		if testing || site.failures >= b.threshold {
+			site.state = BreakerOpen
+			site.openedAt = now
+		}
+	}
+}
+
+// site returns the breaker of a site, creating it closed
+func (b *circuitBreakers) site(platform string) *siteBreaker {
+	if b.sites == nil {
+		b.sites = make(map[string]*siteBreaker)
+	}
+	site, ok := b.sites[platform]
+	if !ok {
+		site = &siteBreaker{state: BreakerClosed}
+		b.sites[platform] = site
+	}
+	return site
+}
+
+// isOutageError reports whether a download failed because of the site rather than the video: a yt-dlp failure
+// that retrying could have fixed, such as a network error or a timeout. Cancelled downloads don't count.
+func isOutageError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var failure *ytDlpError
+	return errors.As(err, &failure) && isRetryableError(err)
+}
@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Clipped reports whether the download is trimmed to a time range
+func (o DownloadOptions) Clipped() bool {
+	return o.ClipEnd > o.ClipStart
+}
+
+// clipSuffix returns the part of downloaded file names that records the clip's time range, e.g. "_clip_00-01-30_00-02-00"
+func (o DownloadOptions) clipSuffix() string {
+	if !o.Clipped() {
+		return ""
+	}
+	return fmt.Sprintf("_clip_%s_%s", clipTimestamp(o.ClipStart, "-"), clipTimestamp(o.ClipEnd, "-"))
+}
+
+// sectionArgs returns the yt-dlp arguments that download only the clip's time range
+func (o DownloadOptions) sectionArgs() []string {
+	if !o.Clipped() {
+		return nil
+	}
+	return []string{
+		"--download-sections", fmt.Sprintf("*%d-%d", o.ClipStart, o.ClipEnd),
+		// Cut exactly at the requested times rather than at the nearest keyframes
+		"--force-keyframes-at-cuts",
+	}
+}
+
+// clipTimestamp formats seconds as hours, minutes and seconds joined by sep
+func clipTimestamp(seconds int, sep string) string {
+	return fmt.Sprintf("%02d%s%02d%s%02d", seconds/3600, sep, seconds/60%60, sep, seconds%60)
+}
+
+// trimSubtitle cuts a subtitle to the clip's time range and shifts it to start with the clip,
+// returning the path of the trimmed subtitle
+func (d *VideoDownloader) trimSubtitle(ctx context.Context, subtitlePath string, opts DownloadOptions) (string, error) {
+	ffmpegPath := d.dependencyPaths["ffmpeg"]
+	if ffmpegPath == "" {
+		return "", errors.New("ffmpeg executable path not found")
+	}
+
+	ext := filepath.Ext(subtitlePath)
+	trimmedPath := strings.TrimSuffix(subtitlePath, ext) + opts.clipSuffix() + ext
+
+	args := []string{
+		"-y",
+		"-ss", strconv.Itoa(opts.ClipStart),
+		"-i", subtitlePath,
+		"-t", strconv.Itoa(opts.ClipEnd - opts.ClipStart),
+		trimmedPath,
+	}
+
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		d.log(ctx).Error("Subtitle trimming failed: %v, output: %s", err, string(output))
+		return "", fmt.Errorf("subtitle trimming failed: %w", err)
+	}
+
+	return trimmedPath, nil
+}
+
+// trimClipSubtitle trims a subtitle to the clip, returning an empty path if there is no subtitle or it can't be trimmed
+func (d *VideoDownloader) trimClipSubtitle(ctx context.Context, subtitlePath string, opts DownloadOptions) string {
+	if subtitlePath == "" {
+		return ""
+	}
+	trimmedPath, err := d.trimSubtitle(ctx, subtitlePath, opts)
+	if err != nil {
+		// An untrimmed subtitle would be out of sync with the clip
+		d.log(ctx).Warn("Dropping subtitle %s that couldn't be trimmed to the clip: %v", subtitlePath, err)
+		return ""
+	}
+	return trimmedPath
+}
@@ -0,0 +1,57 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoAudio is returned when the audio of a file without an audio stream is extracted
+var ErrNoAudio = errors.New("file has no audio")
+
+// audioCodecs maps the audio formats to the ffmpeg encoder and file extension they are written with
+var audioCodecs = map[string]struct{ encoder, ext string }{
+	"mp3":  {"libmp3lame", ".mp3"},
+	"m4a":  {"aac", ".m4a"},
+	"opus": {"libopus", ".opus"},
+}
+
+// ExtractAudio converts the first audio stream of a local media file, such as a video sent to the bot, to one of
+// AudioFormats with an optional bitrate from AudioBitrates. The audio is written next to the file, and its path
+// is returned.
+func (d *VideoDownloader) ExtractAudio(ctx context.Context, mediaPath string, format string, bitrate string) (string, error) {
+	ffmpegPath := d.dependencyPaths["ffmpeg"]
+	if ffmpegPath == "" {
+		return "", errors.New("ffmpeg executable path not found")
+	}
+
+	codec, ok := audioCodecs[format]
+	if !ok {
+		codec = audioCodecs["mp3"]
+	}
+	audioPath := filepath.Join(filepath.Dir(mediaPath), "audio"+codec.ext)
+
+	args := []string{"-y", "-i", mediaPath, "-map", "0:a:0", "-vn", "-c:a", codec.encoder}
+	if bitrate != "" {
+		args = append(args, "-b:a", bitrate)
+	}
+	args = append(args, audioPath)
+
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		os.Remove(audioPath)
+		if strings.Contains(string(output), "matches no streams") {
+			return "", ErrNoAudio
+		}
+		d.log(ctx).Error("Audio extraction failed: %v, output: %s", err, string(output))
+		return "", fmt.Errorf("audio extraction failed: %w", err)
+	}
+
+	d.log(ctx).Info("Extracted the audio of %s to %s", mediaPath, audioPath)
+	return audioPath, nil
+}
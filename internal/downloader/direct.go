@@ -0,0 +1,299 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+)
+
+// Kinds of media files downloaded directly
+const (
+	directVideo = "video"
+	directAudio = "audio"
+	directImage = "image"
+)
+
+// directMediaKinds maps the extensions of media files that are downloaded with a plain HTTP request to their kind
+var directMediaKinds = map[string]string{
+	".mp4":  directVideo,
+	".m4v":  directVideo,
+	".mp3":  directAudio,
+	".m4a":  directAudio,
+	".ogg":  directAudio,
+	".opus": directAudio,
+	".wav":  directAudio,
+	".flac": directAudio,
+	".jpg":  directImage,
+	".jpeg": directImage,
+	".png":  directImage,
+	".gif":  directImage,
+	".webp": directImage,
+}
+
+// directHeaderTimeout is how long a server has to start answering a direct download, the body is only limited
+// by the download's context
+const directHeaderTimeout = 30 * time.Second
+
+// ErrFileTooLarge is returned when a directly downloaded file is larger than the configured maximum
+var ErrFileTooLarge = errors.New("file too large")
+
+// directMedia describes a media file found at a URL
+type directMedia struct {
+	kind string // directVideo, directAudio or directImage
+	ext  string // extension the file is saved with
+	size int64  // from the Content-Length header, 0 when unknown
+}
+
+// directMediaExt returns the extension of a URL's path when it is one of a media file, empty otherwise
+func directMediaExt(url string) string {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return ""
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	if _, ok := directMediaKinds[ext]; !ok {
+		return ""
+	}
+	return ext
+}
+
+// directMediaName returns the file name of a media file URL, shown as its title
+func directMediaName(url string) string {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return url
+	}
+	return path.Base(u.Path)
+}
+
+// IsDirectMediaURL reports whether a URL points to a media file by its extension, e.g. https://example.com/clip.mp4
+func IsDirectMediaURL(url string) bool {
+	return directMediaExt(url) != ""
+}
+
+// WithMaxDirectSize sets the size above which direct downloads are aborted, 0 removes the limit
+func (d *VideoDownloader) WithMaxDirectSize(maxSize int64) *VideoDownloader {
+	d.maxDirectSize = maxSize
+	return d
+}
+
+// newDirectClient creates the client of direct downloads, going through the given proxy unless it is empty
+func newDirectClient(proxy string) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = directHeaderTimeout
+	if proxy != "" {
+		if proxyURL, err := neturl.Parse(proxy); err == nil {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	return &http.Client{Transport: transport}
+}
+
+// probeDirectMedia checks with a HEAD request that a URL with a media extension serves a media file,
+// not e.g. an HTML page. The request has the headers of the host and the given ones, like the download.
+// Servers that don't answer HEAD requests are trusted on the extension.
+func (d *VideoDownloader) probeDirectMedia(ctx context.Context, url string, headers map[string]string) (*directMedia, bool) {
+	ext := directMediaExt(url)
+	if ext == "" {
+		return nil, false
+	}
+	media := &directMedia{kind: directMediaKinds[ext], ext: ext}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, false
+	}
+	setHeaders(req, d.hostHeaders(url))
+	setHeaders(req, headers)
+	resp, err := d.directClient.Do(req)
+	if err != nil {
+		d.log(ctx).Warn("Failed to check direct media URL %s, trying yt-dlp: %v", url, err)
+		return nil, false
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		return media, true
+	}
+	if resp.StatusCode != http.StatusOK {
+		d.log(ctx).Info("Direct media URL %s answered %s, trying yt-dlp", url, resp.Status)
+		return nil, false
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case contentType == "" || contentType == "application/octet-stream":
+		// Generic file servers don't always know the type, the extension decides
+	case strings.HasPrefix(contentType, media.kind+"/"):
+	default:
+		d.log(ctx).Info("Direct media URL %s serves %s, trying yt-dlp", url, contentType)
+		return nil, false
+	}
+
+	if resp.ContentLength > 0 {
+		media.size = resp.ContentLength
+	}
+	return media, true
+}
+
+// directMediaFor returns the media file to download directly for a URL, if the download can be done that way.
+// Clips and audio extracted from videos need yt-dlp and ffmpeg.
+func (d *VideoDownloader) directMediaFor(ctx context.Context, url string, opts DownloadOptions) (*directMedia, bool) {
+	if !IsDirectMediaURL(url) || opts.Clipped() {
+		return nil, false
+	}
+	media, ok := d.probeDirectMedia(ctx, url, opts.Headers)
+	if !ok || (opts.AudioOnly && media.kind != directAudio) {
+		return nil, false
+	}
+	return media, true
+}
+
+// downloadDirect downloads a media file with a plain HTTP request and fills in the result
+func (d *VideoDownloader) downloadDirect(ctx context.Context, url string, media *directMedia, opts DownloadOptions, downloadPath string, result *DownloadResult) (*DownloadResult, error) {
+	d.log(ctx).Info("Downloading %s file directly from %s", media.kind, url)
+
+	if d.maxDirectSize > 0 && media.size > d.maxDirectSize {
+		os.RemoveAll(downloadPath)
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d bytes", ErrFileTooLarge, media.size, d.maxDirectSize)
+	}
+
+	var filePath string
+	switch media.kind {
+	case directVideo:
+		filePath = filepath.Join(downloadPath, "video_base.mp4")
+	case directAudio:
+		filePath = filepath.Join(downloadPath, "audio"+media.ext)
+	default:
+		filePath = filepath.Join(downloadPath, "image"+media.ext)
+	}
+
+	err := utils.RetryWithContext(ctx, func() error {
+		return d.fetchFile(ctx, url, filePath, opts.Headers, opts.OnProgress)
+	}, d.downloadRetryOptions(opts))
+
+	if err := d.checkCancelled(ctx, downloadPath); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file after %d retries: %w", d.retryOpts.MaxRetries, err)
+	}
+
+	if size, err := FileSize(filePath); err == nil {
+		result.FileSize = size
+	}
+
+	switch media.kind {
+	case directVideo:
+		result.VideoPath = filePath
+		result.Duration = d.getVideoDuration(ctx, filePath)
+		if err := d.extractThumbnail(ctx, filePath, downloadPath); err != nil {
+			d.log(ctx).Warn("Failed to extract thumbnail from video: %v", err)
+		} else if thumbnailPath := filepath.Join(downloadPath, "thumbnail.png"); fileExists(thumbnailPath) {
+			result.ThumbnailPath = thumbnailPath
+		}
+	case directAudio:
+		result.AudioPath = filePath
+		result.Duration = d.getVideoDuration(ctx, filePath)
+	default:
+		result.ImagePath = filePath
+	}
+
+	return result, nil
+}
+
+// fetchFile downloads a URL to a file with the headers of its host and the given ones, reporting the progress when
+// the size is known.
+// Files over the maximum direct download size are removed and fail permanently.
+func (d *VideoDownloader) fetchFile(ctx context.Context, url string, filePath string, headers map[string]string, onProgress func(percent float64)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return utils.Permanent(fmt.Errorf("invalid direct download URL: %w", err))
+	}
+	setHeaders(req, d.hostHeaders(url))
+	setHeaders(req, headers)
+
+	resp, err := d.directClient.Do(req)
+	if err != nil {
+		if d.proxy != "" && isProxyError(err.Error()) {
+			return utils.Permanent(fmt.Errorf("%w: %v", ErrProxyUnreachable, err))
+		}
+		return fmt.Errorf("direct download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return utils.Permanent(fmt.Errorf("%w: %s", ErrAuthRequired, resp.Status))
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return utils.Permanent(fmt.Errorf("direct download failed: %s", resp.Status))
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("direct download failed: %s", resp.Status)
+	}
+
+	if d.maxDirectSize > 0 && resp.ContentLength > d.maxDirectSize {
+		return utils.Permanent(fmt.Errorf("%w: %d bytes, the limit is %d bytes", ErrFileTooLarge, resp.ContentLength, d.maxDirectSize))
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return utils.Permanent(fmt.Errorf("failed to create file: %w", err))
+	}
+
+	// Read one byte past the limit to know when a file without a Content-Length is too large
+	var body io.Reader = resp.Body
+	if d.maxDirectSize > 0 {
+		body = io.LimitReader(resp.Body, d.maxDirectSize+1)
+	}
+	var writer io.Writer = file
+	if onProgress != nil && resp.ContentLength > 0 {
+		writer = &sizeProgressWriter{w: file, total: resp.ContentLength, onProgress: onProgress}
+	}
+
+	written, err := io.Copy(writer, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && d.maxDirectSize > 0 && written > d.maxDirectSize {
+		err = utils.Permanent(fmt.Errorf("%w: more than %d bytes", ErrFileTooLarge, d.maxDirectSize))
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return err
+	}
+	return nil
+}
+
+// setHeaders sets HTTP headers of a request, replacing those it already has
+func setHeaders(req *http.Request, headers map[string]string) {
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// sizeProgressWriter reports the share of a file of known size written so far
+type sizeProgressWriter struct {
+	w          io.Writer
+	total      int64
+	written    int64
+	onProgress func(percent float64)
+}
+
+// Write writes to the underlying writer and reports the progress
+func (p *sizeProgressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.onProgress(float64(p.written) * 100 / float64(p.total))
+	return n, err
+}
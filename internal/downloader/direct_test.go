@@ -0,0 +1,38 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+)
+
+func TestDirectMediaProbeSendsTheDownloadHeaders(t *testing.T) {
+	var gotMethod, gotReferer string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotReferer = r.Method, r.Header.Get("Referer")
+		w.Header().Set("Content-Type", "video/mp4")
+	}))
+	defer server.Close()
+
+	// The test server is reached without a proxy of the environment
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		t.Setenv(name, "")
+	}
+	logger, err := utils.NewEnhancedLogger(&utils.EnhancedLoggerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewVideoDownloader(t.TempDir(), logger, 0, nil, "")
+
+	opts := DownloadOptions{Headers: map[string]string{"Referer": "https://example.com/watch"}}
+	media, ok := d.directMediaFor(context.Background(), server.URL+"/video.mp4", opts)
+	if !ok || media.kind != directVideo {
+		t.Fatalf("directMediaFor() = %+v, %t, want a video", media, ok)
+	}
+	if gotMethod != http.MethodHead || gotReferer != opts.Headers["Referer"] {
+		t.Errorf("probe sent %s with the referer %q, want HEAD with %q", gotMethod, gotReferer, opts.Headers["Referer"])
+	}
+}
@@ -4,23 +4,45 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	neturl "net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"errors" // Make sure errors is imported
 
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/metrics"
 	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
 )
 
+// ErrAuthRequired is returned when a video needs to be signed in to, or the cookies used have expired
+var ErrAuthRequired = errors.New("authentication required or cookies expired")
+
+// ErrProxyUnreachable is returned when the configured proxy can't be connected to
+var ErrProxyUnreachable = errors.New("proxy unreachable")
+
+// ErrNoSmallerFormat is returned when a video has no format expected to fit in a size limit
+var ErrNoSmallerFormat = errors.New("no smaller format fits the size limit")
+
 // VideoDownloader handles video downloading and processing
 type VideoDownloader struct {
 	downloadDir     string
 	logger          *utils.EnhancedLogger
 	retryOpts       *utils.RetryOptions
-	dependencyPaths map[string]string // New field to store paths
+	dependencyPaths map[string]string      // New field to store paths
+	cookiesFile     string                 // cookies used for all downloads unless a download has its own
+	proxy           string                 // proxy passed to yt-dlp and aria2c, empty for direct connections
+	directClient    *http.Client           // client of direct downloads, going through the proxy
+	hostOptions     map[string]HostOptions // per-host overrides of the download options
+	maxDirectSize   int64                  // size above which direct media file downloads are aborted, 0 for no limit
+	translator      SubtitleTranslator     // translates subtitles from other languages, nil when not configured
+	probeCache      probeCache             // metadata of recently probed videos
+	breakers        circuitBreakers        // pause downloads from sites that keep failing
+	metrics         *metrics.Collector     // records the duration of downloads, nil when metrics are disabled
 }
 
 // DownloadResult contains paths to downloaded files
@@ -30,10 +52,58 @@ type DownloadResult struct {
 	AudioPath        string
 	SubtitlePath     string
 	HasSubtitle      bool
+	SubtitleSource   string // one of SubtitleUploaded, SubtitleAutomatic or SubtitleTranslated, empty when unknown
+	SubtitleFrom     string // language the subtitle was translated from, only set for SubtitleTranslated
 	FileSize         int64
 	Duration         int
 	Error            error
 	ThumbnailPath    string
+	ImagePath        string // set instead of VideoPath for links to image files
+	InfoJSONPath     string
+	DescriptionPath  string
+	AudioTracks      []AudioFile // every audio track as a separate file, only set for DownloadOptions.AudioTrack AllAudioTracks
+	Metadata         VideoMetadata
+}
+
+// VideoMetadata describes a downloaded video as its site reports it, with empty fields when unknown
+type VideoMetadata struct {
+	ID       string // ID of the video on its site
+	Title    string
+	Uploader string
+}
+
+// DownloadOptions controls what is downloaded besides the video
+type DownloadOptions struct {
+	BurnLang        string                        // language of the subtitles burned into the video
+	FileLang        string                        // language of the subtitle file
+	IncludeMetadata bool                          // also write yt-dlp's info JSON and description files
+	OnProgress      func(percent float64)         // called with the progress of the video download, may be nil
+	OnRetry         func(attempt, maxRetries int) // called when the video or audio download failed and is retried, may be nil
+	CookiesFile     string                        // cookies to authenticate with, overrides the configured cookies file
+	AudioOnly       bool                          // download only the audio track, skipping the video and subtitles
+	AudioFormat     string                        // one of AudioFormats, defaults to mp3
+	AudioBitrate    string                        // one of AudioBitrates, empty keeps yt-dlp's default quality
+	AudioTrack      string                        // language of the audio track to download, AllAudioTracks for all of them, empty for the default track
+	ClipStart       int                           // start of the time range to download in seconds, used with ClipEnd
+	ClipEnd         int                           // end of the time range to download in seconds, zero downloads the whole video
+	CorrelationID   string                        // ID of the download request added to every log line, empty for none
+	Headers         map[string]string             // HTTP headers of the video and audio downloads, override those of the host
+	TranslateSubs   bool                          // translate a subtitle from another language when there is none in the wanted one
+	MaxHeight       int                           // highest video height to download, e.g. 720, 0 for the best available
+
+	host HostOptions // options of the URL's host, resolved by Download
+}
+
+// AudioFormats are the formats audio can be extracted to
+var AudioFormats = []string{"mp3", "m4a", "opus"}
+
+// AudioBitrates are the bitrates audio can be extracted with
+var AudioBitrates = []string{"128K", "192K", "320K"}
+
+// PlaylistProgress receives progress updates while a playlist is downloaded
+type PlaylistProgress struct {
+	OnStart func(index, total int)                                    // before an entry is downloaded
+	OnDone  func(index, total int, result *DownloadResult, err error) // after an entry was downloaded or failed
 }
 
 // getCookiePath dynamically generates the absolute path to the cookie file for a given domain
@@ -46,21 +116,62 @@ func getCookiePath(domain string) string {
 }
 
 // NewVideoDownloader creates a new video downloader
-// Modified to accept dependencyPaths
-func NewVideoDownloader(downloadDir string, logger *utils.EnhancedLogger, maxRetries int, dependencyPaths map[string]string) *VideoDownloader {
+// Modified to accept dependencyPaths. The proxy falls back to the HTTPS_PROXY and HTTP_PROXY environment variables.
+func NewVideoDownloader(downloadDir string, logger *utils.EnhancedLogger, maxRetries int, dependencyPaths map[string]string, proxy string) *VideoDownloader {
 	retryOpts := utils.DefaultRetryOptions().
 		WithMaxRetries(maxRetries).
-		WithLogger(logger)
+		WithLogger(logger).
+		WithIsRetryable(isRetryableError)
+
+	if proxy == "" {
+		proxy = proxyFromEnv()
+	}
+	if proxy != "" {
+		logger.Info("Using proxy for downloads: %s", redactProxy(proxy))
+	}
 
 	return &VideoDownloader{
 		downloadDir:     downloadDir,
 		logger:          logger,
 		retryOpts:       retryOpts,
 		dependencyPaths: dependencyPaths, // Store the paths
+		proxy:           proxy,
+		directClient:    newDirectClient(proxy),
+	}
+}
+
+// proxyFromEnv returns the proxy set through the standard environment variables
+func proxyFromEnv() string {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		if proxy := os.Getenv(name); proxy != "" {
+			return proxy
+		}
+	}
+	return ""
+}
+
+// redactProxy hides the password of a proxy URL so it can be logged
+func redactProxy(proxy string) string {
+	parsed, err := neturl.Parse(proxy)
+	if err != nil {
+		return "<invalid proxy URL>"
 	}
+	return parsed.Redacted()
+}
+
+// isHTTPProxy checks if the proxy is an HTTP proxy, the only kind aria2c supports
+func (d *VideoDownloader) isHTTPProxy() bool {
+	proxy := strings.ToLower(d.proxy)
+	return strings.HasPrefix(proxy, "http://") || strings.HasPrefix(proxy, "https://")
 }
 
-func (d *VideoDownloader) getCookiesArgs(url string) []string {
+// WithCookiesFile sets the cookies file passed to yt-dlp for every download
+func (d *VideoDownloader) WithCookiesFile(cookiesFile string) *VideoDownloader {
+	d.cookiesFile = cookiesFile
+	return d
+}
+
+func (d *VideoDownloader) getCookiesArgs(url string, cookiesFile string) []string {
 	domainCookies := map[string]string{
 		"tiktok.com": "tiktok",
 		"twitter.com": "twitter",
@@ -84,6 +195,22 @@ func (d *VideoDownloader) getCookiesArgs(url string) []string {
 		"--geo-bypass-country", "US",
 		"--user-agent", userAgent,
 	}
+	if d.proxy != "" {
+		args = append(args, "--proxy", d.proxy)
+	}
+	args = append(args, headerArgs(d.hostHeaders(url))...)
+
+	// Cookies of the download or the configured cookies file take precedence over the per-domain files
+	if cookiesFile == "" {
+		cookiesFile = d.cookiesFile
+	}
+	if cookiesFile != "" {
+		if fileExists(cookiesFile) {
+			d.logger.Info("Using cookies file: %s", cookiesFile)
+			return append(args, "--cookies", cookiesFile)
+		}
+		d.logger.Warn("Cookies file not found, falling back to per-domain cookies: %s", cookiesFile)
+	}
 
 	for domain, cookieName := range domainCookies {
 		if strings.Contains(url, domain) {
@@ -109,8 +236,63 @@ func fileExists(path string) bool {
 	return err == nil
 }
 
-// Download downloads a video and returns paths to the downloaded files
-func (d *VideoDownloader) Download(ctx context.Context, url string, captionLang string) (*DownloadResult, error) {
+// FileSize returns the size of a file in bytes
+func FileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// log returns the logger of an operation, which carries its correlation ID when it has one
+func (d *VideoDownloader) log(ctx context.Context) *utils.EnhancedLogger {
+	return utils.LoggerFromContext(ctx, d.logger)
+}
+
+// withCorrelation returns a context whose logger adds the correlation ID of a download to every line
+func (d *VideoDownloader) withCorrelation(ctx context.Context, opts DownloadOptions) context.Context {
+	// A playlist entry is logged with the logger of its playlist
+	if opts.CorrelationID == "" || utils.LoggerFromContext(ctx, nil) != nil {
+		return ctx
+	}
+	return utils.ContextWithLogger(ctx, d.logger.With(map[string]interface{}{"correlation_id": opts.CorrelationID}))
+}
+
+// Download downloads a video and returns paths to the downloaded files.
+// It returns a PlatformUnavailableError without downloading while the circuit breaker of the video's site is open.
+func (d *VideoDownloader) Download(ctx context.Context, url string, opts DownloadOptions) (*DownloadResult, error) {
+	ctx = d.withCorrelation(ctx, opts)
+
+	platform := PlatformOf(url)
+	if err := d.breakers.allow(platform); err != nil {
+		d.log(ctx).Warn("Not downloading %s: %v", url, err)
+		return nil, err
+	}
+	start := time.Now()
+	result, err := d.download(ctx, url, opts)
+	d.breakers.record(platform, err)
+	if err == nil {
+		d.metrics.ObserveDownload(platform, time.Since(start))
+	}
+	return result, err
+}
+
+// WithMetrics records the duration of successful downloads in a collector, nil records nothing
+func (d *VideoDownloader) WithMetrics(collector *metrics.Collector) *VideoDownloader {
+	d.metrics = collector
+	return d
+}
+
+// download downloads a video regardless of the circuit breaker of its site
+func (d *VideoDownloader) download(ctx context.Context, url string, opts DownloadOptions) (*DownloadResult, error) {
+
+	for name, value := range opts.Headers {
+		if err := utils.ValidateHeader(name, value); err != nil {
+			return nil, fmt.Errorf("invalid download header: %w", err)
+		}
+	}
+
 	// Create a unique download directory for this request
 	downloadID := fmt.Sprintf("%d", time.Now().UnixNano())
 	downloadPath := filepath.Join(d.downloadDir, downloadID)
@@ -128,34 +310,57 @@ func (d *VideoDownloader) Download(ctx context.Context, url string, captionLang
 
 	result := &DownloadResult{}
 
+	// Host-specific options take precedence over the user's preferences and the defaults
+	if host, ok := d.resolveHostOptions(url); ok {
+		d.log(ctx).Info("Using host-specific download options for %s", url)
+		opts.host = host
+	}
+
+	// Links to media files are fetched as they are, yt-dlp would only wrap them in its generic extractor
+	if media, ok := d.directMediaFor(ctx, url, opts); ok {
+		return d.downloadDirect(ctx, url, media, opts, downloadPath, result)
+	}
+
 	// Download thumbnail
-	d.logger.Info("Downloading high-resolution PNG thumbnail from %s", url)
+	d.log(ctx).Info("Downloading high-resolution PNG thumbnail from %s", url)
 	err := utils.RetryWithContext(ctx, func() error {
-		return d.downloadThumbnail(ctx, url, downloadPath)
+		return d.downloadThumbnail(ctx, url, opts.CookiesFile, downloadPath)
 	}, d.retryOpts)
 
+	if err := d.checkCancelled(ctx, downloadPath); err != nil {
+		return nil, err
+	}
+
 	if err != nil {
-		d.logger.Warn("Failed to download thumbnail: %v", err)
+		d.log(ctx).Warn("Failed to download thumbnail: %v", err)
 		// Continue without thumbnail
 	} else {
 		thumbnailPath := filepath.Join(downloadPath, "thumbnail.png")
 		if fileExists(thumbnailPath) {
 			result.ThumbnailPath = thumbnailPath
-			d.logger.Info("Successfully downloaded high-resolution PNG thumbnail to %s", thumbnailPath)
+			d.log(ctx).Info("Successfully downloaded high-resolution PNG thumbnail to %s", thumbnailPath)
 		}
 	}
 
+	if opts.AudioOnly {
+		return d.downloadAudioOnly(ctx, url, opts, downloadPath, result)
+	}
+
 	// Download primary video (best video + best audio merged)
-	d.logger.Info("Downloading primary video from %s", url)
+	d.log(ctx).Info("Downloading primary video from %s", url)
 	err = utils.RetryWithContext(ctx, func() error {
-		return d.downloadPrimaryVideo(ctx, url, downloadPath)
-	}, d.retryOpts)
+		return d.downloadPrimaryVideo(ctx, url, opts, downloadPath)
+	}, d.downloadRetryOptions(opts))
+
+	if err := d.checkCancelled(ctx, downloadPath); err != nil {
+		return nil, err
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to download primary video after %d retries: %w", d.retryOpts.MaxRetries, err)
 	}
 
-	result.VideoPath = filepath.Join(downloadPath, "video_base.mp4")
+	result.VideoPath = filepath.Join(downloadPath, "video_base"+opts.clipSuffix()+".mp4")
 
 	// Get file size
 	fileInfo, err := os.Stat(result.VideoPath)
@@ -163,78 +368,289 @@ func (d *VideoDownloader) Download(ctx context.Context, url string, captionLang
 		result.FileSize = fileInfo.Size()
 	}
 
-	// Download subtitle if available
-	d.logger.Info("Downloading subtitle in language %s from %s", captionLang, url)
-	var subtitlePath string
-	err = utils.RetryWithContext(ctx, func() error {
-		var err error
-		subtitlePath, err = d.downloadSubtitle(ctx, url, captionLang, downloadPath)
-		return err
-	}, d.retryOpts)
-
+	// Download subtitle file if available
+	d.log(ctx).Info("Downloading subtitle in language %s from %s", opts.FileLang, url)
+	info := d.subtitleInfo(ctx, url, opts)
+	subtitle, err := d.fetchSubtitle(ctx, url, opts, opts.FileLang, "subtitle", downloadPath, info)
 	if err != nil {
-		d.logger.Warn("Failed to download subtitle after %d retries: %v", d.retryOpts.MaxRetries, err)
+		d.log(ctx).Warn("Failed to download subtitle after %d retries: %v", d.retryOpts.MaxRetries, err)
 		// Continue without subtitle
-	} else if subtitlePath != "" {
-		result.SubtitlePath = subtitlePath
+	} else if subtitle.path != "" {
+		result.SubtitlePath = subtitle.path
 		result.HasSubtitle = true
+		result.SubtitleSource, result.SubtitleFrom = subtitle.source, subtitle.from
+	}
 
+	// The subtitle burned into the video may be in a different language than the subtitle file
+	burnSubtitlePath := result.SubtitlePath
+	if opts.BurnLang != opts.FileLang {
+		d.log(ctx).Info("Downloading subtitle to burn in language %s from %s", opts.BurnLang, url)
+		burnSubtitle, err := d.fetchSubtitle(ctx, url, opts, opts.BurnLang, "burn_subtitle", downloadPath, info)
+		burnSubtitlePath = burnSubtitle.path
+		if err != nil {
+			d.log(ctx).Warn("Failed to download subtitle to burn after %d retries: %v", d.retryOpts.MaxRetries, err)
+			burnSubtitlePath = ""
+		}
+	}
+
+	// The subtitles of a clip have to start with it to stay in sync
+	if opts.Clipped() {
+		result.SubtitlePath, burnSubtitlePath = d.trimClipSubtitle(ctx, result.SubtitlePath, opts), d.trimClipSubtitle(ctx, burnSubtitlePath, opts)
+		result.HasSubtitle = result.SubtitlePath != ""
+	}
+
+	if burnSubtitlePath != "" {
 		// Embed subtitle into video
-		d.logger.Info("Embedding subtitle into video")
+		d.log(ctx).Info("Embedding subtitle into video")
 		err := utils.RetryWithContext(ctx, func() error {
-			return d.embedSubtitle(ctx, result.VideoPath, subtitlePath, downloadPath)
+			return d.embedSubtitle(ctx, result.VideoPath, burnSubtitlePath, filepath.Join(downloadPath, "video_final"+opts.clipSuffix()+".mp4"))
 		}, d.retryOpts)
 
 		if err != nil {
-			d.logger.Warn("Failed to embed subtitle after %d retries: %v", d.retryOpts.MaxRetries, err)
+			d.log(ctx).Warn("Failed to embed subtitle after %d retries: %v", d.retryOpts.MaxRetries, err)
 			// Continue without embedded subtitle
 		} else {
-			result.VideoWithSubPath = filepath.Join(downloadPath, "video_final.mp4")
+			result.VideoWithSubPath = filepath.Join(downloadPath, "video_final"+opts.clipSuffix()+".mp4")
 		}
 	}
 
+	if err := d.checkCancelled(ctx, downloadPath); err != nil {
+		return nil, err
+	}
+
 	// Extract audio
-	d.logger.Info("Extracting audio from %s", url)
-	err = utils.RetryWithContext(ctx, func() error {
-		return d.extractAudio(ctx, url, downloadPath)
-	}, d.retryOpts)
+	d.log(ctx).Info("Extracting audio from %s", url)
+	if opts.AudioTrack == AllAudioTracks {
+		err = d.extractAllAudioTracks(ctx, url, opts, downloadPath, result)
+	} else {
+		var audioPath string
+		err = utils.RetryWithContext(ctx, func() error {
+			var err error
+			audioPath, err = d.extractAudio(ctx, url, opts, downloadPath)
+			return err
+		}, d.retryOpts)
+		result.AudioPath = audioPath
+	}
 
 	if err != nil {
-		d.logger.Warn("Failed to extract audio after %d retries: %v", d.retryOpts.MaxRetries, err)
+		d.log(ctx).Warn("Failed to extract audio after %d retries: %v", d.retryOpts.MaxRetries, err)
 		// Continue without audio
-	} else {
-		result.AudioPath = filepath.Join(downloadPath, "audio.mp3")
+	}
+
+	if err := d.checkCancelled(ctx, downloadPath); err != nil {
+		return nil, err
+	}
+
+	// Write metadata files if requested
+	if opts.IncludeMetadata {
+		d.writeMetadata(ctx, url, opts.CookiesFile, downloadPath, result)
 	}
 
 	// Get video duration
-	result.Duration = d.getVideoDuration(result.VideoPath)
+	result.Duration = d.getVideoDuration(ctx, result.VideoPath)
 
 	// If thumbnail wasn't downloaded, extract it from the video
 	if result.ThumbnailPath == "" && result.VideoPath != "" {
-		d.logger.Info("Extracting high-resolution PNG thumbnail from video")
+		d.log(ctx).Info("Extracting high-resolution PNG thumbnail from video")
 		err := d.extractThumbnail(ctx, result.VideoPath, downloadPath)
 		if err != nil {
-			d.logger.Warn("Failed to extract thumbnail from video: %v", err)
+			d.log(ctx).Warn("Failed to extract thumbnail from video: %v", err)
 		} else {
 			thumbnailPath := filepath.Join(downloadPath, "thumbnail.png")
 			if fileExists(thumbnailPath) {
 				result.ThumbnailPath = thumbnailPath
-				d.logger.Info("Successfully extracted high-resolution PNG thumbnail from video to %s", thumbnailPath)
+				d.log(ctx).Info("Successfully extracted high-resolution PNG thumbnail from video to %s", thumbnailPath)
 			}
 		}
 	}
 
+	// Embed the thumbnail and the video's title and uploader so media players show them
+	d.embedCoverAndTags(ctx, url, opts, result)
+	if size, err := FileSize(result.VideoPath); err == nil {
+		result.FileSize = size
+	}
+
 	return result, nil
 }
 
+// URLValidation is the outcome of checking a URL with a simulated yt-dlp run
+type URLValidation struct {
+	Valid    bool
+	Title    string
+	Duration int   // in seconds, 0 when unknown
+	Size     int64 // estimated size in bytes, 0 when unknown
+}
+
+// ValidateURL checks with a simulated yt-dlp run that a URL can be downloaded and returns the video title
+// along with its estimated duration and size.
+// An unsupported or unavailable URL is reported as invalid without an error, an error means the check itself failed.
+func (d *VideoDownloader) ValidateURL(ctx context.Context, url string) (*URLValidation, error) {
+	if media, ok := d.probeDirectMedia(ctx, url, nil); ok {
+		return &URLValidation{Valid: true, Title: directMediaName(url), Size: media.size}, nil
+	}
+
+	ytDlpPath := d.dependencyPaths["yt-dlp"]
+	if ytDlpPath == "" {
+		return nil, errors.New("yt-dlp executable path not found")
+	}
+
+	args := d.getCookiesArgs(url, "")
+	args = append(args,
+		"--simulate",
+		"--quiet",
+		"--no-warnings",
+		"--no-playlist",
+		"--print", "%(duration|0)s\t%(filesize,filesize_approx|0)s\t%(title)s",
+		url,
+	)
+
+	cmd := exec.CommandContext(ctx, ytDlpPath, args...)
+	output, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("url validation interrupted: %w", ctx.Err())
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if isAuthError(string(exitErr.Stderr)) {
+			return nil, ErrAuthRequired
+		}
+		if d.proxy != "" && isProxyError(string(exitErr.Stderr)) {
+			return nil, ErrProxyUnreachable
+		}
+		d.log(ctx).Info("URL %s is not downloadable: %s", url, strings.TrimSpace(string(exitErr.Stderr)))
+		return &URLValidation{Valid: false}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("url validation failed: %w", err)
+	}
+
+	line := strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+	fields := strings.SplitN(line, "\t", 3)
+	if len(fields) < 3 {
+		return &URLValidation{Valid: true, Title: line}, nil
+	}
+
+	validation := &URLValidation{Valid: true, Title: fields[2]}
+	if duration, err := strconv.ParseFloat(fields[0], 64); err == nil {
+		validation.Duration = int(duration)
+	}
+	if size, err := strconv.ParseFloat(fields[1], 64); err == nil {
+		validation.Size = int64(size)
+	}
+	return validation, nil
+}
+
+// IsPlaylistURL checks if a URL points to a YouTube playlist rather than a single video
+func IsPlaylistURL(url string) bool {
+	parsed, err := neturl.Parse(url)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	if !strings.HasSuffix(host, "youtube.com") {
+		return false
+	}
+
+	// Links to a video opened from a playlist also carry list=, only treat them as playlists without a video ID
+	query := parsed.Query()
+	return query.Get("list") != "" && (parsed.Path == "/playlist" || query.Get("v") == "")
+}
+
+// ListPlaylistEntries returns the video URLs of the first maxItems entries of a playlist
+func (d *VideoDownloader) ListPlaylistEntries(ctx context.Context, url string, cookiesFile string, maxItems int) ([]string, error) {
+	ytDlpPath := d.dependencyPaths["yt-dlp"]
+	if ytDlpPath == "" {
+		return nil, errors.New("yt-dlp executable path not found")
+	}
+
+	args := d.getCookiesArgs(url, cookiesFile)
+	args = append(args,
+		"--flat-playlist",
+		"--playlist-end", strconv.Itoa(maxItems),
+		"--print", "url",
+		url,
+	)
+
+	cmd := exec.CommandContext(ctx, ytDlpPath, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		d.log(ctx).Error("Listing playlist entries failed: %v", err)
+		return nil, fmt.Errorf("listing playlist entries failed: %w", err)
+	}
+
+	var entries []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "http") {
+			entries = append(entries, line)
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no entries found in playlist")
+	}
+	return entries, nil
+}
+
+// DownloadPlaylist downloads up to maxItems entries of a playlist one after another.
+// Failed entries are reported through progress and skipped, the successful results are returned.
+func (d *VideoDownloader) DownloadPlaylist(ctx context.Context, url string, opts DownloadOptions, maxItems int, progress PlaylistProgress) ([]*DownloadResult, error) {
+	ctx = d.withCorrelation(ctx, opts)
+	entries, err := d.ListPlaylistEntries(ctx, url, opts.CookiesFile, maxItems)
+	if err != nil {
+		return nil, err
+	}
+
+	d.log(ctx).Info("Downloading %d entries of playlist %s", len(entries), url)
+
+	var results []*DownloadResult
+	for i, entry := range entries {
+		if ctx.Err() != nil {
+			return results, fmt.Errorf("playlist download cancelled: %w", ctx.Err())
+		}
+
+		if progress.OnStart != nil {
+			progress.OnStart(i+1, len(entries))
+		}
+
+		result, err := d.Download(ctx, entry, opts)
+		if err != nil {
+			d.log(ctx).Warn("Failed to download playlist entry %d/%d (%s): %v", i+1, len(entries), entry, err)
+		} else {
+			results = append(results, result)
+		}
+
+		if progress.OnDone != nil {
+			progress.OnDone(i+1, len(entries), result, err)
+		}
+	}
+
+	return results, nil
+}
+
+// checkCancelled removes the partial download and returns an error if the context was cancelled
+func (d *VideoDownloader) checkCancelled(ctx context.Context, downloadPath string) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+
+	d.log(ctx).Info("Download cancelled, removing partial files in %s", downloadPath)
+	if err := os.RemoveAll(downloadPath); err != nil {
+		d.log(ctx).Warn("Failed to remove cancelled download directory %s: %v", downloadPath, err)
+	}
+	return fmt.Errorf("download cancelled: %w", ctx.Err())
+}
+
 // downloadThumbnail downloads the thumbnail for the video
-func (d *VideoDownloader) downloadThumbnail(ctx context.Context, url string, downloadPath string) error {
+func (d *VideoDownloader) downloadThumbnail(ctx context.Context, url string, cookiesFile string, downloadPath string) error {
 	ytDlpPath := d.dependencyPaths["yt-dlp"]
 	if ytDlpPath == "" {
 		return errors.New("yt-dlp executable path not found")
 	}
 
-	args := d.getCookiesArgs(url)
+	args := d.getCookiesArgs(url, cookiesFile)
 	args = append(args,
 		"--skip-download",
 		"--write-thumbnail",
@@ -249,8 +665,8 @@ func (d *VideoDownloader) downloadThumbnail(ctx context.Context, url string, dow
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {
-		d.logger.Error("Thumbnail download failed: %v, output: %s", err, string(output))
-		return fmt.Errorf("thumbnail download failed: %w", err)
+		d.log(ctx).Error("Thumbnail download failed: %v, output: %s", err, string(output))
+		return fmt.Errorf("thumbnail download failed: %w", ytDlpFailure(err, output))
 	}
 
 	// Find all downloaded thumbnails (they should now be .image or .webp)
@@ -300,11 +716,11 @@ func (d *VideoDownloader) downloadThumbnail(ctx context.Context, url string, dow
 		ffmpegOutput, ffmpegErr := ffmpegCmd.CombinedOutput()
 
 		if ffmpegErr != nil {
-			d.logger.Error("Manual WEBP/Image to PNG conversion failed: %v, output: %s", ffmpegErr, string(ffmpegOutput))
+			d.log(ctx).Error("Manual WEBP/Image to PNG conversion failed: %v, output: %s", ffmpegErr, string(ffmpegOutput))
 			return fmt.Errorf("manual WEBP/Image to PNG conversion failed: %w", ffmpegErr)
 		}
 
-		d.logger.Info("Successfully converted %s to %s", largestThumbnail, newPath)
+		d.log(ctx).Info("Successfully converted %s to %s", largestThumbnail, newPath)
 
 		// Remove other thumbnails and the original largestThumbnail to save space
 		for _, file := range files {
@@ -343,60 +759,165 @@ func (d *VideoDownloader) extractThumbnail(ctx context.Context, videoPath string
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {
-		d.logger.Error("Thumbnail extraction failed: %v, output: %s", err, string(output))
+		d.log(ctx).Error("Thumbnail extraction failed: %v, output: %s", err, string(output))
 		return fmt.Errorf("thumbnail extraction failed: %w", err)
 	}
 
 	return nil
 }
 
-// downloadPrimaryVideo downloads the best video + best audio merged
-func (d *VideoDownloader) downloadPrimaryVideo(ctx context.Context, url string, downloadPath string) error {
+// downloadPrimaryVideo downloads the best video + best audio merged, with the audio track in the requested language
+// if there is one and trimmed to the requested clip
+func (d *VideoDownloader) downloadPrimaryVideo(ctx context.Context, url string, opts DownloadOptions, downloadPath string) error {
+	cookiesFile, audioTrack, onProgress := opts.CookiesFile, opts.AudioTrack, opts.OnProgress
+	outputPath := filepath.Join(downloadPath, "video_base"+opts.clipSuffix()+".mp4")
+
 	ytDlpPath := d.dependencyPaths["yt-dlp"]
 	aria2cPath := d.dependencyPaths["aria2c"]
 	if ytDlpPath == "" || aria2cPath == "" {
 		return errors.New("yt-dlp or aria2c executable path not found")
 	}
 
-	args := d.getCookiesArgs(url)
+	aria2cArgs := "-x 16 -s 16 -k 1M --async-dns=false --async-dns-server=8.8.8.8,1.1.1.1 --summary-interval=1"
+	if d.isHTTPProxy() {
+		// SOCKS proxies aren't supported by aria2c, those downloads fall back to the direct method below
+		aria2cArgs += " --all-proxy=" + d.proxy
+	}
+
+	height := opts.heightFilter()
+	format := fmt.Sprintf("bv*[vcodec^=avc]%s+ba/best[ext=mp4][vcodec^=avc]%s", height, height)
+	if audioTrack != "" && audioTrack != AllAudioTracks {
+		// Fall back to the default track when the language isn't available
+		format = fmt.Sprintf("bv*[vcodec^=avc]%s+ba[language=%s]/%s", height, audioTrack, format)
+	}
+	if opts.host.Format != "" {
+		format = opts.host.Format
+	}
+
+	args := d.getCookiesArgs(url, cookiesFile)
 	args = append(args,
-		"-f", "bv*[vcodec^=avc]+ba/best[ext=mp4][vcodec^=avc]",
+		"-f", format,
 		"--merge-output-format", "mp4",
 		"--external-downloader", aria2cPath, // Use the stored path
-		"--external-downloader-args", "-x 16 -s 16 -k 1M --async-dns=false --async-dns-server=8.8.8.8,1.1.1.1",
-		"-o", filepath.Join(downloadPath, "video_base.mp4"),
+		"--external-downloader-args", aria2cArgs,
+		"--newline",
+	)
+	args = append(args, opts.sectionArgs()...)
+	args = append(args, headerArgs(opts.Headers)...)
+	args = append(args, opts.host.ExtraArgs...)
+	args = append(args,
+		"-o", outputPath,
 		url,
 	)
 
 	cmd := exec.CommandContext(ctx, ytDlpPath, args...) // Use the stored path
-	output, err := cmd.CombinedOutput()
+	output, err := runWithProgress(cmd, onProgress)
 
 	if err != nil {
-		d.logger.Warn("aria2c download failed, trying direct download: %v, output: %s", err, string(output))
+		d.log(ctx).Warn("aria2c download failed, trying direct download: %v, output: %s", err, string(output))
 
 		// Try direct download without aria2c
-		directArgs := d.getCookiesArgs(url)
+		directArgs := d.getCookiesArgs(url, cookiesFile)
 		directArgs = append(directArgs,
-			"-f", "bv*[vcodec^=avc]+ba/best[ext=mp4][vcodec^=avc]",
+			"-f", format,
 			"--merge-output-format", "mp4",
-			"-o", filepath.Join(downloadPath, "video_base.mp4"),
+			"--newline",
+		)
+		directArgs = append(directArgs, opts.sectionArgs()...)
+		directArgs = append(directArgs, headerArgs(opts.Headers)...)
+		directArgs = append(directArgs, opts.host.ExtraArgs...)
+		directArgs = append(directArgs,
+			"-o", outputPath,
 			url,
 		)
 
 		directCmd := exec.CommandContext(ctx, ytDlpPath, directArgs...) // Use the stored path
-		directOutput, directErr := directCmd.CombinedOutput()
+		directOutput, directErr := runWithProgress(directCmd, onProgress)
 
 		if directErr != nil {
-			d.logger.Error("Direct download also failed: %v, output: %s", directErr, string(directOutput))
-			return fmt.Errorf("video download failed with both aria2c and direct methods: %w", directErr)
+			d.log(ctx).Error("Direct download also failed: %v, output: %s", directErr, string(directOutput))
+
+			// Retrying won't help until the user signs in or refreshes their cookies
+			if isAuthError(string(directOutput)) {
+				return utils.Permanent(fmt.Errorf("%w: %v", ErrAuthRequired, directErr))
+			}
+			if d.proxy != "" && isProxyError(string(directOutput)) {
+				return utils.Permanent(fmt.Errorf("%w: %v", ErrProxyUnreachable, directErr))
+			}
+			return fmt.Errorf("video download failed with both aria2c and direct methods: %w", ytDlpFailure(directErr, directOutput))
 		}
 	}
 
 	return nil
 }
 
-// downloadSubtitle downloads the subtitle in the specified language
-func (d *VideoDownloader) downloadSubtitle(ctx context.Context, url string, lang string, downloadPath string) (string, error) {
+// isAuthError checks if yt-dlp output reports that the video needs signing in or that the cookies expired
+func isAuthError(output string) bool {
+	output = strings.ToLower(output)
+	for _, marker := range []string{
+		"sign in to confirm",
+		"login required",
+		"private video",
+		"cookies are no longer valid",
+		"use --cookies",
+	} {
+		if strings.Contains(output, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// isProxyError checks if yt-dlp output reports that the proxy couldn't be connected to
+func isProxyError(output string) bool {
+	output = strings.ToLower(output)
+	for _, marker := range []string{
+		"proxyerror",
+		"unable to connect to proxy",
+		"cannot connect to proxy",
+		"tunnel connection failed",
+		// SOCKS errors of yt-dlp and of urllib3, video titles and URLs may well mention socks
+		"socks4error",
+		"socks5error",
+		"socks server",
+		"socks proxy",
+		"sockshttpconnectionpool",
+		"sockshttpsconnectionpool",
+		"missing dependencies for socks support",
+	} {
+		if strings.Contains(output, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// runWithProgress runs a command and returns its combined output, reporting download progress if onProgress is set
+func runWithProgress(cmd *exec.Cmd, onProgress func(percent float64)) ([]byte, error) {
+	if onProgress == nil {
+		return cmd.CombinedOutput()
+	}
+
+	writer := newProgressWriter(onProgress)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	err := cmd.Run()
+	return writer.Output(), err
+}
+
+// downloadSubtitleWithRetry downloads the subtitle in the specified language, retrying on failure
+func (d *VideoDownloader) downloadSubtitleWithRetry(ctx context.Context, url string, cookiesFile string, lang string, name string, downloadPath string) (string, error) {
+	var subtitlePath string
+	err := utils.RetryWithContext(ctx, func() error {
+		var err error
+		subtitlePath, err = d.downloadSubtitle(ctx, url, cookiesFile, lang, name, downloadPath)
+		return err
+	}, d.retryOpts)
+	return subtitlePath, err
+}
+
+// downloadSubtitle downloads the subtitle in the specified language, saving it as name.<language>.<ext>
+func (d *VideoDownloader) downloadSubtitle(ctx context.Context, url string, cookiesFile string, lang string, name string, downloadPath string) (string, error) {
 	ytDlpPath := d.dependencyPaths["yt-dlp"]
 	if ytDlpPath == "" {
 		return "", errors.New("yt-dlp executable path not found")
@@ -405,21 +926,21 @@ func (d *VideoDownloader) downloadSubtitle(ctx context.Context, url string, lang
 	// First, check available subtitles
 	availableSubs, err := d.listAvailableSubtitles(ctx, url)
 	if err != nil {
-		d.logger.Warn("Failed to list available subtitles: %v", err)
+		d.log(ctx).Warn("Failed to list available subtitles: %v", err)
 		// Continue with download attempt anyway
 	} else {
-		d.logger.Info("Available subtitles: %s", availableSubs)
+		d.log(ctx).Info("Available subtitles: %s", availableSubs)
 	}
 
 	// Improved subtitle download arguments
-	args := d.getCookiesArgs(url)
+	args := d.getCookiesArgs(url, cookiesFile)
 	args = append(args,
 		"--skip-download",
 		"--write-subs",
 		"--write-auto-sub",
 		"--sub-lang", lang,
 		"--sub-format", "srt/vtt",
-		"-o", filepath.Join(downloadPath, "subtitle.%(language)s.%(ext)s"),
+		"-o", filepath.Join(downloadPath, name+".%(language)s.%(ext)s"),
 		url,
 	)
 
@@ -428,31 +949,31 @@ func (d *VideoDownloader) downloadSubtitle(ctx context.Context, url string, lang
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {
-		d.logger.Error("Subtitle download failed: %v, output: %s", err, string(output))
-		return "", fmt.Errorf("subtitle download failed: %w", err)
+		d.log(ctx).Error("Subtitle download failed: %v, output: %s", err, string(output))
+		return "", fmt.Errorf("subtitle download failed: %w", ytDlpFailure(err, output))
 	}
 
 	// Check if subtitle was downloaded
 	outputStr := string(output)
 	if strings.Contains(outputStr, "There are no subtitles") ||
 		strings.Contains(outputStr, "Subtitle not available") {
-		d.logger.Info("No subtitles available in language %s", lang)
+		d.log(ctx).Info("No subtitles available in language %s", lang)
 		return "", nil
 	}
 
 	// Look for subtitle files with more flexible patterns
 	// First try the expected language-specific pattern
 	subtitlePatterns := []string{
-		filepath.Join(downloadPath, fmt.Sprintf("subtitle.%s.srt", lang)),
-		filepath.Join(downloadPath, fmt.Sprintf("subtitle.%s.vtt", lang)),
-		filepath.Join(downloadPath, "subtitle.srt"),
-		filepath.Join(downloadPath, "subtitle.vtt"),
+		filepath.Join(downloadPath, fmt.Sprintf("%s.%s.srt", name, lang)),
+		filepath.Join(downloadPath, fmt.Sprintf("%s.%s.vtt", name, lang)),
+		filepath.Join(downloadPath, name+".srt"),
+		filepath.Join(downloadPath, name+".vtt"),
 	}
 
 	// Also check for auto-generated subtitles
 	autoSubPatterns := []string{
-		filepath.Join(downloadPath, fmt.Sprintf("subtitle.%s.auto.srt", lang)),
-		filepath.Join(downloadPath, fmt.Sprintf("subtitle.%s.auto.vtt", lang)),
+		filepath.Join(downloadPath, fmt.Sprintf("%s.%s.auto.srt", name, lang)),
+		filepath.Join(downloadPath, fmt.Sprintf("%s.%s.auto.vtt", name, lang)),
 	}
 
 	// Combine all patterns
@@ -461,19 +982,19 @@ func (d *VideoDownloader) downloadSubtitle(ctx context.Context, url string, lang
 	// Try to find any matching subtitle file
 	for _, pattern := range allPatterns {
 		if fileExists(pattern) {
-			d.logger.Info("Successfully found subtitle at %s", pattern)
+			d.log(ctx).Info("Successfully found subtitle at %s", pattern)
 			return pattern, nil
 		}
 	}
 
 	// If we still haven't found anything, try a more general glob search
-	files, err := filepath.Glob(filepath.Join(downloadPath, "subtitle.*"))
+	files, err := filepath.Glob(filepath.Join(downloadPath, name+".*"))
 	if err == nil && len(files) > 0 {
-		d.logger.Info("Found subtitle using glob search: %s", files[0])
+		d.log(ctx).Info("Found subtitle using glob search: %s", files[0])
 		return files[0], nil
 	}
 
-	d.logger.Warn("Subtitle file not found despite successful download")
+	d.log(ctx).Warn("Subtitle file not found despite successful download")
 	return "", fmt.Errorf("subtitle file not found")
 }
 
@@ -499,15 +1020,13 @@ func (d *VideoDownloader) listAvailableSubtitles(ctx context.Context, url string
 	return string(output), nil
 }
 
-// embedSubtitle embeds the subtitle into the video
-func (d *VideoDownloader) embedSubtitle(ctx context.Context, videoPath string, subtitlePath string, downloadPath string) error {
+// embedSubtitle burns the subtitle into the video, writing the result to outputPath
+func (d *VideoDownloader) embedSubtitle(ctx context.Context, videoPath string, subtitlePath string, outputPath string) error {
 	ffmpegPath := d.dependencyPaths["ffmpeg"]
 	if ffmpegPath == "" {
 		return errors.New("ffmpeg executable path not found")
 	}
 
-	outputPath := filepath.Join(downloadPath, "video_final.mp4")
-
 	args := []string{
 		"-i", videoPath,
 		"-vf", fmt.Sprintf("subtitles=%s", subtitlePath),
@@ -519,27 +1038,131 @@ func (d *VideoDownloader) embedSubtitle(ctx context.Context, videoPath string, s
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {
-		d.logger.Error("Subtitle embedding failed: %v, output: %s", err, string(output))
+		d.log(ctx).Error("Subtitle embedding failed: %v, output: %s", err, string(output))
 		return fmt.Errorf("subtitle embedding failed: %w", err)
 	}
 
-	d.logger.Info("Successfully embedded subtitle into video at %s", outputPath)
+	d.log(ctx).Info("Successfully embedded subtitle into video at %s", outputPath)
 	return nil
 }
 
-// extractAudio extracts the audio from the video
-func (d *VideoDownloader) extractAudio(ctx context.Context, url string, downloadPath string) error {
+// downloadRetryOptions returns the retry options of the main download, reporting retries through opts.OnRetry
+func (d *VideoDownloader) downloadRetryOptions(opts DownloadOptions) *utils.RetryOptions {
+	if opts.OnRetry == nil {
+		return d.retryOpts
+	}
+
+	retryOpts := *d.retryOpts
+	return retryOpts.WithOnRetry(func(attempt int, wait time.Duration, err error) {
+		opts.OnRetry(attempt, retryOpts.MaxRetries)
+	})
+}
+
+// downloadAudioOnly downloads only the audio track of a video, for downloads with DownloadOptions.AudioOnly
+func (d *VideoDownloader) downloadAudioOnly(ctx context.Context, url string, opts DownloadOptions, downloadPath string, result *DownloadResult) (*DownloadResult, error) {
+	d.log(ctx).Info("Downloading audio only from %s", url)
+	var err error
+	if opts.AudioTrack == AllAudioTracks {
+		err = d.extractAllAudioTracks(ctx, url, opts, downloadPath, result)
+	} else {
+		err = utils.RetryWithContext(ctx, func() error {
+			var err error
+			result.AudioPath, err = d.extractAudio(ctx, url, opts, downloadPath)
+			return err
+		}, d.downloadRetryOptions(opts))
+	}
+
+	if err := d.checkCancelled(ctx, downloadPath); err != nil {
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to download audio after %d retries: %w", d.retryOpts.MaxRetries, err)
+	}
+
+	if opts.IncludeMetadata {
+		d.writeMetadata(ctx, url, opts.CookiesFile, downloadPath, result)
+	}
+	d.embedCoverAndTags(ctx, url, opts, result)
+
+	for _, track := range result.AudioTracks {
+		if size, err := FileSize(track.Path); err == nil {
+			result.FileSize += size
+		}
+	}
+	if len(result.AudioTracks) == 0 {
+		if size, err := FileSize(result.AudioPath); err == nil {
+			result.FileSize = size
+		}
+	}
+	result.Duration = d.getVideoDuration(ctx, result.AudioPath)
+
+	return result, nil
+}
+
+// writeMetadata writes the metadata files of a video and adds their paths to the result, failures are only logged
+func (d *VideoDownloader) writeMetadata(ctx context.Context, url string, cookiesFile string, downloadPath string, result *DownloadResult) {
+	d.log(ctx).Info("Writing metadata files for %s", url)
+	err := utils.RetryWithContext(ctx, func() error {
+		return d.downloadMetadata(ctx, url, cookiesFile, downloadPath)
+	}, d.retryOpts)
+
+	if err != nil {
+		d.log(ctx).Warn("Failed to write metadata files after %d retries: %v", d.retryOpts.MaxRetries, err)
+		// Continue without metadata
+		return
+	}
+
+	if infoJSONPath := filepath.Join(downloadPath, "metadata.info.json"); fileExists(infoJSONPath) {
+		result.InfoJSONPath = infoJSONPath
+	}
+	if descriptionPath := filepath.Join(downloadPath, "metadata.description"); fileExists(descriptionPath) {
+		result.DescriptionPath = descriptionPath
+	}
+}
+
+// extractAudio extracts the audio from the video in the requested format and bitrate, returning the audio file path
+func (d *VideoDownloader) extractAudio(ctx context.Context, url string, opts DownloadOptions, downloadPath string) (string, error) {
+	language := opts.AudioTrack
+	if language == AllAudioTracks {
+		language = ""
+	}
+	return d.extractAudioTrack(ctx, url, opts, language, "audio"+opts.clipSuffix(), downloadPath)
+}
+
+// extractAudioTrack extracts the audio track in a language, or the default track for an empty language,
+// to the named file and returns its path
+func (d *VideoDownloader) extractAudioTrack(ctx context.Context, url string, opts DownloadOptions, language string, name string, downloadPath string) (string, error) {
 	ytDlpPath := d.dependencyPaths["yt-dlp"]
 	if ytDlpPath == "" {
-		return errors.New("yt-dlp executable path not found")
+		return "", errors.New("yt-dlp executable path not found")
 	}
 
-	args := d.getCookiesArgs(url)
+	format := opts.AudioFormat
+	if format == "" {
+		format = "mp3"
+	}
+
+	selector := "ba"
+	if language != "" {
+		selector = fmt.Sprintf("ba[language=%s]/ba", language)
+	}
+
+	args := d.getCookiesArgs(url, opts.CookiesFile)
 	args = append(args,
-		"-f", "ba",
+		"-f", selector,
+		"--no-playlist",
 		"--extract-audio",
-		"--audio-format", "mp3",
-		"-o", filepath.Join(downloadPath, "audio.%(ext)s"),
+		"--audio-format", format,
+	)
+	if opts.AudioBitrate != "" {
+		args = append(args, "--audio-quality", opts.AudioBitrate)
+	}
+	args = append(args, opts.sectionArgs()...)
+	args = append(args, headerArgs(opts.Headers)...)
+	args = append(args, opts.host.ExtraArgs...)
+	args = append(args,
+		"-o", filepath.Join(downloadPath, name+".%(ext)s"),
 		url,
 	)
 
@@ -547,19 +1170,56 @@ func (d *VideoDownloader) extractAudio(ctx context.Context, url string, download
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {
-		d.logger.Error("Audio extraction failed: %v, output: %s", err, string(output))
-		return fmt.Errorf("audio extraction failed: %w", err)
+		d.log(ctx).Error("Audio extraction failed: %v, output: %s", err, string(output))
+
+		// Retrying won't help until the user signs in or refreshes their cookies
+		if isAuthError(string(output)) {
+			return "", utils.Permanent(fmt.Errorf("%w: %v", ErrAuthRequired, err))
+		}
+		if d.proxy != "" && isProxyError(string(output)) {
+			return "", utils.Permanent(fmt.Errorf("%w: %v", ErrProxyUnreachable, err))
+		}
+		return "", fmt.Errorf("audio extraction failed: %w", ytDlpFailure(err, output))
+	}
+
+	audioPath := filepath.Join(downloadPath, name+"."+format)
+	d.log(ctx).Info("Successfully extracted audio to %s", audioPath)
+	return audioPath, nil
+}
+
+// downloadMetadata writes the video's info JSON and description using yt-dlp
+func (d *VideoDownloader) downloadMetadata(ctx context.Context, url string, cookiesFile string, downloadPath string) error {
+	ytDlpPath := d.dependencyPaths["yt-dlp"]
+	if ytDlpPath == "" {
+		return errors.New("yt-dlp executable path not found")
+	}
+
+	args := d.getCookiesArgs(url, cookiesFile)
+	args = append(args,
+		"--skip-download",
+		"--write-info-json",
+		"--write-description",
+		"-o", filepath.Join(downloadPath, "metadata.%(ext)s"),
+		url,
+	)
+
+	cmd := exec.CommandContext(ctx, ytDlpPath, args...)
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		d.log(ctx).Error("Metadata download failed: %v, output: %s", err, string(output))
+		return fmt.Errorf("metadata download failed: %w", ytDlpFailure(err, output))
 	}
 
-	d.logger.Info("Successfully extracted audio to %s", filepath.Join(downloadPath, "audio.mp3"))
+	d.log(ctx).Info("Successfully wrote metadata files to %s", downloadPath)
 	return nil
 }
 
 // getVideoDuration gets the duration of a video in seconds
-func (d *VideoDownloader) getVideoDuration(videoPath string) int {
+func (d *VideoDownloader) getVideoDuration(ctx context.Context, videoPath string) int {
 	ffprobePath := d.dependencyPaths["ffprobe"] // Use ffprobe
 	if ffprobePath == "" {
-		d.logger.Warn("ffprobe executable path not found, cannot get video duration.")
+		d.log(ctx).Warn("ffprobe executable path not found, cannot get video duration.")
 		return 0
 	}
 
@@ -570,11 +1230,11 @@ func (d *VideoDownloader) getVideoDuration(videoPath string) int {
 		videoPath,
 	}
 
-	cmd := exec.Command(ffprobePath, args...) // Use the stored path
+	cmd := exec.CommandContext(ctx, ffprobePath, args...) // Use the stored path
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {
-		d.logger.Warn("Failed to get video duration: %v", err)
+		d.log(ctx).Warn("Failed to get video duration: %v", err)
 		return 0
 	}
 
@@ -583,13 +1243,97 @@ func (d *VideoDownloader) getVideoDuration(videoPath string) int {
 	var duration float64
 	_, err = fmt.Sscanf(durationStr, "%f", &duration)
 	if err != nil {
-		d.logger.Warn("Failed to parse video duration: %v", err)
+		d.log(ctx).Warn("Failed to parse video duration: %v", err)
 		return 0
 	}
 
 	return int(duration)
 }
 
+// maxSplitAttempts limits how often splitting is retried with more parts when a part is still too large
+const maxSplitAttempts = 3
+
+// SplitVideo splits a video by duration into keyframe-aligned parts that are each at most maxSize bytes
+func (d *VideoDownloader) SplitVideo(ctx context.Context, videoPath string, maxSize int64) ([]string, error) {
+	ffmpegPath := d.dependencyPaths["ffmpeg"]
+	if ffmpegPath == "" {
+		return nil, errors.New("ffmpeg executable path not found")
+	}
+
+	size, err := FileSize(videoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video size: %w", err)
+	}
+
+	duration := d.getVideoDuration(ctx, videoPath)
+	if duration <= 0 {
+		return nil, errors.New("video duration unknown, cannot split by duration")
+	}
+
+	// Aim for parts 10% below the limit since cuts can only happen on keyframes
+	target := maxSize * 9 / 10
+	parts := int((size + target - 1) / target)
+	partsDir := filepath.Join(filepath.Dir(videoPath), "parts")
+
+	for attempt := 1; attempt <= maxSplitAttempts; attempt++ {
+		if err := os.RemoveAll(partsDir); err != nil {
+			return nil, fmt.Errorf("failed to clear parts directory: %w", err)
+		}
+		if err := os.MkdirAll(partsDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create parts directory: %w", err)
+		}
+
+		segmentTime := (duration + parts - 1) / parts
+		if segmentTime < 1 {
+			segmentTime = 1
+		}
+
+		d.log(ctx).Info("Splitting %s into %d parts of about %d seconds", videoPath, parts, segmentTime)
+
+		// Stream copy makes the segment muxer cut on keyframes only, so every part plays cleanly
+		args := []string{
+			"-i", videoPath,
+			"-map", "0",
+			"-c", "copy",
+			"-f", "segment",
+			"-segment_time", strconv.Itoa(segmentTime),
+			"-reset_timestamps", "1",
+			filepath.Join(partsDir, "part_%03d.mp4"),
+		}
+
+		cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			d.log(ctx).Error("Video splitting failed: %v, output: %s", err, string(output))
+			return nil, fmt.Errorf("video splitting failed: %w", err)
+		}
+
+		files, err := filepath.Glob(filepath.Join(partsDir, "part_*.mp4"))
+		if err != nil || len(files) == 0 {
+			return nil, fmt.Errorf("no parts found after splitting")
+		}
+
+		var largest int64
+		for _, file := range files {
+			if partSize, err := FileSize(file); err == nil && partSize > largest {
+				largest = partSize
+			}
+		}
+
+		if largest <= maxSize {
+			d.log(ctx).Info("Split %s into %d parts", videoPath, len(files))
+			return files, nil
+		}
+
+		// Keyframes were too sparse for the requested part length, retry with proportionally more parts
+		d.log(ctx).Warn("Largest part is %d bytes, exceeding %d bytes, retrying with more parts", largest, maxSize)
+		parts = int(int64(parts)*largest/target) + 1
+	}
+
+	os.RemoveAll(partsDir)
+	return nil, fmt.Errorf("could not split video into parts under %d bytes after %d attempts", maxSize, maxSplitAttempts)
+}
+
 func (d *VideoDownloader) CleanupDownloads(maxAge time.Duration) error {
 	entries, err := os.ReadDir(d.downloadDir)
 	if err != nil {
@@ -0,0 +1,24 @@
+package downloader
+
+import "testing"
+
+func TestIsProxyError(t *testing.T) {
+	tests := []struct {
+		output string
+		want   bool
+	}{
+		{"ERROR: [youtube] abc: Unable to download webpage: ProxyError('Cannot connect to proxy.')", true},
+		{"ERROR: Unable to download webpage: Tunnel connection failed: 407 Proxy Authentication Required", true},
+		{"ERROR: [generic] Unable to download webpage: Socks5Error: [Errno 1] general SOCKS server failure", true},
+		{"ERROR: Socks4Error: [Errno 91] request rejected or failed", true},
+		{"ERROR: Unable to download webpage: SOCKSHTTPSConnectionPool(host='example.com', port=443): Max retries exceeded", true},
+		{"ERROR: Missing dependencies for SOCKS support.", true},
+		{"[download] Destination: Funny socks compilation.mp4\nERROR: unable to download video data: HTTP Error 403: Forbidden", false},
+		{"ERROR: [youtube] https://www.youtube.com/watch?v=socks: Video unavailable", false},
+	}
+	for _, tt := range tests {
+		if got := isProxyError(tt.output); got != tt.want {
+			t.Errorf("isProxyError(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
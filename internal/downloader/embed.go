@@ -0,0 +1,126 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// mediaTags are the metadata tags written into downloaded files
+type mediaTags struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Artist string `json:"uploader"`
+}
+
+// embedCoverAndTags embeds the thumbnail as cover art and the video's title and uploader into every
+// downloaded media file of a result, and keeps them in the result's metadata. Failures are only logged, the
+// files are sent untagged.
+func (d *VideoDownloader) embedCoverAndTags(ctx context.Context, url string, opts DownloadOptions, result *DownloadResult) {
+	tags := d.readTags(ctx, url, opts.CookiesFile, result.InfoJSONPath)
+	result.Metadata = VideoMetadata{ID: tags.ID, Title: tags.Title, Uploader: tags.Artist}
+
+	paths := []string{result.VideoPath, result.VideoWithSubPath, result.AudioPath}
+	for _, track := range result.AudioTracks {
+		if track.Path != result.AudioPath {
+			paths = append(paths, track.Path)
+		}
+	}
+
+	for _, path := range paths {
+		if path == "" || !fileExists(path) {
+			continue
+		}
+		if err := d.embedCoverAndMetadata(ctx, path, result.ThumbnailPath, tags); err != nil {
+			d.log(ctx).Warn("Failed to embed cover art and metadata into %s: %v", path, err)
+		}
+	}
+
+}
+
+// readTags reads the ID, title and uploader of a video from its info JSON, or by probing it when there is none.
+// It returns empty tags if neither is available.
+func (d *VideoDownloader) readTags(ctx context.Context, url string, cookiesFile string, infoJSONPath string) mediaTags {
+	var tags mediaTags
+	if infoJSONPath != "" {
+		data, err := os.ReadFile(infoJSONPath)
+		if err == nil && json.Unmarshal(data, &tags) == nil {
+			return tags
+		}
+	}
+
+	info, err := d.probe(ctx, url, cookiesFile)
+	if err != nil || info == nil {
+		d.log(ctx).Warn("No metadata to embed for %s: %v", url, err)
+		return tags
+	}
+	return mediaTags{ID: info.ID, Title: info.Title, Artist: info.Uploader}
+}
+
+// embedCoverAndMetadata remuxes a media file with ffmpeg to add the thumbnail as cover art and the title and
+// artist tags, without re-encoding. The cover is left out when there is no thumbnail or the container can't
+// hold one, e.g. opus.
+func (d *VideoDownloader) embedCoverAndMetadata(ctx context.Context, mediaPath string, thumbnailPath string, tags mediaTags) error {
+	ffmpegPath := d.dependencyPaths["ffmpeg"]
+	if ffmpegPath == "" {
+		return errors.New("ffmpeg executable path not found")
+	}
+
+	ext := strings.ToLower(filepath.Ext(mediaPath))
+	withCover := thumbnailPath != "" && fileExists(thumbnailPath) && (ext == ".mp4" || ext == ".m4a" || ext == ".mp3")
+	if !withCover && tags.Title == "" && tags.Artist == "" {
+		return nil
+	}
+
+	args := []string{"-y", "-i", mediaPath}
+	if withCover {
+		// The cover follows the video stream of videos and is the only picture of audio files
+		coverStream := "v:0"
+		if ext == ".mp4" {
+			coverStream = "v:1"
+		}
+		args = append(args,
+			"-i", thumbnailPath,
+			"-map", "0",
+			"-map", "1",
+			"-disposition:"+coverStream, "attached_pic",
+		)
+	} else {
+		args = append(args, "-map", "0")
+	}
+	args = append(args, "-c", "copy")
+	if tags.Title != "" {
+		args = append(args, "-metadata", "title="+tags.Title)
+	}
+	if tags.Artist != "" {
+		args = append(args, "-metadata", "artist="+tags.Artist)
+	}
+	if ext == ".mp3" {
+		args = append(args, "-id3v2_version", "3")
+	}
+
+	// Write next to the original and replace it only once ffmpeg succeeded
+	taggedPath := strings.TrimSuffix(mediaPath, filepath.Ext(mediaPath)) + ".tagged" + filepath.Ext(mediaPath)
+	args = append(args, taggedPath)
+
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		os.Remove(taggedPath)
+		d.log(ctx).Error("Embedding cover art and metadata failed: %v, output: %s", err, string(output))
+		return fmt.Errorf("embedding cover art and metadata failed: %w", err)
+	}
+
+	if err := os.Rename(taggedPath, mediaPath); err != nil {
+		os.Remove(taggedPath)
+		return fmt.Errorf("failed to replace %s with the tagged file: %w", mediaPath, err)
+	}
+
+	d.log(ctx).Info("Embedded cover art and metadata into %s", mediaPath)
+	return nil
+}
@@ -0,0 +1,101 @@
+package downloader
+
+import (
+	neturl "net/url"
+	"sort"
+	"strings"
+)
+
+// HostOptions tunes how videos from a host are downloaded. They take precedence over the user's preferences,
+// which take precedence over the global defaults.
+type HostOptions struct {
+	Format    string            // yt-dlp format selector of the video, replaces the default and the user's audio track choice
+	ExtraArgs []string          // yt-dlp arguments added last to the video and audio downloads, overriding the bot's own
+	Referer   string            // Referer header of every request to the host, defaults to its entry in defaultReferers
+	Headers   map[string]string // HTTP headers added to every request to the host, e.g. Origin
+}
+
+// defaultReferers are the referers sent to hosts that refuse downloads without one with "HTTP Error 403"
+var defaultReferers = map[string]string{
+	"vimeo.com":       "https://vimeo.com/",
+	"dailymotion.com": "https://www.dailymotion.com/",
+	"bilibili.com":    "https://www.bilibili.com/",
+	"bilibili.tv":     "https://www.bilibili.tv/",
+	"streamable.com":  "https://streamable.com/",
+	"pinterest.com":   "https://www.pinterest.com/",
+}
+
+// WithHostOptions sets the download options of hosts, keyed by host name. A host's options also apply to its subdomains.
+func (d *VideoDownloader) WithHostOptions(hostOptions map[string]HostOptions) *VideoDownloader {
+	d.hostOptions = make(map[string]HostOptions, len(hostOptions))
+	for host, options := range hostOptions {
+		d.hostOptions[strings.ToLower(strings.TrimSpace(host))] = options
+	}
+	return d
+}
+
+// resolveHostOptions returns the options of the host of a URL, the most specific host wins,
+// e.g. "music.youtube.com" over "youtube.com"
+func (d *VideoDownloader) resolveHostOptions(url string) (HostOptions, bool) {
+	return lookupHost(d.hostOptions, url)
+}
+
+// hostHeaders returns the HTTP headers sent with every request for a URL: the configured headers of its host
+// and its referer, the configured one or the default one
+func (d *VideoDownloader) hostHeaders(url string) map[string]string {
+	options, _ := d.resolveHostOptions(url)
+	referer := options.Referer
+	if referer == "" {
+		referer, _ = lookupHost(defaultReferers, url)
+	}
+
+	headers := make(map[string]string, len(options.Headers)+1)
+	for name, value := range options.Headers {
+		headers[name] = value
+	}
+	if referer != "" {
+		headers["Referer"] = referer
+	}
+	return headers
+}
+
+// headerArgs returns the yt-dlp arguments that send HTTP headers, sorted by name so the command is stable
+func headerArgs(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		args = append(args, "--add-header", name+":"+headers[name])
+	}
+	return args
+}
+
+// lookupHost returns the entry of the host of a URL, trying the full host first and then dropping one label at a time
+func lookupHost[T any](entries map[string]T, url string) (T, bool) {
+	var zero T
+	if len(entries) == 0 {
+		return zero, false
+	}
+
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return zero, false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for host != "" {
+		if entry, ok := entries[host]; ok {
+			return entry, true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return zero, false
+}
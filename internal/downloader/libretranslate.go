@@ -0,0 +1,72 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LibreTranslator translates subtitles with a LibreTranslate compatible API
+type LibreTranslator struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewLibreTranslator creates a translator for the LibreTranslate API at a base URL, e.g. https://libretranslate.com.
+// The API key is optional for self-hosted instances.
+func NewLibreTranslator(url string, apiKey string, timeout time.Duration) *LibreTranslator {
+	return &LibreTranslator{
+		url:    strings.TrimSuffix(url, "/"),
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Translate translates texts from one language to another and returns the translations in the same order
+func (t *LibreTranslator) Translate(ctx context.Context, texts []string, from, to string) ([]string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"q":       texts,
+		"source":  translatorLanguage(from),
+		"target":  translatorLanguage(to),
+		"format":  "text",
+		"api_key": t.apiKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url+"/translate", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid translation API URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("translation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		TranslatedText []string `json:"translatedText"`
+		Error          string   `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid translation response (%s): %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("translation failed: %s: %s", resp.Status, result.Error)
+	}
+	return result.TranslatedText, nil
+}
+
+// translatorLanguage returns the language code LibreTranslate knows a subtitle language by, e.g. "pt" for "pt-BR"
+func translatorLanguage(lang string) string {
+	base, _, _ := strings.Cut(lang, "-")
+	return strings.ToLower(base)
+}
@@ -0,0 +1,140 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// previewFrameTimeout bounds grabbing a frame from the remote video when the host exposes no thumbnail
+const previewFrameTimeout = 20 * time.Second
+
+// VideoPreview contains the metadata and thumbnail of a video probed without downloading it
+type VideoPreview struct {
+	Title         string
+	Uploader      string
+	Duration      int
+	ThumbnailPath string // empty when no thumbnail is available
+	Dir           string // directory holding the thumbnail, to be removed by the caller
+}
+
+// videoInfo is the subset of yt-dlp's JSON output used for previews
+type videoInfo struct {
+	ID        string  `json:"id"`
+	Title     string  `json:"title"`
+	Uploader  string  `json:"uploader"`
+	Duration  float64 `json:"duration"`
+	Thumbnail string  `json:"thumbnail"`
+	URL       string  `json:"url"`      // direct media URL, only set for single-format videos
+	Language  string  `json:"language"` // language spoken in the video, empty when the host doesn't tell
+	Formats   []struct {
+		Language string `json:"language"`
+		VCodec   string `json:"vcodec"`
+		ACodec   string `json:"acodec"`
+		Height   int    `json:"height"`
+		// Sizes in bytes, yt-dlp estimates the approximate one from the bitrate when the exact one is unknown
+		FileSize       int64   `json:"filesize"`
+		FileSizeApprox float64 `json:"filesize_approx"`
+	} `json:"formats"`
+	Subtitles         map[string]json.RawMessage `json:"subtitles"`
+	AutomaticCaptions map[string]json.RawMessage `json:"automatic_captions"`
+}
+
+// Preview probes a video and fetches its thumbnail without downloading the video.
+// It returns a nil preview if the URL can't be probed.
+func (d *VideoDownloader) Preview(ctx context.Context, url string, cookiesFile string) (*VideoPreview, error) {
+	info, err := d.probe(ctx, url, cookiesFile)
+	if err != nil || info == nil {
+		return nil, err
+	}
+
+	preview := &VideoPreview{
+		Title:    info.Title,
+		Uploader: info.Uploader,
+		Duration: int(info.Duration),
+		Dir:      filepath.Join(d.downloadDir, fmt.Sprintf("preview_%d", time.Now().UnixNano())),
+	}
+
+	if err := os.MkdirAll(preview.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create preview directory: %w", err)
+	}
+
+	thumbnailPath := filepath.Join(preview.Dir, "thumbnail.png")
+	switch {
+	case info.Thumbnail != "":
+		if err := d.downloadThumbnail(ctx, url, cookiesFile, preview.Dir); err != nil {
+			d.log(ctx).Warn("Failed to download preview thumbnail: %v", err)
+		}
+	case strings.HasPrefix(info.URL, "http"):
+		// Grabbing a single frame of a direct media URL is cheap, anything else would need the download
+		frameCtx, cancel := context.WithTimeout(ctx, previewFrameTimeout)
+		err := d.extractThumbnail(frameCtx, info.URL, preview.Dir)
+		cancel()
+		if err != nil {
+			d.log(ctx).Warn("Failed to generate preview thumbnail: %v", err)
+		}
+	}
+
+	if fileExists(thumbnailPath) {
+		preview.ThumbnailPath = thumbnailPath
+	}
+	return preview, nil
+}
+
+// probe reads the metadata of a video with yt-dlp, returning nil if the URL can't be probed.
+// Videos probed in the last probeCacheTTL aren't probed again.
+func (d *VideoDownloader) probe(ctx context.Context, url string, cookiesFile string) (*videoInfo, error) {
+	cacheKey := probeCacheKey(url, cookiesFile)
+	if info, ok := d.probeCache.get(cacheKey); ok {
+		return info, nil
+	}
+
+	ytDlpPath := d.dependencyPaths["yt-dlp"]
+	if ytDlpPath == "" {
+		return nil, errors.New("yt-dlp executable path not found")
+	}
+
+	args := d.getCookiesArgs(url, cookiesFile)
+	args = append(args,
+		"--dump-single-json",
+		"--skip-download",
+		"--quiet",
+		"--no-warnings",
+		"--no-playlist",
+		url,
+	)
+
+	cmd := exec.CommandContext(ctx, ytDlpPath, args...)
+	output, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("probe interrupted: %w", ctx.Err())
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if isAuthError(string(exitErr.Stderr)) {
+			return nil, ErrAuthRequired
+		}
+		if d.proxy != "" && isProxyError(string(exitErr.Stderr)) {
+			return nil, ErrProxyUnreachable
+		}
+		d.log(ctx).Info("URL %s can't be probed: %s", url, strings.TrimSpace(string(exitErr.Stderr)))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("probe failed: %w", err)
+	}
+
+	var info videoInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse probe output: %w", err)
+	}
+	d.probeCache.put(cacheKey, &info)
+	return &info, nil
+}
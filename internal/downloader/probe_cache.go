@@ -0,0 +1,70 @@
+package downloader
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// probeCacheTTL is how long the metadata of a probed video is reused, formats and subtitles rarely change sooner
+	probeCacheTTL = 10 * time.Minute
+	// maxProbeCacheSize is the number of probed videos kept, the oldest are dropped beyond it
+	maxProbeCacheSize = 256
+)
+
+// probeCache keeps the metadata of recently probed videos, so the buttons offered after a download don't probe
+// the same video again. The zero value is ready to use.
+type probeCache struct {
+	mu      sync.Mutex
+	entries map[string]probeCacheEntry
+}
+
+// probeCacheEntry is a probed video and when it was probed
+type probeCacheEntry struct {
+	info     *videoInfo
+	probedAt time.Time
+}
+
+// probeCacheKey returns the key of a video in the cache, cookies can change what a video offers
+func probeCacheKey(url string, cookiesFile string) string {
+	return cookiesFile + "\x00" + url
+}
+
+// get returns the metadata of a video probed less than probeCacheTTL ago
+func (c *probeCache) get(key string) (*videoInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Since(entry.probedAt) > probeCacheTTL {
+		return nil, false
+	}
+	return entry.info, true
+}
+
+// put stores the metadata of a video, dropping expired entries and then the oldest ones when the cache is full
+func (c *probeCache) put(key string, info *videoInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]probeCacheEntry)
+	}
+	if len(c.entries) >= maxProbeCacheSize {
+		var oldestKey string
+		var oldest time.Time
+		for k, entry := range c.entries {
+			if time.Since(entry.probedAt) > probeCacheTTL {
+				delete(c.entries, k)
+				continue
+			}
+			if oldestKey == "" || entry.probedAt.Before(oldest) {
+				oldestKey, oldest = k, entry.probedAt
+			}
+		}
+		if len(c.entries) >= maxProbeCacheSize {
+			delete(c.entries, oldestKey)
+		}
+	}
+	c.entries[key] = probeCacheEntry{info: info, probedAt: time.Now()}
+}
@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+var (
+	// yt-dlp: "[download]  42.3% of ~ 10.00MiB at 1.2MiB/s ETA 00:05"
+	ytDlpProgressPattern = regexp.MustCompile(`^\[download\]\s+(\d+(?:\.\d+)?)%`)
+	// aria2c: "[#2089b0 400KiB/33MiB(1%) CN:1 DL:115KiB ETA:4m51s]"
+	aria2cProgressPattern = regexp.MustCompile(`^\[#\w+ [^(]*\((\d+)%\)`)
+)
+
+// progressWriter collects the output of a download command and reports progress percentages found in it
+type progressWriter struct {
+	onProgress func(percent float64)
+	output     bytes.Buffer
+	line       []byte
+	mu         sync.Mutex
+}
+
+// newProgressWriter creates a writer reporting progress to onProgress
+func newProgressWriter(onProgress func(percent float64)) *progressWriter {
+	return &progressWriter{onProgress: onProgress}
+}
+
+// Write stores the output and parses every complete line, which may end with \r for progress readouts.
+// Only the latest progress of the output written at once is reported, after unlocking so a slow onProgress
+// doesn't hold up the command writing its output.
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	w.output.Write(p)
+	percent, found := 0.0, false
+	for _, b := range p {
+		if b == '\n' || b == '\r' {
+			if linePercent, ok := w.parseLine(); ok {
+				percent, found = linePercent, true
+			}
+			w.line = w.line[:0]
+			continue
+		}
+		w.line = append(w.line, b)
+	}
+	w.mu.Unlock()
+
+	if found {
+		w.onProgress(percent)
+	}
+	return len(p), nil
+}
+
+// Output returns everything written so far
+func (w *progressWriter) Output() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.output.Bytes()
+}
+
+// parseLine returns the progress percentage of the current line, false if it isn't a progress line
+func (w *progressWriter) parseLine() (float64, bool) {
+	line := bytes.TrimSpace(w.line)
+
+	match := ytDlpProgressPattern.FindSubmatch(line)
+	if match == nil {
+		match = aria2cProgressPattern.FindSubmatch(line)
+	}
+	if match == nil {
+		return 0, false
+	}
+
+	percent, err := strconv.ParseFloat(string(match[1]), 64)
+	if err != nil {
+		return 0, false
+	}
+	return percent, true
+}
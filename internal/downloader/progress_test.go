@@ -0,0 +1,43 @@
+package downloader
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProgressWriterReportsLatestProgress(t *testing.T) {
+	var reported []float64
+	w := newProgressWriter(func(percent float64) { reported = append(reported, percent) })
+
+	writes := []string{
+		"[youtube] abc: Downloading webpage\n",
+		"[download]  10.0% of 10.00MiB at 1.00MiB/s ETA 00:09\r[download]  20.5% of 10.00MiB at 1.00MiB/s ETA 00:08\r[down",
+		"load]  30.0% of 10.00MiB",
+		" at 1.00MiB/s ETA 00:07\r",
+		"[#2089b0 400KiB/33MiB(42%) CN:1 DL:115KiB ETA:4m51s]\n",
+		"[download] 100% of 10.00MiB\n",
+	}
+	for _, s := range writes {
+		if n, err := w.Write([]byte(s)); err != nil || n != len(s) {
+			t.Fatalf("Write(%q) = %d, %v", s, n, err)
+		}
+	}
+
+	// Of the lines completed by the same write only the last is reported, a line split over writes once it ends
+	if want := []float64{20.5, 30, 42, 100}; !reflect.DeepEqual(reported, want) {
+		t.Errorf("reported %v, want %v", reported, want)
+	}
+}
+
+func TestProgressWriterReportsWithoutTheLock(t *testing.T) {
+	var w *progressWriter
+	w = newProgressWriter(func(percent float64) {
+		// Reading the output would deadlock if progress were reported with the lock held
+		w.Output()
+	})
+
+	w.Write([]byte("[download]  50.0% of 10.00MiB\n"))
+	if got := string(w.Output()); got != "[download]  50.0% of 10.00MiB\n" {
+		t.Errorf("Output() = %q", got)
+	}
+}
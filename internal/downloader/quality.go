@@ -0,0 +1,157 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxSmallerAttempts limits how many smaller qualities are downloaded when the estimate of the first was too low
+const maxSmallerAttempts = 2
+
+// ListQualities probes a video and returns the heights of the video formats the bot can download, highest first.
+// The probe is cached, offering the qualities of a video that was just downloaded doesn't probe it again.
+func (d *VideoDownloader) ListQualities(ctx context.Context, url string, cookiesFile string) ([]int, error) {
+	info, err := d.probe(ctx, url, cookiesFile)
+	if err != nil || info == nil {
+		return nil, err
+	}
+
+	var heights []int
+	seen := make(map[int]bool)
+	for _, format := range info.Formats {
+		// Videos are downloaded in H.264 to play everywhere, other codecs aren't picked
+		if !strings.HasPrefix(format.VCodec, "avc") || format.Height <= 0 || seen[format.Height] {
+			continue
+		}
+		seen[format.Height] = true
+		heights = append(heights, format.Height)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(heights)))
+	return heights, nil
+}
+
+// heightFilter returns the yt-dlp format filter limiting the video to MaxHeight, empty when there is no limit
+func (o DownloadOptions) heightFilter() string {
+	if o.MaxHeight <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("[height<=%d]", o.MaxHeight)
+}
+
+// DownloadSmallerVideo downloads the video of a link again, for a video at videoPath too large to upload, in the
+// highest quality below it that is expected to be at most maxSize bytes. The video is downloaded into a directory
+// next to videoPath and its path is returned, or ErrNoSmallerFormat when no format is small enough.
+func (d *VideoDownloader) DownloadSmallerVideo(ctx context.Context, url string, opts DownloadOptions, videoPath string, maxSize int64) (string, error) {
+	// A format forced for the host leaves no quality to choose
+	if host, ok := d.resolveHostOptions(url); ok {
+		if host.Format != "" {
+			return "", ErrNoSmallerFormat
+		}
+		opts.host = host
+	}
+
+	info, err := d.probe(ctx, url, opts.CookiesFile)
+	if err != nil {
+		return "", err
+	}
+	if info == nil {
+		return "", ErrNoSmallerFormat
+	}
+
+	// Estimates are for the whole video, a clip is the share of it
+	scale := 1.0
+	if opts.Clipped() && info.Duration > 0 {
+		scale = float64(opts.ClipEnd-opts.ClipStart) / info.Duration
+	}
+
+	heights := fittingHeights(info, opts.MaxHeight, maxSize, scale)
+	if len(heights) > maxSmallerAttempts {
+		heights = heights[:maxSmallerAttempts]
+	}
+	for _, height := range heights {
+		d.log(ctx).Info("Downloading %s again in %dp to fit in %d bytes", url, height, maxSize)
+
+		opts.MaxHeight = height
+		downloadPath := filepath.Join(filepath.Dir(videoPath), fmt.Sprintf("%dp", height))
+		if err := os.MkdirAll(downloadPath, 0755); err != nil {
+			return "", fmt.Errorf("failed to create download directory: %w", err)
+		}
+		if err := d.downloadPrimaryVideo(ctx, url, opts, downloadPath); err != nil {
+			return "", fmt.Errorf("failed to download video in %dp: %w", height, err)
+		}
+
+		smallerPath := filepath.Join(downloadPath, "video_base"+opts.clipSuffix()+".mp4")
+		size, err := FileSize(smallerPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to get video size: %w", err)
+		}
+		if size <= maxSize {
+			return smallerPath, nil
+		}
+
+		d.log(ctx).Warn("Video in %dp is %d bytes, exceeding %d bytes", height, size, maxSize)
+		os.RemoveAll(downloadPath)
+	}
+
+	return "", ErrNoSmallerFormat
+}
+
+// fittingHeights returns the heights below maxHeight, or below the highest one when maxHeight is 0, whose video
+// and best audio are expected to be at most maxSize bytes once scaled, highest first. Formats of unknown size
+// aren't counted on.
+func fittingHeights(info *videoInfo, maxHeight int, maxSize int64, scale float64) []int {
+	// The best audio is merged into video-only formats, count the largest to stay on the safe side
+	var audioSize float64
+	for _, format := range info.Formats {
+		if format.VCodec == "none" && format.ACodec != "" && format.ACodec != "none" {
+			audioSize = max(audioSize, formatSize(format.FileSize, format.FileSizeApprox))
+		}
+	}
+
+	// The best format of a height is downloaded, so a height fits only if its largest format does
+	sizes := make(map[int]float64)
+	for _, format := range info.Formats {
+		// Videos are downloaded in H.264 to play everywhere, other codecs aren't picked
+		if !strings.HasPrefix(format.VCodec, "avc") || format.Height <= 0 {
+			continue
+		}
+		size := formatSize(format.FileSize, format.FileSizeApprox)
+		if size <= 0 {
+			sizes[format.Height] = -1
+			continue
+		}
+		if format.ACodec == "" || format.ACodec == "none" {
+			size += audioSize
+		}
+		if sizes[format.Height] >= 0 {
+			sizes[format.Height] = max(sizes[format.Height], size)
+		}
+	}
+
+	if maxHeight <= 0 {
+		for height := range sizes {
+			maxHeight = max(maxHeight, height)
+		}
+	}
+
+	var heights []int
+	for height, size := range sizes {
+		if height < maxHeight && size > 0 && size*scale <= float64(maxSize) {
+			heights = append(heights, height)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(heights)))
+	return heights
+}
+
+// formatSize returns the size of a format, its approximate size when the exact one is unknown
+func formatSize(fileSize int64, fileSizeApprox float64) float64 {
+	if fileSize > 0 {
+		return float64(fileSize)
+	}
+	return fileSizeApprox
+}
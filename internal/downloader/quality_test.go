@@ -0,0 +1,45 @@
+package downloader
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFittingHeights(t *testing.T) {
+	var info videoInfo
+	err := json.Unmarshal([]byte(`{"formats": [
+		{"vcodec": "none", "acodec": "mp4a.40.2", "filesize": 3000000},
+		{"vcodec": "avc1.640028", "acodec": "none", "height": 1080, "filesize": 90000000},
+		{"vcodec": "avc1.4d401f", "acodec": "none", "height": 720, "filesize_approx": 45000000.5},
+		{"vcodec": "avc1.4d401e", "acodec": "none", "height": 480, "filesize": 20000000},
+		{"vcodec": "avc1.4d401e", "acodec": "none", "height": 480, "filesize": 25000000},
+		{"vcodec": "avc1.4d4015", "acodec": "none", "height": 360},
+		{"vcodec": "vp9", "acodec": "none", "height": 240, "filesize": 1000000},
+		{"vcodec": "avc1.42c00d", "acodec": "mp4a.40.2", "height": 144, "filesize": 5000000}
+	]}`), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		maxHeight int
+		maxSize   int64
+		scale     float64
+		want      []int
+	}{
+		{"below the highest quality", 0, 50000000, 1, []int{720, 480, 144}},
+		{"largest format of a height with the best audio", 0, 47000000, 1, []int{480, 144}},
+		{"below the quality asked for", 480, 50000000, 1, []int{144}},
+		{"share of a clip", 0, 30000000, 0.5, []int{720, 480, 144}},
+		{"nothing fits", 0, 1000000, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fittingHeights(&info, tt.maxHeight, tt.maxSize, tt.scale); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fittingHeights(%d, %d, %g) = %v, want %v", tt.maxHeight, tt.maxSize, tt.scale, got, tt.want)
+			}
+		})
+	}
+}
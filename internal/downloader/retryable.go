@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"errors"
+	"strings"
+)
+
+// ytDlpError is a failed yt-dlp run together with the output telling why it failed
+type ytDlpError struct {
+	err    error
+	output string
+}
+
+func (e *ytDlpError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ytDlpError) Unwrap() error {
+	return e.err
+}
+
+// ytDlpFailure wraps the error of a yt-dlp run with its output, so the failure can be classified as retryable or not
+func ytDlpFailure(err error, output []byte) error {
+	return &ytDlpError{err: err, output: string(output)}
+}
+
+// isRetryableError reports whether trying a failed yt-dlp run again can help. Network errors and timeouts can,
+// videos that were removed, are blocked in the server's country or are age-restricted can't.
+// Errors without yt-dlp output are retried.
+func isRetryableError(err error) bool {
+	var failure *ytDlpError
+	if !errors.As(err, &failure) {
+		return true
+	}
+	return !isUnavailableError(failure.output)
+}
+
+// isUnavailableError checks if yt-dlp output reports that the video can't be downloaded however often it is tried
+func isUnavailableError(output string) bool {
+	output = strings.ToLower(output)
+	for _, marker := range []string{
+		"video unavailable",
+		"this video is not available",
+		"this video has been removed",
+		"this video does not exist",
+		"http error 404",
+		"http error 410",
+		"not available in your country",
+		"geo restricted",
+		"geo-restricted",
+		"age-restricted",
+		"inappropriate for some users",
+		"unsupported url",
+		"is not a valid url",
+		"due to a copyright claim",
+	} {
+		if strings.Contains(output, marker) {
+			return true
+		}
+	}
+	return false
+}
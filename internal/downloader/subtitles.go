@@ -0,0 +1,74 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SubtitleTrack is a subtitle language available for a video
+type SubtitleTrack struct {
+	Language  string
+	Automatic bool // generated or machine-translated by the host rather than uploaded
+}
+
+// ListSubtitles probes a video and returns its subtitle languages, uploaded subtitles first.
+// Automatic captions are only listed for languages without an uploaded subtitle.
+func (d *VideoDownloader) ListSubtitles(ctx context.Context, url string, cookiesFile string) ([]SubtitleTrack, error) {
+	info, err := d.probe(ctx, url, cookiesFile)
+	if err != nil || info == nil {
+		return nil, err
+	}
+
+	var tracks []SubtitleTrack
+	for _, language := range sortedLanguages(info.Subtitles) {
+		tracks = append(tracks, SubtitleTrack{Language: language})
+	}
+	for _, language := range sortedLanguages(info.AutomaticCaptions) {
+		if _, ok := info.Subtitles[language]; ok {
+			continue
+		}
+		tracks = append(tracks, SubtitleTrack{Language: language, Automatic: true})
+	}
+	return tracks, nil
+}
+
+// ValidSubtitleLanguage reports whether a subtitle language is a language tag, such as "en", "pt-BR" or "en-orig"
+func ValidSubtitleLanguage(lang string) bool {
+	return audioLanguagePattern.MatchString(strings.TrimSuffix(lang, "-orig"))
+}
+
+// sortedLanguages returns the subtitle languages of a yt-dlp subtitles map in alphabetical order
+func sortedLanguages[T any](subtitles map[string]T) []string {
+	var languages []string
+	for language := range subtitles {
+		// "live_chat" is a chat replay rather than a subtitle
+		if language == "live_chat" || !ValidSubtitleLanguage(language) {
+			continue
+		}
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// DownloadSubtitle downloads only the subtitle of a video in a language, falling back to automatic captions.
+// It returns an empty path if there is no subtitle in that language. The subtitle's directory is to be
+// removed by the caller.
+func (d *VideoDownloader) DownloadSubtitle(ctx context.Context, url string, cookiesFile string, lang string) (string, error) {
+	downloadPath := filepath.Join(d.downloadDir, fmt.Sprintf("subtitle_%d", time.Now().UnixNano()))
+	if err := os.MkdirAll(downloadPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create subtitle directory: %w", err)
+	}
+
+	subtitlePath, err := d.downloadSubtitleWithRetry(ctx, url, cookiesFile, lang, "subtitle", downloadPath)
+	if err != nil || subtitlePath == "" {
+		os.RemoveAll(downloadPath)
+		return "", err
+	}
+	return subtitlePath, nil
+}
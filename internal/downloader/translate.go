@@ -0,0 +1,198 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Sources of a downloaded subtitle
+const (
+	SubtitleUploaded   = "uploaded"   // uploaded with the video
+	SubtitleAutomatic  = "automatic"  // generated or machine-translated by the video's host
+	SubtitleTranslated = "translated" // machine-translated from another language by the SubtitleTranslator
+)
+
+// translateBatchSize is the number of subtitle cues sent to the translator at once
+const translateBatchSize = 100
+
+// cueTagPattern matches the formatting and timestamp tags inside WebVTT cues, e.g. <c> or <00:00:01.000>
+var cueTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// SubtitleTranslator machine-translates subtitle text
+type SubtitleTranslator interface {
+	// Translate translates texts from one language to another and returns the translations in the same order
+	Translate(ctx context.Context, texts []string, from, to string) ([]string, error)
+}
+
+// subtitleFile is a downloaded subtitle and where it comes from
+type subtitleFile struct {
+	path   string
+	source string // one of the Subtitle* sources, empty when unknown
+	from   string // language the subtitle was translated from, only set for SubtitleTranslated
+}
+
+// WithSubtitleTranslator sets the translator of subtitles in languages the video has none in, nil only uses the
+// captions the host translates itself
+func (d *VideoDownloader) WithSubtitleTranslator(translator SubtitleTranslator) *VideoDownloader {
+	d.translator = translator
+	return d
+}
+
+// subtitleInfo probes the subtitles a video has, to tell where a subtitle comes from and to find one to translate.
+// It returns nil when translation wasn't asked for or the video can't be probed.
+func (d *VideoDownloader) subtitleInfo(ctx context.Context, url string, opts DownloadOptions) *videoInfo {
+	if !opts.TranslateSubs {
+		return nil
+	}
+	info, err := d.probe(ctx, url, opts.CookiesFile)
+	if err != nil {
+		d.log(ctx).Warn("Failed to list the subtitles of %s, not translating them: %v", url, err)
+		return nil
+	}
+	return info
+}
+
+// fetchSubtitle downloads the subtitle of a video in a language, falling back to automatic captions. When the video
+// has neither in that language and info is set, a subtitle in another language is translated if a translator is
+// configured.
+func (d *VideoDownloader) fetchSubtitle(ctx context.Context, url string, opts DownloadOptions, lang string, name string, downloadPath string, info *videoInfo) (subtitleFile, error) {
+	if info != nil && d.translator != nil && !hasSubtitle(info.Subtitles, lang) && !hasSubtitle(info.AutomaticCaptions, lang) {
+		return d.translateSubtitle(ctx, url, opts, lang, name, downloadPath, info)
+	}
+
+	subtitlePath, err := d.downloadSubtitleWithRetry(ctx, url, opts.CookiesFile, lang, name, downloadPath)
+	if err != nil || subtitlePath == "" {
+		return subtitleFile{}, err
+	}
+
+	subtitle := subtitleFile{path: subtitlePath}
+	switch {
+	case info == nil:
+	case hasSubtitle(info.Subtitles, lang):
+		subtitle.source = SubtitleUploaded
+	default:
+		subtitle.source = SubtitleAutomatic
+	}
+	return subtitle, nil
+}
+
+// translateSubtitle downloads a subtitle of the video in another language and translates it.
+// A subtitle that can't be translated is left out rather than failing the download.
+func (d *VideoDownloader) translateSubtitle(ctx context.Context, url string, opts DownloadOptions, lang string, name string, downloadPath string, info *videoInfo) (subtitleFile, error) {
+	from := translationSource(info)
+	if from == "" {
+		d.log(ctx).Info("No subtitle to translate to %s", lang)
+		return subtitleFile{}, nil
+	}
+
+	d.log(ctx).Info("Translating subtitle from %s to %s", from, lang)
+	sourcePath, err := d.downloadSubtitleWithRetry(ctx, url, opts.CookiesFile, from, name+"_source", downloadPath)
+	if err != nil || sourcePath == "" {
+		return subtitleFile{}, err
+	}
+	defer os.Remove(sourcePath)
+
+	from = strings.TrimSuffix(from, "-orig")
+	translatedPath := filepath.Join(downloadPath, fmt.Sprintf("%s.%s%s", name, lang, filepath.Ext(sourcePath)))
+	if err := d.translateSubtitleFile(ctx, sourcePath, translatedPath, from, lang); err != nil {
+		d.log(ctx).Warn("Failed to translate subtitle from %s to %s: %v", from, lang, err)
+		return subtitleFile{}, nil
+	}
+	return subtitleFile{path: translatedPath, source: SubtitleTranslated, from: from}, nil
+}
+
+// translationSource returns the subtitle language to translate from: the video's language when it has an uploaded
+// subtitle in it, another uploaded subtitle, or else the captions generated in the video's original language
+func translationSource(info *videoInfo) string {
+	uploaded := sortedLanguages(info.Subtitles)
+	if hasSubtitle(info.Subtitles, info.Language) {
+		return info.Language
+	}
+	if len(uploaded) > 0 {
+		return uploaded[0]
+	}
+
+	for _, language := range sortedLanguages(info.AutomaticCaptions) {
+		if strings.HasSuffix(language, "-orig") {
+			return language
+		}
+	}
+	if hasSubtitle(info.AutomaticCaptions, info.Language) {
+		return info.Language
+	}
+	return ""
+}
+
+// hasSubtitle reports whether a yt-dlp subtitles map has a language
+func hasSubtitle[T any](subtitles map[string]T, lang string) bool {
+	if lang == "" {
+		return false
+	}
+	_, ok := subtitles[lang]
+	return ok
+}
+
+// translateSubtitleFile translates the cues of an SRT or WebVTT subtitle, keeping their numbers and timings.
+// The lines of a cue are translated together and written as one line.
+func (d *VideoDownloader) translateSubtitleFile(ctx context.Context, sourcePath string, targetPath string, from, to string) error {
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return err
+	}
+
+	// Subtitles are made of blocks separated by blank lines, the lines after the timing line of a cue are its text
+	blocks := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n\n")
+	var texts []string
+	var cues []int // block of each text
+	for i, block := range blocks {
+		lines := strings.Split(block, "\n")
+		timing := cueTimingLine(lines)
+		if timing < 0 {
+			continue
+		}
+		text := strings.TrimSpace(cueTagPattern.ReplaceAllString(strings.Join(lines[timing+1:], " "), ""))
+		if text == "" {
+			continue
+		}
+		texts = append(texts, text)
+		cues = append(cues, i)
+	}
+	if len(texts) == 0 {
+		return fmt.Errorf("no text found in subtitle %s", sourcePath)
+	}
+
+	translated := make([]string, 0, len(texts))
+	for start := 0; start < len(texts); start += translateBatchSize {
+		end := min(start+translateBatchSize, len(texts))
+		batch, err := d.translator.Translate(ctx, texts[start:end], from, to)
+		if err != nil {
+			return err
+		}
+		if len(batch) != end-start {
+			return fmt.Errorf("translator returned %d texts for %d", len(batch), end-start)
+		}
+		translated = append(translated, batch...)
+	}
+
+	for j, i := range cues {
+		lines := strings.Split(blocks[i], "\n")
+		timing := cueTimingLine(lines)
+		blocks[i] = strings.Join(append(lines[:timing+1], translated[j]), "\n")
+	}
+	return os.WriteFile(targetPath, []byte(strings.Join(blocks, "\n\n")), 0644)
+}
+
+// cueTimingLine returns the index of the "start --> end" line of a subtitle block, -1 for blocks that aren't cues
+// such as the WebVTT header
+func cueTimingLine(lines []string) int {
+	for i, line := range lines {
+		if strings.Contains(line, "-->") {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gopkg.in/telebot.v3"
+)
+
+const (
+	// chartDefaultDays is the range of /chart without an argument
+	chartDefaultDays = 14
+	// chartMaxDays is the widest range /chart accepts
+	chartMaxDays = 90
+	// chartBarWidth is the length of the longest bar in the chart
+	chartBarWidth = 20
+	// auditDefaultLimit is the number of actions /audit lists without an argument
+	auditDefaultLimit = 20
+	// auditMaxLimit is the most actions /audit lists
+	auditMaxLimit = 100
+	// statsDefaultDays is the range of /stats without an argument
+	statsDefaultDays = 7
+	// statsTopChats is the number of most active users /stats lists
+	statsTopChats = 5
+	// lookupMaxErrorLogs is the most error logs /lookup lists
+	lookupMaxErrorLogs = 10
+)
+
+// isAdmin checks if a chat is allowed to use admin commands
+func (h *BotHandler) isAdmin(chatID int64) bool {
+	for _, id := range h.config.Admin.ChatIDs {
+		if id == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+// handleChart handles the /chart admin command that shows downloads per day as an ASCII bar chart
+func (h *BotHandler) handleChart(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /chart command from chat ID: %d", chatID)
+
+	if !h.isAdmin(chatID) {
+		return nil
+	}
+	user := h.findUser(chatID)
+
+	days := chartDefaultDays
+	if payload := strings.TrimSpace(c.Message().Payload); payload != "" {
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 1 || n > chartMaxDays {
+			return h.send(c, h.textf(user, "chart_usage", map[string]string{"max": strconv.Itoa(chartMaxDays)}))
+		}
+		days = n
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Buckets are UTC days, the range starts at midnight UTC so the first day is complete
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+	counts, err := h.downloadRepo.CountByDay(ctx, since)
+	if err != nil {
+		return h.send(c, h.errorMessage(user, err))
+	}
+	h.audit(chatID, "chart", fmt.Sprintf("%d days", days))
+
+	return h.send(c, fmt.Sprintf("%s\n```\n%s```", h.text(user, "chart_title"), renderBarChart(counts)), telebot.ModeMarkdown)
+}
+
+// handleStats handles the /stats admin command that summarizes users and downloads
+func (h *BotHandler) handleStats(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /stats command from chat ID: %d", chatID)
+
+	if !h.isAdmin(chatID) {
+		return nil
+	}
+	user := h.findUser(chatID)
+
+	days := statsDefaultDays
+	if payload := strings.TrimSpace(c.Message().Payload); payload != "" {
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 1 || n > chartMaxDays {
+			return h.send(c, h.textf(user, "stats_usage", map[string]string{"max": strconv.Itoa(chartMaxDays)}))
+		}
+		days = n
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Like /chart, the ranges start at midnight UTC
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(days - 1))
+
+	totalUsers, activeUsers, err := h.userRepo.CountUsers(ctx, since)
+	if err != nil {
+		return h.send(c, h.errorMessage(user, err))
+	}
+	todayCounts, err := h.downloadRepo.CountByStatus(ctx, today)
+	if err != nil {
+		return h.send(c, h.errorMessage(user, err))
+	}
+	rangeCounts, err := h.downloadRepo.CountByStatus(ctx, since)
+	if err != nil {
+		return h.send(c, h.errorMessage(user, err))
+	}
+	topChats, err := h.downloadRepo.TopChats(ctx, since, statsTopChats)
+	if err != nil {
+		return h.send(c, h.errorMessage(user, err))
+	}
+	h.audit(chatID, "stats", fmt.Sprintf("%d days", days))
+
+	lines := []string{
+		h.textf(user, "stats_users", map[string]string{
+			"total":  strconv.FormatInt(totalUsers, 10),
+			"days":   strconv.Itoa(days),
+			"active": strconv.FormatInt(activeUsers, 10),
+		}),
+		"",
+		h.text(user, "stats_downloads_today") +
+			h.formatStatusCounts(todayCounts, user),
+		h.textf(user, "stats_downloads_days", map[string]string{"days": strconv.Itoa(days)}) + h.formatStatusCounts(rangeCounts, user),
+	}
+
+	if len(topChats) > 0 {
+		lines = append(lines, "", h.text(user, "stats_top_users"))
+		for i, chat := range topChats {
+			lines = append(lines, fmt.Sprintf("%d. %d - %d", i+1, chat.ChatID, chat.Count))
+		}
+	}
+
+	// Sites whose downloads failed recently, open breakers refuse downloads until they are retried
+	if breakers := h.downloader.BreakerStatuses(); len(breakers) > 0 {
+		lines = append(lines, "", h.text(user, "stats_failing_sites"))
+		for _, breaker := range breakers {
+			line := h.textf(user, "stats_breaker", map[string]string{
+				"platform": breaker.Platform,
+				"state":    breaker.State,
+				"failures": strconv.Itoa(breaker.Failures),
+			})
+			if breaker.State == downloader.BreakerOpen {
+				line += fmt.Sprintf(" (%s UTC)", breaker.RetryAt.UTC().Format("15:04:05"))
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	_, err = h.sendTo(chatID, strings.Join(lines, "\n"))
+	return err
+}
+
+// correlationIDPattern matches the correlation IDs of models.NewCorrelationID
+var correlationIDPattern = regexp.MustCompile(`^[0-9a-f]{8}$`)
+
+// handleLookup handles the /lookup admin command that finds a download, its result and its error logs by the
+// reference shown to the user or by the request ID
+func (h *BotHandler) handleLookup(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /lookup command from chat ID: %d", chatID)
+
+	if !h.isAdmin(chatID) {
+		return nil
+	}
+	user := h.findUser(chatID)
+
+	id := strings.ToLower(strings.TrimSpace(c.Message().Payload))
+	if id == "" {
+		return h.send(c, h.text(user, "lookup_usage"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var requests []*models.DownloadRequest
+	var correlationIDs []string
+	if requestID, err := primitive.ObjectIDFromHex(id); err == nil {
+		request, err := h.downloadRepo.GetDownloadRequestByID(ctx, requestID)
+		if err != nil {
+			return h.send(c, h.errorMessage(user, err))
+		}
+		if request != nil {
+			requests = append(requests, request)
+		}
+	} else if correlationIDPattern.MatchString(id) {
+		correlationIDs = append(correlationIDs, id)
+		requests, err = h.downloadRepo.FindRequestsByCorrelationID(ctx, id)
+		if err != nil {
+			return h.send(c, h.errorMessage(user, err))
+		}
+	} else {
+		return h.send(c, h.textf(user, "lookup_invalid_id", map[string]string{"id": id}))
+	}
+
+	// Error logs are matched by request too, requests from before correlation IDs only have those
+	requestIDs := make([]primitive.ObjectID, 0, len(requests))
+	for _, request := range requests {
+		requestIDs = append(requestIDs, request.ID)
+		if request.CorrelationID != "" && request.CorrelationID != id {
+			correlationIDs = append(correlationIDs, request.CorrelationID)
+		}
+	}
+	errorLogs, err := h.errorLogRepo.GetErrorLogsForRequests(ctx, correlationIDs, requestIDs, lookupMaxErrorLogs)
+	if err != nil {
+		return h.send(c, h.errorMessage(user, err))
+	}
+	h.audit(chatID, "lookup", id)
+
+	if len(requests) == 0 && len(errorLogs) == 0 {
+		return h.send(c, h.textf(user, "lookup_not_found", map[string]string{"id": id}))
+	}
+
+	var lines []string
+	for _, request := range requests {
+		line := h.textf(user, "lookup_request", map[string]string{
+			"id":      request.ID.Hex(),
+			"ref":     request.CorrelationID,
+			"chat":    strconv.FormatInt(request.ChatID, 10),
+			"url":     request.URL,
+			"status":  request.Status,
+			"created": request.CreatedAt.UTC().Format("2006-01-02 15:04:05 UTC"),
+		})
+		if request.ErrorReason != "" {
+			line += h.textf(user, "lookup_reason", map[string]string{"reason": request.ErrorReason})
+		}
+
+		result, err := h.downloadRepo.GetDownloadResultByRequestID(ctx, request.ID)
+		if err != nil {
+			return h.send(c, h.errorMessage(user, err))
+		}
+		if result != nil {
+			key := "lookup_result"
+			if !resultOnDisk(result) {
+				key = "lookup_result_deleted"
+			}
+			line += h.textf(user, key, map[string]string{
+				"id":       result.ID.Hex(),
+				"size":     fmt.Sprintf("%.1f", float64(result.FileSize)/(1024*1024)),
+				"duration": strconv.Itoa(result.Duration),
+			})
+		} else {
+			line += h.text(user, "lookup_no_result")
+		}
+		lines = append(lines, line)
+	}
+	if len(requests) == 0 {
+		lines = append(lines, h.textf(user, "lookup_no_request", map[string]string{"id": id}))
+	}
+	for _, errorLog := range errorLogs {
+		lines = append(lines, fmt.Sprintf("%s %s: %s\n%s",
+			errorLog.CreatedAt.UTC().Format("2006-01-02 15:04:05 UTC"), errorLog.Level, errorLog.Message, errorLog.Error))
+	}
+
+	// Error logs can make the lookup longer than a message
+	_, err = h.sendTo(chatID, strings.Join(lines, "\n\n"), telebot.NoPreview)
+	return err
+}
+
+// handleTranslations handles the /translations admin command that lists the keys missing from or unknown to each
+// language file, compared to the default language
+func (h *BotHandler) handleTranslations(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /translations command from chat ID: %d", chatID)
+
+	if !h.isAdmin(chatID) {
+		return nil
+	}
+	h.audit(chatID, "translations", "")
+	user := h.findUser(chatID)
+
+	result := h.lm.Validate()
+	langCodes := result.Languages()
+	if len(langCodes) == 0 {
+		return h.send(c, h.textf(user, "translations_complete", map[string]string{"lang": result.DefaultLang}))
+	}
+
+	lines := []string{h.textf(user, "translations_compared", map[string]string{"lang": result.DefaultLang})}
+	for _, langCode := range langCodes {
+		line := langCode + ":"
+		if missing := result.Missing[langCode]; len(missing) > 0 {
+			line += h.textf(user, "translations_missing", map[string]string{
+				"n":    strconv.Itoa(len(missing)),
+				"keys": strings.Join(missing, ", "),
+			})
+		}
+		if extra := result.Extra[langCode]; len(extra) > 0 {
+			line += h.textf(user, "translations_unknown", map[string]string{
+				"n":    strconv.Itoa(len(extra)),
+				"keys": strings.Join(extra, ", "),
+			})
+		}
+		lines = append(lines, line)
+	}
+
+	_, err := h.sendTo(chatID, strings.Join(lines, "\n\n"))
+	return err
+}
+
+// formatStatusCounts returns the total of download counts followed by the count of each status, e.g.
+// "12 (completed 10, failed 2)"
+func (h *BotHandler) formatStatusCounts(counts map[string]int64, user *models.User) string {
+	var total int64
+	statuses := make([]string, 0, len(counts))
+	for status, count := range counts {
+		total += count
+		statuses = append(statuses, status)
+	}
+	if total == 0 {
+		return "0"
+	}
+	sort.Strings(statuses)
+
+	parts := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		parts = append(parts, fmt.Sprintf("%s %d", h.statusLabel(status, user), counts[status]))
+	}
+	return fmt.Sprintf("%d (%s)", total, strings.Join(parts, ", "))
+}
+
+// audit records an admin action in the audit log, failures are only logged
+func (h *BotHandler) audit(adminID int64, action string, target string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := h.auditRepo.LogAction(ctx, models.NewAuditLog(adminID, action, target)); err != nil {
+		h.logger.Error("Error recording admin action %s by chat ID %d: %v", action, adminID, err)
+	}
+}
+
+// handleAudit handles the /audit admin command that lists the latest admin actions
+func (h *BotHandler) handleAudit(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /audit command from chat ID: %d", chatID)
+
+	if !h.isAdmin(chatID) {
+		return nil
+	}
+	user := h.findUser(chatID)
+
+	limit := auditDefaultLimit
+	if payload := strings.TrimSpace(c.Message().Payload); payload != "" {
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 1 || n > auditMaxLimit {
+			return h.send(c, h.textf(user, "audit_usage", map[string]string{"max": strconv.Itoa(auditMaxLimit)}))
+		}
+		limit = n
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	logs, err := h.auditRepo.GetRecentActions(ctx, int64(limit))
+	if err != nil {
+		return h.send(c, h.errorMessage(user, err))
+	}
+	if len(logs) == 0 {
+		return h.send(c, h.text(user, "audit_none"))
+	}
+
+	lines := []string{h.text(user, "audit_title")}
+	for _, entry := range logs {
+		line := fmt.Sprintf("%s  %d  %s", entry.CreatedAt.UTC().Format("2006-01-02 15:04"), entry.AdminID, entry.Action)
+		if entry.Target != "" {
+			line += " " + entry.Target
+		}
+		lines = append(lines, line)
+	}
+	_, err = h.sendTo(chatID, strings.Join(lines, "\n"))
+	return err
+}
+
+// renderBarChart renders counts keyed by day as one bar per line, oldest day first
+func renderBarChart(counts map[string]int64) string {
+	dayKeys := make([]string, 0, len(counts))
+	var max int64
+	for day, count := range counts {
+		dayKeys = append(dayKeys, day)
+		if count > max {
+			max = count
+		}
+	}
+	sort.Strings(dayKeys)
+
+	var chart strings.Builder
+	for _, day := range dayKeys {
+		count := counts[day]
+		width := 0
+		if max > 0 {
+			width = int(count * chartBarWidth / max)
+		}
+		if width == 0 && count > 0 {
+			width = 1 // Keep days with a few downloads visible next to busy ones
+		}
+		fmt.Fprintf(&chart, "%s %-*s %d\n", day[5:], chartBarWidth, strings.Repeat("#", width), count)
+	}
+	return chart.String()
+}
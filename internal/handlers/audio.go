@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"gopkg.in/telebot.v3"
+)
+
+// handleAudio handles the /audio command that downloads only the audio of a video, in the user's preferred format
+func (h *BotHandler) handleAudio(c telebot.Context) error {
+	chatID := c.Chat().ID
+	url := strings.TrimSpace(c.Message().Payload)
+	h.logger.Info("Received /audio command from chat ID %d: %s", chatID, url)
+
+	user := h.findUser(chatID)
+
+	if !isValidURL(url) {
+		return h.send(c, h.text(user, "audio_usage"))
+	}
+	if !h.isAllowedHost(url) {
+		return h.send(c, h.unsupportedSiteMessage(user))
+	}
+	if h.isPlaylistDownload(url) {
+		return h.send(c, h.text(user, "audio_no_playlists"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	allowed, err := h.rateLimiter.Allow(ctx, utils.RateLimitDownload, fmt.Sprintf("%d", chatID))
+	if err != nil {
+		h.logger.Warn("Rate limiter error for chat ID %d, allowing request: %v", chatID, err)
+	}
+	if !allowed {
+		return h.send(c, h.text(user, "error_rate_limit"))
+	}
+
+	// Validation runs without the user's own cookies, so skip it for users who uploaded some
+	var validation *downloader.URLValidation
+	if !h.hasUserCookies(chatID) {
+		validation, err = h.validateURL(url)
+		if errors.Is(err, downloader.ErrAuthRequired) {
+			return h.send(c, h.authRequiredMessage(user))
+		}
+		if errors.Is(err, downloader.ErrProxyUnreachable) {
+			return h.send(c, h.proxyUnreachableMessage(user))
+		}
+		if err == nil && !validation.Valid {
+			return h.send(c, h.text(user, "error_unsupported_link"))
+		}
+	}
+
+	_, err = h.queueDownload(c.Chat(), url, "audio", user, h.downloadPriority(validation), c.Message().ID)
+	return err
+}
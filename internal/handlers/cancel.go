@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gopkg.in/telebot.v3"
+)
+
+// activeDownload is an in-progress download that can be cancelled
+type activeDownload struct {
+	requestID primitive.ObjectID
+	cancel    context.CancelFunc
+}
+
+// trackDownload registers the cancel function of a download that is starting
+func (h *BotHandler) trackDownload(chatID int64, requestID primitive.ObjectID, cancel context.CancelFunc) {
+	h.activeMu.Lock()
+	defer h.activeMu.Unlock()
+
+	h.activeDownloads[chatID] = append(h.activeDownloads[chatID], activeDownload{
+		requestID: requestID,
+		cancel:    cancel,
+	})
+}
+
+// untrackDownload removes a download that has finished from the active downloads, reporting whether it was still there
+func (h *BotHandler) untrackDownload(chatID int64, requestID primitive.ObjectID) bool {
+	h.activeMu.Lock()
+	defer h.activeMu.Unlock()
+
+	downloads := h.activeDownloads[chatID]
+	found := false
+	for i, download := range downloads {
+		if download.requestID == requestID {
+			downloads = append(downloads[:i], downloads[i+1:]...)
+			found = true
+			break
+		}
+	}
+
+	if len(downloads) == 0 {
+		delete(h.activeDownloads, chatID)
+	} else {
+		h.activeDownloads[chatID] = downloads
+	}
+	return found
+}
+
+// popLatestDownload removes and returns the most recently started download of a chat
+func (h *BotHandler) popLatestDownload(chatID int64) (activeDownload, bool) {
+	h.activeMu.Lock()
+	defer h.activeMu.Unlock()
+
+	downloads := h.activeDownloads[chatID]
+	if len(downloads) == 0 {
+		return activeDownload{}, false
+	}
+
+	latest := downloads[len(downloads)-1]
+	if len(downloads) == 1 {
+		delete(h.activeDownloads, chatID)
+	} else {
+		h.activeDownloads[chatID] = downloads[:len(downloads)-1]
+	}
+	return latest, true
+}
+
+// popChatDownloads removes and returns every in-progress download of a chat
+func (h *BotHandler) popChatDownloads(chatID int64) []activeDownload {
+	h.activeMu.Lock()
+	defer h.activeMu.Unlock()
+
+	downloads := h.activeDownloads[chatID]
+	delete(h.activeDownloads, chatID)
+	return downloads
+}
+
+// popAllDownloads removes and returns every in-progress download, keyed by chat
+func (h *BotHandler) popAllDownloads() map[int64][]activeDownload {
+	h.activeMu.Lock()
+	defer h.activeMu.Unlock()
+
+	downloads := h.activeDownloads
+	h.activeDownloads = make(map[int64][]activeDownload)
+	return downloads
+}
+
+// handleCancel handles the /cancel command by aborting the user's most recent in-progress or queued download
+func (h *BotHandler) handleCancel(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /cancel command from chat ID: %d", chatID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
+	if err != nil {
+		h.logger.Error("Error finding user: %v", err)
+	}
+
+	download, ok := h.popLatestDownload(chatID)
+	if !ok {
+		// Nothing running, drop the latest download still waiting in the queue instead
+		if requestID, queued := h.cancelQueuedDownload(chatID); queued {
+			if err := h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID, "cancelled"); err != nil {
+				h.logger.Error("Error marking download request %s as cancelled: %v", requestID.Hex(), err)
+			}
+			return h.send(c, h.cancelledMessage(user))
+		}
+
+		return h.send(c, h.text(user, "cancel_none"))
+	}
+
+	// Mark the request before stopping it so the download goroutine doesn't report a failure
+	if err := h.downloadRepo.UpdateDownloadRequestStatus(ctx, download.requestID, "cancelled"); err != nil {
+		h.logger.Error("Error marking download request %s as cancelled: %v", download.requestID.Hex(), err)
+	}
+	download.cancel()
+
+	return h.send(c, h.cancelledMessage(user))
+}
+
+// cancelledMessage returns the localized message confirming a cancelled download
+func (h *BotHandler) cancelledMessage(user *models.User) string {
+	return h.text(user, "download_cancelled")
+}
+
+// handleCancelAll handles the /cancelall command that aborts all of the user's queued and in-progress downloads.
+// Admins can use /cancelall all to drain the whole queue.
+func (h *BotHandler) handleCancelAll(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /cancelall command from chat ID: %d", chatID)
+
+	user := h.findUser(chatID)
+	if strings.TrimSpace(c.Message().Payload) == "all" {
+		if !h.isAdmin(chatID) {
+			return nil
+		}
+		return h.cancelAllChats(c, user)
+	}
+
+	var cancelled []primitive.ObjectID
+	for _, job := range h.queue.CancelChat(chatID) {
+		h.releaseUserSlot(job.ChatID)
+		if requestID, err := primitive.ObjectIDFromHex(job.ID); err == nil {
+			cancelled = append(cancelled, requestID)
+		}
+	}
+	downloads := h.popChatDownloads(chatID)
+	for _, download := range downloads {
+		cancelled = append(cancelled, download.requestID)
+	}
+	h.cancelRequests(cancelled, downloads)
+
+	if len(cancelled) == 0 {
+		return h.send(c, h.text(user, "cancelall_none"))
+	}
+	return h.send(c, h.textf(user, "cancelall_done", map[string]string{"n": strconv.Itoa(len(cancelled))}))
+}
+
+// cancelAllChats aborts every queued and in-progress download of every chat and tells the affected users
+func (h *BotHandler) cancelAllChats(c telebot.Context, user *models.User) error {
+	adminID := c.Chat().ID
+
+	var cancelled []primitive.ObjectID
+	chats := make(map[int64]bool)
+	for _, job := range h.queue.CancelAll() {
+		h.releaseUserSlot(job.ChatID)
+		if requestID, err := primitive.ObjectIDFromHex(job.ID); err == nil {
+			cancelled = append(cancelled, requestID)
+			chats[job.ChatID] = true
+		}
+	}
+	var downloads []activeDownload
+	for chatID, chatDownloads := range h.popAllDownloads() {
+		for _, download := range chatDownloads {
+			cancelled = append(cancelled, download.requestID)
+			downloads = append(downloads, download)
+		}
+		chats[chatID] = true
+	}
+	h.cancelRequests(cancelled, downloads)
+	h.audit(adminID, "cancelall", fmt.Sprintf("%d downloads of %d chats", len(cancelled), len(chats)))
+
+	for chatID := range chats {
+		if chatID == adminID {
+			continue
+		}
+		chatUser := h.findUser(chatID)
+		if _, err := h.sendTo(chatID, h.text(chatUser, "cancelled_by_admin")); err != nil {
+			h.logger.Warn("Error telling chat ID %d about the cancelled downloads: %v", chatID, err)
+		}
+	}
+
+	return h.send(c, h.textf(user, "cancelall_admin_done", map[string]string{
+		"n":     strconv.Itoa(len(cancelled)),
+		"chats": strconv.Itoa(len(chats)),
+	}))
+}
+
+// cancelRequests marks download requests as cancelled, then stops the in-progress ones among them
+func (h *BotHandler) cancelRequests(requestIDs []primitive.ObjectID, downloads []activeDownload) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Mark the requests before stopping them so the download goroutines don't report failures
+	if _, err := h.downloadRepo.CancelRequests(ctx, requestIDs); err != nil {
+		h.logger.Error("Error marking %d download requests as cancelled: %v", len(requestIDs), err)
+	}
+	for _, download := range downloads {
+		download.cancel()
+	}
+}
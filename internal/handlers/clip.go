@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+)
+
+// clipRange is the segment of a video to download, in seconds
+type clipRange struct {
+	Start int
+	End   int
+}
+
+// clipRangePattern matches a time range such as "1:30-2:00" or "00:01:30-00:02:00.5"
+var clipRangePattern = regexp.MustCompile(`^[0-9:.]+-[0-9:.]+$`)
+
+// clipSuffixPattern matches the time range the downloader adds to the names of clip files
+var clipSuffixPattern = regexp.MustCompile(`_clip_(\d{2,}-\d{2}-\d{2})_(\d{2,}-\d{2}-\d{2})\.[^.]+$`)
+
+// errInvalidClip is returned for time ranges that can't be parsed or end before they start
+var errInvalidClip = errors.New("invalid clip time range")
+
+// parseClipRange finds a time range appended to a message, e.g. "URL 00:01:30-00:02:00".
+// It returns nil if the message has none.
+func parseClipRange(text string) (*clipRange, error) {
+	for _, field := range strings.Fields(text) {
+		if strings.Contains(field, "://") || field[0] < '0' || field[0] > '9' || !strings.Contains(field, "-") {
+			continue
+		}
+		if !clipRangePattern.MatchString(field) {
+			return nil, errInvalidClip
+		}
+
+		startSpec, endSpec, _ := strings.Cut(field, "-")
+		start, err := parseTimestamp(startSpec)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseTimestamp(endSpec)
+		if err != nil {
+			return nil, err
+		}
+		if start >= end {
+			return nil, errInvalidClip
+		}
+		return &clipRange{Start: start, End: end}, nil
+	}
+	return nil, nil
+}
+
+// parseTimestamp parses seconds, minutes:seconds or hours:minutes:seconds into seconds,
+// fractions of a second are dropped
+func parseTimestamp(spec string) (int, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) > 3 {
+		return 0, errInvalidClip
+	}
+
+	seconds := 0
+	for i, part := range parts {
+		if i == len(parts)-1 {
+			part, _, _ = strings.Cut(part, ".")
+		}
+		value, err := strconv.Atoi(part)
+		if err != nil || value < 0 {
+			return 0, errInvalidClip
+		}
+		// Minutes and seconds after the first part can't exceed 59
+		if i > 0 && value > 59 {
+			return 0, errInvalidClip
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds, nil
+}
+
+// formatTimestamp formats seconds as hours:minutes:seconds
+func formatTimestamp(seconds int) string {
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// clipFileName adds the time range of a clip file to the name it is sent with, e.g. "Video 00-01-30_00-02-00.mp4"
+func clipFileName(fileName string, localPath string) string {
+	match := clipSuffixPattern.FindStringSubmatch(localPath)
+	if match == nil {
+		return fileName
+	}
+	dot := strings.LastIndex(fileName, ".")
+	if dot < 0 {
+		dot = len(fileName)
+	}
+	return fileName[:dot] + " " + match[1] + "_" + match[2] + fileName[dot:]
+}
+
+// invalidClipMessage returns the localized message for a malformed time range
+func (h *BotHandler) invalidClipMessage(user *models.User) string {
+	return h.text(user, "clip_invalid")
+}
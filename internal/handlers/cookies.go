@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/config"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
+
+// maxCookiesFileSize is the largest cookies file accepted through /setcookies
+const maxCookiesFileSize = 1024 * 1024
+
+// handleSetCookies handles the /setcookies command that explains how to upload cookies or removes them
+func (h *BotHandler) handleSetCookies(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /setcookies command from chat ID: %d", chatID)
+
+	user := h.findUser(chatID)
+
+	if strings.EqualFold(strings.TrimSpace(c.Message().Payload), "clear") {
+		if err := os.Remove(h.userCookiesPath(chatID)); err != nil && !os.IsNotExist(err) {
+			h.logger.Error("Error removing cookies of chat ID %d: %v", chatID, err)
+			return h.send(c, h.errorMessage(user, err))
+		}
+		return h.send(c, h.text(user, "cookies_removed"))
+	}
+
+	return h.send(c, h.text(user, "cookies_help"))
+}
+
+// handleDocument handles uploaded documents, storing them as the user's cookies when captioned /setcookies.
+// Other documents are handled as uploaded media.
+func (h *BotHandler) handleDocument(c telebot.Context) error {
+	msg := c.Message()
+	if msg.Document == nil || !strings.HasPrefix(strings.TrimSpace(msg.Caption), "/setcookies") {
+		return h.handleUpload(c)
+	}
+
+	chatID := c.Chat().ID
+	h.logger.Info("Received cookies file from chat ID: %d", chatID)
+
+	if !h.featureEnabled(config.FeatureUserCookies) {
+		return h.featureDisabled(c)
+	}
+
+	user := h.findUser(chatID)
+
+	if msg.Document.FileSize > maxCookiesFileSize {
+		return h.send(c, h.invalidCookiesMessage(user))
+	}
+
+	if err := os.MkdirAll(h.config.Download.UserCookiesDir, 0700); err != nil {
+		h.logger.Error("Error creating cookies directory: %v", err)
+		return h.send(c, h.errorMessage(user, err))
+	}
+
+	// Download next to the final file and only replace the previous cookies once the upload is valid
+	cookiesPath := h.userCookiesPath(chatID)
+	uploadPath := cookiesPath + ".upload"
+	defer os.Remove(uploadPath)
+
+	if err := h.bot.Download(&msg.Document.File, uploadPath); err != nil {
+		h.logger.Error("Error downloading cookies file of chat ID %d: %v", chatID, err)
+		return h.send(c, h.errorMessage(user, err))
+	}
+
+	if !isNetscapeCookiesFile(uploadPath) {
+		return h.send(c, h.invalidCookiesMessage(user))
+	}
+
+	if err := os.Chmod(uploadPath, 0600); err != nil {
+		h.logger.Error("Error restricting cookies file permissions: %v", err)
+		return h.send(c, h.errorMessage(user, err))
+	}
+	if err := os.Rename(uploadPath, cookiesPath); err != nil {
+		h.logger.Error("Error saving cookies file of chat ID %d: %v", chatID, err)
+		return h.send(c, h.errorMessage(user, err))
+	}
+
+	return h.send(c, h.text(user, "cookies_saved"))
+}
+
+// userCookiesPath returns where the cookies uploaded by a chat are stored
+func (h *BotHandler) userCookiesPath(chatID int64) string {
+	return filepath.Join(h.config.Download.UserCookiesDir, fmt.Sprintf("%d.txt", chatID))
+}
+
+// hasUserCookies checks if a chat uploaded its own cookies, which are ignored while the feature is turned off
+func (h *BotHandler) hasUserCookies(chatID int64) bool {
+	return h.featureEnabled(config.FeatureUserCookies) && fileExists(h.userCookiesPath(chatID))
+}
+
+// isNetscapeCookiesFile checks that a file looks like a Netscape cookies file as expected by yt-dlp
+func isNetscapeCookiesFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if strings.Contains(line, "HTTP Cookie File") {
+				return true
+			}
+			continue
+		}
+		// Cookie lines have 7 tab separated fields
+		return len(strings.Split(line, "\t")) == 7
+	}
+	return false
+}
+
+// invalidCookiesMessage returns the localized message for a rejected cookies file
+func (h *BotHandler) invalidCookiesMessage(user *models.User) string {
+	return h.text(user, "cookies_invalid")
+}
+
+// authRequiredMessage returns the localized message for a video that needs signing in or fresh cookies
+func (h *BotHandler) authRequiredMessage(user *models.User) string {
+	return h.text(user, "error_auth_required")
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// downloadLogger returns a context carrying a logger that tags every line about a download request with its request
+// and correlation IDs, and that logger. The downloader and the download repository log with the logger of the context.
+func (h *BotHandler) downloadLogger(ctx context.Context, requestID primitive.ObjectID, correlationID string) (context.Context, *utils.EnhancedLogger) {
+	fields := map[string]interface{}{"request_id": requestID.Hex()}
+	if correlationID != "" {
+		fields["correlation_id"] = correlationID
+	}
+	logger := h.enhancedLogger.With(fields)
+	return utils.ContextWithLogger(ctx, logger), logger
+}
+
+// logDownloadError logs a failed download to the log file and stores it in the error logs, so admins can query
+// failures and /lookup finds them by correlation ID. The stack is only set for downloads that panicked.
+func (h *BotHandler) logDownloadError(log *utils.EnhancedLogger, requestID primitive.ObjectID, chatID int64, correlationID string, message string, downloadErr error, stack string) {
+	level := "error"
+	if stack != "" {
+		level = "panic"
+		log.Error("%s: %v\n%s", message, downloadErr, stack)
+	} else {
+		log.Error("%s: %v", message, downloadErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errorLog := models.NewErrorLog(level, message, downloadErr.Error(), stack).
+		WithChatID(chatID).
+		WithRequestID(requestID).
+		WithCorrelationID(correlationID)
+	if err := h.errorLogRepo.LogError(ctx, errorLog); err != nil {
+		log.Warn("Error recording failure of download request %s: %v", requestID.Hex(), err)
+	}
+}
+
+// errorRef returns the localized line with the reference of a failed download to give support, empty without one
+func (h *BotHandler) errorRef(correlationID string, user *models.User) string {
+	if correlationID == "" {
+		return ""
+	}
+	return "\n\n" + h.text(user, "error_ref") + correlationID
+}
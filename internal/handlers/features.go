@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/config"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"gopkg.in/telebot.v3"
+)
+
+// featureEnabled reports whether an optional feature is turned on, by the configuration or an admin override
+func (h *BotHandler) featureEnabled(name string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return h.features.Enabled(ctx, name)
+}
+
+// requireFeature is a middleware that answers commands and buttons of a feature that is turned off
+// instead of handling them
+func (h *BotHandler) requireFeature(name string) telebot.MiddlewareFunc {
+	return func(next telebot.HandlerFunc) telebot.HandlerFunc {
+		return func(c telebot.Context) error {
+			if h.featureEnabled(name) {
+				return next(c)
+			}
+			return h.featureDisabled(c)
+		}
+	}
+}
+
+// featureDisabled tells the user the feature they asked for is turned off
+func (h *BotHandler) featureDisabled(c telebot.Context) error {
+	user := h.findUser(c.Chat().ID)
+	if c.Callback() != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: h.text(user, "feature_disabled"), ShowAlert: true})
+	}
+	return h.reply(c, h.language(user), "feature_disabled", nil)
+}
+
+// handleFeatures lists the feature flags for admins, or turns one on or off on all instances:
+// /features <name> on|off|reset
+func (h *BotHandler) handleFeatures(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /features command from chat ID: %d", chatID)
+
+	if !h.isAdmin(chatID) {
+		return nil
+	}
+	user := h.findUser(chatID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	args := strings.Fields(c.Message().Payload)
+	if len(args) == 0 {
+		overrides := h.features.Overrides(ctx)
+		lines := []string{h.text(user, "features_title")}
+		for _, name := range config.FeatureNames {
+			state := h.featureState(ctx, name, user)
+			if _, ok := overrides[name]; ok {
+				state += h.text(user, "features_overridden")
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", name, state))
+		}
+		_, err := h.sendTo(chatID, strings.Join(lines, "\n"))
+		return err
+	}
+
+	usage := h.textf(user, "features_usage", map[string]string{"names": strings.Join(config.FeatureNames, ", ")})
+	if len(args) != 2 || !config.IsFeature(args[0]) {
+		return h.send(c, usage)
+	}
+	name, action := args[0], strings.ToLower(args[1])
+
+	var err error
+	switch action {
+	case "on", "off":
+		err = h.features.Set(ctx, name, action == "on")
+	case "reset":
+		err = h.features.Reset(ctx, name)
+	default:
+		return h.send(c, usage)
+	}
+	if errors.Is(err, utils.ErrNoFeatureOverrides) {
+		return h.send(c, h.text(user, "features_no_redis"))
+	}
+	if err != nil {
+		h.logger.Error("Error changing feature %s: %v", name, err)
+		return h.send(c, h.text(user, "error_general"))
+	}
+	h.audit(chatID, "features", name+" "+action)
+
+	return h.send(c, h.textf(user, "features_changed", map[string]string{"name": name, "state": h.featureState(ctx, name, user)}))
+}
+
+// featureState returns whether a feature is on or off in the user's language
+func (h *BotHandler) featureState(ctx context.Context, name string, user *models.User) string {
+	if h.features.Enabled(ctx, name) {
+		return h.text(user, "features_on")
+	}
+	return h.text(user, "features_off")
+}
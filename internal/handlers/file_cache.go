@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gopkg.in/telebot.v3"
+)
+
+// sendCachedDownload sends the files of a link downloaded before with the same options, by the file IDs Telegram
+// returned for them, instead of downloading and uploading them again. It reports whether the request was handled,
+// otherwise it is to be downloaded as usual: when no file is cached or none could be sent.
+func (h *BotHandler) sendCachedDownload(ctx context.Context, log *utils.EnhancedLogger, requestID primitive.ObjectID, chatID int64, url string, opts downloader.DownloadOptions, statusMsg *telebot.Message) bool {
+	cached := h.cachedFiles(ctx, url, opts)
+	if cached == nil {
+		return false
+	}
+
+	// Users who get their files through buttons are sent them once they ask, which needs the files on disk
+	user := h.findUser(chatID)
+	if user != nil && user.NotifyOnReady {
+		return false
+	}
+
+	h.editStatus(statusMsg, h.text(user, "download_completed"))
+	chat := &telebot.Chat{ID: chatID}
+	sent, err := h.resendResult(chat, cached, user)
+	if err != nil {
+		// Telegram no longer accepts a file ID
+		h.forgetCachedFiles(ctx, url, opts)
+		if sent == 0 {
+			log.Warn("Sending the cached files of %s failed, downloading it again: %v", url, err)
+			return false
+		}
+
+		// A fresh download would send the files that went through again
+		log.Error("Sending the cached files of %s failed after %d files were sent: %v", url, sent, err)
+		h.metrics.started()
+		h.downloadFinished(url, "failed", 0)
+		if err := h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID, "failed"); err != nil {
+			log.Error("Error updating download request status: %v", err)
+		}
+		if _, err := h.sendTo(chatID, h.text(user, "error_general")); err != nil {
+			log.Error("Error sending error message to chat ID %d: %v", chatID, err)
+		}
+		return true
+	}
+	log.Info("Sent the cached files of %s to chat ID %d", url, chatID)
+
+	h.metrics.started()
+	h.downloadFinished(url, "completed", 0)
+
+	cached.ChatID = chatID
+	if _, err := h.downloadRepo.CompleteWithResult(ctx, requestID, cached); err != nil {
+		log.Error("Error completing download request: %v", err)
+		cached.ID = primitive.NilObjectID
+		h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID, "completed")
+	}
+
+	if !quiet(user) {
+		h.sendTo(chatID, h.text(user, "all_files_sent"), h.qualityMarkup(cached, user))
+	}
+	return true
+}
+
+// cacheFiles remembers the file IDs of a download that was sent, so the next download of the link with the same
+// options is sent by them. Downloads sent only in part, e.g. split to fit the upload limit, aren't cached.
+func (h *BotHandler) cacheFiles(ctx context.Context, url string, opts downloader.DownloadOptions, result *models.DownloadResult) {
+	if !h.fileCacheEnabled(opts) || !result.HasFileIDs() || len(result.AudioTracks) > 0 ||
+		(result.VideoPath != "" && result.VideoFileID == "") ||
+		(result.VideoWithSubPath != "" && result.VideoWithSubFileID == "") ||
+		(result.AudioPath != "" && result.AudioFileID == "") ||
+		(result.SubtitlePath != "" && result.SubtitleFileID == "") {
+		return
+	}
+
+	// Only what resending needs, the local files are removed long before the entry expires
+	data, err := json.Marshal(&models.DownloadResult{
+		VideoPath:          result.VideoPath,
+		SubtitlePath:       result.SubtitlePath,
+		HasSubtitle:        result.HasSubtitle,
+		SubtitleSource:     result.SubtitleSource,
+		SubtitleFrom:       result.SubtitleFrom,
+		FileSize:           result.FileSize,
+		Duration:           result.Duration,
+		VideoFileID:        result.VideoFileID,
+		VideoWithSubFileID: result.VideoWithSubFileID,
+		AudioFileID:        result.AudioFileID,
+		SubtitleFileID:     result.SubtitleFileID,
+		ThumbnailFileID:    result.ThumbnailFileID,
+	})
+	if err != nil {
+		h.logger.Warn("Error encoding cached files of %s: %v", url, err)
+		return
+	}
+
+	ttl := time.Duration(h.config.Download.FileCacheTTL) * time.Hour
+	if err := h.redisClient.Set(ctx, fileCacheKey(url, opts), data, ttl); err != nil {
+		h.logger.Warn("Error caching files of %s: %v", url, err)
+	}
+}
+
+// cachedFiles returns the cached files of a link downloaded with the same options as a download result of the
+// link without local files, nil when there are none
+func (h *BotHandler) cachedFiles(ctx context.Context, url string, opts downloader.DownloadOptions) *models.DownloadResult {
+	if !h.fileCacheEnabled(opts) {
+		return nil
+	}
+
+	data, err := h.redisClient.Get(ctx, fileCacheKey(url, opts))
+	if err != nil {
+		return nil
+	}
+
+	var result models.DownloadResult
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		h.logger.Warn("Error decoding cached files of %s: %v", url, err)
+		return nil
+	}
+	result.URL = url
+	result.CreatedAt = time.Now()
+	result.FilesExpired = true
+	return &result
+}
+
+// forgetCachedFiles removes the cached files of a link after Telegram rejected them
+func (h *BotHandler) forgetCachedFiles(ctx context.Context, url string, opts downloader.DownloadOptions) {
+	if err := h.redisClient.Delete(ctx, fileCacheKey(url, opts)); err != nil {
+		h.logger.Warn("Error removing cached files of %s: %v", url, err)
+	}
+}
+
+// fileCacheEnabled reports whether downloads with the given options are cached. Downloads with the user's own
+// cookies may be private and are never shared, and metadata files and separate audio tracks aren't cached.
+func (h *BotHandler) fileCacheEnabled(opts downloader.DownloadOptions) bool {
+	return h.redisClient != nil && h.config.Download.FileCacheTTL > 0 &&
+		opts.CookiesFile == "" && !opts.IncludeMetadata && opts.AudioTrack != downloader.AllAudioTracks
+}
+
+// fileCacheKey returns the Redis key caching the files of a link downloaded with the options that change them
+func fileCacheKey(url string, opts downloader.DownloadOptions) string {
+	format := opts.AudioFormat
+	if format == "" {
+		format = downloader.AudioFormats[0]
+	}
+	id := fmt.Sprintf("%s|%t|%s|%s|%s|%s|%t|%s|%d|%d|%d", url, opts.AudioOnly, format, opts.AudioBitrate,
+		opts.BurnLang, opts.FileLang, opts.TranslateSubs, opts.AudioTrack, opts.ClipStart, opts.ClipEnd, opts.MaxHeight)
+	sum := sha1.Sum([]byte(id))
+	return "file_cache:" + hex.EncodeToString(sum[:])
+}
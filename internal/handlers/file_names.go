@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+)
+
+// mediaFileName names a sent file after its video with the configured template, e.g. "{title} [{id}].{ext}".
+// Without a template or a known title the file keeps its localized generic name. The returned name always ends
+// with ext, which includes its dot.
+func (h *BotHandler) mediaFileName(meta *models.VideoMetadata, generic string, ext string) string {
+	template := h.config.Download.FileNameTemplate
+	if template == "" || meta == nil || strings.TrimSpace(meta.Title) == "" {
+		return generic + ext
+	}
+
+	name := strings.NewReplacer(
+		"{title}", meta.Title,
+		"{id}", meta.ID,
+		"{uploader}", meta.Uploader,
+		"{ext}", strings.TrimPrefix(ext, "."),
+	).Replace(template)
+
+	// Leave out the brackets around a placeholder the site gave no value for, e.g. videos without an ID
+	name = strings.NewReplacer(" []", "", " ()", "", "[]", "", "()", "").Replace(name)
+	if !strings.HasSuffix(name, ext) {
+		name += ext
+	}
+	return name
+}
+
+// videoMetadata converts the metadata of a download to the one stored with its result, nil when nothing is known
+func videoMetadata(meta downloader.VideoMetadata) *models.VideoMetadata {
+	if meta == (downloader.VideoMetadata{}) {
+		return nil
+	}
+	return &models.VideoMetadata{ID: meta.ID, Title: meta.Title, Uploader: meta.Uploader}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
+
+// tidyGroupChat deletes the message asking for a download and the download's status message once its files are
+// sent to a group, as configured with GROUP_DELETE_COMMANDS and GROUP_DELETE_STATUS. Private chats keep both, and
+// so do failed downloads so their error and retry button stay visible.
+func (h *BotHandler) tidyGroupChat(request *models.DownloadRequest, statusMsg *telebot.Message) {
+	deleteCommand := h.config.Group.DeleteCommands && request.MessageID != 0
+	deleteStatus := h.config.Group.DeleteStatus && statusMsg != nil
+	// Groups have negative chat IDs
+	if request.ChatID >= 0 || (!deleteCommand && !deleteStatus) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	current, err := h.downloadRepo.GetDownloadRequestByID(ctx, request.ID)
+	if err != nil {
+		h.logger.Warn("Not deleting the messages of download request %s: %v", request.ID.Hex(), err)
+		return
+	}
+	if current == nil || current.Status != "completed" {
+		return
+	}
+
+	if deleteCommand {
+		h.deleteGroupMessage(&telebot.StoredMessage{MessageID: strconv.Itoa(request.MessageID), ChatID: request.ChatID})
+	}
+	if deleteStatus {
+		h.deleteGroupMessage(statusMsg)
+	}
+}
+
+// deleteGroupMessage deletes a message from a group, logging rather than failing when the bot may not delete it
+func (h *BotHandler) deleteGroupMessage(msg telebot.Editable) {
+	err := h.bot.Delete(msg)
+	_, chatID := msg.MessageSig()
+	switch {
+	case err == nil:
+	case errors.Is(err, telebot.ErrNoRightsToDelete):
+		// Other users' messages can only be deleted by admins with the right to delete messages
+		h.logger.Warn("Can't delete messages in chat %d, the bot needs to be an admin allowed to delete messages", chatID)
+	case errors.Is(err, telebot.ErrNotFoundToDelete):
+		// Already deleted, e.g. by the user or for another link of the same message
+	default:
+		h.logger.Warn("Failed to delete message in chat %d: %v", chatID, err)
+	}
+}
@@ -2,15 +2,27 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-redis/redis/v8"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/buildinfo"
 	"github.com/mohammedteir/telegram-video-downloader-bot/internal/config"
 	"github.com/mohammedteir/telegram-video-downloader-bot/internal/database"
 	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/i18n"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/metrics"
 	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
 	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/worker"
 
     "go.mongodb.org/mongo-driver/bson/primitive"
 
@@ -22,10 +34,42 @@ type BotHandler struct {
 	bot           *telebot.Bot
 	userRepo      *database.UserRepository
 	downloadRepo  *database.DownloadRepository
+	auditRepo     *database.AuditLogRepository
+	errorLogRepo  *database.ErrorLogRepository
 	redisClient   *database.RedisClient
 	config        *config.Config
 	logger        *utils.Logger
+	enhancedLogger *utils.EnhancedLogger // tags the log lines of a download with its IDs, see downloadLogger
 	downloader    *downloader.VideoDownloader
+	killSwitch    *utils.KillSwitch
+	features      *utils.FeatureFlags
+	rateLimiter   *utils.RateLimiter
+	lm            *i18n.LanguageManager
+
+	// Shared limit on status message edits across all downloads
+	editThrottle *utils.TokenBucket
+
+	// Bounded pool of workers processing download requests
+	queue *worker.Queue
+
+	// In-progress downloads per chat, in the order they were started
+	activeDownloads map[int64][]activeDownload
+	activeMu        sync.Mutex
+
+	// Downloads per chat that are queued or running, limited to Worker.MaxPerUser, and the outcomes of those of
+	// users in quiet mode
+	userSlots      map[int64]int
+	quietSummaries map[int64]*quietSummary
+	userSlotsMu    sync.Mutex
+
+	// Download counters of this instance shown by /metrics
+	metrics *downloadMetrics
+
+	// Download metrics served to Prometheus by the health server, nil when they are disabled
+	collector *metrics.Collector
+
+	// Done once the bot shuts down, ends waits outside of a download such as flood limit retries
+	ctx context.Context
 }
 
 
@@ -37,6 +81,8 @@ func NewBotHandler(
 	config *config.Config,
 	logger *utils.Logger,
 	dependencyPaths map[string]string,
+	killSwitch *utils.KillSwitch,
+	lm *i18n.LanguageManager,
 ) *BotHandler {
 
 	// Initialize download repository
@@ -65,26 +111,97 @@ if err != nil {
     // Handle error - fall back to using the regular logger
     logger.Error("Failed to create enhanced logger: %v", err)
     // You might need a fallback solution here
+} else {
+    enhancedLogger.WithForwarder(logger.Forwarder())
 }
 
 	
 mongoClient := userRepo.GetClient() // Access the client directly
 downloadRepo := database.NewDownloadRepository(mongoClient, config.MongoDB.Database, enhancedLogger)
+	auditRepo := database.NewAuditLogRepository(mongoClient, config.MongoDB.Database, enhancedLogger)
+	errorLogRepo := database.NewErrorLogRepository(mongoClient, config.MongoDB.Database, enhancedLogger)
+
+	// Enforce one result per download request, reprocessed requests update their result
+	indexCtx, indexCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := downloadRepo.EnsureResultIndexes(indexCtx); err != nil {
+		logger.Warn("Download results may be duplicated until the request ID index exists: %v", err)
+	}
+	if err := downloadRepo.EnsureRequestIndexes(indexCtx); err != nil {
+		logger.Warn("Statistics and /lookup may be slow until the download requests indexes exist: %v", err)
+	}
+	if config.Download.ResultRetention > 0 {
+		if err := downloadRepo.EnsureResultTTLIndex(indexCtx, resultRetention(config)); err != nil {
+			logger.Warn("Old download results are only deleted by the hourly cleanup until the TTL index exists: %v", err)
+		}
+	}
+	if err := errorLogRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Warn("/lookup may be slow until the error logs index exists: %v", err)
+	}
+	indexCancel()
 
 	
 	// Initialize downloader
 	
- videoDownloader := downloader.NewVideoDownloader(config.Download.TempDir, enhancedLogger, 3,dependencyPaths) // 3 is the default max retries
+ videoDownloader := downloader.NewVideoDownloader(config.Download.TempDir, enhancedLogger, 3,dependencyPaths, config.Download.Proxy). // 3 is the default max retries
+	WithCookiesFile(config.Download.CookiesFile).
+	WithHostOptions(hostDownloadOptions(config.Download.HostOptions)).
+	WithMaxDirectSize(config.Download.MaxDirectSize).
+	WithCircuitBreaker(config.Download.BreakerLimit, time.Duration(config.Download.BreakerWindow)*time.Second, time.Duration(config.Download.BreakerCooldown)*time.Second)
+
+	// Download metrics cost nothing unless they are served
+	var collector *metrics.Collector
+	if config.Health.Addr != "" && config.Health.DownloadMetrics {
+		collector = metrics.New()
+		videoDownloader.WithMetrics(collector)
+	}
+	if config.Translate.URL != "" {
+		videoDownloader.WithSubtitleTranslator(downloader.NewLibreTranslator(config.Translate.URL, config.Translate.APIKey, time.Duration(config.Translate.Timeout)*time.Second))
+	}
+
+	// Initialize rate limiter, shared between instances through Redis when available
+	var limiterRedis *redis.Client
+	if redisClient != nil {
+		limiterRedis = redisClient.GetClient()
+	}
+	rateLimiter := utils.NewRateLimiter(
+		config.RateLimit.Enabled,
+		config.RateLimit.RequestsMax,
+		config.RateLimit.TimeWindow,
+		config.RateLimit.UserLimit,
+		limiterRedis,
+		enhancedLogger,
+	).WithCategoryLimit(utils.RateLimitCommand, config.RateLimit.CommandMax, config.RateLimit.CommandWindow)
+	if config.RateLimit.Algorithm == utils.RateLimitTokenBucket {
+		rateLimiter.WithTokenBucket(config.RateLimit.RefillRate, config.RateLimit.Burst)
+	}
+	if collector != nil {
+		rateLimiter.WithRejectionHook(collector.RateLimited)
+	}
 
 	
 	return &BotHandler{
 		bot:           bot,
 		userRepo:      userRepo,
 		downloadRepo:  downloadRepo,
+		auditRepo:     auditRepo,
+		errorLogRepo:  errorLogRepo,
 		redisClient:   redisClient,
 		config:        config,
 		logger:        logger,
+		enhancedLogger: enhancedLogger,
 		downloader:    videoDownloader,
+		killSwitch:    killSwitch,
+		features:      utils.NewFeatureFlags(config.Features.OverridesKey, config.Features.CacheTTL, limiterRedis, config.FeatureEnabled, enhancedLogger),
+		rateLimiter:   rateLimiter,
+		lm:            lm,
+		editThrottle:  utils.NewTokenBucket(config.Telegram.EditRate, config.Telegram.EditBurst),
+		queue:         worker.NewQueue(config.Worker.PoolSize, config.Worker.QueueSize, logger),
+		activeDownloads: make(map[int64][]activeDownload),
+		userSlots:     make(map[int64]int),
+		quietSummaries: make(map[int64]*quietSummary),
+		metrics:       newDownloadMetrics(),
+		collector:     collector,
+		ctx:           context.Background(),
 	}
 }
 
@@ -92,19 +209,68 @@ downloadRepo := database.NewDownloadRepository(mongoClient, config.MongoDB.Datab
 func (h *BotHandler) RegisterHandlers() {
 	// Command handlers
 	h.bot.Handle("/start", h.handleStart)
-	h.bot.Handle("/help", h.handleHelp)
-	h.bot.Handle("/about", h.handleAbout)
-	h.bot.Handle("/lang", h.handleLanguage)
+	h.bot.Handle("/help", h.handleHelp, h.commandRateLimit)
+	h.bot.Handle("/about", h.handleAbout, h.commandRateLimit)
+	h.bot.Handle("/version", h.handleVersion, h.commandRateLimit)
+	h.bot.Handle("/lang", h.handleLanguage, h.commandRateLimit)
+	h.bot.Handle("/cancel", h.handleCancel)
+	h.bot.Handle("/cancelall", h.handleCancelAll)
+	h.bot.Handle("/status", h.handleStatus, h.commandRateLimit)
+	h.bot.Handle("/metadata", h.handleMetadata, h.requireFeature(config.FeatureMetadata))
+	h.bot.Handle("/translatesubs", h.handleTranslateSubs, h.requireFeature(config.FeatureTranslateSubs))
+	h.bot.Handle("/setcookies", h.handleSetCookies, h.requireFeature(config.FeatureUserCookies))
+	h.bot.Handle("/zip", h.handleZip, h.requireFeature(config.FeatureZip))
+	h.bot.Handle("/quiet", h.handleQuiet, h.commandRateLimit)
+	h.bot.Handle("/thumb", h.handleThumb, h.requireFeature(config.FeatureThumb))
+	h.bot.Handle("/chart", h.handleChart)
+	h.bot.Handle("/audit", h.handleAudit)
+	h.bot.Handle("/stats", h.handleStats)
+	h.bot.Handle("/lookup", h.handleLookup)
+	h.bot.Handle("/search", h.handleSearch)
+	h.bot.Handle("/translations", h.handleTranslations)
+	h.bot.Handle("/metrics", h.handleMetrics)
+	h.bot.Handle("/features", h.handleFeatures)
+	h.bot.Handle("/settings", h.handleSettings, h.commandRateLimit)
+	h.bot.Handle("/history", h.handleHistory, h.commandRateLimit)
+	h.bot.Handle("/audio", h.handleAudio)
+	h.bot.Handle("/tracks", h.handleTracks, h.requireFeature(config.FeatureAudioTracks))
+	h.bot.Handle(telebot.OnDocument, h.handleDocument)
+	h.bot.Handle(telebot.OnVideo, h.handleUpload)
+	h.bot.Handle(telebot.OnAudio, h.handleUpload)
+	h.bot.Handle(telebot.OnPhoto, h.handleUpload)
+	h.bot.Handle(telebot.OnVoice, h.handleUpload)
+	h.bot.Handle(telebot.OnVideoNote, h.handleUpload)
 	
 	// Button handlers
 	h.bot.Handle(&telebot.InlineButton{Unique: "set_interface_lang"}, h.handleSetInterfaceLanguage)
 	h.bot.Handle(&telebot.InlineButton{Unique: "set_caption_lang"}, h.handleSetCaptionLanguage)
+	h.bot.Handle(&telebot.InlineButton{Unique: "set_burn_lang"}, h.handleSetBurnLanguage)
+	h.bot.Handle(&telebot.InlineButton{Unique: "set_file_lang"}, h.handleSetFileLanguage)
+	h.bot.Handle(&telebot.InlineButton{Unique: "change_quality"}, h.handleChangeQuality, h.requireFeature(config.FeatureChangeQuality))
+	h.bot.Handle(&telebot.InlineButton{Unique: "pick_quality"}, h.handlePickQuality, h.requireFeature(config.FeatureChangeQuality))
 	
 	// Language selection buttons
-	h.bot.Handle(&telebot.InlineButton{Unique: "lang_ar"}, h.handleLanguageSelection)
-	h.bot.Handle(&telebot.InlineButton{Unique: "lang_en"}, h.handleLanguageSelection)
-	h.bot.Handle(&telebot.InlineButton{Unique: "lang_de"}, h.handleLanguageSelection)
-	h.bot.Handle(&telebot.InlineButton{Unique: "lang_fr"}, h.handleLanguageSelection)
+	for _, lang := range models.GetSupportedLanguages() {
+		h.bot.Handle(&telebot.InlineButton{Unique: "lang_" + lang.Code}, h.handleLanguageSelection)
+	}
+	
+	// Previous download buttons
+	h.bot.Handle(&telebot.InlineButton{Unique: "resend_yes"}, h.handleResend)
+	h.bot.Handle(&telebot.InlineButton{Unique: "resend_no"}, h.handleResendSkip)
+	h.bot.Handle(&telebot.InlineButton{Unique: "history_resend"}, h.handleHistoryResend)
+	h.bot.Handle(&telebot.InlineButton{Unique: "history_page"}, h.handleHistoryPage)
+	h.bot.Handle(&telebot.InlineButton{Unique: "audio_track"}, h.handleAudioTrack, h.requireFeature(config.FeatureAudioTracks))
+	h.bot.Handle(&telebot.InlineButton{Unique: "ready_send"}, h.handleReadySend)
+	h.bot.Handle(&telebot.InlineButton{Unique: "subtitle_lang"}, h.handleSubtitleLanguage)
+	h.bot.Handle(&telebot.InlineButton{Unique: "retry_download"}, h.handleRetryDownload)
+	
+	// Settings buttons
+	h.bot.Handle(&telebot.InlineButton{Unique: "toggle_send_as_document"}, h.handleToggleSendAsDocument)
+	h.bot.Handle(&telebot.InlineButton{Unique: "cycle_audio_format"}, h.handleCycleAudioFormat)
+	h.bot.Handle(&telebot.InlineButton{Unique: "cycle_audio_bitrate"}, h.handleCycleAudioBitrate)
+	h.bot.Handle(&telebot.InlineButton{Unique: "toggle_download_mode"}, h.handleToggleDownloadMode)
+	h.bot.Handle(&telebot.InlineButton{Unique: "toggle_notify_on_ready"}, h.handleToggleNotifyOnReady)
+	h.bot.Handle(&telebot.InlineButton{Unique: "settings_lang"}, h.handleSettingsLanguage)
 	
 	// Handle text messages (for URL processing)
 	h.bot.Handle(telebot.OnText, h.handleText)
@@ -122,60 +288,33 @@ func (h *BotHandler) handleStart(c telebot.Context) error {
 	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
 	if err != nil {
 		h.logger.Error("Error finding user: %v", err)
-		return c.Send("An error occurred. Please try again later.")
+		return h.send(c, h.errorMessage(user, err))
 	}
 	
 	if user == nil {
-		// New user
+		// New user, greeted in the language of their Telegram app when it is supported
 		user = models.NewUser(chatID)
+		if sender := c.Sender(); sender != nil {
+			user.InterfaceLanguage = h.detectLanguage(sender.LanguageCode)
+		}
 		user, err = h.userRepo.CreateUser(ctx, user)
 		if err != nil {
 			h.logger.Error("Error creating user: %v", err)
-			return c.Send("An error occurred. Please try again later.")
+			return h.send(c, h.errorMessage(user, err))
 		}
 		
 		// Send welcome message with language selection
-		return h.sendWelcomeMessage(c)
+		return h.sendWelcomeMessage(c, user)
 	}
 	
 	// Returning user
-	var welcomeBack string
-	switch user.InterfaceLanguage {
-	case "ar":
-		welcomeBack = "مرحبًا بعودتك! أرسل رابط فيديو لتنزيله."
-	case "de":
-		welcomeBack = "Willkommen zurück! Sende einen Video-Link zum Herunterladen."
-	case "fr":
-		welcomeBack = "Bon retour! Envoyez un lien vidéo pour le télécharger."
-	default: // English
-		welcomeBack = "Welcome back! Send a video link to download it."
-	}
-	
-	return c.Send(welcomeBack)
+	return h.reply(c, h.language(user), "welcome_back", nil)
 }
 
 // sendWelcomeMessage sends the welcome message with language selection
-func (h *BotHandler) sendWelcomeMessage(c telebot.Context) error {
-	welcomeMsg := "Welcome to the Video Downloader Bot! Please select your preferred language:"
-	
-	// Create language selection buttons
-	var buttons [][]telebot.InlineButton
-	
-	// Add language buttons
-	langRow := []telebot.InlineButton{
-		{Text: "العربية 🇸🇦", Unique: "lang_ar", Data: "interface"},
-		{Text: "English 🇬🇧", Unique: "lang_en", Data: "interface"},
-	}
-	
-	langRow2 := []telebot.InlineButton{
-		{Text: "Deutsch 🇩🇪", Unique: "lang_de", Data: "interface"},
-		{Text: "Français 🇫🇷", Unique: "lang_fr", Data: "interface"},
-	}
-	
-	buttons = append(buttons, langRow, langRow2)
-	
-	return c.Send(welcomeMsg, &telebot.ReplyMarkup{
-		InlineKeyboard: buttons,
+func (h *BotHandler) sendWelcomeMessage(c telebot.Context, user *models.User) error {
+	return h.reply(c, h.language(user), "welcome_new", nil, &telebot.ReplyMarkup{
+		InlineKeyboard: languageButtons("interface"),
 	})
 }
 
@@ -191,91 +330,32 @@ func (h *BotHandler) handleHelp(c telebot.Context) error {
 	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
 	if err != nil {
 		h.logger.Error("Error finding user: %v", err)
-		return c.Send("An error occurred. Please try again later.")
-	}
-	
-	var helpText string
-	if user == nil || user.InterfaceLanguage == "en" {
-		helpText = `*Video Downloader Bot Help*
-
-*How to use:*
-1. Simply send a video link from YouTube, Twitter, Instagram, etc.
-2. The bot will download and send you:
-   - Best quality video
-   - Subtitle-embedded video (if captions available)
-   - Audio-only file
-   - Subtitle file (if available)
-
-*Commands:*
-/start - Start the bot
-/help - Show this help message
-/lang - Change language settings
-/about - About this bot
-
-*Language Settings:*
-You can change your interface language and preferred caption language using the /lang command.`
-	} else if user.InterfaceLanguage == "ar" {
-		helpText = `*مساعدة بوت تنزيل الفيديو*
-
-*كيفية الاستخدام:*
-1. ما عليك سوى إرسال رابط فيديو من يوتيوب أو تويتر أو انستغرام، إلخ.
-2. سيقوم البوت بتنزيل وإرسال:
-   - فيديو بأفضل جودة
-   - فيديو مع ترجمة مدمجة (إذا كانت الترجمة متوفرة)
-   - ملف صوتي فقط
-   - ملف الترجمة (إذا كان متوفرًا)
-
-*الأوامر:*
-/start - بدء البوت
-/help - عرض رسالة المساعدة هذه
-/lang - تغيير إعدادات اللغة
-/about - حول هذا البوت
-
-*إعدادات اللغة:*
-يمكنك تغيير لغة الواجهة ولغة الترجمة المفضلة باستخدام الأمر /lang`
-	} else if user.InterfaceLanguage == "de" {
-		helpText = `*Video Downloader Bot Hilfe*
-
-*Verwendung:*
-1. Senden Sie einfach einen Video-Link von YouTube, Twitter, Instagram usw.
-2. Der Bot lädt herunter und sendet Ihnen:
-   - Video in bester Qualität
-   - Video mit eingebetteten Untertiteln (falls verfügbar)
-   - Nur-Audio-Datei
-   - Untertiteldatei (falls verfügbar)
-
-*Befehle:*
-/start - Bot starten
-/help - Diese Hilfemeldung anzeigen
-/lang - Spracheinstellungen ändern
-/about - Über diesen Bot
-
-*Spracheinstellungen:*
-Sie können Ihre Oberflächensprache und bevorzugte Untertitelsprache mit dem Befehl /lang ändern.`
-	} else if user.InterfaceLanguage == "fr" {
-		helpText = `*Aide du Bot de Téléchargement Vidéo*
-
-*Comment utiliser:*
-1. Envoyez simplement un lien vidéo de YouTube, Twitter, Instagram, etc.
-2. Le bot téléchargera et vous enverra:
-   - Vidéo de meilleure qualité
-   - Vidéo avec sous-titres intégrés (si disponibles)
-   - Fichier audio uniquement
-   - Fichier de sous-titres (si disponible)
-
-*Commandes:*
-/start - Démarrer le bot
-/help - Afficher ce message d'aide
-/lang - Modifier les paramètres de langue
-/about - À propos de ce bot
-
-*Paramètres de langue:*
-Vous pouvez modifier votre langue d'interface et votre langue de sous-titres préférée à l'aide de la commande /lang`
-	}
-	
-	return c.Send(helpText, &telebot.SendOptions{
+		return h.send(c, h.errorMessage(user, err))
+	}
+	
+	lang := h.language(user)
+	helpText := fmt.Sprintf("*%s*\n\n*%s*\n%s\n%s\n%s\n%s\n%s\n%s\n\n*%s*\n%s\n%s\n%s\n%s\n\n*%s*\n%s",
+		h.lm.GetString(lang, "help_title"),
+		h.lm.GetString(lang, "help_usage"),
+		h.lm.GetString(lang, "help_usage_1"),
+		h.lm.GetString(lang, "help_usage_2"),
+		h.lm.GetString(lang, "help_usage_2_1"),
+		h.lm.GetString(lang, "help_usage_2_2"),
+		h.lm.GetString(lang, "help_usage_2_3"),
+		h.lm.GetString(lang, "help_usage_2_4"),
+		h.lm.GetString(lang, "help_commands"),
+		h.lm.GetString(lang, "help_cmd_start"),
+		h.lm.GetString(lang, "help_cmd_help"),
+		h.lm.GetString(lang, "help_cmd_lang"),
+		h.lm.GetString(lang, "help_cmd_about"),
+		h.lm.GetString(lang, "help_lang"),
+		h.lm.GetString(lang, "help_lang_desc"),
+	)
+	
+	_, err = h.sendTo(chatID, helpText, &telebot.SendOptions{
 		ParseMode: telebot.ModeMarkdown,
 	})
+	return err
 }
 
 // handleAbout handles the /about command
@@ -290,21 +370,23 @@ func (h *BotHandler) handleAbout(c telebot.Context) error {
 	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
 	if err != nil {
 		h.logger.Error("Error finding user: %v", err)
-		return c.Send("An error occurred. Please try again later.")
+		return h.send(c, h.errorMessage(user, err))
 	}
 	
-	var aboutText string
-	if user == nil || user.InterfaceLanguage == "en" {
-		aboutText = "This bot downloads and sends: best video, best audio, and subtitles in your preferred language. It also embeds captions into a video version if available. Developed by MohammedTeir."
-	} else if user.InterfaceLanguage == "ar" {
-		aboutText = "يقوم هذا البوت بتنزيل وإرسال: أفضل فيديو، وأفضل صوت، وترجمات بلغتك المفضلة. كما يدمج الترجمات في نسخة الفيديو إذا كانت متوفرة. تم تطويره بواسطة محمد طير."
-	} else if user.InterfaceLanguage == "de" {
-		aboutText = "Dieser Bot lädt herunter und sendet: bestes Video, besten Audio und Untertitel in Ihrer bevorzugten Sprache. Er bettet auch Untertitel in eine Videoversion ein, falls verfügbar. Entwickelt von MohammedTeir."
-	} else if user.InterfaceLanguage == "fr" {
-		aboutText = "Ce bot télécharge et envoie: la meilleure vidéo, le meilleur audio et les sous-titres dans votre langue préférée. Il intègre également les sous-titres dans une version vidéo si disponible. Développé par MohammedTeir."
-	}
-	
-	return c.Send(aboutText)
+	return h.reply(c, h.language(user), "about", nil)
+}
+
+// handleVersion handles the /version command, showing which build of the bot answers
+func (h *BotHandler) handleVersion(c telebot.Context) error {
+    chatID := c.Chat().ID
+    h.logger.Info("Received /version command from chat ID: %d", chatID)
+
+    build := buildinfo.Get()
+    return h.reply(c, h.language(h.findUser(chatID)), "version", map[string]string{
+        "version": build.Version,
+        "commit":  build.Commit,
+        "date":    build.Date,
+    })
 }
 
 // handleLanguage handles the /lang command
@@ -319,44 +401,31 @@ func (h *BotHandler) handleLanguage(c telebot.Context) error {
 	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
 	if err != nil {
 		h.logger.Error("Error finding user: %v", err)
-		return c.Send("An error occurred. Please try again later.")
-	}
-	
-	var langText string
-	if user == nil || user.InterfaceLanguage == "en" {
-		langText = "Please select what you want to change:"
-	} else if user.InterfaceLanguage == "ar" {
-		langText = "الرجاء تحديد ما تريد تغييره:"
-	} else if user.InterfaceLanguage == "de" {
-		langText = "Bitte wählen Sie aus, was Sie ändern möchten:"
-	} else if user.InterfaceLanguage == "fr" {
-		langText = "Veuillez sélectionner ce que vous souhaitez modifier:"
+		return h.send(c, h.errorMessage(user, err))
 	}
 	
 	// Create language selection buttons
 	var buttons [][]telebot.InlineButton
 	
 	// Add language type buttons
-	var interfaceBtn, captionBtn telebot.InlineButton
-	
-	if user == nil || user.InterfaceLanguage == "en" {
-		interfaceBtn = telebot.InlineButton{Text: "Interface Language", Unique: "set_interface_lang"}
-		captionBtn = telebot.InlineButton{Text: "Caption Language", Unique: "set_caption_lang"}
-	} else if user.InterfaceLanguage == "ar" {
-		interfaceBtn = telebot.InlineButton{Text: "لغة الواجهة", Unique: "set_interface_lang"}
-		captionBtn = telebot.InlineButton{Text: "لغة الترجمة", Unique: "set_caption_lang"}
-	} else if user.InterfaceLanguage == "de" {
-		interfaceBtn = telebot.InlineButton{Text: "Oberflächensprache", Unique: "set_interface_lang"}
-		captionBtn = telebot.InlineButton{Text: "Untertitelsprache", Unique: "set_caption_lang"}
-	} else if user.InterfaceLanguage == "fr" {
-		interfaceBtn = telebot.InlineButton{Text: "Langue d'interface", Unique: "set_interface_lang"}
-		captionBtn = telebot.InlineButton{Text: "Langue des sous-titres", Unique: "set_caption_lang"}
+	interfaceBtn := telebot.InlineButton{Text: h.text(user, "lang_interface"), Unique: "set_interface_lang"}
+	captionBtn := telebot.InlineButton{Text: h.text(user, "lang_caption"), Unique: "set_caption_lang"}
+	
+	burnBtn := telebot.InlineButton{
+		Text:   h.text(user, "lang_burn"),
+		Unique: "set_burn_lang",
+	}
+	fileBtn := telebot.InlineButton{
+		Text:   h.text(user, "lang_file"),
+		Unique: "set_file_lang",
 	}
 	
 	buttons = append(buttons, []telebot.InlineButton{interfaceBtn})
 	buttons = append(buttons, []telebot.InlineButton{captionBtn})
+	buttons = append(buttons, []telebot.InlineButton{burnBtn})
+	buttons = append(buttons, []telebot.InlineButton{fileBtn})
 	
-	return c.Send(langText, &telebot.ReplyMarkup{
+	return h.reply(c, h.language(user), "lang_select", nil, &telebot.ReplyMarkup{
 		InlineKeyboard: buttons,
 	})
 }
@@ -366,24 +435,8 @@ func (h *BotHandler) handleSetInterfaceLanguage(c telebot.Context) error {
 	chatID := c.Chat().ID
 	h.logger.Info("User %d is setting interface language", chatID)
 	
-	// Create language selection buttons
-	var buttons [][]telebot.InlineButton
-	
-	// Add language buttons
-	langRow := []telebot.InlineButton{
-		{Text: "العربية 🇸🇦", Unique: "lang_ar", Data: "interface"},
-		{Text: "English 🇬🇧", Unique: "lang_en", Data: "interface"},
-	}
-	
-	langRow2 := []telebot.InlineButton{
-		{Text: "Deutsch 🇩🇪", Unique: "lang_de", Data: "interface"},
-		{Text: "Français 🇫🇷", Unique: "lang_fr", Data: "interface"},
-	}
-	
-	buttons = append(buttons, langRow, langRow2)
-	
-	return c.Edit("Choose Interface Language:", &telebot.ReplyMarkup{
-		InlineKeyboard: buttons,
+	return h.edit(c, h.text(h.findUser(chatID), "lang_choose_interface"), &telebot.ReplyMarkup{
+		InlineKeyboard: languageButtons("interface"),
 	})
 }
 
@@ -392,25 +445,55 @@ func (h *BotHandler) handleSetCaptionLanguage(c telebot.Context) error {
 	chatID := c.Chat().ID
 	h.logger.Info("User %d is setting caption language", chatID)
 	
-	// Create language selection buttons
-	var buttons [][]telebot.InlineButton
-	
-	// Add language buttons
-	langRow := []telebot.InlineButton{
-		{Text: "العربية 🇸🇦", Unique: "lang_ar", Data: "caption"},
-		{Text: "English 🇬🇧", Unique: "lang_en", Data: "caption"},
+	return h.edit(c, h.text(h.findUser(chatID), "lang_choose_caption"), &telebot.ReplyMarkup{
+		InlineKeyboard: languageButtons("caption"),
+	})
+}
+
+// handleSetBurnLanguage handles the burned-in subtitle language selection button
+func (h *BotHandler) handleSetBurnLanguage(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("User %d is setting burned-in subtitle language", chatID)
+	return h.edit(c, h.text(h.findUser(chatID), "lang_choose_burn"), &telebot.ReplyMarkup{
+		InlineKeyboard: languageButtons("burn"),
+	})
+}
+
+// handleSetFileLanguage handles the subtitle file language selection button
+func (h *BotHandler) handleSetFileLanguage(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("User %d is setting subtitle file language", chatID)
+	return h.edit(c, h.text(h.findUser(chatID), "lang_choose_file"), &telebot.ReplyMarkup{
+		InlineKeyboard: languageButtons("file"),
+	})
+}
+
+// detectLanguage returns the interface language matching a Telegram language code such as "de" or "pt-BR", the
+// default language when there is none. A regional variant is kept when it has a language file or a fallback chain,
+// otherwise its base language is used.
+func (h *BotHandler) detectLanguage(languageCode string) string {
+	code := strings.ToLower(languageCode)
+	base, _, _ := strings.Cut(code, "-")
+	for _, candidate := range []string{code, base} {
+		if candidate != "" && h.lm.HasLanguage(candidate) {
+			return candidate
+		}
 	}
-	
-	langRow2 := []telebot.InlineButton{
-		{Text: "Deutsch 🇩🇪", Unique: "lang_de", Data: "caption"},
-		{Text: "Français 🇫🇷", Unique: "lang_fr", Data: "caption"},
+	return h.lm.GetDefaultLanguage()
+}
+
+// languageButtons returns the language selection buttons for the given setting, two per row
+func languageButtons(setting string) [][]telebot.InlineButton {
+	var buttons [][]telebot.InlineButton
+	for i, lang := range models.GetSupportedLanguages() {
+		button := telebot.InlineButton{Text: lang.NativeName + " " + lang.Flag, Unique: "lang_" + lang.Code, Data: setting}
+		if i%2 == 0 {
+			buttons = append(buttons, []telebot.InlineButton{button})
+		} else {
+			buttons[len(buttons)-1] = append(buttons[len(buttons)-1], button)
+		}
 	}
-	
-	buttons = append(buttons, langRow, langRow2)
-	
-	return c.Edit("Choose Caption Language:", &telebot.ReplyMarkup{
-		InlineKeyboard: buttons,
-	})
+	return buttons
 }
 
 // handleLanguageSelection handles language selection buttons
@@ -421,6 +504,9 @@ func (h *BotHandler) handleLanguageSelection(c telebot.Context) error {
 	// Extract language code from button unique identifier
 	langCode := c.Callback().Unique[5:] // Remove "lang_" prefix
 	
+	// Languages chosen from /settings go back to the settings menu
+	data, fromSettings := strings.CutSuffix(data, ":settings")
+	
 	h.logger.Info("User %d selected language %s for %s", chatID, langCode, data)
 	
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -434,28 +520,27 @@ func (h *BotHandler) handleLanguageSelection(c telebot.Context) error {
 		if err != nil {
 			h.logger.Error("Error updating interface language: %v", err)
 			return c.Respond(&telebot.CallbackResponse{
-				Text: "Error updating language",
+				Text: h.text(h.findUser(chatID), "lang_update_error"),
 			})
 		}
 		
-		// Set success message based on selected language
-		switch langCode {
-		case "ar":
-			successMsg = "تم تغيير لغة الواجهة إلى العربية!"
-		case "de":
-			successMsg = "Oberflächensprache auf Deutsch geändert!"
-		case "fr":
-			successMsg = "Langue d'interface changée en français!"
+		// The success message is in the newly selected language
+		successMsg = h.lm.GetString(langCode, "lang_updated_interface")
+	} else {
+		// Update caption, burned-in subtitle or subtitle file language
+		var err error
+		switch data {
+		case "burn":
+			err = h.userRepo.UpdateUserBurnLanguage(ctx, chatID, langCode)
+		case "file":
+			err = h.userRepo.UpdateUserFileLanguage(ctx, chatID, langCode)
 		default:
-			successMsg = "Interface language changed to English!"
+			err = h.userRepo.UpdateUserCaptionLanguage(ctx, chatID, langCode)
 		}
-	} else {
-		// Update caption language
-		err := h.userRepo.UpdateUserCaptionLanguage(ctx, chatID, langCode)
 		if err != nil {
 			h.logger.Error("Error updating caption language: %v", err)
 			return c.Respond(&telebot.CallbackResponse{
-				Text: "Error updating language",
+				Text: h.text(h.findUser(chatID), "lang_update_error"),
 			})
 		}
 		
@@ -463,22 +548,8 @@ func (h *BotHandler) handleLanguageSelection(c telebot.Context) error {
 		user, err := h.userRepo.FindUserByChatID(ctx, chatID)
 		if err != nil {
 			h.logger.Error("Error finding user: %v", err)
-			successMsg = "Caption language updated!"
-		} else if user == nil {
-			successMsg = "Caption language updated!"
-		} else {
-			// Set success message based on interface language
-			switch user.InterfaceLanguage {
-			case "ar":
-				successMsg = "تم تغيير لغة الترجمة!"
-			case "de":
-				successMsg = "Untertitelsprache geändert!"
-			case "fr":
-				successMsg = "Langue des sous-titres modifiée!"
-			default:
-				successMsg = "Caption language updated!"
-			}
 		}
+		successMsg = h.text(user, "lang_updated_caption")
 	}
 	
 	// Respond to callback
@@ -486,8 +557,14 @@ func (h *BotHandler) handleLanguageSelection(c telebot.Context) error {
 		Text: successMsg,
 	})
 	
+	if fromSettings {
+		if user, err := h.userRepo.FindUserByChatID(ctx, chatID); err == nil && user != nil {
+			return h.edit(c, h.settingsText(user), h.settingsMarkup(user))
+		}
+	}
+	
 	// Edit message to show success
-	return c.Edit(successMsg)
+	return h.edit(c, successMsg)
 }
 
 // handleText handles text messages (for URL processing)
@@ -495,10 +572,17 @@ func (h *BotHandler) handleText(c telebot.Context) error {
 	chatID := c.Chat().ID
 	text := c.Text()
 	
-	h.logger.Info("Received text from chat ID %d: %s", chatID, text)
+	h.logger.Info("Received text from chat ID %d: %s", chatID, truncateForLog(text))
 	
-	// Check if text is a URL
-	if !isValidURL(text) {
+	// Check if text contains URLs
+	urls, tooLong := dropLongURLs(extractURLs(text))
+	if tooLong > 0 {
+		// The other links of the message are still downloaded
+		if err := h.send(c, h.urlTooLongMessage(h.findUser(chatID))); err != nil || len(urls) == 0 {
+			return err
+		}
+	}
+	if len(urls) == 0 {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		
@@ -506,24 +590,12 @@ func (h *BotHandler) handleText(c telebot.Context) error {
 		user, err := h.userRepo.FindUserByChatID(ctx, chatID)
 		if err != nil {
 			h.logger.Error("Error finding user: %v", err)
-			return c.Send("Please send a valid video URL.")
-		}
-		
-		var invalidURLMsg string
-		if user == nil || user.InterfaceLanguage == "en" {
-			invalidURLMsg = "Please send a valid video URL."
-		} else if user.InterfaceLanguage == "ar" {
-			invalidURLMsg = "الرجاء إرسال رابط فيديو صالح."
-		} else if user.InterfaceLanguage == "de" {
-			invalidURLMsg = "Bitte senden Sie eine gültige Video-URL."
-		} else if user.InterfaceLanguage == "fr" {
-			invalidURLMsg = "Veuillez envoyer une URL vidéo valide."
 		}
 		
-		return c.Send(invalidURLMsg)
+		return h.reply(c, h.language(user), "invalid_url", nil)
 	}
 	
-	// URL is valid, send processing message
+	// URL is valid, look up the user's preferences
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	
@@ -531,116 +603,225 @@ func (h *BotHandler) handleText(c telebot.Context) error {
 	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
 	if err != nil {
 		h.logger.Error("Error finding user: %v", err)
-		return c.Send("Processing your video. This may take a while...")
+		return h.send(c, h.errorMessage(user, err))
+	}
+	
+	// Several links in one message are queued together
+	if len(urls) > 1 {
+		return h.handleMultipleURLs(c, urls, user)
+	}
+	url := urls[0]
+	if !h.isAllowedHost(url) {
+		return h.send(c, h.unsupportedSiteMessage(user))
+	}
+	
+	// A time range after the link downloads only that clip
+	clip, err := parseClipRange(text)
+	if err != nil {
+		return h.send(c, h.invalidClipMessage(user))
+	}
+	if clip != nil && !h.featureEnabled(config.FeatureClips) {
+		return h.featureDisabled(c)
+	}
+	if clip != nil && h.isPlaylistDownload(url) {
+		return h.send(c, h.text(user, "clip_single_video"))
+	}
+	
+	// Enforce the per-user download rate limit
+	allowed, err := h.rateLimiter.Allow(ctx, utils.RateLimitDownload, fmt.Sprintf("%d", chatID))
+	if err != nil {
+		h.logger.Warn("Rate limiter error for chat ID %d, allowing request: %v", chatID, err)
+	}
+	if !allowed {
+		return h.send(c, h.text(user, "error_rate_limit"))
+	}
+	
+	// Offer to resend a recent download of the same URL instead of downloading it again
+	if previous := h.findResendableResult(ctx, chatID, url); previous != nil && clip == nil {
+		return h.sendResendPrompt(c, previous, user)
+	}
+	
+	// Check the URL cheaply before the heavy download, playlists are validated entry by entry.
+	// Validation runs without the user's own cookies, so skip it for users who uploaded some.
+	var validation *downloader.URLValidation
+	if !h.isPlaylistDownload(url) && !h.hasUserCookies(chatID) {
+		validation, err = h.validateURL(url)
+		if errors.Is(err, downloader.ErrAuthRequired) {
+			return h.send(c, h.authRequiredMessage(user))
+		}
+		if errors.Is(err, downloader.ErrProxyUnreachable) {
+			return h.send(c, h.proxyUnreachableMessage(user))
+		}
+		if err == nil && !validation.Valid {
+			return h.send(c, h.text(user, "error_unsupported_link"))
+		}
+		if err == nil && clip != nil && validation.Duration > 0 && clip.Start >= validation.Duration {
+			return h.send(c, h.textf(user, "clip_after_end", map[string]string{"duration": formatTimestamp(validation.Duration)}))
+		}
+		if err == nil && validation.Title != "" {
+			if err := h.send(c, h.textf(user, "download_found", map[string]string{"title": validation.Title})); err != nil {
+				h.logger.Error("Error sending found title to chat ID %d: %v", chatID, err)
+			}
+		}
 	}
 	
-	var processingMsg string
-	if user == nil || user.InterfaceLanguage == "en" {
-		processingMsg = "Processing your video. This may take a while..."
-	} else if user.InterfaceLanguage == "ar" {
-		processingMsg = "جاري معالجة الفيديو الخاص بك. قد يستغرق هذا بعض الوقت..."
-	} else if user.InterfaceLanguage == "de" {
-		processingMsg = "Ihr Video wird verarbeitet. Dies kann eine Weile dauern..."
-	} else if user.InterfaceLanguage == "fr" {
-		processingMsg = "Traitement de votre vidéo en cours. Cela peut prendre un moment..."
+	if clip != nil {
+		h.logger.Info("Downloading clip %s-%s of %s for chat ID %d", formatTimestamp(clip.Start), formatTimestamp(clip.End), url, chatID)
+		request := models.NewDownloadRequest(chatID, url)
+		request.Source = h.downloadSource(url, user)
+		request.ClipStart, request.ClipEnd = clip.Start, clip.End
+		request.MessageID = c.Message().ID
+		_, err := h.queueRequest(c.Chat(), request, user, h.downloadPriority(validation))
+		return err
 	}
 	
-	// Send processing message
-	statusMsg, err := h.bot.Send(c.Chat(), processingMsg)
+	return h.startDownload(c.Chat(), url, user, h.downloadPriority(validation), c.Message().ID)
+}
+
+// startDownload sends the processing message, records the download request and processes it in the background.
+// messageID is the user's message asking for the download, 0 when it came from a button.
+func (h *BotHandler) startDownload(chat *telebot.Chat, url string, user *models.User, priority int, messageID int) error {
+	_, err := h.queueDownload(chat, url, h.downloadSource(url, user), user, priority, messageID)
+	return err
+}
+
+// downloadSource returns how a link is downloaded in the user's download mode, "download" or "audio".
+// Playlists are always downloaded as videos.
+func (h *BotHandler) downloadSource(url string, user *models.User) string {
+	if user != nil && user.DownloadMode == "audio" && !h.isPlaylistDownload(url) {
+		return "audio"
+	}
+	return "download"
+}
+
+// queueDownload does the work of startDownload for a request from the given source, "download" or "audio",
+// and reports whether the download was queued
+func (h *BotHandler) queueDownload(chat *telebot.Chat, url string, source string, user *models.User, priority int, messageID int) (bool, error) {
+	request := models.NewDownloadRequest(chat.ID, url)
+	request.Source = source
+	request.MessageID = messageID
+	return h.queueRequest(chat, request, user, priority)
+}
+
+// queueRequest stores a new download request and queues it, returning whether it was queued
+func (h *BotHandler) queueRequest(chat *telebot.Chat, downloadRequest *models.DownloadRequest, user *models.User, priority int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	
+	if refused, err := h.refuseDownload(ctx, chat, user); refused {
+		return false, err
+	}
+	if !h.acquireUserSlot(chat.ID, false) {
+		return false, h.refuseBusyUser(chat, user)
+	}
+	
+	// Send processing message, without a notification in quiet mode
+	var silent []interface{}
+	if quiet(user) {
+		silent = append(silent, telebot.Silent)
+	}
+	statusMsg, err := h.sendTo(chat.ID, h.text(user, "processing"), silent...)
 	if err != nil {
 		h.logger.Error("Error sending processing message: %v", err)
 	}
 	
 	// Create download request
-	downloadRequest := models.NewDownloadRequest(chatID, text)
 	downloadRequest, err = h.downloadRepo.CreateDownloadRequest(ctx, downloadRequest)
 	if err != nil {
 		h.logger.Error("Error creating download request: %v", err)
-		return c.Send("An error occurred. Please try again later.")
+		h.releaseUserSlot(chat.ID)
+		_, err = h.sendTo(chat.ID, h.errorMessage(user, err))
+		return false, err
 	}
 	
-	// Get caption language
-	captionLang := "en" // Default to English
-	if user != nil {
-		captionLang = user.CaptionLanguage
+	// Process download on the worker pool
+	return h.enqueueDownload(downloadRequest, h.requestOptions(downloadRequest, user), priority, statusMsg, user), nil
+}
+
+// refuseDownload tells the user and reports true when no new downloads can be started right now
+func (h *BotHandler) refuseDownload(ctx context.Context, chat *telebot.Chat, user *models.User) (bool, error) {
+	// Refuse new downloads while an operator has the global kill-switch on
+	if h.killSwitch != nil && h.killSwitch.Active(ctx) {
+		h.logger.Warn("Rejected download for chat ID %d: kill-switch is on", chat.ID)
+		_, err := h.sendTo(chat.ID, h.text(user, "downloads_disabled"))
+		return true, err
 	}
 	
-	// Process download in a goroutine
-	go func() {
-		h.processDownload(downloadRequest.ID, chatID, text, captionLang, statusMsg)
-	}()
-	
-	return nil
+	// Refuse new downloads before they fill the disk
+	if !h.hasFreeDiskSpace() {
+		_, err := h.sendTo(chat.ID, h.text(user, "server_busy"))
+		return true, err
+	}
+	return false, nil
 }
 
-// sendThumbnail sends the thumbnail to the user if it exists
-func (h *BotHandler) sendThumbnail(chatID int64, thumbnailPath string, user *models.User) {
-    if thumbnailPath == "" || !fileExists(thumbnailPath) {
+// sendThumbnail sends the thumbnail to the user if it exists and returns its Telegram file ID
+func (h *BotHandler) sendThumbnail(chatID int64, file telebot.File, user *models.User) (string, error) {
+    if !isSendable(file) {
         h.logger.Debug("No thumbnail to send or file doesn't exist")
-        return
+        return "", nil
     }
 
     chat := &telebot.Chat{ID: chatID}
     
-    // Create caption based on user's language
-    var caption string
-    if user == nil || user.InterfaceLanguage == "en" {
-        caption = "Video thumbnail"
-    } else if user.InterfaceLanguage == "ar" {
-        caption = "صورة مصغرة للفيديو"
-    } else if user.InterfaceLanguage == "de" {
-        caption = "Video-Vorschaubild"
-    } else if user.InterfaceLanguage == "fr" {
-        caption = "Miniature de la vidéo"
-    }
-
     // Send as photo
     photo := &telebot.Photo{
-        File:    telebot.FromDisk(thumbnailPath),
-        Caption: caption,
+        File:    file,
+        Caption: h.text(user, "thumbnail_caption"),
     }
     
-    _, err := h.bot.Send(chat, photo)
+    msg, err := h.sendFile(chat, photo)
     if err != nil {
         h.logger.Error("Error sending thumbnail: %v", err)
+        return "", err
     }
+    return sentFileID(msg), nil
 }
 
-// sendAudioFile sends the downloaded audio file to the user with a descriptive name
-func (h *BotHandler) sendAudioFile(chat *telebot.Chat, audioPath string, user *models.User) {
-    if audioPath == "" || !fileExists(audioPath) {
+// sendAudioFile sends the downloaded audio file to the user with a descriptive name and returns its Telegram file ID
+func (h *BotHandler) sendAudioFile(chat *telebot.Chat, file telebot.File, meta *models.VideoMetadata, user *models.User) (string, error) {
+    return h.sendAudioTrack(chat, file, "", meta, user)
+}
+
+// sendAudioTrack sends an audio file named after its track language, if any, and returns its Telegram file ID
+func (h *BotHandler) sendAudioTrack(chat *telebot.Chat, file telebot.File, language string, meta *models.VideoMetadata, user *models.User) (string, error) {
+    if !isSendable(file) {
         h.logger.Debug("No audio file to send or file doesn't exist")
-        return
+        return "", nil
+    }
+
+    // Keep the extension of the extracted format, files resent by ID are named by Telegram
+    ext := filepath.Ext(file.FileLocal)
+    if ext == "" {
+        ext = ".mp3"
     }
 
-    // Create file name based on user's language
-    var fileName string
-    if user == nil || user.InterfaceLanguage == "en" {
-        fileName = "Audio Track.mp3"
-    } else if user.InterfaceLanguage == "ar" {
-        fileName = "المقطع الصوتي.mp3"
-    } else if user.InterfaceLanguage == "de" {
-        fileName = "Audiospur.mp3"
-    } else if user.InterfaceLanguage == "fr" {
-        fileName = "Piste Audio.mp3"
+    // Name the file after the video, or else based on user's language
+    fileName := h.mediaFileName(meta, h.text(user, "file_audio_track"), ext)
+    if language != "" {
+        fileName = strings.TrimSuffix(fileName, ext) + " (" + language + ")" + ext
     }
+    fileName = h.safeFileName(clipFileName(fileName, file.FileLocal))
 
     audio := &telebot.Audio{
-        File:     telebot.FromDisk(audioPath),
+        File:     file,
         FileName: fileName,
     }
     
-    _, err := h.bot.Send(chat, audio)
+    msg, err := h.sendFile(chat, audio)
     if err != nil {
         h.logger.Error("Error sending audio file: %v", err)
+        return "", err
     }
+    return sentFileID(msg), nil
 }
 
-// sendSubtitleFile sends the downloaded subtitle file to the user with a descriptive name
-func (h *BotHandler) sendSubtitleFile(chat *telebot.Chat, subtitlePath string, user *models.User) {
-    if subtitlePath == "" || !fileExists(subtitlePath) {
+// sendSubtitleFile sends the downloaded subtitle file to the user with a descriptive name and an optional caption,
+// and returns its Telegram file ID
+func (h *BotHandler) sendSubtitleFile(chat *telebot.Chat, file telebot.File, subtitlePath string, caption string, meta *models.VideoMetadata, user *models.User) (string, error) {
+    if !isSendable(file) {
         h.logger.Debug("No subtitle file to send or file doesn't exist")
-        return
+        return "", nil
     }
 
     // Get file extension
@@ -649,106 +830,127 @@ func (h *BotHandler) sendSubtitleFile(chat *telebot.Chat, subtitlePath string, u
         ext = ".srt" // default to .srt if no extension found
     }
 
-    // Create file name based on user's language
-    var fileName string
-    if user == nil || user.InterfaceLanguage == "en" {
-        fileName = "Subtitles" + ext
-    } else if user.InterfaceLanguage == "ar" {
-        fileName = "الترجمة" + ext
-    } else if user.InterfaceLanguage == "de" {
-        fileName = "Untertitel" + ext
-    } else if user.InterfaceLanguage == "fr" {
-        fileName = "Sous-titres" + ext
-    }
+    // Name the file after the video, or else based on user's language
+    fileName := h.safeFileName(h.mediaFileName(meta, h.text(user, "file_subtitles"), ext))
 
     doc := &telebot.Document{
-        File:     telebot.FromDisk(subtitlePath),
+        File:     file,
         FileName: fileName,
+        Caption:  caption,
     }
     
-    _, err := h.bot.Send(chat, doc)
+    msg, err := h.sendFile(chat, doc)
     if err != nil {
         h.logger.Error("Error sending subtitle file: %v", err)
+        return "", err
     }
+    return sentFileID(msg), nil
 }
 
 
-// sendPrimaryVideo sends the main video file to the user
-func (h *BotHandler) sendPrimaryVideo(chat *telebot.Chat, videoPath string, user *models.User) {
-    if videoPath == "" || !fileExists(videoPath) {
+// sendPrimaryVideo sends the main video file to the user and returns its Telegram file ID
+func (h *BotHandler) sendPrimaryVideo(chat *telebot.Chat, file telebot.File, meta *models.VideoMetadata, user *models.User) (string, error) {
+    if !isSendable(file) {
         h.logger.Debug("No primary video to send or file doesn't exist")
-        return
+        return "", nil
     }
 
-    // Create file name based on user's language
-    var fileName string
-    if user == nil || user.InterfaceLanguage == "en" {
-        fileName = "Video.mp4"
-    } else if user.InterfaceLanguage == "ar" {
-        fileName = "الفيديو.mp4"
-    } else if user.InterfaceLanguage == "de" {
-        fileName = "Video.mp4"
-    } else if user.InterfaceLanguage == "fr" {
-        fileName = "Vidéo.mp4"
-    }
+    // Name the file after the video, or else based on user's language
+    fileName := h.mediaFileName(meta, h.text(user, "file_video"), ".mp4")
 
-    video := &telebot.Video{
-        File:     telebot.FromDisk(videoPath),
-        FileName: fileName,
+    fileName = h.safeFileName(clipFileName(fileName, file.FileLocal))
+
+    // Documents skip Telegram's re-encoding and keep the original quality
+    var media telebot.Sendable = &telebot.Video{File: file, FileName: fileName}
+    if user != nil && user.SendAsDocument {
+        media = &telebot.Document{File: file, FileName: fileName}
     }
     
-    _, err := h.bot.Send(chat, video)
+    msg, err := h.sendFile(chat, media)
+    if err != nil && file.FileID != "" {
+        // A cached file ID only works with the kind it was sent as, which may predate a settings change
+        h.logger.Debug("Resending primary video with the other media kind: %v", err)
+        if _, ok := media.(*telebot.Video); ok {
+            media = &telebot.Document{File: file, FileName: fileName}
+        } else {
+            media = &telebot.Video{File: file, FileName: fileName}
+        }
+        msg, err = h.sendFile(chat, media)
+    }
     if err != nil {
         h.logger.Error("Error sending primary video: %v", err)
+        return "", err
     }
+    return sentFileID(msg), nil
 }
 
-// sendVideoWithSubtitles sends the video with embedded subtitles to the user
-func (h *BotHandler) sendVideoWithSubtitles(chat *telebot.Chat, videoPath string, user *models.User) {
-    if videoPath == "" || !fileExists(videoPath) {
+// sendVideoWithSubtitles sends the video with embedded subtitles to the user and returns its Telegram file ID
+func (h *BotHandler) sendVideoWithSubtitles(chat *telebot.Chat, file telebot.File, meta *models.VideoMetadata, user *models.User) (string, error) {
+    if !isSendable(file) {
         h.logger.Debug("No subtitled video to send or file doesn't exist")
-        return
+        return "", nil
     }
 
-    // Create caption and file name based on user's language
-    var captionText, fileName string
-    if user == nil || user.InterfaceLanguage == "en" {
-        captionText = "Video with embedded subtitles"
-        fileName = "Video (With Subtitles).mp4"
-    } else if user.InterfaceLanguage == "ar" {
-        captionText = "فيديو مع ترجمة مدمجة"
-        fileName = "الفيديو (مع ترجمة).mp4"
-    } else if user.InterfaceLanguage == "de" {
-        captionText = "Video mit eingebetteten Untertiteln"
-        fileName = "Video (mit Untertiteln).mp4"
-    } else if user.InterfaceLanguage == "fr" {
-        captionText = "Vidéo avec sous-titres intégrés"
-        fileName = "Vidéo (avec sous-titres).mp4"
-    }
+    // Create caption based on user's language, and name the file after the video or else in that language
+    captionText := h.text(user, "video_with_subs")
+    fileName := h.safeFileName(clipFileName(h.mediaFileName(meta, h.text(user, "file_video_with_subs"), ".mp4"), file.FileLocal))
 
     video := &telebot.Video{
-        File:     telebot.FromDisk(videoPath),
+        File:     file,
         Caption:  captionText,
         FileName: fileName,
     }
     
-    _, err := h.bot.Send(chat, video)
+    msg, err := h.sendFile(chat, video)
     if err != nil {
         h.logger.Error("Error sending video with subtitles: %v", err)
+        return "", err
     }
+    return sentFileID(msg), nil
 }
 
-// processDownload handles the video download process
-func (h *BotHandler) processDownload(requestID interface{}, chatID int64, url string, captionLang string, statusMsg *telebot.Message) {
-	ctx := context.Background()
+// processDownload handles the video download process, the context is done once the bot shuts down
+func (h *BotHandler) processDownload(ctx context.Context, requestID interface{}, chatID int64, url string, opts downloader.DownloadOptions, statusMsg *telebot.Message) {
+	// Every line logged about the download, here, in the downloader and in the repository, carries its IDs
+	ctx, log := h.downloadLogger(ctx, requestID.(primitive.ObjectID), opts.CorrelationID)
 	
 	// Update request status to processing
 	h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID.(primitive.ObjectID), "processing")
 	
-	// Download video
-	result, err := h.downloader.Download(ctx, url, captionLang)
+	// Show the download progress on the status message
+	opts.OnProgress = h.progressReporter(ctx, chatID, statusMsg)
+	opts.OnRetry = h.retryReporter(ctx, chatID, statusMsg)
+	
+	// Send the files of the same download by their Telegram file IDs when they are cached
+	if h.sendCachedDownload(ctx, log, requestID.(primitive.ObjectID), chatID, url, opts, statusMsg) {
+		return
+	}
+	
+	// Download video with a context the user can cancel through /cancel
+	downloadCtx, cancel := context.WithCancel(ctx)
+	h.trackDownload(chatID, requestID.(primitive.ObjectID), cancel)
+	h.metrics.started()
+	result, err := h.downloader.Download(downloadCtx, url, opts)
+	h.untrackDownload(chatID, requestID.(primitive.ObjectID))
+	cancel()
+	
+	if err != nil && ctx.Err() != nil {
+		h.downloadInterrupted(log, requestID.(primitive.ObjectID), chatID, statusMsg)
+		return
+	}
+	
+	if err != nil && downloadCtx.Err() == context.Canceled {
+		h.downloadFinished(url, "cancelled", 0)
+		// The request status was already set to cancelled by /cancel
+		log.Info("Download of request %s was cancelled by chat ID %d", requestID.(primitive.ObjectID).Hex(), chatID)
+		user, _ := h.userRepo.FindUserByChatID(ctx, chatID)
+		h.editStatus(statusMsg, h.cancelledMessage(user))
+		return
+	}
+	
 	if err != nil {
-		h.logger.Error("Error downloading video: %v", err)
+		h.downloadFinished(url, "failed", 0)
+		h.logDownloadError(log, requestID.(primitive.ObjectID), chatID, opts.CorrelationID, "Download failed", err, "")
 		
 		// Update request status to failed
 		h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID.(primitive.ObjectID), "failed")
@@ -756,92 +958,83 @@ func (h *BotHandler) processDownload(requestID interface{}, chatID int64, url st
 		// Get user language preference
 		user, _ := h.userRepo.FindUserByChatID(ctx, chatID)
 		
-		var errorMsg string
-		if user == nil || user.InterfaceLanguage == "en" {
-			errorMsg = "Failed to download video. Please try again later."
-		} else if user.InterfaceLanguage == "ar" {
-			errorMsg = "فشل تنزيل الفيديو. الرجاء المحاولة مرة أخرى لاحقًا."
-		} else if user.InterfaceLanguage == "de" {
-			errorMsg = "Video konnte nicht heruntergeladen werden. Bitte versuchen Sie es später erneut."
-		} else if user.InterfaceLanguage == "fr" {
-			errorMsg = "Échec du téléchargement de la vidéo. Veuillez réessayer plus tard."
+		errorMsg := h.text(user, "download_error")
+		
+		// Tell the user to sign in rather than to retry when the video needs authentication
+		if errors.Is(err, downloader.ErrAuthRequired) {
+			errorMsg = h.authRequiredMessage(user)
+		}
+		if errors.Is(err, downloader.ErrProxyUnreachable) {
+			errorMsg = h.proxyUnreachableMessage(user)
+		}
+		if errors.Is(err, downloader.ErrFileTooLarge) {
+			errorMsg = h.text(user, "file_too_large")
+		}
+		var unavailable *downloader.PlatformUnavailableError
+		if errors.As(err, &unavailable) {
+			errorMsg = h.lm.GetStringf(h.language(user), "error_platform_unavailable", map[string]string{"platform": unavailable.Platform})
 		}
 		
-		// Send error message
-		h.bot.Edit(statusMsg, errorMsg)
+		// Send error message with the reference to give support and a button to try the same request again
+		h.editStatus(statusMsg, errorMsg+h.errorRef(opts.CorrelationID, user), h.retryMarkup(requestID.(primitive.ObjectID), user))
 		return
 	}
 	
-	// Update request status to completed
-	h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID.(primitive.ObjectID), "completed")
+	h.downloadFinished(url, "completed", result.FileSize)
+	
+	// The files are downloaded, record and send them even if the bot is shutting down meanwhile
+	ctx = context.WithoutCancel(ctx)
 	
 	// Create download result
 	downloadResult := &models.DownloadResult{
-		RequestID:       requestID.(primitive.ObjectID),
 		ChatID:          chatID,
+		URL:             url,
 		VideoPath:       result.VideoPath,
 		VideoWithSubPath: result.VideoWithSubPath,
 		AudioPath:       result.AudioPath,
 		SubtitlePath:    result.SubtitlePath,
+		ThumbnailPath:   result.ThumbnailPath,
+		InfoJSONPath:    result.InfoJSONPath,
+		DescriptionPath: result.DescriptionPath,
 		HasSubtitle:     result.HasSubtitle,
+		SubtitleSource:  result.SubtitleSource,
+		SubtitleFrom:    result.SubtitleFrom,
+		FileSize:        result.FileSize,
+		Duration:        result.Duration,
 		CreatedAt:       time.Now(),
+		Metadata:        videoMetadata(result.Metadata),
+	}
+	for _, track := range result.AudioTracks {
+		downloadResult.AudioTracks = append(downloadResult.AudioTracks, models.AudioTrack{Language: track.Language, Path: track.Path})
 	}
 	
-	_, err = h.downloadRepo.CreateDownloadResult(ctx, downloadResult)
-	if err != nil {
-		h.logger.Error("Error creating download result: %v", err)
+	// Mark the request completed together with storing its result
+	if _, err = h.downloadRepo.CompleteWithResult(ctx, requestID.(primitive.ObjectID), downloadResult); err != nil {
+		log.Error("Error completing download request: %v", err)
+		downloadResult.ID = primitive.NilObjectID
+		// The files are sent anyway, don't leave the request to be resumed after a restart
+		h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID.(primitive.ObjectID), "completed")
 	}
 	
 	// Get user language preference
 	user, _ := h.userRepo.FindUserByChatID(ctx, chatID)
 	
-	var completedMsg string
-	if user == nil || user.InterfaceLanguage == "en" {
-		completedMsg = "Download completed! Sending files..."
-	} else if user.InterfaceLanguage == "ar" {
-		completedMsg = "اكتمل التنزيل! جاري إرسال الملفات..."
-	} else if user.InterfaceLanguage == "de" {
-		completedMsg = "Download abgeschlossen! Dateien werden gesendet..."
-	} else if user.InterfaceLanguage == "fr" {
-		completedMsg = "Téléchargement terminé! Envoi des fichiers..."
-	}
-	
 	// Update status message
-	h.bot.Edit(statusMsg, completedMsg)
+	h.editStatus(statusMsg, h.text(user, "download_completed"))
 	
-	// Send files to user
+	// Send files to user, or let users who asked for it pull them with buttons
 	chat := &telebot.Chat{ID: chatID}
-	
-	// Send thumbnail if available
-   if result.ThumbnailPath != "" {
-    h.sendThumbnail(chatID, result.ThumbnailPath, user)
-    }
-
-     // Send primary video if available
-    h.sendPrimaryVideo(chat, result.VideoPath, user)
-
-    // Send video with subtitles if available
-     h.sendVideoWithSubtitles(chat, result.VideoWithSubPath, user)
-	
-    // Send audio file if available
-      h.sendAudioFile(chat, result.AudioPath, user)
-
-    // Send subtitle file if available
-      h.sendSubtitleFile(chat, result.SubtitlePath, user)
-	
-	// Send completion message
-	var doneMsg string
-	if user == nil || user.InterfaceLanguage == "en" {
-		doneMsg = "All files sent! Send another video link to download more."
-	} else if user.InterfaceLanguage == "ar" {
-		doneMsg = "تم إرسال جميع الملفات! أرسل رابط فيديو آخر للتنزيل مرة أخرى."
-	} else if user.InterfaceLanguage == "de" {
-		doneMsg = "Alle Dateien gesendet! Senden Sie einen weiteren Video-Link, um mehr herunterzuladen."
-	} else if user.InterfaceLanguage == "fr" {
-		doneMsg = "Tous les fichiers envoyés! Envoyez un autre lien vidéo pour télécharger plus."
+	if user != nil && user.NotifyOnReady && !downloadResult.ID.IsZero() {
+		h.sendReadyPrompt(chat, downloadResult, user)
+	} else {
+		h.sendDownloadFiles(ctx, chat, url, opts, result, downloadResult, user)
+		h.cacheFiles(ctx, url, opts, downloadResult)
 	}
 	
-	h.bot.Send(chat, doneMsg)
+	// Offer the languages the video does have when it has no subtitle in the user's language
+	if !opts.AudioOnly && result.SubtitlePath == "" && !downloadResult.ID.IsZero() {
+		h.offerSubtitleLanguages(chat, downloadResult, opts, user)
+	}
 	
 	// Schedule cleanup of download files (after 1 hour)
 	go func() {
@@ -860,18 +1053,238 @@ func (h *BotHandler) processDownload(requestID interface{}, chatID int64, url st
 		if result.SubtitlePath != "" {
 			os.Remove(result.SubtitlePath)
 		}
+		if result.InfoJSONPath != "" {
+			os.Remove(result.InfoJSONPath)
+		}
+		if result.DescriptionPath != "" {
+			os.Remove(result.DescriptionPath)
+		}
+		if result.ImagePath != "" {
+			os.Remove(result.ImagePath)
+		}
 		
-		// Remove parent directory
+		// Remove parent directory, audio-only downloads and images have no video
 		if result.VideoPath != "" {
 			os.RemoveAll(filepath.Dir(result.VideoPath))
+		} else if result.AudioPath != "" {
+			os.RemoveAll(filepath.Dir(result.AudioPath))
+		} else if result.ImagePath != "" {
+			os.RemoveAll(filepath.Dir(result.ImagePath))
 		}
 	}()
 }
 
+// sendDownloadFiles sends the files of a completed download and stores their Telegram file IDs
+func (h *BotHandler) sendDownloadFiles(ctx context.Context, chat *telebot.Chat, url string, opts downloader.DownloadOptions, result *downloader.DownloadResult, downloadResult *models.DownloadResult, user *models.User) {
+	chatID := chat.ID
+	
+	// Time the upload of every file for the download metrics, a failed upload would skew the durations
+	start := time.Now()
+	var sendErrs []error
+	defer func() {
+		if h.collector != nil && errors.Join(sendErrs...) == nil {
+			h.collector.ObserveUpload(downloader.PlatformOf(url), time.Since(start))
+		}
+	}()
+	
+	// Leave out files that are too large for Telegram to accept
+	videoPath, videoWithSubPath, audioPath := result.VideoPath, result.VideoWithSubPath, result.AudioPath
+	oversized := false
+	var videoParts []string
+	if h.exceedsUploadLimit(videoPath) {
+		videoPath = h.smallerVideo(ctx, url, opts, videoPath)
+		downloadResult.VideoPath = videoPath
+	}
+	if h.exceedsUploadLimit(videoPath) {
+		videoParts = h.splitOversizedVideo(ctx, videoPath)
+		videoPath, oversized = "", len(videoParts) == 0
+	}
+	if h.exceedsUploadLimit(videoWithSubPath) {
+		videoWithSubPath, oversized = "", true
+	}
+	if h.exceedsUploadLimit(audioPath) {
+		audioPath, oversized = "", true
+	}
+	imagePath := result.ImagePath
+	if h.exceedsUploadLimit(imagePath) {
+		imagePath, oversized = "", true
+	}
+	audioTracks := h.sendableAudioTracks(downloadResult.AudioTracks)
+	if len(audioTracks) < len(downloadResult.AudioTracks) {
+		oversized = true
+	}
+	
+	// Send thumbnail if available
+	var err error
+	if result.ThumbnailPath != "" {
+		downloadResult.ThumbnailFileID, err = h.sendThumbnail(chatID, telebot.FromDisk(result.ThumbnailPath), user)
+		sendErrs = append(sendErrs, err)
+	}
+
+	// Send primary video if available
+	downloadResult.VideoFileID, err = h.sendPrimaryVideo(chat, telebot.FromDisk(videoPath), downloadResult.Metadata, user)
+	sendErrs = append(sendErrs, err)
+
+	// Send the parts of a primary video that was split to fit the upload limit
+	sendErrs = append(sendErrs, h.sendVideoParts(chat, videoParts, user))
+
+	// Send video with subtitles if available
+	downloadResult.VideoWithSubFileID, err = h.sendVideoWithSubtitles(chat, telebot.FromDisk(videoWithSubPath), downloadResult.Metadata, user)
+	sendErrs = append(sendErrs, err)
+
+	// Send audio file if available, every track labelled by language when there are several
+	if len(downloadResult.AudioTracks) > 0 {
+		sendErrs = append(sendErrs, h.sendAudioTracks(chat, audioTracks, downloadResult.Metadata, user))
+	} else {
+		downloadResult.AudioFileID, err = h.sendAudioFile(chat, telebot.FromDisk(audioPath), downloadResult.Metadata, user)
+		sendErrs = append(sendErrs, err)
+	}
+
+	// Send subtitle file if available
+	downloadResult.SubtitleFileID, err = h.sendSubtitleFile(chat, telebot.FromDisk(result.SubtitlePath), result.SubtitlePath, h.subtitleCaption(downloadResult, user), downloadResult.Metadata, user)
+	sendErrs = append(sendErrs, err)
+
+	// Send the image of a link to an image file
+	sendErrs = append(sendErrs, h.sendImage(chat, imagePath))
+
+	// Send metadata files if the user asked for them
+	sendErrs = append(sendErrs, h.sendMetadataFiles(chat, result.InfoJSONPath, result.DescriptionPath, user))
+
+	// Point the user to the source for files that could not be uploaded
+	if oversized {
+		h.sendUploadLimitWarning(chat, url, user)
+	}
+
+	// Remember the file IDs so the same download can be resent without re-uploading
+	if !downloadResult.ID.IsZero() {
+		if err := h.downloadRepo.UpdateDownloadResultFileIDs(ctx, downloadResult); err != nil {
+			h.logger.Error("Error saving file IDs for download result: %v", err)
+		}
+	}
+	
+	// Send completion message, offering to download the video again in another quality
+	if !quiet(user) {
+		h.sendTo(chat.ID, h.text(user, "all_files_sent"), h.qualityMarkup(downloadResult, user))
+	}
+}
+
+// exceedsUploadLimit checks if a downloaded file is larger than the configured upload limit
+func (h *BotHandler) exceedsUploadLimit(path string) bool {
+	if path == "" || h.config.Download.MaxUploadSize <= 0 {
+		return false
+	}
+
+	size, err := downloader.FileSize(path)
+	if err != nil {
+		return false
+	}
+
+	if size > h.config.Download.MaxUploadSize {
+		h.logger.Warn("File %s is %d bytes, exceeding the upload limit of %d bytes", path, size, h.config.Download.MaxUploadSize)
+		return true
+	}
+	return false
+}
+
+// smallerVideo downloads a video over the upload limit again in a quality that fits, if splitting is enabled,
+// returning the path of the smaller video, or videoPath when there is none
+func (h *BotHandler) smallerVideo(ctx context.Context, url string, opts downloader.DownloadOptions, videoPath string) string {
+	if !h.config.Download.SplitOversized {
+		return videoPath
+	}
+
+	// The status message already reports the download as completed
+	opts.OnProgress, opts.OnRetry = nil, nil
+	smallerPath, err := h.downloader.DownloadSmallerVideo(ctx, url, opts, videoPath, h.config.Download.MaxUploadSize)
+	if err != nil {
+		h.logger.Warn("No smaller format of %s fits the upload limit, splitting it: %v", url, err)
+		return videoPath
+	}
+	return smallerPath
+}
+
+// splitOversizedVideo splits a video over the upload limit into parts if enabled, returning nil otherwise
+func (h *BotHandler) splitOversizedVideo(ctx context.Context, videoPath string) []string {
+	if !h.config.Download.SplitOversized {
+		return nil
+	}
+
+	parts, err := h.downloader.SplitVideo(ctx, videoPath, h.config.Download.MaxUploadSize)
+	if err != nil {
+		h.logger.Error("Error splitting oversized video %s: %v", videoPath, err)
+		return nil
+	}
+	return parts
+}
+
+// sendVideoParts sends the parts of a split video as a numbered sequence followed by how to rejoin them,
+// stopping at the first part that fails to send
+func (h *BotHandler) sendVideoParts(chat *telebot.Chat, parts []string, user *models.User) error {
+	if len(parts) == 0 {
+		return nil
+	}
+
+	for i, part := range parts {
+		caption := h.textf(user, "file_part", map[string]string{"part": strconv.Itoa(i + 1), "parts": strconv.Itoa(len(parts))})
+
+		// Keep the same file names in every language so the rejoin command works as is
+		video := &telebot.Video{
+			File:     telebot.FromDisk(part),
+			FileName: h.safeFileName(fmt.Sprintf("video_part_%03d.mp4", i+1)),
+			Caption:  caption,
+		}
+
+		if _, err := h.sendFile(chat, video); err != nil {
+			h.logger.Error("Error sending video part %d/%d: %v", i+1, len(parts), err)
+			return err
+		}
+	}
+
+	rejoinCmd := "printf \"file '%s'\\n\" video_part_*.mp4 > parts.txt && ffmpeg -f concat -i parts.txt -c copy video.mp4"
+	noteMsg := h.textf(user, "video_split", map[string]string{"parts": strconv.Itoa(len(parts)), "command": rejoinCmd})
+
+	if _, err := h.sendTo(chat.ID, noteMsg); err != nil {
+		h.logger.Error("Error sending video parts note: %v", err)
+		return err
+	}
+	return nil
+}
+
+// sendUploadLimitWarning tells the user that some files were too large to send and links to the source
+func (h *BotHandler) sendUploadLimitWarning(chat *telebot.Chat, url string, user *models.User) {
+	limitMB := h.config.Download.MaxUploadSize / (1024 * 1024)
+
+	warningMsg := h.textf(user, "upload_limit_warning", map[string]string{"limit": strconv.FormatInt(limitMB, 10), "url": url})
+
+	if _, err := h.sendTo(chat.ID, warningMsg); err != nil {
+		h.logger.Error("Error sending upload limit warning: %v", err)
+	}
+}
+
 // isValidURL checks if a string is a valid URL
 func isValidURL(text string) bool {
-	// This is a simple check, you might want to use a more robust URL validation
-	return len(text) > 8 && (text[:7] == "http://" || text[:8] == "https://")
+	u, err := url.ParseRequestURI(text)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	host := u.Hostname()
+	if net.ParseIP(host) != nil {
+		return true
+	}
+
+	// Require a domain with a top-level domain, e.g. reject "https://x" and "https://1.2.3"
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" {
+			return false
+		}
+	}
+	_, err = strconv.Atoi(labels[len(labels)-1])
+	return err != nil
 }
 
 // fileExists checks if a file exists
@@ -879,3 +1292,89 @@ func fileExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
+
+// isSendable checks if a file is stored on Telegram's servers or exists on disk
+func isSendable(file telebot.File) bool {
+	if file.FileID != "" {
+		return true
+	}
+	return file.FileLocal != "" && fileExists(file.FileLocal)
+}
+
+// sentFileID returns the Telegram file ID of the media in a sent message
+func sentFileID(msg *telebot.Message) string {
+	if msg == nil {
+		return ""
+	}
+	media := msg.Media()
+	if media == nil {
+		return ""
+	}
+	return media.MediaFile().FileID
+}
+
+// findUser looks up a user to localize messages with, returning nil if the user can't be found
+func (h *BotHandler) findUser(chatID int64) *models.User {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
+	if err != nil {
+		h.logger.Error("Error finding user: %v", err)
+	}
+	return user
+}
+
+// language returns the language code to localize a user's messages in, the default language for unknown users
+func (h *BotHandler) language(user *models.User) string {
+	if user == nil || user.InterfaceLanguage == "" {
+		return h.lm.GetDefaultLanguage()
+	}
+	return user.InterfaceLanguage
+}
+
+// text returns the string of a key from the language files in the user's interface language
+func (h *BotHandler) text(user *models.User, key string) string {
+	return h.lm.GetString(h.language(user), key)
+}
+
+// textf returns the string of a key like text, with its {name} placeholders replaced by params
+func (h *BotHandler) textf(user *models.User, key string, params map[string]string) string {
+	return h.lm.GetStringf(h.language(user), key, params)
+}
+
+// errorMessage returns the localized message for an error the user can only wait out, telling them when the
+// database is down rather than that something went wrong
+func (h *BotHandler) errorMessage(user *models.User, err error) string {
+	if errors.Is(err, database.ErrDatabaseUnavailable) {
+		return h.text(user, "error_database_unavailable")
+	}
+	return h.text(user, "error_general")
+}
+
+// invalidRequest answers a button whose data can't be parsed, e.g. one of a message sent by an older version
+func (h *BotHandler) invalidRequest(c telebot.Context, user *models.User) error {
+	return c.Respond(&telebot.CallbackResponse{Text: h.text(user, "invalid_request")})
+}
+
+// hasFreeDiskSpace checks that the download directory has at least the configured free space
+func (h *BotHandler) hasFreeDiskSpace() bool {
+	free, err := utils.FreeDiskSpace(h.config.Download.TempDir)
+	if err != nil {
+		h.logger.Warn("Failed to check free disk space, accepting download: %v", err)
+		return true
+	}
+
+	h.logger.Debug("Free disk space in %s: %d bytes", h.config.Download.TempDir, free)
+	if h.config.Download.MinFreeSpace > 0 && free < uint64(h.config.Download.MinFreeSpace) {
+		h.logger.Warn("Refusing download: %d bytes free in %s, below the minimum of %d bytes",
+			free, h.config.Download.TempDir, h.config.Download.MinFreeSpace)
+		return false
+	}
+	return true
+}
+
+// proxyUnreachableMessage returns the localized message for a download that failed because the proxy is down
+func (h *BotHandler) proxyUnreachableMessage(user *models.User) string {
+	return h.text(user, "error_proxy_unreachable")
+}
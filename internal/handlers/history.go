@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/config"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gopkg.in/telebot.v3"
+)
+
+// historyPageSize is the number of downloads listed per /history page
+const historyPageSize = 10
+
+// handleHistory handles the /history command that lists the user's recent downloads
+func (h *BotHandler) handleHistory(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /history command from chat ID: %d", chatID)
+
+	user := h.findUser(chatID)
+	text, markup, err := h.historyPage(chatID, user, 0)
+	if err != nil {
+		return h.send(c, h.errorMessage(user, err))
+	}
+	return h.send(c, text, markup, telebot.NoPreview)
+}
+
+// handleHistoryPage handles the buttons that move between /history pages
+func (h *BotHandler) handleHistoryPage(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	page, err := strconv.Atoi(c.Data())
+	if err != nil || page < 0 {
+		h.logger.Warn("Invalid history page in button from chat ID %d: %s", chatID, c.Data())
+		return h.invalidRequest(c, h.findUser(chatID))
+	}
+
+	user := h.findUser(chatID)
+	text, markup, err := h.historyPage(chatID, user, page)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: h.errorMessage(user, err)})
+	}
+
+	c.Respond()
+	return h.edit(c, text, markup, telebot.NoPreview)
+}
+
+// handleHistoryResend handles the resend button of a /history entry by sending its files from disk
+func (h *BotHandler) handleHistoryResend(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	resultID, err := primitive.ObjectIDFromHex(c.Data())
+	if err != nil {
+		h.logger.Warn("Invalid download result ID in history button from chat ID %d: %s", chatID, c.Data())
+		return h.invalidRequest(c, h.findUser(chatID))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user := h.findUser(chatID)
+	result, err := h.downloadRepo.GetDownloadResultByID(ctx, resultID)
+	if err != nil || result == nil || result.ChatID != chatID || !resultOnDisk(result) {
+		// The files may have been cleaned up since the history was listed
+		return c.Respond(&telebot.CallbackResponse{
+			Text:      h.text(user, "download_expired"),
+			ShowAlert: true,
+		})
+	}
+
+	h.logger.Info("Resending download result %s from history to chat ID %d", result.ID.Hex(), chatID)
+	c.Respond()
+
+	// Leave out files that are too large for Telegram to accept
+	videoPath, videoWithSubPath, audioPath := result.VideoPath, result.VideoWithSubPath, result.AudioPath
+	oversized := false
+	if h.exceedsUploadLimit(videoPath) {
+		videoPath, oversized = "", true
+	}
+	if h.exceedsUploadLimit(videoWithSubPath) {
+		videoWithSubPath, oversized = "", true
+	}
+	if h.exceedsUploadLimit(audioPath) {
+		audioPath, oversized = "", true
+	}
+
+	chat := c.Chat()
+	h.sendPrimaryVideo(chat, telebot.FromDisk(videoPath), result.Metadata, user)
+	h.sendVideoWithSubtitles(chat, telebot.FromDisk(videoWithSubPath), result.Metadata, user)
+	if len(result.AudioTracks) > 0 {
+		audioTracks := h.sendableAudioTracks(result.AudioTracks)
+		oversized = oversized || len(audioTracks) < len(result.AudioTracks)
+		h.sendAudioTracks(chat, audioTracks, result.Metadata, user)
+	} else {
+		h.sendAudioFile(chat, telebot.FromDisk(audioPath), result.Metadata, user)
+	}
+	h.sendSubtitleFile(chat, telebot.FromDisk(result.SubtitlePath), result.SubtitlePath, h.subtitleCaption(result, user), result.Metadata, user)
+
+	if oversized {
+		h.sendUploadLimitWarning(chat, result.URL, user)
+	}
+	return nil
+}
+
+// historyPage returns the localized listing and buttons of a page of the chat's downloads
+func (h *BotHandler) historyPage(chatID int64, user *models.User, page int) (string, *telebot.ReplyMarkup, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Fetch one extra result to know whether an older page exists
+	results, err := h.downloadRepo.GetResultsByChatID(ctx, chatID, historyPageSize+1, int64(page*historyPageSize))
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(results) == 0 {
+		if page > 0 {
+			return h.text(user, "history_no_older"), &telebot.ReplyMarkup{}, nil
+		}
+		return h.text(user, "history_empty"), &telebot.ReplyMarkup{}, nil
+	}
+
+	hasOlder := len(results) > historyPageSize
+	if hasOlder {
+		results = results[:historyPageSize]
+	}
+
+	var lines []string
+	lines = append(lines, h.text(user, "history_title"))
+
+	var buttons [][]telebot.InlineButton
+	var resendRow []telebot.InlineButton
+	for i, result := range results {
+		number := page*historyPageSize + i + 1
+		line := fmt.Sprintf("\n%d. %s\n%s", number, result.URL, result.CreatedAt.UTC().Format("2006-01-02 15:04 UTC"))
+
+		if resultOnDisk(result) {
+			resendRow = append(resendRow, telebot.InlineButton{
+				Text:   fmt.Sprintf("%s %d", h.text(user, "btn_resend"), number),
+				Unique: "history_resend",
+				Data:   result.ID.Hex(),
+			})
+			// Keep the buttons readable on narrow screens
+			if len(resendRow) == 2 {
+				buttons = append(buttons, resendRow)
+				resendRow = nil
+			}
+		} else {
+			line += " - " + h.text(user, "history_expired")
+		}
+		lines = append(lines, line)
+	}
+	if len(resendRow) > 0 {
+		buttons = append(buttons, resendRow)
+	}
+
+	var navRow []telebot.InlineButton
+	if page > 0 {
+		navRow = append(navRow, telebot.InlineButton{
+			Text:   h.text(user, "btn_newer"),
+			Unique: "history_page",
+			Data:   strconv.Itoa(page - 1),
+		})
+	}
+	if hasOlder {
+		navRow = append(navRow, telebot.InlineButton{
+			Text:   h.text(user, "btn_older"),
+			Unique: "history_page",
+			Data:   strconv.Itoa(page + 1),
+		})
+	}
+	if len(navRow) > 0 {
+		buttons = append(buttons, navRow)
+	}
+
+	return strings.Join(lines, "\n"), &telebot.ReplyMarkup{InlineKeyboard: buttons}, nil
+}
+
+// resultOnDisk reports whether the files of a download result haven't been cleaned up yet
+func resultOnDisk(result *models.DownloadResult) bool {
+	if result.FilesExpired {
+		return false
+	}
+	return fileExists(result.VideoPath) || fileExists(result.VideoWithSubPath) || fileExists(result.AudioPath)
+}
+
+// resultRetention returns how long download results are kept
+func resultRetention(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.Download.ResultRetention) * 24 * time.Hour
+}
+
+// CleanupResults deletes the download results older than the retention and flags those whose files were removed
+// from disk, so /history shows them as expired without checking the disk again
+func (h *BotHandler) CleanupResults(ctx context.Context) {
+	if h.config.Download.ResultRetention > 0 {
+		// The TTL index normally deletes them first, this covers deployments where it couldn't be created
+		deleted, err := h.downloadRepo.DeleteOldResults(ctx, time.Now().Add(-resultRetention(h.config)))
+		if err != nil {
+			h.logger.Error("Error deleting old download results: %v", err)
+		} else if deleted > 0 {
+			h.logger.Info("Deleted %d download results older than %d days", deleted, h.config.Download.ResultRetention)
+		}
+	}
+
+	results, err := h.downloadRepo.FindResultsWithFiles(ctx)
+	if err != nil {
+		h.logger.Error("Error finding download results to check for removed files: %v", err)
+		return
+	}
+
+	var expired []primitive.ObjectID
+	for _, result := range results {
+		if !resultOnDisk(result) {
+			expired = append(expired, result.ID)
+		}
+	}
+	marked, err := h.downloadRepo.MarkResultsExpired(ctx, expired)
+	if err != nil {
+		h.logger.Error("Error flagging download results with removed files: %v", err)
+		return
+	}
+	if marked > 0 {
+		h.logger.Info("Flagged %d download results whose files were removed", marked)
+	}
+}
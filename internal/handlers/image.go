@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"path/filepath"
+
+	"gopkg.in/telebot.v3"
+)
+
+// sendImage sends an image downloaded from a link to an image file as a photo, or as a document when Telegram
+// doesn't accept it as a photo, e.g. because of its dimensions or format
+func (h *BotHandler) sendImage(chat *telebot.Chat, imagePath string) error {
+	if imagePath == "" || !fileExists(imagePath) {
+		return nil
+	}
+
+	photo := &telebot.Photo{File: telebot.FromDisk(imagePath)}
+	_, err := h.sendFile(chat, photo)
+	if err == nil {
+		return nil
+	}
+	h.logger.Warn("Error sending image as photo, sending it as a document: %v", err)
+
+	doc := &telebot.Document{
+		File:     telebot.FromDisk(imagePath),
+		FileName: h.safeFileName("image" + filepath.Ext(imagePath)),
+	}
+	if _, err := h.sendFile(chat, doc); err != nil {
+		h.logger.Error("Error sending image: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/i18n"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+)
+
+// newLanguageHandler creates a handler with language files for the given codes
+func newLanguageHandler(t *testing.T, langCodes ...string) *BotHandler {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, langCode := range langCodes {
+		if err := os.WriteFile(filepath.Join(dir, langCode+".json"), []byte(`{"greeting": "hi"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger, err := utils.NewEnhancedLogger(&utils.EnhancedLoggerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lm, err := i18n.NewLanguageManager(dir, "en", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &BotHandler{lm: lm}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	h := newLanguageHandler(t, "en", "de", "pt-BR")
+	h.lm.WithFallbacks(map[string][]string{"es-MX": {"de"}})
+
+	tests := []struct {
+		languageCode string
+		want         string
+	}{
+		{"de", "de"},
+		{"de-AT", "de"},
+		{"pt-BR", "pt-br"},
+		{"es-MX", "es-mx"},
+		{"es-AR", "en"},
+		{"ja", "en"},
+		{"", "en"},
+	}
+	for _, tt := range tests {
+		if got := h.detectLanguage(tt.languageCode); got != tt.want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tt.languageCode, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageName(t *testing.T) {
+	for langCode, want := range map[string]string{
+		"de":    "Deutsch",
+		"es-mx": "Español (es-mx)",
+		"ja":    "ja",
+		"":      "English",
+	} {
+		if got := languageName(langCode); got != want {
+			t.Errorf("languageName(%q) = %q, want %q", langCode, got, want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/config/languages"
+)
+
+// messageKeyPattern matches the language file keys the handlers look up, e.g. h.text(user, "download_error")
+var messageKeyPattern = regexp.MustCompile(`(?:h\.text|h\.textf|GetString|GetStringf)\([^,()]+(?:\([^()]*\))?, "([\w.]+)"|h\.reply\([^,]+, [^,]+, "([\w.]+)"`)
+
+// usedMessageKeys returns the keys looked up by the handler sources
+func usedMessageKeys(t *testing.T) map[string]bool {
+	t.Helper()
+
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]bool)
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, match := range messageKeyPattern.FindAllStringSubmatch(string(source), -1) {
+			keys[match[1]+match[2]] = true
+		}
+	}
+	return keys
+}
+
+// loadLanguage parses a shipped language file
+func loadLanguage(t *testing.T, langCode string) map[string]string {
+	t.Helper()
+
+	data, err := languages.Files.ReadFile(langCode + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		t.Fatalf("%s.json: %v", langCode, err)
+	}
+	return messages
+}
+
+func TestMessageKeysExistInDefaultLanguage(t *testing.T) {
+	keys := usedMessageKeys(t)
+	if len(keys) < 100 {
+		t.Fatalf("found only %d message keys, the pattern no longer matches the lookups", len(keys))
+	}
+
+	en := loadLanguage(t, "en")
+	for key := range keys {
+		if _, ok := en[key]; !ok {
+			t.Errorf("key %q is used by the handlers but missing from en.json", key)
+		}
+	}
+}
+
+func TestLanguagesTranslateEveryMessage(t *testing.T) {
+	entries, err := languages.Files.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	en := loadLanguage(t, "en")
+	placeholder := regexp.MustCompile(`\{\w+\}`)
+	for _, entry := range entries {
+		langCode, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || langCode == "en" {
+			continue
+		}
+		messages := loadLanguage(t, langCode)
+		for key, text := range en {
+			translation, ok := messages[key]
+			if !ok {
+				t.Errorf("%s.json is missing key %q", langCode, key)
+				continue
+			}
+			if strings.Contains(key, ".") {
+				// plural forms may spell out their count, like "one download"
+				continue
+			}
+			for _, name := range placeholder.FindAllString(text, -1) {
+				if !strings.Contains(translation, name) {
+					t.Errorf("%s.json key %q is missing placeholder %s", langCode, key, name)
+				}
+			}
+		}
+	}
+}
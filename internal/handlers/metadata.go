@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
+
+// handleMetadata handles the /metadata command that turns sending metadata files on or off
+func (h *BotHandler) handleMetadata(c telebot.Context) error {
+	return h.handleToggle(c, toggleSetting{
+		command:     "metadata",
+		get:         func(user *models.User) bool { return user.IncludeMetadata },
+		set:         h.userRepo.UpdateUserIncludeMetadata,
+		statusOn:    "metadata_status_on",
+		statusOff:   "metadata_status_off",
+		enabledKey:  "metadata_enabled",
+		disabledKey: "metadata_disabled",
+	})
+}
+
+// sendMetadataFiles sends the info JSON and description files of a download as documents, returning the errors
+// of those that failed to send
+func (h *BotHandler) sendMetadataFiles(chat *telebot.Chat, infoJSONPath, descriptionPath string, user *models.User) error {
+	var errs []error
+	if infoJSONPath != "" && fileExists(infoJSONPath) {
+		doc := &telebot.Document{
+			File:     telebot.FromDisk(infoJSONPath),
+			FileName: h.safeFileName("info.json"),
+			Caption:  h.text(user, "metadata_caption_info"),
+		}
+		if _, err := h.sendFile(chat, doc); err != nil {
+			h.logger.Error("Error sending info JSON file: %v", err)
+			errs = append(errs, err)
+		}
+	}
+
+	if descriptionPath != "" && fileExists(descriptionPath) {
+		doc := &telebot.Document{
+			File:     telebot.FromDisk(descriptionPath),
+			FileName: h.safeFileName("description.txt"),
+			Caption:  h.text(user, "metadata_caption_description"),
+		}
+		if _, err := h.sendFile(chat, doc); err != nil {
+			h.logger.Error("Error sending description file: %v", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
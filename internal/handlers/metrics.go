@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/health"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/metrics"
+
+	"gopkg.in/telebot.v3"
+)
+
+// downloadMetrics counts the downloads processed by the workers of this instance, updated without locks
+type downloadMetrics struct {
+	requests  atomic.Int64 // downloads started
+	successes atomic.Int64 // downloads completed
+	failures  atomic.Int64 // downloads failed, cancelled ones excluded
+	cancelled atomic.Int64 // downloads cancelled by their user
+	active    atomic.Int64 // downloads in progress
+	bytes     atomic.Int64 // size of the completed downloads
+	since     atomic.Int64 // Unix time the counters were started or last reset
+}
+
+// downloadMetricsSnapshot is a copy of the metrics at one point in time
+type downloadMetricsSnapshot struct {
+	requests, successes, failures, cancelled, active, bytes int64
+	since                                                   time.Time
+}
+
+// newDownloadMetrics creates metrics counting from now
+func newDownloadMetrics() *downloadMetrics {
+	m := &downloadMetrics{}
+	m.since.Store(time.Now().Unix())
+	return m
+}
+
+// started records a download that started, call finished once it ends
+func (m *downloadMetrics) started() {
+	m.requests.Add(1)
+	m.active.Add(1)
+}
+
+// finished records the end of a download, its size counts only for completed downloads
+func (m *downloadMetrics) finished(outcome string, size int64) {
+	m.active.Add(-1)
+	switch outcome {
+	case "completed":
+		m.successes.Add(1)
+		m.bytes.Add(size)
+	case "cancelled":
+		m.cancelled.Add(1)
+	default:
+		m.failures.Add(1)
+	}
+}
+
+// snapshot returns the current metrics. With reset the counters start over from zero, the active downloads are a
+// gauge and are kept.
+func (m *downloadMetrics) snapshot(reset bool) downloadMetricsSnapshot {
+	read := func(counter *atomic.Int64) int64 {
+		if reset {
+			return counter.Swap(0)
+		}
+		return counter.Load()
+	}
+
+	s := downloadMetricsSnapshot{
+		requests:  read(&m.requests),
+		successes: read(&m.successes),
+		failures:  read(&m.failures),
+		cancelled: read(&m.cancelled),
+		bytes:     read(&m.bytes),
+		active:    m.active.Load(),
+	}
+	if reset {
+		s.since = time.Unix(m.since.Swap(time.Now().Unix()), 0)
+	} else {
+		s.since = time.Unix(m.since.Load(), 0)
+	}
+	return s
+}
+
+// downloadFinished records the end of a download in the counters of /metrics and, when download metrics are
+// enabled, in those served to Prometheus
+func (h *BotHandler) downloadFinished(url, outcome string, size int64) {
+	h.metrics.finished(outcome, size)
+	if h.collector != nil {
+		h.collector.DownloadFinished(downloader.PlatformOf(url), outcome)
+	}
+}
+
+// Metrics returns the collector of the download metrics served to Prometheus, nil when they are disabled
+func (h *BotHandler) Metrics() *metrics.Collector {
+	return h.collector
+}
+
+// DownloadStats returns the downloads in progress and waiting on this instance, as reported by /healthz
+func (h *BotHandler) DownloadStats() health.DownloadStats {
+	queue := h.queue.Stats()
+	return health.DownloadStats{
+		Active:  h.metrics.active.Load(),
+		Queued:  queue.Waiting,
+		Workers: queue.Workers,
+	}
+}
+
+// handleMetrics handles the /metrics admin command that shows the download counters of this instance
+func (h *BotHandler) handleMetrics(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /metrics command from chat ID: %d", chatID)
+
+	if !h.isAdmin(chatID) {
+		return nil
+	}
+	h.audit(chatID, "metrics", "")
+
+	reset := h.config.Admin.MetricsResetOnRead
+	s := h.metrics.snapshot(reset)
+
+	user := h.findUser(chatID)
+	text := h.textf(user, "metrics_text", map[string]string{
+		"since":     s.since.UTC().Format("2006-01-02 15:04:05 UTC"),
+		"ago":       time.Since(s.since).Round(time.Second).String(),
+		"started":   strconv.FormatInt(s.requests, 10),
+		"completed": strconv.FormatInt(s.successes, 10),
+		"failed":    strconv.FormatInt(s.failures, 10),
+		"cancelled": strconv.FormatInt(s.cancelled, 10),
+		"active":    strconv.FormatInt(s.active, 10),
+		"size":      fmt.Sprintf("%.1f", float64(s.bytes)/(1024*1024)),
+	})
+	if reset {
+		text += h.text(user, "metrics_reset")
+	}
+	return h.send(c, text)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gopkg.in/telebot.v3"
+)
+
+// isPlaylistDownload checks if a URL should be downloaded as a playlist
+func (h *BotHandler) isPlaylistDownload(url string) bool {
+	return h.config.Download.MaxPlaylistSize > 0 && downloader.IsPlaylistURL(url)
+}
+
+// processPlaylist downloads the entries of a playlist one by one, sending each video as soon as it is ready.
+// The context is done once the bot shuts down.
+func (h *BotHandler) processPlaylist(ctx context.Context, requestID primitive.ObjectID, chatID int64, url string, opts downloader.DownloadOptions, statusMsg *telebot.Message) {
+	// Every line logged about the download, here, in the downloader and in the repository, carries its IDs
+	ctx, log := h.downloadLogger(ctx, requestID, opts.CorrelationID)
+
+	// Update request status to processing
+	h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID, "processing")
+
+	user, _ := h.userRepo.FindUserByChatID(ctx, chatID)
+	chat := &telebot.Chat{ID: chatID}
+
+	// With zip delivery the entries are kept until the whole playlist is downloaded and bundled
+	zipDelivery := user != nil && user.ZipPlaylists
+	var zipEntries []downloader.ArchiveEntry
+	defer func() {
+		for _, entry := range zipEntries {
+			if err := os.RemoveAll(filepath.Dir(entry.Path)); err != nil {
+				log.Warn("Failed to remove playlist entry directory: %v", err)
+			}
+		}
+	}()
+
+	var sent, total int
+	var size int64
+	progress := downloader.PlaylistProgress{
+		OnStart: func(index, count int) {
+			total = count
+			h.editProgress(statusMsg, h.textf(user, "playlist_downloading", map[string]string{
+				"index": strconv.Itoa(index),
+				"total": strconv.Itoa(count),
+			}))
+		},
+		OnDone: func(index, count int, result *downloader.DownloadResult, err error) {
+			if err != nil {
+				return
+			}
+			size += result.FileSize
+			if zipDelivery {
+				zipEntries = append(zipEntries, downloader.ArchiveEntry{
+					Path: playlistItemVideo(result),
+					Name: fmt.Sprintf("%s %d.mp4", h.text(user, "file_video"), index),
+				})
+				return
+			}
+			if h.sendPlaylistItem(chat, result, index, count, user) {
+				sent++
+			}
+
+			// Each entry has its own download directory, remove it as soon as the entry was sent
+			if err := os.RemoveAll(filepath.Dir(result.VideoPath)); err != nil {
+				log.Warn("Failed to remove playlist entry directory: %v", err)
+			}
+		},
+	}
+
+	// Download with a context the user can cancel through /cancel
+	downloadCtx, cancel := context.WithCancel(ctx)
+	h.trackDownload(chatID, requestID, cancel)
+	h.metrics.started()
+	_, err := h.downloader.DownloadPlaylist(downloadCtx, url, opts, h.config.Download.MaxPlaylistSize, progress)
+	h.untrackDownload(chatID, requestID)
+	cancel()
+
+	if ctx.Err() != nil {
+		h.downloadInterrupted(log, requestID, chatID, statusMsg)
+		return
+	}
+
+	if downloadCtx.Err() == context.Canceled {
+		h.downloadFinished(url, "cancelled", 0)
+		// The request status was already set to cancelled by /cancel
+		log.Info("Playlist download of request %s was cancelled by chat ID %d", requestID.Hex(), chatID)
+		h.editStatus(statusMsg, h.cancelledMessage(user))
+		return
+	}
+
+	if err != nil {
+		h.downloadFinished(url, "failed", 0)
+		h.logDownloadError(log, requestID, chatID, opts.CorrelationID, "Playlist download failed", err, "")
+		h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID, "failed")
+		h.editStatus(statusMsg, h.text(user, "playlist_error")+h.errorRef(opts.CorrelationID, user))
+		return
+	}
+
+	// The entries are downloaded, send and record them even if the bot is shutting down meanwhile
+	ctx = context.WithoutCancel(ctx)
+
+	if zipDelivery {
+		h.editStatus(statusMsg, h.text(user, "playlist_zipping"))
+		sent = h.sendPlaylistZip(chat, requestID, zipEntries, user)
+	}
+
+	// A playlist none of whose entries reached the user failed, whatever the reason
+	if sent == 0 {
+		h.downloadFinished(url, "failed", 0)
+		log.Error("No entry of playlist request %s was delivered to chat ID %d", requestID.Hex(), chatID)
+		h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID, "failed")
+		h.editStatus(statusMsg, h.text(user, "playlist_error")+h.errorRef(opts.CorrelationID, user))
+		return
+	}
+
+	h.downloadFinished(url, "completed", size)
+	h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID, "completed")
+	h.editStatus(statusMsg, h.textf(user, "playlist_finished", map[string]string{
+		"sent":  strconv.Itoa(sent),
+		"total": strconv.Itoa(total),
+	}))
+}
+
+// sendPlaylistItem sends the video of a playlist entry, preferring the version with embedded subtitles
+func (h *BotHandler) sendPlaylistItem(chat *telebot.Chat, result *downloader.DownloadResult, index, total int, user *models.User) bool {
+	videoPath := playlistItemVideo(result)
+
+	if h.exceedsUploadLimit(videoPath) {
+		h.logger.Warn("Skipping playlist entry %d/%d: file is too large to upload", index, total)
+		return false
+	}
+
+	video := &telebot.Video{
+		File:     telebot.FromDisk(videoPath),
+		FileName: h.safeFileName(h.mediaFileName(videoMetadata(result.Metadata), fmt.Sprintf("%s %d", h.text(user, "file_video"), index), ".mp4")),
+		Caption:  fmt.Sprintf("%d/%d", index, total),
+	}
+
+	if _, err := h.sendFile(chat, video); err != nil {
+		h.logger.Error("Error sending playlist entry %d/%d: %v", index, total, err)
+		return false
+	}
+	return true
+}
+
+// playlistItemVideo returns the video file of a playlist entry, preferring the version with embedded subtitles
+func playlistItemVideo(result *downloader.DownloadResult) string {
+	if result.VideoWithSubPath != "" && fileExists(result.VideoWithSubPath) {
+		return result.VideoWithSubPath
+	}
+	return result.VideoPath
+}
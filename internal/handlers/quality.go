@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/config"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gopkg.in/telebot.v3"
+)
+
+// qualityProbeTimeout bounds listing the qualities of a video, the probe is usually cached from the download
+const qualityProbeTimeout = time.Minute
+
+// qualityMarkup returns the button that downloads a video again in another quality, nil for downloads without
+// a stored result or without a video
+func (h *BotHandler) qualityMarkup(result *models.DownloadResult, user *models.User) *telebot.ReplyMarkup {
+	if result.ID.IsZero() || result.VideoPath == "" || !h.featureEnabled(config.FeatureChangeQuality) {
+		return nil
+	}
+	return &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{{{
+			Text:   h.text(user, "btn_change_quality"),
+			Unique: "change_quality",
+			Data:   result.ID.Hex(),
+		}}},
+	}
+}
+
+// handleChangeQuality handles the button of a finished download by offering the qualities the video is available in
+func (h *BotHandler) handleChangeQuality(c telebot.Context) error {
+	chatID := c.Chat().ID
+	user := h.findUser(chatID)
+
+	result, err := h.qualityResult(c, c.Data(), user)
+	if result == nil {
+		return err
+	}
+	c.Respond()
+
+	ctx, cancel := context.WithTimeout(context.Background(), qualityProbeTimeout)
+	defer cancel()
+
+	heights, err := h.downloader.ListQualities(ctx, result.URL, h.downloadOptions(chatID, user).CookiesFile)
+	if err != nil {
+		h.logger.Error("Error listing the qualities of %s: %v", result.URL, err)
+		if errors.Is(err, downloader.ErrAuthRequired) {
+			return h.send(c, h.authRequiredMessage(user))
+		}
+		return h.send(c, h.text(user, "error_general"))
+	}
+	if len(heights) == 0 {
+		return h.reply(c, h.language(user), "quality_none", nil)
+	}
+
+	var buttons [][]telebot.InlineButton
+	var row []telebot.InlineButton
+	for _, height := range heights {
+		row = append(row, telebot.InlineButton{
+			Text:   fmt.Sprintf("%dp", height),
+			Unique: "pick_quality",
+			Data:   result.ID.Hex() + ":" + strconv.Itoa(height),
+		})
+		if len(row) == 3 {
+			buttons = append(buttons, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		buttons = append(buttons, row)
+	}
+	return h.reply(c, h.language(user), "quality_choose", nil, &telebot.ReplyMarkup{InlineKeyboard: buttons})
+}
+
+// handlePickQuality handles the quality buttons by downloading the video of a finished download again in that quality
+func (h *BotHandler) handlePickQuality(c telebot.Context) error {
+	chatID := c.Chat().ID
+	user := h.findUser(chatID)
+
+	id, heightData, _ := strings.Cut(c.Data(), ":")
+	height, err := strconv.Atoi(heightData)
+	if err != nil || height <= 0 {
+		h.logger.Warn("Invalid quality in button from chat ID %d: %s", chatID, c.Data())
+		return h.invalidRequest(c, user)
+	}
+	result, err := h.qualityResult(c, id, user)
+	if result == nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Another quality counts towards the rate limit like a new download
+	allowed, err := h.rateLimiter.Allow(ctx, utils.RateLimitDownload, fmt.Sprintf("%d", chatID))
+	if err != nil {
+		h.logger.Warn("Rate limiter error for chat ID %d, allowing request: %v", chatID, err)
+	}
+	if !allowed {
+		return c.Respond(&telebot.CallbackResponse{Text: h.text(user, "error_rate_limit"), ShowAlert: true})
+	}
+
+	h.logger.Info("User %d chose %dp for %s", chatID, height, result.URL)
+	c.Respond()
+	// Remove the buttons so the choice isn't queued twice
+	if _, err := h.bot.EditReplyMarkup(c.Message(), nil); err != nil {
+		h.logger.Warn("Failed to remove quality buttons: %v", err)
+	}
+
+	request := models.NewDownloadRequest(chatID, result.URL)
+	request.Source = "download"
+	request.MaxHeight = height
+	_, err = h.queueRequest(c.Chat(), request, user, h.downloadPriority(nil))
+	return err
+}
+
+// qualityResult returns the download result of a quality button, answering the button and returning nil when it
+// is invalid, belongs to another chat, or was deleted since
+func (h *BotHandler) qualityResult(c telebot.Context, id string, user *models.User) (*models.DownloadResult, error) {
+	chatID := c.Chat().ID
+
+	resultID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		h.logger.Warn("Invalid download result ID in quality button from chat ID %d: %s", chatID, c.Data())
+		return nil, h.invalidRequest(c, user)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.downloadRepo.GetDownloadResultByID(ctx, resultID)
+	if err != nil {
+		return nil, c.Respond(&telebot.CallbackResponse{Text: h.errorMessage(user, err), ShowAlert: true})
+	}
+	if result == nil || result.ChatID != chatID || result.URL == "" {
+		// Old results are deleted after DOWNLOAD_RESULT_RETENTION days
+		return nil, c.Respond(&telebot.CallbackResponse{Text: h.text(user, "quality_unavailable"), ShowAlert: true})
+	}
+	return result, nil
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/config"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/worker"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gopkg.in/telebot.v3"
+)
+
+// resumeRetryInterval is how long a resumed download waits for room in a full queue before trying again
+const resumeRetryInterval = 10 * time.Second
+
+// StartQueue starts the download workers and resumes the downloads interrupted by a restart in the background
+func (h *BotHandler) StartQueue(ctx context.Context) {
+	h.ctx = ctx
+	h.queue.Start(ctx)
+	go h.resumePendingDownloads(ctx)
+}
+
+// StopQueue stops taking downloads and leaves the requests still waiting for a worker pending, so they are
+// resumed on the next start instead of being lost. Resumed requests wait in the queue as processing, which is
+// reset as well. Slots aren't given back, the bot is stopping and quiet users would get a partial summary.
+func (h *BotHandler) StopQueue() {
+	jobs := h.queue.Stop()
+	if len(jobs) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, job := range jobs {
+		requestID, err := primitive.ObjectIDFromHex(job.ID)
+		if err != nil {
+			continue
+		}
+		if err := h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID, "pending"); err != nil {
+			h.logger.Error("Error leaving download request %s pending: %v", job.ID, err)
+		}
+	}
+	h.logger.Info("Left %d queued download requests pending for the next start", len(jobs))
+}
+
+// resumePendingDownloads enqueues the download requests that were waiting or running when the bot stopped.
+// Requests that don't fit in the queue stay pending and wait for room, or for the next start if the bot stops first.
+func (h *BotHandler) resumePendingDownloads(ctx context.Context) {
+	findCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	requests, err := h.downloadRepo.FindRequestsByStatus(findCtx, []string{"pending", "processing"})
+	cancel()
+	if err != nil {
+		h.logger.Error("Error loading pending download requests: %v", err)
+		return
+	}
+
+	if len(requests) > 0 {
+		h.logger.Info("Resuming %d pending download requests", len(requests))
+	}
+
+	for _, request := range requests {
+		// Only downloads go through the queue, other requests are answered right away
+		if request.Source != "" && request.Source != "download" && request.Source != "audio" {
+			updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			h.downloadRepo.UpdateDownloadRequestStatus(updateCtx, request.ID, "failed")
+			cancel()
+			continue
+		}
+
+		user := h.findUser(request.ChatID)
+		statusMsg, err := h.sendTo(request.ChatID, h.textf(user, "download_resuming", map[string]string{"url": request.URL}), telebot.NoPreview)
+		if err != nil {
+			h.logger.Error("Error sending resume message: %v", err)
+		}
+
+		h.acquireUserSlot(request.ChatID, true)
+		job := h.downloadJob(request, h.requestOptions(request, user), h.downloadPriority(nil), statusMsg, user)
+		position, err := h.queue.Enqueue(job)
+		for errors.Is(err, worker.ErrQueueFull) {
+			timer := time.NewTimer(resumeRetryInterval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				h.releaseUserSlot(request.ChatID)
+				h.logger.Info("Left the remaining download requests pending for the next start")
+				return
+			}
+			position, err = h.queue.Enqueue(job)
+		}
+		h.reportEnqueued(request, position, err, statusMsg, user)
+	}
+}
+
+// enqueueDownload adds a download request to the worker queue and tells the user their position.
+// It reports whether the request was queued. The caller must have acquired a slot of the chat, which is
+// released when the download ends or isn't queued.
+func (h *BotHandler) enqueueDownload(request *models.DownloadRequest, opts downloader.DownloadOptions, priority int, statusMsg *telebot.Message, user *models.User) bool {
+	position, err := h.queue.Enqueue(h.downloadJob(request, opts, priority, statusMsg, user))
+	return h.reportEnqueued(request, position, err, statusMsg, user)
+}
+
+// downloadJob returns the job running a download request on the worker queue
+func (h *BotHandler) downloadJob(request *models.DownloadRequest, opts downloader.DownloadOptions, priority int, statusMsg *telebot.Message, user *models.User) *worker.Job {
+	requestID, chatID, url := request.ID, request.ChatID, request.URL
+
+	return &worker.Job{
+		ID:       requestID.Hex(),
+		ChatID:   chatID,
+		Priority: priority,
+		Run: func(ctx context.Context) {
+			defer h.releaseUserSlot(chatID)
+			defer h.recordQuietOutcome(request, user)
+			defer h.recoverDownload(request, statusMsg, user)
+
+			if !opts.AudioOnly && h.isPlaylistDownload(url) {
+				h.processPlaylist(ctx, requestID, chatID, url, opts, statusMsg)
+			} else {
+				h.processDownload(ctx, requestID, chatID, url, opts, statusMsg)
+			}
+			h.tidyGroupChat(request, statusMsg)
+		},
+	}
+}
+
+// reportEnqueued tells the user the outcome of enqueueing a download request and reports whether it was queued.
+// The slot of the chat is released when it wasn't.
+func (h *BotHandler) reportEnqueued(request *models.DownloadRequest, position int, err error, statusMsg *telebot.Message, user *models.User) bool {
+	requestID, chatID := request.ID, request.ChatID
+
+	if errors.Is(err, worker.ErrQueueStopped) {
+		// The bot is shutting down, the request stays pending and is resumed once it is back
+		h.logger.Info("Left download request %s for chat ID %d pending while shutting down", requestID.Hex(), chatID)
+		h.releaseUserSlot(chatID)
+		h.editStatus(statusMsg, h.text(user, "queue_restarting"))
+		return false
+	}
+	if err != nil {
+		h.logger.Warn("Refused download request %s for chat ID %d: %v", requestID.Hex(), chatID, err)
+		h.releaseUserSlot(chatID)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID, "failed")
+
+		h.editStatus(statusMsg, h.text(user, "queue_full"))
+		return false
+	}
+
+	if position > 0 {
+		h.editStatus(statusMsg, h.textf(user, "queue_position", map[string]string{"position": strconv.Itoa(position)}))
+	}
+
+	return true
+}
+
+// downloadInterrupted tells the user a download stopped because the bot is shutting down. The request is left
+// processing, so it is resumed on the next start.
+func (h *BotHandler) downloadInterrupted(log *utils.EnhancedLogger, requestID primitive.ObjectID, chatID int64, statusMsg *telebot.Message) {
+	log.Info("Download of request %s for chat ID %d was interrupted by the shutdown", requestID.Hex(), chatID)
+	h.editStatus(statusMsg, h.text(h.findUser(chatID), "queue_restarting"))
+}
+
+// recoverDownload keeps a panic in a download from crashing the bot. The panic is logged and stored in the error logs
+// with its stack, and a download that didn't complete is marked failed and offered to the user to retry.
+func (h *BotHandler) recoverDownload(request *models.DownloadRequest, statusMsg *telebot.Message, user *models.User) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	ctx, log := h.downloadLogger(context.Background(), request.ID, request.CorrelationID)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// A download that panicked while running is still tracked and counted as in progress
+	if h.untrackDownload(request.ChatID, request.ID) {
+		h.downloadFinished(request.URL, "failed", 0)
+	}
+	h.logDownloadError(log, request.ID, request.ChatID, request.CorrelationID, "Download crashed", fmt.Errorf("panic: %v", recovered), string(debug.Stack()))
+
+	// The files may have been sent before the panic
+	if current, err := h.downloadRepo.GetDownloadRequestByID(ctx, request.ID); err == nil && current != nil && current.Status == "completed" {
+		return
+	}
+	h.downloadRepo.UpdateDownloadRequestStatus(ctx, request.ID, "failed")
+	h.editStatus(statusMsg, h.text(user, "download_error")+h.errorRef(request.CorrelationID, user), h.retryMarkup(request.ID, user))
+}
+
+// unknownCostDuration is the duration assumed for downloads whose cost couldn't be estimated
+const unknownCostDuration = 10 * 60
+
+// downloadPriority returns the queue priority of a download from its estimated duration and size,
+// so cheap downloads run before expensive ones. A nil validation means no estimate is available.
+func (h *BotHandler) downloadPriority(validation *downloader.URLValidation) int {
+	duration, size := unknownCostDuration, int64(0)
+	if validation != nil && (validation.Duration > 0 || validation.Size > 0) {
+		duration, size = validation.Duration, validation.Size
+	}
+
+	cost := h.config.Worker.DurationWeight*float64(duration)/60 +
+		h.config.Worker.SizeWeight*float64(size)/(1024*1024)
+	return -int(cost)
+}
+
+// cancelQueuedDownload cancels the latest download of a chat that is still waiting for a worker
+func (h *BotHandler) cancelQueuedDownload(chatID int64) (primitive.ObjectID, bool) {
+	jobID, ok := h.queue.CancelLatest(chatID)
+	if !ok {
+		return primitive.NilObjectID, false
+	}
+	h.releaseUserSlot(chatID)
+
+	requestID, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return primitive.NilObjectID, false
+	}
+	return requestID, true
+}
+
+// downloadOptions returns the download options matching the user's preferences
+func (h *BotHandler) downloadOptions(chatID int64, user *models.User) downloader.DownloadOptions {
+	opts := downloader.DownloadOptions{BurnLang: "en", FileLang: "en"} // Default to English
+	if user != nil {
+		opts.BurnLang = user.SubtitleBurnLanguage()
+		opts.FileLang = user.SubtitleFileLanguage()
+		opts.IncludeMetadata = user.IncludeMetadata && h.featureEnabled(config.FeatureMetadata)
+		opts.AudioFormat = user.AudioFormat
+		opts.AudioBitrate = user.AudioBitrate
+		opts.TranslateSubs = user.TranslateSubtitles && h.featureEnabled(config.FeatureTranslateSubs)
+	}
+	if h.hasUserCookies(chatID) {
+		opts.CookiesFile = h.userCookiesPath(chatID)
+	}
+	return opts
+}
+
+// requestOptions returns the download options of a download request
+func (h *BotHandler) requestOptions(request *models.DownloadRequest, user *models.User) downloader.DownloadOptions {
+	opts := h.downloadOptions(request.ChatID, user)
+	opts.AudioOnly = request.Source == "audio"
+	opts.AudioTrack = request.AudioTrack
+	opts.ClipStart, opts.ClipEnd = request.ClipStart, request.ClipEnd
+	opts.MaxHeight = request.MaxHeight
+	opts.CorrelationID = request.CorrelationID
+	return opts
+}
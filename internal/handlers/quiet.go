@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
+
+// quietSummary counts the finished downloads of a chat in quiet mode, until its last queued or running one ends
+type quietSummary struct {
+	completed int
+	failed    int
+}
+
+// handleQuiet handles the /quiet command that turns quiet mode on or off. In quiet mode the processing message of
+// a download is sent without a notification, the completion message is left out, and one summary is sent once
+// all of the user's downloads finished.
+func (h *BotHandler) handleQuiet(c telebot.Context) error {
+	return h.handleToggle(c, toggleSetting{
+		command:     "quiet",
+		get:         func(user *models.User) bool { return user.QuietMode },
+		set:         h.userRepo.UpdateUserQuietMode,
+		statusOn:    "quiet_status_on",
+		statusOff:   "quiet_status_off",
+		enabledKey:  "quiet_on",
+		disabledKey: "quiet_off",
+	})
+}
+
+// quiet reports whether a user asked for one summary instead of a message per download
+func quiet(user *models.User) bool {
+	return user != nil && user.QuietMode
+}
+
+// recordQuietOutcome counts a download that ended for the summary of a user in quiet mode
+func (h *BotHandler) recordQuietOutcome(request *models.DownloadRequest, user *models.User) {
+	if !quiet(user) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	current, err := h.downloadRepo.GetDownloadRequestByID(ctx, request.ID)
+	if err != nil || current == nil {
+		return
+	}
+
+	h.userSlotsMu.Lock()
+	defer h.userSlotsMu.Unlock()
+
+	summary := h.quietSummaries[request.ChatID]
+	if summary == nil {
+		summary = &quietSummary{}
+		h.quietSummaries[request.ChatID] = summary
+	}
+	switch current.Status {
+	case "completed":
+		summary.completed++
+	case "failed":
+		summary.failed++
+	}
+}
+
+// sendQuietSummary tells a user in quiet mode how their downloads went once the last of them ended
+func (h *BotHandler) sendQuietSummary(chatID int64, summary *quietSummary) {
+	if summary == nil || summary.completed+summary.failed == 0 {
+		return
+	}
+
+	user := h.findUser(chatID)
+	text := h.lm.GetStringf(h.language(user), "quiet_summary", map[string]string{
+		"completed": strconv.Itoa(summary.completed),
+		"failed":    strconv.Itoa(summary.failed),
+	})
+	if _, err := h.sendTo(chatID, text); err != nil {
+		h.logger.Error("Error sending download summary to chat ID %d: %v", chatID, err)
+	}
+}
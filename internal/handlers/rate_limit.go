@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"gopkg.in/telebot.v3"
+)
+
+// commandRateLimit is a middleware that limits lightweight commands separately from downloads,
+// so a user who used up their downloads can still navigate the bot
+func (h *BotHandler) commandRateLimit(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(c telebot.Context) error {
+		chatID := c.Chat().ID
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		allowed, err := h.rateLimiter.Allow(ctx, utils.RateLimitCommand, fmt.Sprintf("%d", chatID))
+		cancel()
+		if err != nil {
+			h.logger.Warn("Rate limiter error for chat ID %d, allowing command: %v", chatID, err)
+		}
+		if !allowed {
+			user := h.findUser(chatID)
+			return h.send(c, h.text(user, "error_command_rate_limit"))
+		}
+		return next(c)
+	}
+}
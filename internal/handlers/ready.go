@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gopkg.in/telebot.v3"
+)
+
+// sendReadyPrompt tells the user a download is ready and offers a button per file, for users who pull files on demand
+func (h *BotHandler) sendReadyPrompt(chat *telebot.Chat, result *models.DownloadResult, user *models.User) {
+	h.logger.Info("Offering download result %s to chat ID %d", result.ID.Hex(), chat.ID)
+
+	var buttons [][]telebot.InlineButton
+	addButton := func(kind string, label string, paths ...string) {
+		var size int64
+		for _, path := range paths {
+			if fileSize, err := downloader.FileSize(path); err == nil {
+				size += fileSize
+			}
+		}
+		if size == 0 {
+			return
+		}
+		buttons = append(buttons, []telebot.InlineButton{{
+			Text:   fmt.Sprintf("%s (%.1f MB)", label, float64(size)/(1024*1024)),
+			Unique: "ready_send",
+			Data:   result.ID.Hex() + ":" + kind,
+		}})
+	}
+
+	addButton("video", h.text(user, "btn_send_video"), result.VideoPath)
+	addButton("sub", h.text(user, "btn_send_video_with_subs"), result.VideoWithSubPath)
+	audioPaths := []string{result.AudioPath}
+	if len(result.AudioTracks) > 0 {
+		audioPaths = nil
+		for _, track := range result.AudioTracks {
+			audioPaths = append(audioPaths, track.Path)
+		}
+	}
+	addButton("audio", h.text(user, "btn_send_audio"), audioPaths...)
+	addButton("subtitle", h.text(user, "btn_send_subtitle"), result.SubtitlePath)
+
+	msg := h.text(user, "ready_prompt")
+	if _, err := h.sendTo(chat.ID, msg, &telebot.ReplyMarkup{InlineKeyboard: buttons}); err != nil {
+		h.logger.Error("Error sending ready message: %v", err)
+	}
+}
+
+// handleReadySend handles the buttons of a ready download by sending the chosen file,
+// by its stored Telegram file ID when it was sent before or from disk otherwise
+func (h *BotHandler) handleReadySend(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	id, kind, _ := strings.Cut(c.Data(), ":")
+	resultID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		h.logger.Warn("Invalid download result ID in ready button from chat ID %d: %s", chatID, c.Data())
+		return h.invalidRequest(c, h.findUser(chatID))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user := h.findUser(chatID)
+	result, err := h.downloadRepo.GetDownloadResultByID(ctx, resultID)
+	if err != nil || result == nil || result.ChatID != chatID {
+		return c.Respond(&telebot.CallbackResponse{Text: h.readyExpiredMessage(user), ShowAlert: true})
+	}
+
+	chat := c.Chat()
+	var fileID *string
+	var path string
+	var send func(file telebot.File) (string, error)
+	switch kind {
+	case "video":
+		fileID, path = &result.VideoFileID, result.VideoPath
+		send = func(file telebot.File) (string, error) { return h.sendPrimaryVideo(chat, file, result.Metadata, user) }
+	case "sub":
+		fileID, path = &result.VideoWithSubFileID, result.VideoWithSubPath
+		send = func(file telebot.File) (string, error) {
+			return h.sendVideoWithSubtitles(chat, file, result.Metadata, user)
+		}
+	case "audio":
+		if len(result.AudioTracks) > 0 {
+			return h.sendReadyAudioTracks(c, result, user)
+		}
+		fileID, path = &result.AudioFileID, result.AudioPath
+		send = func(file telebot.File) (string, error) { return h.sendAudioFile(chat, file, result.Metadata, user) }
+	case "subtitle":
+		fileID, path = &result.SubtitleFileID, result.SubtitlePath
+		send = func(file telebot.File) (string, error) {
+			return h.sendSubtitleFile(chat, file, result.SubtitlePath, h.subtitleCaption(result, user), result.Metadata, user)
+		}
+	default:
+		h.logger.Warn("Invalid file kind in ready button from chat ID %d: %s", chatID, c.Data())
+		return h.invalidRequest(c, user)
+	}
+
+	onDisk := fileExists(path)
+	if *fileID == "" && !onDisk {
+		return c.Respond(&telebot.CallbackResponse{Text: h.readyExpiredMessage(user), ShowAlert: true})
+	}
+	c.Respond()
+
+	// A file sent before doesn't have to be uploaded again
+	if *fileID != "" {
+		if _, err := send(telebot.File{FileID: *fileID}); err == nil {
+			return nil
+		}
+		if !onDisk {
+			h.sendTo(chat.ID, h.readyExpiredMessage(user))
+			return nil
+		}
+	}
+
+	if h.exceedsUploadLimit(path) {
+		h.sendUploadLimitWarning(chat, result.URL, user)
+		return nil
+	}
+
+	h.logger.Info("Sending %s of download result %s to chat ID %d", kind, result.ID.Hex(), chatID)
+	sentID, err := send(telebot.FromDisk(path))
+	if err != nil || sentID == "" {
+		return nil
+	}
+
+	// Remember the file ID so pressing the button again doesn't re-upload the file
+	*fileID = sentID
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer saveCancel()
+	if err := h.downloadRepo.UpdateDownloadResultFileIDs(saveCtx, result); err != nil {
+		h.logger.Error("Error saving file IDs for download result: %v", err)
+	}
+	return nil
+}
+
+// sendReadyAudioTracks sends the audio tracks of a ready download from disk
+func (h *BotHandler) sendReadyAudioTracks(c telebot.Context, result *models.DownloadResult, user *models.User) error {
+	var onDisk []models.AudioTrack
+	for _, track := range result.AudioTracks {
+		if fileExists(track.Path) {
+			onDisk = append(onDisk, track)
+		}
+	}
+	if len(onDisk) == 0 {
+		return c.Respond(&telebot.CallbackResponse{Text: h.readyExpiredMessage(user), ShowAlert: true})
+	}
+	c.Respond()
+
+	sendable := h.sendableAudioTracks(onDisk)
+	h.sendAudioTracks(c.Chat(), sendable, result.Metadata, user)
+	if len(sendable) < len(onDisk) {
+		h.sendUploadLimitWarning(c.Chat(), result.URL, user)
+	}
+	return nil
+}
+
+// readyExpiredMessage returns the localized message for files of a ready download that were cleaned up
+func (h *BotHandler) readyExpiredMessage(user *models.User) string {
+	return h.text(user, "download_expired")
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gopkg.in/telebot.v3"
+)
+
+// findResendableResult finds a recent download of the same URL in the chat that can be resent by file ID
+func (h *BotHandler) findResendableResult(ctx context.Context, chatID int64, url string) *models.DownloadResult {
+	if h.config.Download.ResendWindow <= 0 {
+		return nil
+	}
+
+	since := time.Now().Add(-time.Duration(h.config.Download.ResendWindow) * time.Second)
+	result, err := h.downloadRepo.FindRecentResultByChatAndURL(ctx, chatID, url, since)
+	if err != nil {
+		h.logger.Error("Error looking up previous download for chat ID %d: %v", chatID, err)
+		return nil
+	}
+	if result == nil || !result.HasFileIDs() {
+		return nil
+	}
+
+	return result
+}
+
+// sendResendPrompt asks the user whether to resend a previous download or download the URL again
+func (h *BotHandler) sendResendPrompt(c telebot.Context, result *models.DownloadResult, user *models.User) error {
+	h.logger.Info("Offering to resend download result %s to chat ID %d", result.ID.Hex(), result.ChatID)
+
+	promptMsg := h.text(user, "resend_prompt")
+
+	resendBtn := telebot.InlineButton{
+		Text:   h.text(user, "btn_resend"),
+		Unique: "resend_yes",
+		Data:   result.ID.Hex(),
+	}
+	downloadBtn := telebot.InlineButton{
+		Text:   h.text(user, "btn_download_again"),
+		Unique: "resend_no",
+		Data:   result.ID.Hex(),
+	}
+
+	return h.send(c, promptMsg, &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{{resendBtn, downloadBtn}},
+	})
+}
+
+// handleResend handles the resend button by sending the previous files by their Telegram file IDs
+func (h *BotHandler) handleResend(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, user, err := h.loadResendResult(ctx, c)
+	if err != nil || result == nil {
+		return err
+	}
+
+	h.logger.Info("Resending download result %s to chat ID %d", result.ID.Hex(), chatID)
+	c.Respond()
+
+	sendingMsg := h.text(user, "resend_sending")
+	h.edit(c, sendingMsg)
+
+	sent, err := h.resendResult(c.Chat(), result, user)
+	if err != nil && sent == 0 {
+		// Telegram rejected a stored file ID, fall back to a fresh download
+		h.logger.Warn("Resending download result %s failed, downloading again: %v", result.ID.Hex(), err)
+		return h.startDownload(c.Chat(), result.URL, user, h.downloadPriority(nil), 0)
+	}
+	if err != nil {
+		// A fresh download would send the files that went through again
+		h.logger.Warn("Resending download result %s failed after %d files were sent: %v", result.ID.Hex(), sent, err)
+		_, err = h.sendTo(chatID, h.text(user, "error_general"))
+		return err
+	}
+
+	return nil
+}
+
+// handleResendSkip handles the download again button by starting a fresh download of the same URL
+func (h *BotHandler) handleResendSkip(c telebot.Context) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, user, err := h.loadResendResult(ctx, c)
+	if err != nil || result == nil {
+		return err
+	}
+
+	c.Respond()
+	c.Delete()
+
+	return h.startDownload(c.Chat(), result.URL, user, h.downloadPriority(nil), 0)
+}
+
+// loadResendResult loads the download result referenced by a resend button and the user who pressed it
+func (h *BotHandler) loadResendResult(ctx context.Context, c telebot.Context) (*models.DownloadResult, *models.User, error) {
+	chatID := c.Chat().ID
+
+	resultID, err := primitive.ObjectIDFromHex(c.Data())
+	if err != nil {
+		h.logger.Warn("Invalid download result ID in resend button from chat ID %d: %s", chatID, c.Data())
+		return nil, nil, h.invalidRequest(c, h.findUser(chatID))
+	}
+
+	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
+	if err != nil {
+		h.logger.Error("Error finding user: %v", err)
+	}
+
+	result, err := h.downloadRepo.GetDownloadResultByID(ctx, resultID)
+	if err != nil || result == nil || result.ChatID != chatID {
+		c.Respond()
+		return nil, nil, h.edit(c, h.text(user, "download_expired"))
+	}
+
+	return result, user, nil
+}
+
+// resendResult sends the files of a previous download using their Telegram file IDs. It returns how many of
+// the video and audio files were sent, stopping at the first one Telegram rejects.
+func (h *BotHandler) resendResult(chat *telebot.Chat, result *models.DownloadResult, user *models.User) (int, error) {
+	if result.ThumbnailFileID != "" {
+		h.sendThumbnail(chat.ID, telebot.File{FileID: result.ThumbnailFileID}, user)
+	}
+
+	sent := 0
+	if result.VideoFileID != "" {
+		if _, err := h.sendPrimaryVideo(chat, telebot.File{FileID: result.VideoFileID}, result.Metadata, user); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	if result.VideoWithSubFileID != "" {
+		if _, err := h.sendVideoWithSubtitles(chat, telebot.File{FileID: result.VideoWithSubFileID}, result.Metadata, user); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	if result.AudioFileID != "" {
+		if _, err := h.sendAudioFile(chat, telebot.File{FileID: result.AudioFileID}, result.Metadata, user); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	if result.SubtitleFileID != "" {
+		h.sendSubtitleFile(chat, telebot.File{FileID: result.SubtitleFileID}, result.SubtitlePath, h.subtitleCaption(result, user), result.Metadata, user)
+	}
+
+	return sent, nil
+}
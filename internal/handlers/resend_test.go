@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/config"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"gopkg.in/telebot.v3"
+)
+
+func TestResendResultStopsAtRejectedFile(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		mu.Lock()
+		methods = append(methods, method)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if method == "sendAudio" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: wrong file identifier/HTTP URL specified"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1},"video":{"file_id":"sent"}}}`))
+	}))
+	defer server.Close()
+
+	bot, err := telebot.NewBot(telebot.Settings{URL: server.URL, Token: "test", Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newLanguageHandler(t, "en")
+	h.bot = bot
+	h.config = &config.Config{}
+	h.ctx = context.Background()
+	if h.logger, err = utils.NewLogger(false, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	result := &models.DownloadResult{VideoFileID: "video", VideoWithSubFileID: "subbed", AudioFileID: "audio"}
+	sent, err := h.resendResult(&telebot.Chat{ID: 1}, result, nil)
+	if err == nil {
+		t.Fatal("resendResult() succeeded, want the error of the rejected audio")
+	}
+	if sent != 2 {
+		t.Errorf("sent = %d, want 2", sent)
+	}
+	if want := []string{"sendVideo", "sendVideo", "sendAudio"}; strings.Join(methods, ",") != strings.Join(want, ",") {
+		t.Errorf("called %v, want %v", methods, want)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gopkg.in/telebot.v3"
+)
+
+// retryMarkup returns the button that retries a failed download request
+func (h *BotHandler) retryMarkup(requestID primitive.ObjectID, user *models.User) *telebot.ReplyMarkup {
+	return &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{{{
+			Text:   h.text(user, "btn_retry"),
+			Unique: "retry_download",
+			Data:   requestID.Hex(),
+		}}},
+	}
+}
+
+// handleRetryDownload handles the retry button of a failed download by queueing the same request again
+func (h *BotHandler) handleRetryDownload(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	requestID, err := primitive.ObjectIDFromHex(c.Data())
+	if err != nil {
+		h.logger.Warn("Invalid download request ID in retry button from chat ID %d: %s", chatID, c.Data())
+		return h.invalidRequest(c, h.findUser(chatID))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user := h.findUser(chatID)
+	request, err := h.downloadRepo.GetDownloadRequestByID(ctx, requestID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: h.errorMessage(user, err)})
+	}
+	if request == nil || request.ChatID != chatID || request.Status != "failed" {
+		// Already retried, or the button was forwarded from another chat
+		return c.Respond(&telebot.CallbackResponse{
+			Text:      h.text(user, "retry_unavailable"),
+			ShowAlert: true,
+		})
+	}
+
+	// A retry counts towards the rate limit like a new download
+	allowed, err := h.rateLimiter.Allow(ctx, utils.RateLimitDownload, fmt.Sprintf("%d", chatID))
+	if err != nil {
+		h.logger.Warn("Rate limiter error for chat ID %d, allowing request: %v", chatID, err)
+	}
+	if !allowed {
+		return c.Respond(&telebot.CallbackResponse{
+			Text:      h.text(user, "error_rate_limit"),
+			ShowAlert: true,
+		})
+	}
+
+	c.Respond()
+	if refused, err := h.refuseDownload(ctx, c.Chat(), user); refused {
+		return err
+	}
+	if !h.acquireUserSlot(chatID, false) {
+		return h.refuseBusyUser(c.Chat(), user)
+	}
+
+	if err := h.downloadRepo.UpdateDownloadRequestStatus(ctx, requestID, "pending"); err != nil {
+		h.releaseUserSlot(chatID)
+		return h.send(c, h.errorMessage(user, err))
+	}
+	h.logger.Info("Retrying download request %s for chat ID %d", requestID.Hex(), chatID)
+
+	// The failure message becomes the status message of the retry, without the button
+	statusMsg := c.Message()
+	h.editStatus(statusMsg, h.text(user, "retry_started"))
+	h.enqueueDownload(request, h.requestOptions(request, user), h.downloadPriority(nil), statusMsg, user)
+	return nil
+}
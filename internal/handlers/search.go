@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/database"
+
+	"gopkg.in/telebot.v3"
+)
+
+// searchPageSize is the number of requests listed per /search page
+const searchPageSize = 10
+
+// searchQueryError is a /search query that can't be parsed, explained by the language file key of the problem and the
+// part of the query it is about
+type searchQueryError struct {
+	key   string
+	value string
+}
+
+func (e *searchQueryError) Error() string {
+	return fmt.Sprintf("%s: %q", e.key, e.value)
+}
+
+// requestStatuses are the statuses a download request can have
+var requestStatuses = map[string]bool{
+	"pending": true, "processing": true, "completed": true, "failed": true, "cancelled": true,
+}
+
+// handleSearch handles the /search admin command that finds download requests by part of their URL, status,
+// chat and date range, e.g. "/search tiktok status:failed from:2024-05-01", newest first
+func (h *BotHandler) handleSearch(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /search command from chat ID: %d", chatID)
+
+	if !h.isAdmin(chatID) {
+		return nil
+	}
+	user := h.findUser(chatID)
+
+	query := strings.TrimSpace(c.Message().Payload)
+	if query == "" {
+		return h.send(c, h.text(user, "search_usage"))
+	}
+	filter, page, err := parseSearchQuery(query)
+	if err != nil {
+		problem := err.Error()
+		var queryErr *searchQueryError
+		if errors.As(err, &queryErr) {
+			problem = h.textf(user, queryErr.key, map[string]string{"value": queryErr.value})
+		}
+		return h.send(c, problem+"\n\n"+h.text(user, "search_usage"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// One more than a page tells whether there is a next one
+	requests, err := h.downloadRepo.SearchRequests(ctx, filter, searchPageSize+1, int64((page-1)*searchPageSize))
+	if err != nil {
+		return h.send(c, h.errorMessage(user, err))
+	}
+	h.audit(chatID, "search", query)
+
+	if len(requests) == 0 {
+		if page > 1 {
+			return h.send(c, h.textf(user, "search_no_page", map[string]string{"page": strconv.Itoa(page)}))
+		}
+		return h.send(c, h.text(user, "search_none"))
+	}
+
+	hasMore := len(requests) > searchPageSize
+	if hasMore {
+		requests = requests[:searchPageSize]
+	}
+
+	lines := []string{h.textf(user, "search_title", map[string]string{"page": strconv.Itoa(page)})}
+	for _, request := range requests {
+		line := h.textf(user, "search_request", map[string]string{
+			"id":      request.ID.Hex(),
+			"created": request.CreatedAt.UTC().Format("2006-01-02 15:04"),
+			"status":  request.Status,
+			"chat":    strconv.FormatInt(request.ChatID, 10),
+			"url":     request.URL,
+		})
+		if request.CorrelationID != "" {
+			line += "\n" + h.text(user, "error_ref") + request.CorrelationID
+		}
+		lines = append(lines, line)
+	}
+	if hasMore {
+		command := fmt.Sprintf("/search %s page:%d", withoutPage(query), page+1)
+		lines = append(lines, h.textf(user, "search_more", map[string]string{"command": command}))
+	}
+
+	_, err = h.sendTo(chatID, strings.Join(lines, "\n"))
+	return err
+}
+
+// parseSearchQuery parses the query of /search into a filter and the page to list, starting at 1
+func parseSearchQuery(query string) (database.SearchFilter, int, error) {
+	var filter database.SearchFilter
+	var words []string
+	page := 1
+
+	for _, field := range strings.Fields(query) {
+		key, value, ok := strings.Cut(field, ":")
+		// URLs contain colons too, only known prefixes are criteria
+		switch strings.ToLower(key) {
+		case "url":
+			words = append(words, value)
+		case "status":
+			status := strings.ToLower(value)
+			if !requestStatuses[status] {
+				return filter, 0, &searchQueryError{key: "search_unknown_status", value: value}
+			}
+			filter.Status = status
+		case "chat":
+			chatID, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return filter, 0, &searchQueryError{key: "search_invalid_chat", value: value}
+			}
+			filter.ChatID = chatID
+		case "from":
+			day, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return filter, 0, &searchQueryError{key: "search_invalid_date", value: value}
+			}
+			filter.From = day
+		case "to":
+			day, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return filter, 0, &searchQueryError{key: "search_invalid_date", value: value}
+			}
+			filter.To = day.AddDate(0, 0, 1)
+		case "page":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return filter, 0, &searchQueryError{key: "search_invalid_page", value: value}
+			}
+			page = n
+		default:
+			if ok && !strings.HasPrefix(value, "//") {
+				return filter, 0, &searchQueryError{key: "search_unknown_criterion", value: key}
+			}
+			words = append(words, field)
+		}
+	}
+
+	if len(words) > 1 {
+		return filter, 0, &searchQueryError{key: "search_several_urls"}
+	}
+	if len(words) == 1 {
+		filter.URL = words[0]
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, 0, &searchQueryError{key: "search_dates_reversed"}
+	}
+	return filter, page, nil
+}
+
+// withoutPage removes the page criterion from a /search query, to add the next one
+func withoutPage(query string) string {
+	var fields []string
+	for _, field := range strings.Fields(query) {
+		if !strings.HasPrefix(strings.ToLower(field), "page:") {
+			fields = append(fields, field)
+		}
+	}
+	return strings.Join(fields, " ")
+}
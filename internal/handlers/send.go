@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"gopkg.in/telebot.v3"
+)
+
+const (
+	// maxMessageLength is the longest text Telegram accepts in a message
+	maxMessageLength = 4096
+	// maxFloodRetries is the number of times a message is sent again after Telegram asked to slow down
+	maxFloodRetries = 3
+)
+
+// reply sends the localized string of a key to the chat of an update, with {name} placeholders replaced by params.
+// Params are escaped when the message is sent with a Markdown parse mode.
+func (h *BotHandler) reply(c telebot.Context, langCode string, key string, params map[string]string, opts ...interface{}) error {
+	if mode := parseMode(opts); mode != telebot.ModeDefault {
+		escaped := make(map[string]string, len(params))
+		for name, value := range params {
+			escaped[name] = escapeMarkdown(value, mode)
+		}
+		params = escaped
+	}
+
+	_, err := h.sendTo(c.Chat().ID, h.lm.GetStringf(langCode, key, params), opts...)
+	return err
+}
+
+// send sends a text to the chat of an update like sendTo, for handlers that only need the error
+func (h *BotHandler) send(c telebot.Context, text string, opts ...interface{}) error {
+	_, err := h.sendTo(c.Chat().ID, text, opts...)
+	return err
+}
+
+// sendTo sends a text to a chat, split over several messages when it is longer than Telegram allows, and returns
+// the last message sent. Buttons are attached to the last message only.
+func (h *BotHandler) sendTo(chatID int64, text string, opts ...interface{}) (*telebot.Message, error) {
+	chat := &telebot.Chat{ID: chatID}
+	parts := splitMessage(text, maxMessageLength)
+
+	var last *telebot.Message
+	for i, part := range parts {
+		partOpts := opts
+		if i < len(parts)-1 {
+			partOpts = withoutMarkup(opts)
+		}
+
+		msg, err := h.sendWithFloodRetry(h.ctx, chat, part, partOpts...)
+		if err != nil {
+			h.logger.Error("Error sending message to chat ID %d: %v", chatID, err)
+			return last, err
+		}
+		last = msg
+	}
+	return last, nil
+}
+
+// sendFile sends a file such as a video, photo or document to a chat, sending it again when Telegram asks to slow
+// down. Callers log the errors, they know what the file was.
+func (h *BotHandler) sendFile(chat *telebot.Chat, file telebot.Sendable, opts ...interface{}) (*telebot.Message, error) {
+	return h.sendWithFloodRetry(h.ctx, chat, file, opts...)
+}
+
+// sendWithFloodRetry sends a text or a file, waiting as long as Telegram asks when the bot sends too fast, or
+// until the context is done
+func (h *BotHandler) sendWithFloodRetry(ctx context.Context, chat *telebot.Chat, what interface{}, opts ...interface{}) (*telebot.Message, error) {
+	what, opts = h.formatSendable(what), h.formatOptions(opts)
+	for attempt := 0; ; attempt++ {
+		msg, err := h.bot.Send(chat, what, opts...)
+
+		var flood telebot.FloodError
+		if err == nil || !errors.As(err, &flood) || attempt == maxFloodRetries {
+			return msg, err
+		}
+
+		wait := time.Duration(flood.RetryAfter) * time.Second
+		h.logger.Warn("Flood limit reached sending to chat ID %d, retrying in %s", chat.ID, wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// edit replaces the text of the message of a button, formatted like the texts sent
+func (h *BotHandler) edit(c telebot.Context, text string, opts ...interface{}) error {
+	return c.Edit(h.formatText(text), h.formatOptions(opts)...)
+}
+
+// formatText returns a message text or caption as it is shown to users, without its emoji in plain text mode.
+// Every text, caption and button label the bot sends or edits goes through it.
+func (h *BotHandler) formatText(text string) string {
+	if !h.config.UI.PlainText {
+		return text
+	}
+	return utils.StripEmoji(text)
+}
+
+// formatSendable formats a text, or the caption of a file
+func (h *BotHandler) formatSendable(what interface{}) interface{} {
+	switch w := what.(type) {
+	case string:
+		return h.formatText(w)
+	case *telebot.Photo:
+		w.Caption = h.formatText(w.Caption)
+	case *telebot.Video:
+		w.Caption = h.formatText(w.Caption)
+	case *telebot.Audio:
+		w.Caption = h.formatText(w.Caption)
+	case *telebot.Document:
+		w.Caption = h.formatText(w.Caption)
+	case *telebot.Animation:
+		w.Caption = h.formatText(w.Caption)
+	}
+	return what
+}
+
+// formatOptions returns the send options with the labels of their buttons formatted. The callback data and URLs of
+// the buttons are left as they are, so the buttons keep working.
+func (h *BotHandler) formatOptions(opts []interface{}) []interface{} {
+	if !h.config.UI.PlainText {
+		return opts
+	}
+
+	formatted := make([]interface{}, len(opts))
+	for i, opt := range opts {
+		switch o := opt.(type) {
+		case *telebot.ReplyMarkup:
+			formatted[i] = h.formatMarkup(o)
+		case *telebot.SendOptions:
+			copied := *o
+			copied.ReplyMarkup = h.formatMarkup(o.ReplyMarkup)
+			formatted[i] = &copied
+		default:
+			formatted[i] = opt
+		}
+	}
+	return formatted
+}
+
+// formatMarkup returns a copy of inline buttons with formatted labels
+func (h *BotHandler) formatMarkup(markup *telebot.ReplyMarkup) *telebot.ReplyMarkup {
+	if markup == nil {
+		return nil
+	}
+
+	copied := *markup
+	copied.InlineKeyboard = make([][]telebot.InlineButton, len(markup.InlineKeyboard))
+	for i, row := range markup.InlineKeyboard {
+		copied.InlineKeyboard[i] = make([]telebot.InlineButton, len(row))
+		for j, button := range row {
+			button.Text = h.formatText(button.Text)
+			copied.InlineKeyboard[i][j] = button
+		}
+	}
+	return &copied
+}
+
+// splitMessage splits a text into parts of at most limit characters, at line breaks when possible
+func splitMessage(text string, limit int) []string {
+	var parts []string
+	for utf8.RuneCountInString(text) > limit {
+		cut := runeOffset(text, limit)
+		if newline := strings.LastIndex(text[:cut], "\n"); newline > 0 {
+			parts = append(parts, text[:newline])
+			text = text[newline+1:]
+			continue
+		}
+		parts = append(parts, text[:cut])
+		text = text[cut:]
+	}
+	return append(parts, text)
+}
+
+// runeOffset returns the byte offset of the nth character of a text
+func runeOffset(text string, n int) int {
+	for i := range text {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(text)
+}
+
+// withoutMarkup returns the send options without the reply markup
+func withoutMarkup(opts []interface{}) []interface{} {
+	var filtered []interface{}
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case *telebot.ReplyMarkup:
+			continue
+		case *telebot.SendOptions:
+			copied := *o
+			copied.ReplyMarkup = nil
+			filtered = append(filtered, &copied)
+		default:
+			filtered = append(filtered, opt)
+		}
+	}
+	return filtered
+}
+
+// parseMode returns the parse mode set by send options, if any
+func parseMode(opts []interface{}) telebot.ParseMode {
+	mode := telebot.ModeDefault
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case telebot.ParseMode:
+			mode = o
+		case *telebot.SendOptions:
+			mode = o.ParseMode
+		}
+	}
+	return mode
+}
+
+// escapeMarkdown escapes the characters of a text that have a meaning in a Markdown parse mode
+func escapeMarkdown(text string, mode telebot.ParseMode) string {
+	special := ""
+	switch mode {
+	case telebot.ModeMarkdown:
+		special = "_*`["
+	case telebot.ModeMarkdownV2:
+		special = "_*[]()~`>#+-=|{}.!\\"
+	default:
+		return text
+	}
+
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// safeFileName makes the name of a sent file safe for Telegram and the file systems it is saved on, and shortens it
+// to the configured length
+func (h *BotHandler) safeFileName(name string) string {
+	return utils.SanitizeFilename(name, h.config.Download.MaxFileNameLen)
+}
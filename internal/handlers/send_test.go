@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/config"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"gopkg.in/telebot.v3"
+)
+
+// newFloodedHandler creates a handler whose bot is asked to slow down for an hour on every request
+func newFloodedHandler(t *testing.T) (*BotHandler, *int32) {
+	t.Helper()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 3600","parameters":{"retry_after":3600}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	bot, err := telebot.NewBot(telebot.Settings{URL: server.URL, Token: "test", Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := utils.NewLogger(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &BotHandler{bot: bot, logger: logger, config: &config.Config{}, ctx: context.Background()}, &requests
+}
+
+func TestSendWithFloodRetryStopsWhenContextIsDone(t *testing.T) {
+	h, requests := newFloodedHandler(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := h.sendWithFloodRetry(ctx, &telebot.Chat{ID: 1}, "hello")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned after %s, the wait for the flood limit wasn't cut short", elapsed)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("sent %d requests, want 1", got)
+	}
+}
+
+func TestPlainTextFormatting(t *testing.T) {
+	h := &BotHandler{config: &config.Config{}}
+	h.config.UI.PlainText = true
+
+	if got, want := h.formatSendable("✅ Done"), "Done"; got != want {
+		t.Errorf("formatted text = %q, want %q", got, want)
+	}
+	video := &telebot.Video{Caption: "Part 1/2 🎬"}
+	h.formatSendable(video)
+	if want := "Part 1/2"; video.Caption != want {
+		t.Errorf("formatted caption = %q, want %q", video.Caption, want)
+	}
+
+	// Button labels lose their emoji, what the buttons do is kept
+	button := telebot.InlineButton{Text: "English 🇬🇧", Unique: "lang_en", Data: "interface"}
+	link := telebot.InlineButton{Text: "🔗 Open", URL: "https://example.com/🎬"}
+	markup := &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{{button, link}}}
+	opts := h.formatOptions([]interface{}{markup, telebot.NoPreview})
+
+	formatted := opts[0].(*telebot.ReplyMarkup).InlineKeyboard[0]
+	if formatted[0].Text != "English" || formatted[0].Unique != button.Unique || formatted[0].Data != button.Data {
+		t.Errorf("formatted button = %+v, want the label English with the data of %+v", formatted[0], button)
+	}
+	if formatted[1].Text != "Open" || formatted[1].URL != link.URL {
+		t.Errorf("formatted link = %+v, want the label Open with the URL of %+v", formatted[1], link)
+	}
+	if markup.InlineKeyboard[0][0].Text != button.Text {
+		t.Errorf("the markup given was changed to %+v", markup.InlineKeyboard[0][0])
+	}
+	if opts[1] != telebot.NoPreview {
+		t.Errorf("other option = %v, want %v", opts[1], telebot.NoPreview)
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"short text", "hello", 10, []string{"hello"}},
+		{"exactly the limit", "0123456789", 10, []string{"0123456789"}},
+		{"split at a line break", "hello\nworld!", 8, []string{"hello", "world!"}},
+		{"split without a line break", "0123456789abc", 5, []string{"01234", "56789", "abc"}},
+		{"multi-byte characters", "ééééé", 2, []string{"éé", "éé", "é"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitMessage(tt.text, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitMessage(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
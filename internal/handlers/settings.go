@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
+
+// handleSettings handles the /settings command that shows the user's preferences menu
+func (h *BotHandler) handleSettings(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /settings command from chat ID: %d", chatID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
+	if err != nil {
+		h.logger.Error("Error finding user: %v", err)
+		return h.send(c, h.errorMessage(user, err))
+	}
+	if user == nil {
+		return h.send(c, h.text(user, "start_first"))
+	}
+
+	return h.send(c, h.settingsText(user), h.settingsMarkup(user))
+}
+
+// handleToggleSendAsDocument handles the settings button that switches between sending videos as videos or documents
+func (h *BotHandler) handleToggleSendAsDocument(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
+	if err != nil || user == nil {
+		return c.Respond(&telebot.CallbackResponse{Text: h.errorMessage(user, err)})
+	}
+
+	enabled := !user.SendAsDocument
+	if err := h.userRepo.UpdateUserSendAsDocument(ctx, chatID, enabled); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: h.errorMessage(user, err)})
+	}
+	user.SendAsDocument = enabled
+
+	c.Respond()
+	return h.edit(c, h.settingsText(user), h.settingsMarkup(user))
+}
+
+// handleToggleNotifyOnReady handles the settings button that switches between sending finished downloads right away
+// or offering them with buttons
+func (h *BotHandler) handleToggleNotifyOnReady(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
+	if err != nil || user == nil {
+		return c.Respond(&telebot.CallbackResponse{Text: h.errorMessage(user, err)})
+	}
+
+	enabled := !user.NotifyOnReady
+	if err := h.userRepo.UpdateUserNotifyOnReady(ctx, chatID, enabled); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: h.errorMessage(user, err)})
+	}
+	user.NotifyOnReady = enabled
+
+	c.Respond()
+	return h.edit(c, h.settingsText(user), h.settingsMarkup(user))
+}
+
+// handleToggleDownloadMode handles the settings button that switches between downloading links as video or audio
+func (h *BotHandler) handleToggleDownloadMode(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
+	if err != nil || user == nil {
+		return c.Respond(&telebot.CallbackResponse{Text: h.errorMessage(user, err)})
+	}
+
+	mode := "audio"
+	if user.DownloadMode == "audio" {
+		mode = "video"
+	}
+	if err := h.userRepo.UpdateUserDownloadMode(ctx, chatID, mode); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: h.errorMessage(user, err)})
+	}
+	user.DownloadMode = mode
+
+	c.Respond()
+	return h.edit(c, h.settingsText(user), h.settingsMarkup(user))
+}
+
+// handleSettingsLanguage handles the settings buttons that open the interface or caption language picker
+func (h *BotHandler) handleSettingsLanguage(c telebot.Context) error {
+	chatID := c.Chat().ID
+	setting := c.Data()
+	if setting != "interface" && setting != "caption" {
+		h.logger.Warn("Invalid language setting in button from chat ID %d: %s", chatID, setting)
+		return h.invalidRequest(c, h.findUser(chatID))
+	}
+
+	user := h.findUser(chatID)
+	title := h.text(user, "lang_choose_interface")
+	if setting == "caption" {
+		title = h.text(user, "lang_choose_caption")
+	}
+
+	// The picker returns to the settings menu once a language is chosen
+	c.Respond()
+	return h.edit(c, title, &telebot.ReplyMarkup{
+		InlineKeyboard: languageButtons(setting + ":settings"),
+	})
+}
+
+// handleCycleAudioFormat handles the settings button that switches to the next audio format
+func (h *BotHandler) handleCycleAudioFormat(c telebot.Context) error {
+	return h.updateAudioPreferences(c, func(user *models.User) {
+		user.AudioFormat = nextOption(downloader.AudioFormats, audioFormat(user))
+	})
+}
+
+// handleCycleAudioBitrate handles the settings button that switches to the next audio bitrate
+func (h *BotHandler) handleCycleAudioBitrate(c telebot.Context) error {
+	return h.updateAudioPreferences(c, func(user *models.User) {
+		// The empty bitrate keeps yt-dlp's default quality
+		user.AudioBitrate = nextOption(append([]string{""}, downloader.AudioBitrates...), user.AudioBitrate)
+	})
+}
+
+// updateAudioPreferences applies a change to the user's audio preferences, saves them and refreshes the settings menu
+func (h *BotHandler) updateAudioPreferences(c telebot.Context, change func(user *models.User)) error {
+	chatID := c.Chat().ID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
+	if err != nil || user == nil {
+		return c.Respond(&telebot.CallbackResponse{Text: h.errorMessage(user, err)})
+	}
+
+	change(user)
+	if err := h.userRepo.UpdateUserAudioPreferences(ctx, chatID, user.AudioFormat, user.AudioBitrate); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: h.errorMessage(user, err)})
+	}
+
+	c.Respond()
+	return h.edit(c, h.settingsText(user), h.settingsMarkup(user))
+}
+
+// audioFormat returns the audio format a user receives extracted audio in
+func audioFormat(user *models.User) string {
+	if user == nil || user.AudioFormat == "" {
+		return downloader.AudioFormats[0]
+	}
+	return user.AudioFormat
+}
+
+// nextOption returns the option after current, wrapping around to the first one
+func nextOption(options []string, current string) string {
+	for i, option := range options {
+		if option == current {
+			return options[(i+1)%len(options)]
+		}
+	}
+	return options[0]
+}
+
+// settingsText returns the localized settings menu with the user's current preferences
+func (h *BotHandler) settingsText(user *models.User) string {
+	mode := h.text(user, "settings_mode_video")
+	if user.DownloadMode == "audio" {
+		mode = h.text(user, "settings_mode_audio")
+	}
+	sendAs := h.text(user, "settings_send_video")
+	if user.SendAsDocument {
+		sendAs = h.text(user, "settings_send_document")
+	}
+
+	return h.textf(user, "settings_text", map[string]string{
+		"interface": languageName(user.InterfaceLanguage),
+		"caption":   languageName(user.CaptionLanguage),
+		"mode":      mode,
+		"send_as":   sendAs,
+	})
+}
+
+// languageName returns the native name of a supported language, the code of other languages
+func languageName(langCode string) string {
+	if langCode == "" {
+		return "English"
+	}
+
+	// Regional variants are named after their base language and code, e.g. "Español (es-mx)"
+	base, _, regional := strings.Cut(langCode, "-")
+	for _, lang := range models.GetSupportedLanguages() {
+		if lang.Code == langCode {
+			return lang.NativeName
+		}
+		if regional && lang.Code == base {
+			return lang.NativeName + " (" + langCode + ")"
+		}
+	}
+	return langCode
+}
+
+// settingsMarkup returns the settings menu buttons, labelled with the user's current preferences
+func (h *BotHandler) settingsMarkup(user *models.User) *telebot.ReplyMarkup {
+	sendAsBtn := telebot.InlineButton{
+		Text:   h.text(user, "btn_send_as_video"),
+		Unique: "toggle_send_as_document",
+	}
+	if user.SendAsDocument {
+		sendAsBtn.Text = h.text(user, "btn_send_as_document")
+	}
+
+	deliveryBtn := telebot.InlineButton{
+		Text:   h.text(user, "btn_deliver_auto"),
+		Unique: "toggle_notify_on_ready",
+	}
+	if user.NotifyOnReady {
+		deliveryBtn.Text = h.text(user, "btn_deliver_on_request")
+	}
+
+	interfaceLangBtn := telebot.InlineButton{
+		Text:   h.text(user, "lang_interface"),
+		Unique: "settings_lang",
+		Data:   "interface",
+	}
+	captionLangBtn := telebot.InlineButton{
+		Text:   h.text(user, "lang_caption"),
+		Unique: "settings_lang",
+		Data:   "caption",
+	}
+
+	modeBtn := telebot.InlineButton{
+		Text:   h.text(user, "btn_mode_video"),
+		Unique: "toggle_download_mode",
+	}
+	if user.DownloadMode == "audio" {
+		modeBtn.Text = h.text(user, "btn_mode_audio")
+	}
+
+	bitrate := user.AudioBitrate
+	if bitrate == "" {
+		bitrate = h.text(user, "settings_default")
+	}
+	formatBtn := telebot.InlineButton{
+		Text:   h.text(user, "btn_audio_format") + strings.ToUpper(audioFormat(user)),
+		Unique: "cycle_audio_format",
+	}
+	bitrateBtn := telebot.InlineButton{
+		Text:   h.text(user, "btn_audio_bitrate") + bitrate,
+		Unique: "cycle_audio_bitrate",
+	}
+
+	return &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{interfaceLangBtn, captionLangBtn},
+			{modeBtn},
+			{sendAsBtn},
+			{deliveryBtn},
+			{formatBtn, bitrateBtn},
+		},
+	}
+}
+
+// toggleSetting is a preference a user turns on or off with a command, e.g. /quiet on
+type toggleSetting struct {
+	command string // the command, for the log
+	get     func(user *models.User) bool
+	set     func(ctx context.Context, chatID int64, enabled bool) error
+
+	// Keys of the messages showing the current setting and how to change it, and of those confirming a change
+	statusOn, statusOff     string
+	enabledKey, disabledKey string
+}
+
+// handleToggle handles a command that turns a setting on or off with its on or off argument, and shows the
+// current setting without one
+func (h *BotHandler) handleToggle(c telebot.Context, setting toggleSetting) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /%s command from chat ID: %d", setting.command, chatID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
+	if err != nil {
+		h.logger.Error("Error finding user: %v", err)
+		return h.send(c, h.errorMessage(user, err))
+	}
+	if user == nil {
+		return h.send(c, h.text(user, "start_first"))
+	}
+
+	var enabled bool
+	switch strings.ToLower(strings.TrimSpace(c.Message().Payload)) {
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		if setting.get(user) {
+			return h.reply(c, h.language(user), setting.statusOn, nil)
+		}
+		return h.reply(c, h.language(user), setting.statusOff, nil)
+	}
+
+	if err := setting.set(ctx, chatID, enabled); err != nil {
+		h.logger.Error("Error updating the %s setting of chat ID %d: %v", setting.command, chatID, err)
+		return h.send(c, h.errorMessage(user, err))
+	}
+
+	if enabled {
+		return h.reply(c, h.language(user), setting.enabledKey, nil)
+	}
+	return h.reply(c, h.language(user), setting.disabledKey, nil)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
+
+// handleStatus handles the /status command by reporting the state of the user's latest download request
+func (h *BotHandler) handleStatus(c telebot.Context) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received /status command from chat ID: %d", chatID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.FindUserByChatID(ctx, chatID)
+	if err != nil {
+		h.logger.Error("Error finding user: %v", err)
+	}
+
+	request, err := h.downloadRepo.GetLatestRequestByChatID(ctx, chatID)
+	if err != nil {
+		return h.send(c, h.text(user, "error_general"))
+	}
+
+	if request == nil {
+		return h.send(c, h.text(user, "status_none"))
+	}
+
+	return h.send(c, h.formatRequestStatus(request, user), telebot.NoPreview)
+}
+
+// formatRequestStatus builds the localized status report of a download request
+func (h *BotHandler) formatRequestStatus(request *models.DownloadRequest, user *models.User) string {
+	statusMsg := h.textf(user, "status_latest", map[string]string{
+		"url":    request.URL,
+		"status": h.statusLabel(request.Status, user),
+	})
+
+	if request.RetryCount > 0 {
+		statusMsg += h.textf(user, "status_retries", map[string]string{"n": strconv.Itoa(request.RetryCount)})
+	}
+
+	if request.ErrorReason != "" {
+		statusMsg += h.textf(user, "status_error", map[string]string{"error": request.ErrorReason})
+	}
+
+	return statusMsg
+}
+
+// statusLabel returns the localized name of a download request status
+func (h *BotHandler) statusLabel(status string, user *models.User) string {
+	switch status {
+	case "pending":
+		return h.text(user, "status_pending")
+	case "processing":
+		return h.text(user, "status_processing")
+	case "completed":
+		return h.text(user, "status_completed")
+	case "failed":
+		return h.text(user, "status_failed")
+	case "cancelled":
+		return h.text(user, "status_cancelled")
+	default:
+		return status
+	}
+}
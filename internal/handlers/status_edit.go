@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/telebot.v3"
+)
+
+// statusMu guards status messages, which are replaced by a new message when the user deleted them
+var statusMu sync.Mutex
+
+// editStatus edits a status message with a final result, waiting for the shared edit throttle if needed
+func (h *BotHandler) editStatus(msg *telebot.Message, text string, opts ...interface{}) {
+	if msg == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := h.editThrottle.Wait(ctx); err != nil {
+		h.logger.Warn("Gave up waiting to edit status message: %v", err)
+		return
+	}
+
+	if err := h.editOrSend(msg, text, opts...); err != nil {
+		h.logger.Debug("Error editing status message: %v", err)
+	}
+}
+
+// editOrSend edits a status message, or sends the text as a new message that takes the status message's
+// place when the user deleted it, so results aren't lost
+func (h *BotHandler) editOrSend(msg *telebot.Message, text string, opts ...interface{}) error {
+	statusMu.Lock()
+	current := *msg
+	statusMu.Unlock()
+
+	// A status message marked as deleted by a progress edit has no ID
+	if current.ID != 0 {
+		_, err := h.bot.Edit(&current, h.formatText(text), h.formatOptions(opts)...)
+		if err == nil || !isMessageGone(err) {
+			return err
+		}
+		h.logger.Info("Status message %d in chat ID %d was deleted, sending a new one", current.ID, current.Chat.ID)
+	}
+
+	sent, err := h.sendTo(current.Chat.ID, text, opts...)
+	if err != nil {
+		return err
+	}
+
+	statusMu.Lock()
+	*msg = *sent
+	statusMu.Unlock()
+	return nil
+}
+
+// editProgress edits a status message with a progress tick, skipping it when edits are being throttled.
+// Progress stops showing once the user deleted the status message.
+func (h *BotHandler) editProgress(msg *telebot.Message, text string) {
+	if msg == nil {
+		return
+	}
+
+	statusMu.Lock()
+	current := *msg
+	statusMu.Unlock()
+	if current.ID == 0 || !h.editThrottle.Allow() {
+		return
+	}
+
+	_, err := h.bot.Edit(&current, h.formatText(text))
+	if err != nil && isMessageGone(err) {
+		h.logger.Info("Status message %d in chat ID %d was deleted, no longer showing progress", current.ID, current.Chat.ID)
+		// The next final status is sent as a new message
+		statusMu.Lock()
+		if msg.ID == current.ID {
+			msg.ID = 0
+		}
+		statusMu.Unlock()
+		return
+	}
+	if err != nil {
+		h.logger.Debug("Error editing progress message: %v", err)
+	}
+}
+
+// isMessageGone reports whether an edit failed because the message was deleted or can no longer be edited
+func isMessageGone(err error) bool {
+	description := strings.ToLower(err.Error())
+	return strings.Contains(description, "message to edit not found") ||
+		strings.Contains(description, "message can't be edited")
+}
+
+// progressEditInterval is the minimum time between two progress edits of the same status message
+const progressEditInterval = 3 * time.Second
+
+// progressReporter returns a download progress callback that shows the percentage on the status message
+func (h *BotHandler) progressReporter(ctx context.Context, chatID int64, msg *telebot.Message) func(percent float64) {
+	if msg == nil {
+		return nil
+	}
+
+	user, _ := h.userRepo.FindUserByChatID(ctx, chatID)
+
+	var lastEdit time.Time
+	var lastPercent int
+	return func(percent float64) {
+		// Skip repeated percentages and edits that come too soon after the previous one
+		if int(percent) == lastPercent || time.Since(lastEdit) < progressEditInterval {
+			return
+		}
+		lastEdit, lastPercent = time.Now(), int(percent)
+
+		h.editProgress(msg, h.textf(user, "download_progress", map[string]string{"percent": strconv.Itoa(lastPercent)}))
+	}
+}
+
+// retryReporter returns a function that tells the user on a status message that a failed download is retried
+func (h *BotHandler) retryReporter(ctx context.Context, chatID int64, msg *telebot.Message) func(attempt, maxRetries int) {
+	if msg == nil {
+		return nil
+	}
+
+	user, _ := h.userRepo.FindUserByChatID(ctx, chatID)
+	return func(attempt, maxRetries int) {
+		h.editProgress(msg, h.textf(user, "download_retrying", map[string]string{
+			"attempt": strconv.Itoa(attempt),
+			"max":     strconv.Itoa(maxRetries),
+		}))
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gopkg.in/telebot.v3"
+)
+
+// maxSubtitleButtons is the most subtitle languages offered when the requested one isn't available,
+// hosts with machine translation list over a hundred
+const maxSubtitleButtons = 12
+
+// subtitleDownloadTimeout bounds listing or downloading the subtitles of a video from a button
+const subtitleDownloadTimeout = 2 * time.Minute
+
+// offerSubtitleLanguages tells the user the video has no subtitle in the requested language and offers
+// the languages it does have, if any
+func (h *BotHandler) offerSubtitleLanguages(chat *telebot.Chat, result *models.DownloadResult, opts downloader.DownloadOptions, user *models.User) {
+	ctx, cancel := context.WithTimeout(context.Background(), subtitleDownloadTimeout)
+	defer cancel()
+
+	tracks, err := h.downloader.ListSubtitles(ctx, result.URL, opts.CookiesFile)
+	if err != nil {
+		h.logger.Warn("Error listing subtitles of %s: %v", result.URL, err)
+		return
+	}
+	if len(tracks) == 0 {
+		return
+	}
+	if len(tracks) > maxSubtitleButtons {
+		tracks = tracks[:maxSubtitleButtons]
+	}
+
+	var buttons [][]telebot.InlineButton
+	var row []telebot.InlineButton
+	for _, track := range tracks {
+		text := track.Language
+		if track.Automatic {
+			text += " " + h.text(user, "subtitle_auto_label")
+		}
+		row = append(row, telebot.InlineButton{
+			Text:   text,
+			Unique: "subtitle_lang",
+			Data:   result.ID.Hex() + ":" + track.Language,
+		})
+		if len(row) == 3 {
+			buttons = append(buttons, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		buttons = append(buttons, row)
+	}
+
+	msg := h.textf(user, "subtitle_choose_language", map[string]string{"language": opts.FileLang})
+	if _, err := h.sendTo(chat.ID, msg, &telebot.ReplyMarkup{InlineKeyboard: buttons}); err != nil {
+		h.logger.Error("Error sending subtitle languages: %v", err)
+	}
+}
+
+// handleSubtitleLanguage handles the subtitle language buttons by downloading and sending the subtitle file in that language
+func (h *BotHandler) handleSubtitleLanguage(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	id, lang, _ := strings.Cut(c.Data(), ":")
+	resultID, err := primitive.ObjectIDFromHex(id)
+	if err != nil || !downloader.ValidSubtitleLanguage(lang) {
+		h.logger.Warn("Invalid subtitle button from chat ID %d: %s", chatID, c.Data())
+		return h.invalidRequest(c, h.findUser(chatID))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), subtitleDownloadTimeout)
+	defer cancel()
+
+	user := h.findUser(chatID)
+	result, err := h.downloadRepo.GetDownloadResultByID(ctx, resultID)
+	if err != nil || result == nil || result.ChatID != chatID {
+		return h.invalidRequest(c, user)
+	}
+
+	h.logger.Info("Downloading %s subtitle of %s for chat ID %d", lang, result.URL, chatID)
+	c.Respond(&telebot.CallbackResponse{
+		Text: h.text(user, "subtitle_downloading"),
+	})
+
+	subtitlePath, err := h.downloader.DownloadSubtitle(ctx, result.URL, h.downloadOptions(chatID, user).CookiesFile, lang)
+	if err != nil || subtitlePath == "" {
+		if err != nil {
+			h.logger.Error("Error downloading %s subtitle of %s: %v", lang, result.URL, err)
+		}
+		return h.send(c, h.text(user, "subtitle_error"))
+	}
+	defer os.RemoveAll(filepath.Dir(subtitlePath))
+
+	_, err = h.sendSubtitleFile(c.Chat(), telebot.FromDisk(subtitlePath), subtitlePath, "", nil, user)
+	return err
+}
+
+// handleTranslateSubs handles the /translatesubs command that turns machine-translated subtitles on or off
+func (h *BotHandler) handleTranslateSubs(c telebot.Context) error {
+	return h.handleToggle(c, toggleSetting{
+		command:     "translatesubs",
+		get:         func(user *models.User) bool { return user.TranslateSubtitles },
+		set:         h.userRepo.UpdateUserTranslateSubtitles,
+		statusOn:    "translatesubs_status_on",
+		statusOff:   "translatesubs_status_off",
+		enabledKey:  "translatesubs_enabled",
+		disabledKey: "translatesubs_disabled",
+	})
+}
+
+// subtitleCaption returns the caption of a download's subtitle file, telling when it was machine-translated or
+// generated rather than uploaded with the video
+func (h *BotHandler) subtitleCaption(result *models.DownloadResult, user *models.User) string {
+	switch result.SubtitleSource {
+	case downloader.SubtitleTranslated:
+		return h.lm.GetStringf(h.language(user), "subtitle_translated", map[string]string{"language": result.SubtitleFrom})
+	case downloader.SubtitleAutomatic:
+		return h.text(user, "subtitle_automatic")
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"gopkg.in/telebot.v3"
+)
+
+// thumbPreviewTimeout bounds probing a video and fetching its thumbnail for /thumb
+const thumbPreviewTimeout = 60 * time.Second
+
+// handleThumb handles the /thumb command that sends a video's thumbnail and metadata without downloading it
+func (h *BotHandler) handleThumb(c telebot.Context) error {
+	chatID := c.Chat().ID
+	url := strings.TrimSpace(c.Message().Payload)
+	h.logger.Info("Received /thumb command from chat ID %d: %s", chatID, url)
+
+	user := h.findUser(chatID)
+
+	if !isValidURL(url) {
+		return h.send(c, h.text(user, "thumb_usage"))
+	}
+	if !h.isAllowedHost(url) {
+		return h.send(c, h.unsupportedSiteMessage(user))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), thumbPreviewTimeout)
+	defer cancel()
+
+	// Previews are cheap but still run yt-dlp, so they count towards the rate limit
+	allowed, err := h.rateLimiter.Allow(ctx, utils.RateLimitDownload, fmt.Sprintf("%d", chatID))
+	if err != nil {
+		h.logger.Warn("Rate limiter error for chat ID %d, allowing request: %v", chatID, err)
+	}
+	if !allowed {
+		return h.send(c, h.text(user, "error_rate_limit"))
+	}
+
+	request := models.NewDownloadRequest(chatID, url)
+	request.Source = "thumb"
+	request.Status = "processing"
+	request, err = h.downloadRepo.CreateDownloadRequest(ctx, request)
+	if err != nil {
+		h.logger.Error("Error creating preview request: %v", err)
+		return h.send(c, h.errorMessage(user, err))
+	}
+
+	opts := h.downloadOptions(chatID, user)
+	preview, err := h.downloader.Preview(ctx, url, opts.CookiesFile)
+	if preview != nil && preview.Dir != "" {
+		defer func() {
+			if err := os.RemoveAll(preview.Dir); err != nil {
+				h.logger.Warn("Failed to remove preview directory: %v", err)
+			}
+		}()
+	}
+
+	if err != nil || preview == nil {
+		h.downloadRepo.UpdateDownloadRequestStatus(ctx, request.ID, "failed")
+		if errors.Is(err, downloader.ErrAuthRequired) {
+			return h.send(c, h.authRequiredMessage(user))
+		}
+		if errors.Is(err, downloader.ErrProxyUnreachable) {
+			return h.send(c, h.proxyUnreachableMessage(user))
+		}
+		if err != nil {
+			h.logger.Error("Error previewing %s: %v", url, err)
+			return h.send(c, h.text(user, "error_general"))
+		}
+		return h.send(c, h.text(user, "error_unsupported_link"))
+	}
+
+	if preview.ThumbnailPath != "" {
+		h.sendThumbnail(chatID, telebot.FromDisk(preview.ThumbnailPath), user)
+	}
+
+	h.downloadRepo.UpdateDownloadRequestStatus(ctx, request.ID, "completed")
+	return h.send(c, h.formatPreview(preview, user), telebot.NoPreview)
+}
+
+// formatPreview returns the localized metadata of a previewed video
+func (h *BotHandler) formatPreview(preview *downloader.VideoPreview, user *models.User) string {
+	var lines []string
+	lines = append(lines, preview.Title)
+
+	if preview.Uploader != "" {
+		lines = append(lines, h.text(user, "preview_uploader")+preview.Uploader)
+	}
+	if preview.Duration > 0 {
+		duration := (time.Duration(preview.Duration) * time.Second).String()
+		lines = append(lines, h.text(user, "preview_duration")+duration)
+	}
+	if preview.ThumbnailPath == "" {
+		lines = append(lines, h.text(user, "preview_no_thumbnail"))
+	}
+
+	return strings.Join(lines, "\n")
+}
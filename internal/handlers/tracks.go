@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"gopkg.in/telebot.v3"
+)
+
+// tracksProbeTimeout bounds probing a video for its audio tracks for /tracks
+const tracksProbeTimeout = 60 * time.Second
+
+// handleTracks handles the /tracks command that lists the audio tracks of a video to download one or all of them
+func (h *BotHandler) handleTracks(c telebot.Context) error {
+	chatID := c.Chat().ID
+	url := strings.TrimSpace(c.Message().Payload)
+	h.logger.Info("Received /tracks command from chat ID %d: %s", chatID, url)
+
+	user := h.findUser(chatID)
+
+	if !isValidURL(url) {
+		return h.send(c, h.text(user, "tracks_usage"))
+	}
+	if !h.isAllowedHost(url) {
+		return h.send(c, h.unsupportedSiteMessage(user))
+	}
+	if h.isPlaylistDownload(url) {
+		return h.send(c, h.text(user, "tracks_single_video"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracksProbeTimeout)
+	defer cancel()
+
+	// Probing runs yt-dlp, so it counts towards the rate limit
+	allowed, err := h.rateLimiter.Allow(ctx, utils.RateLimitDownload, fmt.Sprintf("%d", chatID))
+	if err != nil {
+		h.logger.Warn("Rate limiter error for chat ID %d, allowing request: %v", chatID, err)
+	}
+	if !allowed {
+		return h.send(c, h.text(user, "error_rate_limit"))
+	}
+
+	languages, err := h.downloader.ListAudioTracks(ctx, url, h.downloadOptions(chatID, user).CookiesFile)
+	if errors.Is(err, downloader.ErrAuthRequired) {
+		return h.send(c, h.authRequiredMessage(user))
+	}
+	if errors.Is(err, downloader.ErrProxyUnreachable) {
+		return h.send(c, h.proxyUnreachableMessage(user))
+	}
+	if err != nil {
+		h.logger.Error("Error listing audio tracks of %s: %v", url, err)
+		return h.send(c, h.text(user, "error_general"))
+	}
+
+	if len(languages) < 2 {
+		return h.send(c, h.text(user, "tracks_single_track"))
+	}
+
+	var buttons [][]telebot.InlineButton
+	var row []telebot.InlineButton
+	for _, language := range languages {
+		row = append(row, telebot.InlineButton{Text: language, Unique: "audio_track", Data: language})
+		if len(row) == 3 {
+			buttons = append(buttons, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		buttons = append(buttons, row)
+	}
+	buttons = append(buttons, []telebot.InlineButton{{
+		Text:   h.text(user, "btn_all_tracks"),
+		Unique: "audio_track",
+		Data:   downloader.AllAudioTracks,
+	}})
+
+	// The link is part of the message so the buttons don't have to carry it
+	return h.send(c, h.textf(user, "tracks_choose", map[string]string{"url": url}), &telebot.ReplyMarkup{InlineKeyboard: buttons}, telebot.NoPreview)
+}
+
+// handleAudioTrack handles the /tracks buttons by downloading the video with the chosen audio track
+func (h *BotHandler) handleAudioTrack(c telebot.Context) error {
+	chatID := c.Chat().ID
+	language := c.Data()
+
+	urls := extractURLs(c.Message().Text)
+	if len(urls) == 0 || !downloader.ValidAudioTrack(language) {
+		h.logger.Warn("Invalid audio track button from chat ID %d: %s", chatID, language)
+		return h.invalidRequest(c, h.findUser(chatID))
+	}
+	url := urls[0]
+	user := h.findUser(chatID)
+
+	h.logger.Info("User %d chose audio track %s for %s", chatID, language, url)
+	c.Respond()
+	// Remove the buttons so the choice isn't queued twice
+	if _, err := h.bot.EditReplyMarkup(c.Message(), nil); err != nil {
+		h.logger.Warn("Failed to remove audio track buttons: %v", err)
+	}
+
+	request := models.NewDownloadRequest(chatID, url)
+	request.Source = h.downloadSource(url, user)
+	request.AudioTrack = language
+	_, err := h.queueRequest(c.Chat(), request, user, h.downloadPriority(nil))
+	return err
+}
+
+// sendableAudioTracks returns the audio tracks within the upload limit
+func (h *BotHandler) sendableAudioTracks(tracks []models.AudioTrack) []models.AudioTrack {
+	var sendable []models.AudioTrack
+	for _, track := range tracks {
+		if !h.exceedsUploadLimit(track.Path) {
+			sendable = append(sendable, track)
+		}
+	}
+	return sendable
+}
+
+// sendAudioTracks sends each audio track named after its language, returning the errors of those that failed to send
+func (h *BotHandler) sendAudioTracks(chat *telebot.Chat, tracks []models.AudioTrack, meta *models.VideoMetadata, user *models.User) error {
+	var errs []error
+	for _, track := range tracks {
+		if _, err := h.sendAudioTrack(chat, telebot.FromDisk(track.Path), track.Language, meta, user); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/config"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"gopkg.in/telebot.v3"
+)
+
+// maxUploadedFileSize is the largest file Telegram lets bots download
+const maxUploadedFileSize = 20 * 1024 * 1024
+
+// handleUpload handles media sent to the bot instead of a link. With the uploads feature on, the audio of a video
+// is extracted in the format and bitrate chosen in /settings, other files are answered that they aren't supported.
+// Media posted in groups is left alone, it is usually meant for the other members.
+func (h *BotHandler) handleUpload(c telebot.Context) error {
+	if c.Chat().Type != telebot.ChatPrivate {
+		return nil
+	}
+
+	chatID := c.Chat().ID
+	h.logger.Info("Received uploaded file from chat ID: %d", chatID)
+
+	user := h.findUser(chatID)
+	if !h.featureEnabled(config.FeatureUploads) {
+		return h.reply(c, h.language(user), "upload_not_supported", nil)
+	}
+	file, fileName, ok := uploadedVideo(c.Message())
+	if !ok {
+		return h.reply(c, h.language(user), "upload_video_only", nil)
+	}
+	if file.FileSize > maxUploadedFileSize {
+		return h.reply(c, h.language(user), "upload_too_large", map[string]string{"max": "20 MB"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	allowed, err := h.rateLimiter.Allow(ctx, utils.RateLimitDownload, fmt.Sprintf("%d", chatID))
+	if err != nil {
+		h.logger.Warn("Rate limiter error for chat ID %d, allowing request: %v", chatID, err)
+	}
+	if !allowed {
+		return h.send(c, h.text(user, "error_rate_limit"))
+	}
+	if refused, err := h.refuseDownload(ctx, c.Chat(), user); refused {
+		return err
+	}
+	if !h.acquireUserSlot(chatID, false) {
+		return h.refuseBusyUser(c.Chat(), user)
+	}
+	defer h.releaseUserSlot(chatID)
+
+	statusMsg, err := h.sendTo(chatID, h.text(user, "upload_processing"))
+	if err != nil {
+		h.logger.Error("Error sending processing message: %v", err)
+	}
+
+	uploadDir := filepath.Join(h.config.Download.TempDir, fmt.Sprintf("upload_%d", time.Now().UnixNano()))
+	defer os.RemoveAll(uploadDir)
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		h.logger.Error("Error creating upload directory: %v", err)
+		h.editStatus(statusMsg, h.text(user, "upload_error"))
+		return nil
+	}
+
+	videoPath := filepath.Join(uploadDir, "video"+filepath.Ext(fileName))
+	if err := h.bot.Download(file, videoPath); err != nil {
+		h.logger.Error("Error downloading uploaded file of chat ID %d: %v", chatID, err)
+		h.editStatus(statusMsg, h.text(user, "upload_error"))
+		return nil
+	}
+
+	extractCtx, extractCancel := context.WithTimeout(context.Background(), time.Duration(h.config.Download.Timeout)*time.Second)
+	defer extractCancel()
+
+	bitrate := ""
+	if user != nil {
+		bitrate = user.AudioBitrate
+	}
+	audioPath, err := h.downloader.ExtractAudio(extractCtx, videoPath, audioFormat(user), bitrate)
+	if errors.Is(err, downloader.ErrNoAudio) {
+		h.editStatus(statusMsg, h.text(user, "upload_no_audio"))
+		return nil
+	}
+	if err != nil {
+		h.logger.Error("Error extracting audio of uploaded file of chat ID %d: %v", chatID, err)
+		h.editStatus(statusMsg, h.text(user, "upload_error"))
+		return nil
+	}
+
+	h.editStatus(statusMsg, h.text(user, "download_completed"))
+
+	// Name the audio after the uploaded file, uploads sent as videos often have no name
+	name := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	if name == "" {
+		name = h.text(user, "file_audio_track")
+	}
+	audio := &telebot.Audio{
+		File:     telebot.FromDisk(audioPath),
+		FileName: h.safeFileName(name + filepath.Ext(audioPath)),
+	}
+	if _, err := h.sendFile(c.Chat(), audio); err != nil {
+		h.logger.Error("Error sending audio of uploaded file: %v", err)
+		h.editStatus(statusMsg, h.text(user, "upload_error"))
+	}
+	return nil
+}
+
+// uploadedVideo returns the video of a message, sent as a video or as a document of a video type, and its name
+func uploadedVideo(msg *telebot.Message) (*telebot.File, string, bool) {
+	switch {
+	case msg.Video != nil:
+		return &msg.Video.File, msg.Video.FileName, true
+	case msg.Document != nil && strings.HasPrefix(msg.Document.MIME, "video/"):
+		return &msg.Document.File, msg.Document.FileName, true
+	default:
+		return nil, "", false
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/config"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+
+	"gopkg.in/telebot.v3"
+)
+
+const (
+	// maxURLLength is the longest link accepted for download, longer ones are rejected before they are stored
+	maxURLLength = 2048
+	// maxLoggedTextLength is the number of bytes of a message written to the log
+	maxLoggedTextLength = 512
+)
+
+// extractURLs returns the distinct http(s) URLs in a message, in the order they appear
+func extractURLs(text string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Fields(text) {
+		field = trimTrailingPunctuation(strings.TrimLeft(field, "("))
+		if !isValidURL(field) || seen[field] {
+			continue
+		}
+		seen[field] = true
+		urls = append(urls, field)
+	}
+	return urls
+}
+
+// trimTrailingPunctuation removes the punctuation that links pasted into sentences often end with. A closing
+// parenthesis is kept when it closes one opened in the link, as in Wikipedia links like .../Go_(language).
+// Opening parentheses before a link are removed by the caller.
+func trimTrailingPunctuation(field string) string {
+	for {
+		trimmed := strings.TrimRight(field, ".,;!?")
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, ")") > strings.Count(trimmed, "(") {
+			trimmed = strings.TrimSuffix(trimmed, ")")
+		}
+		if trimmed == field {
+			return field
+		}
+		field = trimmed
+	}
+}
+
+// dropLongURLs returns the URLs that aren't longer than maxURLLength and the number of URLs left out
+func dropLongURLs(urls []string) ([]string, int) {
+	var kept []string
+	for _, url := range urls {
+		if len(url) <= maxURLLength {
+			kept = append(kept, url)
+		}
+	}
+	return kept, len(urls) - len(kept)
+}
+
+// truncateForLog shortens a text to maxLoggedTextLength bytes for the log, noting the full length when cut
+func truncateForLog(text string) string {
+	if len(text) <= maxLoggedTextLength {
+		return text
+	}
+
+	// Don't split a multi-byte character
+	end := maxLoggedTextLength
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return fmt.Sprintf("%s... (%d bytes)", text[:end], len(text))
+}
+
+// urlTooLongMessage returns the localized message for links longer than maxURLLength
+func (h *BotHandler) urlTooLongMessage(user *models.User) string {
+	return h.textf(user, "url_too_long", map[string]string{"max": strconv.Itoa(maxURLLength)})
+}
+
+// isAllowedHost checks if a URL is from one of the allowed sites, any site is allowed when none are configured
+func (h *BotHandler) isAllowedHost(rawURL string) bool {
+	if len(h.config.Download.AllowedHosts) == 0 {
+		return true
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range h.config.Download.AllowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostDownloadOptions converts the configured per-site download options to the downloader's
+func hostDownloadOptions(configured map[string]config.HostDownloadOptions) map[string]downloader.HostOptions {
+	hostOptions := make(map[string]downloader.HostOptions, len(configured))
+	for host, options := range configured {
+		hostOptions[host] = downloader.HostOptions{
+			Format:    options.Format,
+			ExtraArgs: options.ExtraArgs,
+			Referer:   options.Referer,
+			Headers:   options.Headers,
+		}
+	}
+	return hostOptions
+}
+
+// unsupportedSiteMessage returns the localized message for links from sites outside the allowlist
+func (h *BotHandler) unsupportedSiteMessage(user *models.User) string {
+	sites := strings.Join(h.config.Download.AllowedHosts, ", ")
+	return h.textf(user, "site_not_supported", map[string]string{"sites": sites})
+}
+
+// handleMultipleURLs queues a download for each link of a message and replies with how many were queued
+func (h *BotHandler) handleMultipleURLs(c telebot.Context, urls []string, user *models.User) error {
+	chatID := c.Chat().ID
+	h.logger.Info("Received %d links from chat ID %d", len(urls), chatID)
+
+	var notes []string
+	if limit := h.config.Download.MaxURLsPerMsg; limit > 0 && len(urls) > limit {
+		notes = append(notes, h.textf(user, "urls_limit", map[string]string{"max": strconv.Itoa(limit)}))
+		urls = urls[:limit]
+	}
+
+	queued, unsupported, unsupportedSites := 0, 0, 0
+	for _, url := range urls {
+		if !h.isAllowedHost(url) {
+			unsupportedSites++
+			continue
+		}
+
+		// Every link counts towards the rate limit like a link sent on its own
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		allowed, err := h.rateLimiter.Allow(ctx, utils.RateLimitDownload, fmt.Sprintf("%d", chatID))
+		cancel()
+		if err != nil {
+			h.logger.Warn("Rate limiter error for chat ID %d, allowing request: %v", chatID, err)
+		}
+		if !allowed {
+			notes = append(notes, h.text(user, "error_rate_limit"))
+			break
+		}
+
+		var validation *downloader.URLValidation
+		if !h.isPlaylistDownload(url) && !h.hasUserCookies(chatID) {
+			validation, err = h.validateURL(url)
+			if errors.Is(err, downloader.ErrProxyUnreachable) {
+				return h.send(c, h.proxyUnreachableMessage(user))
+			}
+			if errors.Is(err, downloader.ErrAuthRequired) || (err == nil && !validation.Valid) {
+				unsupported++
+				continue
+			}
+		}
+
+		ok, err := h.queueDownload(c.Chat(), url, h.downloadSource(url, user), user, h.downloadPriority(validation), c.Message().ID)
+		if err != nil {
+			h.logger.Error("Error queueing %s for chat ID %d: %v", url, chatID, err)
+		}
+		if !ok {
+			// The user was already told why, e.g. downloads are disabled or the queue is full
+			break
+		}
+		queued++
+	}
+
+	if unsupported > 0 {
+		notes = append(notes, h.textf(user, "urls_unsupported", map[string]string{"n": strconv.Itoa(unsupported)}))
+	}
+
+	if unsupportedSites > 0 {
+		notes = append(notes, h.unsupportedSiteMessage(user))
+	}
+
+	summary := h.textf(user, "urls_queued", map[string]string{
+		"queued": strconv.Itoa(queued),
+		"total":  strconv.Itoa(len(urls)),
+	})
+	return h.send(c, strings.Join(append([]string{summary}, notes...), "\n"))
+}
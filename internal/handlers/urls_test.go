@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/config"
+)
+
+func TestExtractURLs(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"single link", "https://example.com/watch?v=1", []string{"https://example.com/watch?v=1"}},
+		{"trailing punctuation", "see https://example.com/a, and https://example.com/b!", []string{"https://example.com/a", "https://example.com/b"}},
+		{"link in parentheses", "(https://example.com/a)", []string{"https://example.com/a"}},
+		{"link in parentheses after text", "video (https://example.com/a).", []string{"https://example.com/a"}},
+		{"balanced parenthesis kept", "https://en.wikipedia.org/wiki/Go_(language)", []string{"https://en.wikipedia.org/wiki/Go_(language)"}},
+		{"balanced parenthesis in parentheses", "(see https://en.wikipedia.org/wiki/Go_(language))", []string{"https://en.wikipedia.org/wiki/Go_(language)"}},
+		{"duplicates dropped", "https://example.com/a https://example.com/a.", []string{"https://example.com/a"}},
+		{"no links", "hello there", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractURLs(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractURLs(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDropLongURLs(t *testing.T) {
+	link := func(length int) string {
+		const prefix = "https://example.com/"
+		return prefix + strings.Repeat("a", length-len(prefix))
+	}
+	atLimit, overLimit := link(maxURLLength), link(maxURLLength+1)
+
+	kept, dropped := dropLongURLs([]string{"https://example.com/a", atLimit, overLimit})
+	if want := []string{"https://example.com/a", atLimit}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept %d links, want the 2 up to %d bytes", len(kept), maxURLLength)
+	}
+	if dropped != 1 {
+		t.Errorf("dropped %d links, want 1", dropped)
+	}
+
+	if kept, dropped := dropLongURLs([]string{overLimit}); kept != nil || dropped != 1 {
+		t.Errorf("dropLongURLs(one long link) = %d kept, %d dropped, want 0 and 1", len(kept), dropped)
+	}
+}
+
+func TestTruncateForLog(t *testing.T) {
+	atLimit := strings.Repeat("a", maxLoggedTextLength)
+	if got := truncateForLog(atLimit); got != atLimit {
+		t.Errorf("text of %d bytes was truncated", maxLoggedTextLength)
+	}
+
+	overLimit := atLimit + "b"
+	if got, want := truncateForLog(overLimit), fmt.Sprintf("%s... (%d bytes)", atLimit, maxLoggedTextLength+1); got != want {
+		t.Errorf("truncateForLog(%d bytes) = %q, want %q", len(overLimit), got, want)
+	}
+
+	// A two-byte character across the limit is left out whole
+	split := strings.Repeat("a", maxLoggedTextLength-1) + "é" + "b"
+	got := truncateForLog(split)
+	if want := fmt.Sprintf("%s... (%d bytes)", strings.Repeat("a", maxLoggedTextLength-1), len(split)); got != want {
+		t.Errorf("truncateForLog(character across the limit) = %q, want %q", got, want)
+	}
+	if !utf8.ValidString(got) {
+		t.Errorf("truncateForLog() returned invalid UTF-8")
+	}
+}
+
+func TestIsValidURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://www.youtube.com/watch?v=abc", true},
+		{"http://example.com", true},
+		{"https://example.com:8080/video.mp4", true},
+		{"https://192.168.1.10/video.mp4", true},
+		{"https://[::1]:8080/video.mp4", true},
+		{"https://x", false},
+		{"https://1.2.3", false},
+		{"https://example..com", false},
+		{"https://.example.com", false},
+		{"ftp://example.com/video.mp4", false},
+		{"example.com/video", false},
+		{"https://", false},
+		{"javascript:alert(1)", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isValidURL(tt.url); got != tt.want {
+			t.Errorf("isValidURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestIsAllowedHost(t *testing.T) {
+	h := &BotHandler{config: &config.Config{}}
+	if !h.isAllowedHost("https://anything.example/video") {
+		t.Error("a link was refused without an allowlist")
+	}
+
+	h.config.Download.AllowedHosts = []string{"youtube.com", " Instagram.com "}
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://youtube.com/watch?v=abc", true},
+		{"https://www.YouTube.com/watch?v=abc", true},
+		{"https://m.youtube.com/watch?v=abc", true},
+		{"https://www.instagram.com/p/abc", true},
+		{"https://notyoutube.com/watch?v=abc", false},
+		{"https://youtube.com.evil.example/watch?v=abc", false},
+		{"https://vimeo.com/123", false},
+	}
+	for _, tt := range tests {
+		if got := h.isAllowedHost(tt.url); got != tt.want {
+			t.Errorf("isAllowedHost(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"gopkg.in/telebot.v3"
+)
+
+// acquireUserSlot takes one of the slots a chat has for downloads queued or running at the same time, reporting
+// false when they are all taken. Forced slots are taken anyway, for downloads that must not be refused such as
+// the ones resumed after a restart. Each slot taken must be given back with releaseUserSlot.
+func (h *BotHandler) acquireUserSlot(chatID int64, force bool) bool {
+	h.userSlotsMu.Lock()
+	defer h.userSlotsMu.Unlock()
+
+	limit := h.config.Worker.MaxPerUser
+	if !force && limit > 0 && h.userSlots[chatID] >= limit {
+		return false
+	}
+	h.userSlots[chatID]++
+	return true
+}
+
+// releaseUserSlot gives back a slot of a chat once its download ended or was cancelled before it started.
+// When it was the chat's last one, users in quiet mode get the summary of their downloads.
+func (h *BotHandler) releaseUserSlot(chatID int64) {
+	h.userSlotsMu.Lock()
+	if h.userSlots[chatID] > 1 {
+		h.userSlots[chatID]--
+		h.userSlotsMu.Unlock()
+		return
+	}
+	delete(h.userSlots, chatID)
+	summary := h.quietSummaries[chatID]
+	delete(h.quietSummaries, chatID)
+	h.userSlotsMu.Unlock()
+
+	h.sendQuietSummary(chatID, summary)
+}
+
+// refuseBusyUser tells the user to wait for their downloads to finish before starting another one
+func (h *BotHandler) refuseBusyUser(chat *telebot.Chat, user *models.User) error {
+	h.logger.Info("Rejected download for chat ID %d: %d downloads already in progress", chat.ID, h.config.Worker.MaxPerUser)
+	_, err := h.sendTo(chat.ID, h.text(user, "too_many_downloads"))
+	return err
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+)
+
+const (
+	// urlValidationTimeout bounds the simulated yt-dlp run used to validate a URL
+	urlValidationTimeout = 30 * time.Second
+	// urlValidationCacheTTL is how long a validation result is cached in Redis
+	urlValidationCacheTTL = 10 * time.Minute
+)
+
+// validateURL checks that a URL can be downloaded and returns its title and estimated cost, using the Redis cache when possible
+func (h *BotHandler) validateURL(url string) (*downloader.URLValidation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), urlValidationTimeout)
+	defer cancel()
+
+	key := urlValidationKey(url)
+	if h.redisClient != nil {
+		if cached, err := h.redisClient.Get(ctx, key); err == nil {
+			return parseCachedValidation(cached), nil
+		}
+	}
+
+	validation, err := h.downloader.ValidateURL(ctx, url)
+	if err != nil {
+		h.logger.Warn("Error validating URL %s: %v", url, err)
+		return nil, err
+	}
+
+	if h.redisClient != nil {
+		cached := "0|"
+		if validation.Valid {
+			cached = fmt.Sprintf("1|%d|%d|%s", validation.Duration, validation.Size, validation.Title)
+		}
+		if err := h.redisClient.Set(ctx, key, cached, urlValidationCacheTTL); err != nil {
+			h.logger.Warn("Error caching URL validation: %v", err)
+		}
+	}
+
+	return validation, nil
+}
+
+// parseCachedValidation parses a validation cached as "1|<duration>|<size>|<title>" for valid and "0|" for invalid URLs
+func parseCachedValidation(cached string) *downloader.URLValidation {
+	fields := strings.SplitN(cached, "|", 4)
+	validation := &downloader.URLValidation{Valid: fields[0] == "1"}
+	if len(fields) == 4 {
+		validation.Duration, _ = strconv.Atoi(fields[1])
+		validation.Size, _ = strconv.ParseInt(fields[2], 10, 64)
+		validation.Title = fields[3]
+	}
+	return validation
+}
+
+// urlValidationKey returns the Redis key caching the validation of a URL
+func urlValidationKey(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return "url_validation:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/downloader"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gopkg.in/telebot.v3"
+)
+
+// handleZip handles the /zip command that turns zip delivery of playlists on or off
+func (h *BotHandler) handleZip(c telebot.Context) error {
+	return h.handleToggle(c, toggleSetting{
+		command:     "zip",
+		get:         func(user *models.User) bool { return user.ZipPlaylists },
+		set:         h.userRepo.UpdateUserZipPlaylists,
+		statusOn:    "zip_status_on",
+		statusOff:   "zip_status_off",
+		enabledKey:  "zip_enabled",
+		disabledKey: "zip_disabled",
+	})
+}
+
+// sendPlaylistZip bundles the downloaded playlist entries into zip archives, split to fit the upload limit,
+// and sends them as documents. It returns how many entries were sent, those of the archives sent before one failed.
+func (h *BotHandler) sendPlaylistZip(chat *telebot.Chat, requestID primitive.ObjectID, entries []downloader.ArchiveEntry, user *models.User) int {
+	if len(entries) == 0 {
+		return 0
+	}
+
+	archiveDir := filepath.Join(h.config.Download.TempDir, "zip_"+requestID.Hex())
+	defer func() {
+		if err := os.RemoveAll(archiveDir); err != nil {
+			h.logger.Warn("Failed to remove archive directory: %v", err)
+		}
+	}()
+
+	archives, err := downloader.CreateZipArchives(entries, archiveDir, "playlist", h.config.Download.MaxUploadSize)
+	if err != nil {
+		h.logger.Error("Error creating playlist archive: %v", err)
+		return 0
+	}
+	archived := 0
+	for _, archive := range archives {
+		archived += archive.Entries
+	}
+	if archived < len(entries) {
+		h.logger.Warn("Skipped %d playlist entries too large to fit in an archive", len(entries)-archived)
+	}
+
+	sent := 0
+	for i, archive := range archives {
+		doc := &telebot.Document{
+			File:     telebot.FromDisk(archive.Path),
+			FileName: h.safeFileName(filepath.Base(archive.Path)),
+		}
+		if len(archives) > 1 {
+			doc.Caption = h.textf(user, "file_part", map[string]string{"part": strconv.Itoa(i + 1), "parts": strconv.Itoa(len(archives))})
+		}
+
+		if _, err := h.sendFile(chat, doc); err != nil {
+			h.logger.Error("Error sending playlist archive %d/%d: %v", i+1, len(archives), err)
+			return sent
+		}
+		sent += archive.Entries
+	}
+
+	return sent
+}
@@ -0,0 +1,168 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/buildinfo"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/database"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/metrics"
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+)
+
+// Status is the JSON body returned by /healthz
+type Status struct {
+	Status     string                        `json:"status"`
+	KillSwitch bool                          `json:"kill_switch"`
+	Build      buildinfo.Info                `json:"build"`
+	Downloads  *DownloadStats                `json:"downloads,omitempty"`
+	Pools      map[string]database.PoolStats `json:"pools,omitempty"`
+}
+
+// DownloadStats is the download load of the instance
+type DownloadStats struct {
+	Active  int64 `json:"active"`  // downloads being processed
+	Queued  int   `json:"queued"`  // downloads waiting for a worker
+	Workers int   `json:"workers"` // downloads that can be processed at the same time
+}
+
+// Server serves the health and metrics endpoints of the bot
+type Server struct {
+	server     *http.Server
+	killSwitch *utils.KillSwitch
+	pools      []pool
+	downloads  func() DownloadStats
+	maxQueued  int
+	collector  *metrics.Collector
+	logger     *utils.Logger
+}
+
+// pool is a connection pool whose statistics are reported
+type pool struct {
+	name  string
+	stats func() database.PoolStats
+}
+
+// NewServer creates a new health server listening on the given address, an empty one serves nothing
+func NewServer(addr string, killSwitch *utils.KillSwitch, logger *utils.Logger) *Server {
+	s := &Server{
+		killSwitch: killSwitch,
+		logger:     logger,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// WithPool reports the statistics of a connection pool under the given name. It must be called before Start.
+func (s *Server) WithPool(name string, stats func() database.PoolStats) *Server {
+	s.pools = append(s.pools, pool{name: name, stats: stats})
+	return s
+}
+
+// WithDownloads reports the download load, with the status "backlog" once maxQueued downloads or more are waiting
+// for a worker, 0 never reports a backlog. It must be called before Start.
+func (s *Server) WithDownloads(stats func() DownloadStats, maxQueued int) *Server {
+	s.downloads = stats
+	s.maxQueued = maxQueued
+	return s
+}
+
+// WithMetrics also serves the download counters and duration histograms of a collector at /metrics, nil serves
+// none. It must be called before Start.
+func (s *Server) WithMetrics(collector *metrics.Collector) *Server {
+	s.collector = collector
+	return s
+}
+
+// Start serves requests until the server is shut down
+func (s *Server) Start() {
+	// An empty address would listen on port 80 rather than disable the server
+	if s.server.Addr == "" {
+		return
+	}
+	s.logger.Info("Health server listening on %s", s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		s.logger.Error("Health server stopped: %v", err)
+	}
+}
+
+// Shutdown gracefully stops the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// handleHealthz reports the state of the bot
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	status := Status{Status: "ok", Build: buildinfo.Get()}
+	if s.killSwitch != nil && s.killSwitch.Active(r.Context()) {
+		// The instance is healthy but refuses downloads, keep it running
+		status.Status = "disabled"
+		status.KillSwitch = true
+	}
+	if s.downloads != nil {
+		downloads := s.downloads()
+		status.Downloads = &downloads
+		if status.Status == "ok" && s.maxQueued > 0 && downloads.Queued >= s.maxQueued {
+			// Still healthy, but monitors can add instances or alert
+			status.Status = "backlog"
+		}
+	}
+	if len(s.pools) > 0 {
+		status.Pools = make(map[string]database.PoolStats, len(s.pools))
+		for _, p := range s.pools {
+			status.Pools[p.name] = p.stats()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		s.logger.Error("Failed to write health status: %v", err)
+	}
+}
+
+// handleMetrics reports the connection pool statistics, the download load and the download metrics in the
+// Prometheus text format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := make([]database.PoolStats, len(s.pools))
+	for i, p := range s.pools {
+		stats[i] = p.stats()
+	}
+
+	var metrics strings.Builder
+	writeMetric := func(name, metricType, help string, value func(database.PoolStats) string) {
+		fmt.Fprintf(&metrics, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+		for i, p := range s.pools {
+			fmt.Fprintf(&metrics, "%s{pool=%q} %s\n", name, p.name, value(stats[i]))
+		}
+	}
+	writeMetric("bot_pool_in_use_connections", "gauge", "Connections checked out of the pool.",
+		func(ps database.PoolStats) string { return fmt.Sprint(ps.InUse) })
+	writeMetric("bot_pool_idle_connections", "gauge", "Open connections waiting in the pool.",
+		func(ps database.PoolStats) string { return fmt.Sprint(ps.Idle) })
+	writeMetric("bot_pool_wait_count_total", "counter", "Checkouts that found no idle connection.",
+		func(ps database.PoolStats) string { return fmt.Sprint(ps.WaitCount) })
+	if s.downloads != nil {
+		downloads := s.downloads()
+		fmt.Fprintf(&metrics, "# HELP bot_downloads_active Downloads being processed.\n# TYPE bot_downloads_active gauge\nbot_downloads_active %d\n", downloads.Active)
+		fmt.Fprintf(&metrics, "# HELP bot_downloads_queued Downloads waiting for a worker.\n# TYPE bot_downloads_queued gauge\nbot_downloads_queued %d\n", downloads.Queued)
+	}
+	s.collector.WritePrometheus(&metrics)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := w.Write([]byte(metrics.String())); err != nil {
+		s.logger.Error("Failed to write metrics: %v", err)
+	}
+}
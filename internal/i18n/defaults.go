@@ -0,0 +1,73 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/config/languages"
+)
+
+// hasLanguageFiles reports whether a directory listing contains a JSON file
+func hasLanguageFiles(entries []os.DirEntry) bool {
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".json") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeEmbeddedLanguages writes the language files shipped with the bot that are missing from the languages
+// directory. A file that can't be written is logged and skipped, its strings are still loaded from memory.
+func (lm *LanguageManager) writeEmbeddedLanguages() {
+	entries, err := fs.ReadDir(languages.Files, ".")
+	if err != nil {
+		lm.logger.Error("Failed to list embedded language files: %v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		langPath := filepath.Join(lm.languagesPath, entry.Name())
+		if _, err := os.Stat(langPath); err == nil {
+			continue
+		}
+
+		langData, err := languages.Files.ReadFile(entry.Name())
+		if err != nil {
+			lm.logger.Error("Failed to read embedded language file %s: %v", entry.Name(), err)
+			continue
+		}
+		if err := writeFileAtomic(langPath, langData, 0644); err != nil {
+			lm.logger.Error("Failed to write language file %s: %v", langPath, err)
+			continue
+		}
+		lm.logger.Info("Created language file: %s", langPath)
+	}
+}
+
+// loadEmbeddedLanguages parses the language files shipped with the bot, used when none could be read from disk
+func loadEmbeddedLanguages() (map[string]map[string]string, error) {
+	entries, err := fs.ReadDir(languages.Files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		langData, err := languages.Files.ReadFile(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		var langStrings map[string]string
+		if err := json.Unmarshal(langData, &langStrings); err != nil {
+			return nil, fmt.Errorf("failed to parse embedded language file %s: %w", entry.Name(), err)
+		}
+		loaded[fileLanguageCode(entry.Name())] = langStrings
+	}
+	return loaded, nil
+}
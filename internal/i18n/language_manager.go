@@ -3,29 +3,33 @@ package i18n
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
 )
 
 // LanguageManager handles loading and retrieving localized strings
 type LanguageManager struct {
-	languages     map[string]map[string]string
+	languages     map[string]map[string]string // the strings of a language are replaced, never modified in place
 	defaultLang   string
 	languagesPath string
 	logger        *utils.EnhancedLogger
-	mu            sync.RWMutex
+	fallbacks     map[string][]string // languages to try, in order, when a string is missing in a language
+	mu            sync.RWMutex        // guards languages, defaultLang and fallbacks
+	fileMu        sync.Mutex          // serializes reloads and updates of the language files
+	watcher       *fsnotify.Watcher   // reloads changed language files, nil unless StartWatcher was called
+	watchDone     chan struct{}       // closed once the watcher stopped
 }
 
 // NewLanguageManager creates a new language manager
 func NewLanguageManager(languagesPath string, defaultLang string, logger *utils.EnhancedLogger) (*LanguageManager, error) {
 	manager := &LanguageManager{
 		languages:     make(map[string]map[string]string),
-		defaultLang:   defaultLang,
+		defaultLang:   normalizeCode(defaultLang),
 		languagesPath: languagesPath,
 		logger:        logger,
 	}
@@ -38,39 +42,84 @@ func NewLanguageManager(languagesPath string, defaultLang string, logger *utils.
 	return manager, nil
 }
 
-// LoadLanguages loads all language files from the languages directory
-func (lm *LanguageManager) LoadLanguages() error {
+// WithFallbacks sets the fallback chain of each language, e.g. "pt-BR": ["pt", "es"].
+// The default language is always tried last. Language codes are matched case-insensitively.
+func (lm *LanguageManager) WithFallbacks(fallbacks map[string][]string) *LanguageManager {
+	normalized := make(map[string][]string, len(fallbacks))
+	for langCode, chain := range fallbacks {
+		codes := make([]string, len(chain))
+		for i, code := range chain {
+			codes[i] = normalizeCode(code)
+		}
+		normalized[normalizeCode(langCode)] = codes
+	}
+
 	lm.mu.Lock()
 	defer lm.mu.Unlock()
 
+	lm.fallbacks = normalized
+	return lm
+}
+
+// normalizeCode returns a language code in the case the languages are stored in, so "pt-BR" from a file name,
+// the configuration or Telegram finds the strings of "pt-br"
+func normalizeCode(langCode string) string {
+	return strings.ToLower(strings.TrimSpace(langCode))
+}
+
+// fileLanguageCode returns the language code of a language file name, e.g. "pt-BR.json" -> "pt-br"
+func fileLanguageCode(fileName string) string {
+	return normalizeCode(strings.TrimSuffix(fileName, ".json"))
+}
+
+// LoadLanguages loads all language files from the languages directory
+func (lm *LanguageManager) LoadLanguages() error {
+	lm.fileMu.Lock()
+	defer lm.fileMu.Unlock()
+
+	// Files are read without blocking readers, the loaded languages replace the current ones at once
+	languages := make(map[string]map[string]string)
+	lm.mu.RLock()
+	defaultLang := lm.defaultLang
+	lm.mu.RUnlock()
+
 	// Create languages directory if it doesn't exist
 	if err := os.MkdirAll(lm.languagesPath, 0755); err != nil {
 		return fmt.Errorf("failed to create languages directory: %w", err)
 	}
 
 	// Read all files in the languages directory
-	files, err := ioutil.ReadDir(lm.languagesPath)
+	entries, err := os.ReadDir(lm.languagesPath)
 	if err != nil {
 		return fmt.Errorf("failed to read languages directory: %w", err)
 	}
 
+	// On the first run the directory is empty, it gets every language shipped with the bot
+	if !hasLanguageFiles(entries) {
+		lm.logger.Warn("No language files found in %s, writing the default ones", lm.languagesPath)
+		lm.writeEmbeddedLanguages()
+		if entries, err = os.ReadDir(lm.languagesPath); err != nil {
+			return fmt.Errorf("failed to read languages directory: %w", err)
+		}
+	}
+
 	// Load each language file
-	for _, file := range files {
-		if file.IsDir() {
+	for _, entry := range entries {
+		// Only process JSON files
+		if !strings.HasSuffix(entry.Name(), ".json") {
 			continue
 		}
 
-		// Only process JSON files
-		if !strings.HasSuffix(file.Name(), ".json") {
+		langPath := filepath.Join(lm.languagesPath, entry.Name())
+		if !isRegularFile(entry, langPath) {
 			continue
 		}
 
 		// Extract language code from filename (e.g., "en.json" -> "en")
-		langCode := strings.TrimSuffix(file.Name(), ".json")
+		langCode := fileLanguageCode(entry.Name())
 
 		// Load language file
-		langPath := filepath.Join(lm.languagesPath, file.Name())
-		langData, err := ioutil.ReadFile(langPath)
+		langData, err := os.ReadFile(langPath)
 		if err != nil {
 			lm.logger.Error("Failed to read language file %s: %v", langPath, err)
 			continue
@@ -84,146 +133,108 @@ func (lm *LanguageManager) LoadLanguages() error {
 		}
 
 		// Store language strings
-		lm.languages[langCode] = langStrings
+		languages[langCode] = langStrings
 		lm.logger.Info("Loaded language file: %s with %d strings", langPath, len(langStrings))
 	}
 
 	// Check if default language is loaded
-	if _, ok := lm.languages[lm.defaultLang]; !ok {
-		// If no languages are loaded, create default language file
-		if len(lm.languages) == 0 {
-			lm.logger.Warn("No language files found, creating default language file")
-			if err := lm.createDefaultLanguageFile(); err != nil {
-				return fmt.Errorf("failed to create default language file: %w", err)
+	if _, ok := languages[defaultLang]; !ok {
+		// If no language file could be loaded, use the ones shipped with the bot
+		if len(languages) == 0 {
+			lm.logger.Warn("No language files could be loaded, using the embedded ones")
+			embedded, err := loadEmbeddedLanguages()
+			if err != nil {
+				return fmt.Errorf("failed to load embedded language files: %w", err)
 			}
-		} else {
+			languages = embedded
+		}
+		if _, ok := languages[defaultLang]; !ok {
 			// Use first available language as default
-			for langCode := range lm.languages {
-				lm.defaultLang = langCode
-				lm.logger.Warn("Default language %s not found, using %s instead", lm.defaultLang, langCode)
+			for langCode := range languages {
+				lm.logger.Warn("Default language %s not found, using %s instead", defaultLang, langCode)
+				defaultLang = langCode
 				break
 			}
 		}
 	}
 
+	lm.mu.Lock()
+	lm.languages = languages
+	lm.defaultLang = defaultLang
+	lm.mu.Unlock()
+
 	return nil
 }
 
-// createDefaultLanguageFile creates a default language file with English strings
-func (lm *LanguageManager) createDefaultLanguageFile() error {
-	// Default English strings
-	defaultStrings := map[string]string{
-		// Welcome messages
-		"welcome_new":      "Welcome to the Video Downloader Bot! Please select your preferred language:",
-		"welcome_back":     "Welcome back! Send a video link to download it.",
-		
-		// Help messages
-		"help_title":       "Video Downloader Bot Help",
-		"help_usage":       "How to use:",
-		"help_usage_1":     "1. Simply send a video link from YouTube, Twitter, Instagram, etc.",
-		"help_usage_2":     "2. The bot will download and send you:",
-		"help_usage_2_1":   "   - Best quality video",
-		"help_usage_2_2":   "   - Subtitle-embedded video (if captions available)",
-		"help_usage_2_3":   "   - Audio-only file",
-		"help_usage_2_4":   "   - Subtitle file (if available)",
-		"help_commands":    "Commands:",
-		"help_cmd_start":   "/start - Start the bot",
-		"help_cmd_help":    "/help - Show this help message",
-		"help_cmd_lang":    "/lang - Change language settings",
-		"help_cmd_about":   "/about - About this bot",
-		"help_lang":        "Language Settings:",
-		"help_lang_desc":   "You can change your interface language and preferred caption language using the /lang command.",
-		
-		// About message
-		"about":            "This bot downloads and sends: best video, best audio, and subtitles in your preferred language. It also embeds captions into a video version if available. Developed by MohammedTeir.",
-		
-		// Language settings
-		"lang_select":      "Please select what you want to change:",
-		"lang_interface":   "Interface Language",
-		"lang_caption":     "Caption Language",
-		"lang_choose_interface": "Choose Interface Language:",
-		"lang_choose_caption":   "Choose Caption Language:",
-		"lang_updated_interface": "Interface language changed to English!",
-		"lang_updated_caption":   "Caption language updated!",
-		
-		// Download messages
-		"invalid_url":      "Please send a valid video URL.",
-		"processing":       "Processing your video. This may take a while...",
-		"download_error":   "Failed to download video. Please try again later.",
-		"download_completed": "Download completed! Sending files...",
-		"video_with_subs":  "Video with embedded subtitles",
-		"all_files_sent":   "All files sent! Send another video link to download more.",
-		
-		// Error messages
-		"error_general":    "An error occurred. Please try again later.",
-		"error_rate_limit": "You've reached the rate limit. Please try again later.",
-		
-		// Button labels
-		"btn_ar":           "العربية 🇸🇦",
-		"btn_en":           "English 🇬🇧",
-		"btn_de":           "Deutsch 🇩🇪",
-		"btn_fr":           "Français 🇫🇷",
+// isRegularFile checks if a directory entry is a regular file, following symlinks to their target.
+// Directories, broken symlinks and special files such as sockets are skipped.
+func isRegularFile(entry os.DirEntry, path string) bool {
+	if entry.Type()&os.ModeSymlink == 0 {
+		return entry.Type().IsRegular()
 	}
 
-	// Create default language file
-	langPath := filepath.Join(lm.languagesPath, fmt.Sprintf("%s.json", lm.defaultLang))
-	langData, err := json.MarshalIndent(defaultStrings, "", "  ")
+	info, err := os.Stat(path)
 	if err != nil {
-		return fmt.Errorf("failed to marshal default language strings: %w", err)
+		return false
 	}
-
-	if err := ioutil.WriteFile(langPath, langData, 0644); err != nil {
-		return fmt.Errorf("failed to write default language file: %w", err)
-	}
-
-	// Load default language
-	lm.languages[lm.defaultLang] = defaultStrings
-	lm.logger.Info("Created default language file: %s", langPath)
-
-	return nil
+	return info.Mode().IsRegular()
 }
 
-// GetString returns a localized string for the given key and language
+// GetString returns a localized string for the given key and language,
+// walking the language's fallback chain and finally the default language
 func (lm *LanguageManager) GetString(langCode string, key string) string {
 	lm.mu.RLock()
 	defer lm.mu.RUnlock()
 
-	// Check if language exists
-	langStrings, ok := lm.languages[langCode]
-	if !ok {
-		// Fallback to default language
-		langStrings, ok = lm.languages[lm.defaultLang]
-		if !ok {
-			// If default language doesn't exist, return the key
-			return key
+	for _, code := range lm.fallbackChain(langCode) {
+		if value, ok := lm.languages[code][key]; ok {
+			return value
 		}
 	}
 
-	// Check if key exists in language
-	value, ok := langStrings[key]
-	if !ok {
-		// Check if key exists in default language
-		if langCode != lm.defaultLang {
-			defaultStrings, ok := lm.languages[lm.defaultLang]
-			if ok {
-				value, ok = defaultStrings[key]
-				if ok {
-					return value
-				}
-			}
-		}
-		// If key doesn't exist in any language, return the key
-		return key
-	}
+	// If key doesn't exist in any language, return the key
+	return key
+}
+
+// GetStringf returns the localized string of a key like GetString, with {name} placeholders replaced by params,
+// e.g. "Downloading {n}/{total}". Placeholders missing from params are left in the string.
+func (lm *LanguageManager) GetStringf(langCode string, key string, params map[string]string) string {
+	return replaceParams(lm.GetString(langCode, key), params)
+}
 
-	return value
+// fallbackChain returns the languages to look a string up in, in order, for a language code.
+// Without a configured chain a regional variant falls back to its base language, e.g. "pt-BR" to "pt".
+func (lm *LanguageManager) fallbackChain(langCode string) []string {
+	langCode = normalizeCode(langCode)
+	chain := []string{langCode}
+	if fallbacks, ok := lm.fallbacks[langCode]; ok {
+		chain = append(chain, fallbacks...)
+	} else if base, _, found := strings.Cut(langCode, "-"); found {
+		chain = append(chain, base)
+	}
+	return append(chain, lm.defaultLang)
 }
 
 // GetDefaultLanguage returns the default language code
 func (lm *LanguageManager) GetDefaultLanguage() string {
+	lm.mu.RLock()
+	defer lm.mu.RUnlock()
+
 	return lm.defaultLang
 }
 
+// HasLanguage reports whether a language has a language file or a fallback chain, so its users can be shown its
+// strings or those of the languages it falls back to
+func (lm *LanguageManager) HasLanguage(langCode string) bool {
+	lm.mu.RLock()
+	defer lm.mu.RUnlock()
+
+	langCode = normalizeCode(langCode)
+	_, hasFile := lm.languages[langCode]
+	_, hasFallbacks := lm.fallbacks[langCode]
+	return hasFile || hasFallbacks
+}
+
 // GetAvailableLanguages returns a list of available language codes
 func (lm *LanguageManager) GetAvailableLanguages() []string {
 	lm.mu.RLock()
@@ -239,16 +250,17 @@ func (lm *LanguageManager) GetAvailableLanguages() []string {
 
 // AddOrUpdateString adds or updates a string in a language file
 func (lm *LanguageManager) AddOrUpdateString(langCode string, key string, value string) error {
-	lm.mu.Lock()
-	defer lm.mu.Unlock()
+	lm.fileMu.Lock()
+	defer lm.fileMu.Unlock()
 
-	// Check if language exists
-	langStrings, ok := lm.languages[langCode]
-	if !ok {
-		// Create new language
-		langStrings = make(map[string]string)
-		lm.languages[langCode] = langStrings
+	// Update a copy so readers keep the current strings until the file was written
+	code := normalizeCode(langCode)
+	lm.mu.RLock()
+	langStrings := make(map[string]string, len(lm.languages[code])+1)
+	for k, v := range lm.languages[code] {
+		langStrings[k] = v
 	}
+	lm.mu.RUnlock()
 
 	// Add or update string
 	langStrings[key] = value
@@ -260,10 +272,51 @@ func (lm *LanguageManager) AddOrUpdateString(langCode string, key string, value
 		return fmt.Errorf("failed to marshal language strings: %w", err)
 	}
 
-	if err := ioutil.WriteFile(langPath, langData, 0644); err != nil {
+	if err := writeFileAtomic(langPath, langData, 0644); err != nil {
 		return fmt.Errorf("failed to write language file: %w", err)
 	}
 
+	lm.mu.Lock()
+	lm.languages[code] = langStrings
+	lm.mu.Unlock()
+
+	return nil
+}
+
+// writeFileAtomic writes a file through a temporary file in the same directory renamed over it,
+// so it is never read half written and a crash leaves either the old or the new content
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	// Flush to disk before the rename, otherwise a crash could leave the renamed file empty
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// The rename is only durable once the directory is flushed too. The file is in place already, so a directory
+	// that can't be synced, as on Windows, costs durability and not the write.
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
 	return nil
 }
 
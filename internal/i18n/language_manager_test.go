@@ -0,0 +1,257 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+)
+
+// newTestManager creates a language manager over a directory holding the given language files
+func newTestManager(t *testing.T, defaultLang string, files map[string]map[string]string) *LanguageManager {
+	t.Helper()
+
+	dir := t.TempDir()
+	for langCode, strings := range files {
+		data, err := json.Marshal(strings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, langCode+".json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logger, err := utils.NewEnhancedLogger(&utils.EnhancedLoggerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lm, err := NewLanguageManager(dir, defaultLang, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lm
+}
+
+func TestGetString(t *testing.T) {
+	lm := newTestManager(t, "en", map[string]map[string]string{
+		"en": {"greeting": "Hello", "farewell": "Goodbye"},
+		"de": {"greeting": "Hallo"},
+	})
+
+	tests := []struct {
+		name     string
+		langCode string
+		key      string
+		want     string
+	}{
+		{"key of the language", "de", "greeting", "Hallo"},
+		{"key of the default language", "en", "farewell", "Goodbye"},
+		{"missing key falls back to the default language", "de", "farewell", "Goodbye"},
+		{"unknown language uses the default language", "xx", "greeting", "Hello"},
+		{"unknown key returns the key", "de", "missing", "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lm.GetString(tt.langCode, tt.key); got != tt.want {
+				t.Errorf("GetString(%q, %q) = %q, want %q", tt.langCode, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetStringFallbackChain(t *testing.T) {
+	lm := newTestManager(t, "en", map[string]map[string]string{
+		"en":    {"greeting": "Hello", "farewell": "Goodbye", "thanks": "Thanks"},
+		"es":    {"greeting": "Hola", "farewell": "Adiós"},
+		"pt":    {"greeting": "Olá"},
+		"pt-BR": {"welcome": "Bem-vindo"},
+	})
+	// Keys of YAML configuration are lowercased, those of the JSON environment variable keep their case
+	lm.WithFallbacks(map[string][]string{"PT-BR": {"PT", "es"}})
+
+	tests := []struct {
+		name     string
+		langCode string
+		key      string
+		want     string
+	}{
+		{"key of the language file", "pt-BR", "welcome", "Bem-vindo"},
+		{"first fallback", "pt-BR", "greeting", "Olá"},
+		{"second fallback", "pt-BR", "farewell", "Adiós"},
+		{"default language after the chain", "pt-BR", "thanks", "Thanks"},
+		{"code in another case", "PT-br", "farewell", "Adiós"},
+		{"regional variant without a chain falls back to its base", "es-MX", "greeting", "Hola"},
+		{"base language doesn't use the chain of its variant", "pt", "farewell", "Goodbye"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lm.GetString(tt.langCode, tt.key); got != tt.want {
+				t.Errorf("GetString(%q, %q) = %q, want %q", tt.langCode, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetStringf(t *testing.T) {
+	lm := newTestManager(t, "en", map[string]map[string]string{
+		"en": {
+			"queued":   "Position {position} of {total}",
+			"repeated": "{name}, {name}!",
+			"plain":    "No placeholders",
+		},
+		"de": {"queued": "Position {position} von {total}"},
+	})
+
+	tests := []struct {
+		name     string
+		langCode string
+		key      string
+		params   map[string]string
+		want     string
+	}{
+		{"every placeholder", "en", "queued", map[string]string{"position": "2", "total": "5"}, "Position 2 of 5"},
+		{"language of the user", "de", "queued", map[string]string{"position": "2", "total": "5"}, "Position 2 von 5"},
+		{"missing parameter kept", "en", "queued", map[string]string{"position": "2"}, "Position 2 of {total}"},
+		{"repeated placeholder", "en", "repeated", map[string]string{"name": "Ana"}, "Ana, Ana!"},
+		{"value looking like a placeholder", "en", "queued", map[string]string{"position": "{total}", "total": "5"}, "Position {total} of 5"},
+		{"unused parameter", "en", "plain", map[string]string{"name": "Ana"}, "No placeholders"},
+		{"no parameters", "en", "queued", nil, "Position {position} of {total}"},
+		{"fallback to the default language", "fr", "repeated", map[string]string{"name": "Ana"}, "Ana, Ana!"},
+		{"unknown key", "en", "missing", map[string]string{"name": "Ana"}, "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lm.GetStringf(tt.langCode, tt.key, tt.params); got != tt.want {
+				t.Errorf("GetStringf(%q, %q, %v) = %q, want %q", tt.langCode, tt.key, tt.params, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasLanguage(t *testing.T) {
+	lm := newTestManager(t, "en", map[string]map[string]string{
+		"en":    {"greeting": "Hello"},
+		"pt-BR": {"greeting": "Olá"},
+	})
+	lm.WithFallbacks(map[string][]string{"es-MX": {"en"}})
+
+	for langCode, want := range map[string]bool{
+		"en":    true,
+		"pt-br": true,
+		"PT-BR": true,
+		"es-mx": true,
+		"es":    false,
+		"de":    false,
+	} {
+		if got := lm.HasLanguage(langCode); got != want {
+			t.Errorf("HasLanguage(%q) = %v, want %v", langCode, got, want)
+		}
+	}
+}
+
+// Run with -race, updates of different keys, reads and reloads run at the same time
+func TestAddOrUpdateStringConcurrently(t *testing.T) {
+	lm := newTestManager(t, "en", map[string]map[string]string{
+		"en": {"greeting": "Hello"},
+	})
+
+	const updates = 20
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			if err := lm.AddOrUpdateString("en", fmt.Sprintf("key_%d", i), fmt.Sprintf("value %d", i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if got := lm.GetString("en", "greeting"); got != "Hello" {
+				t.Errorf("GetString(greeting) = %q during updates, want %q", got, "Hello")
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := lm.ReloadLanguages(); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+
+	// No update is lost, neither in memory nor in the file
+	reloaded, err := NewLanguageManager(lm.languagesPath, "en", lm.logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < updates; i++ {
+		key, want := fmt.Sprintf("key_%d", i), fmt.Sprintf("value %d", i)
+		if got := lm.GetString("en", key); got != want {
+			t.Errorf("GetString(%q) = %q, want %q", key, got, want)
+		}
+		if got := reloaded.GetString("en", key); got != want {
+			t.Errorf("GetString(%q) of the file = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestWriteFileAtomicReadDuringWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "en.json")
+
+	// Two versions large enough that a plain write would be seen half done
+	versions := make([][]byte, 2)
+	for i := range versions {
+		messages := make(map[string]string)
+		for j := 0; j < 5000; j++ {
+			messages[fmt.Sprintf("key_%d", j)] = fmt.Sprintf("version %d of string %d", i, j)
+		}
+		data, err := json.Marshal(messages)
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions[i] = data
+	}
+	if err := writeFileAtomic(path, versions[0], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			if err := writeFileAtomic(path, versions[i%2], 0644); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+read:
+	for reads := 0; ; reads++ {
+		select {
+		case <-done:
+			break read
+		default:
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("read %d: %v", reads, err)
+			break
+		}
+		if string(data) != string(versions[0]) && string(data) != string(versions[1]) {
+			t.Errorf("read %d: got %d bytes matching neither version", reads, len(data))
+			break
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,107 @@
+package i18n
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Plural categories as defined by CLDR
+const (
+	pluralZero  = "zero"
+	pluralOne   = "one"
+	pluralTwo   = "two"
+	pluralFew   = "few"
+	pluralMany  = "many"
+	pluralOther = "other"
+)
+
+// GetPlural returns the localized plural form of a key for a count, e.g. "queued.one" or "queued.other",
+// with {name} placeholders replaced by params. {n} is replaced by the count unless params set it.
+func (lm *LanguageManager) GetPlural(langCode string, key string, count int, params map[string]string) string {
+	lm.mu.RLock()
+	defer lm.mu.RUnlock()
+
+	for _, code := range lm.fallbackChain(langCode) {
+		langStrings := lm.languages[code]
+		if value, ok := langStrings[key+"."+pluralCategory(code, count)]; ok {
+			return formatParams(value, count, params)
+		}
+		// Every language must have the "other" form, use it when a category isn't translated
+		if value, ok := langStrings[key+"."+pluralOther]; ok {
+			return formatParams(value, count, params)
+		}
+	}
+
+	// If key doesn't exist in any language, return the key
+	return key
+}
+
+// pluralCategory returns the CLDR cardinal plural category of a count in a language
+func pluralCategory(langCode string, count int) string {
+	// Regional variants use the rules of their base language
+	lang, _, _ := strings.Cut(langCode, "-")
+
+	n := count
+	if n < 0 {
+		n = -n
+	}
+
+	switch lang {
+	case "ar":
+		switch {
+		case n == 0:
+			return pluralZero
+		case n == 1:
+			return pluralOne
+		case n == 2:
+			return pluralTwo
+		case n%100 >= 3 && n%100 <= 10:
+			return pluralFew
+		case n%100 >= 11:
+			return pluralMany
+		}
+		return pluralOther
+	case "fr":
+		if n == 0 || n == 1 {
+			return pluralOne
+		}
+		return pluralOther
+	case "ru":
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return pluralOne
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return pluralFew
+		}
+		return pluralMany
+	default:
+		// English, German, Spanish, Turkish and most European languages
+		if n == 1 {
+			return pluralOne
+		}
+		return pluralOther
+	}
+}
+
+// formatParams replaces the {name} placeholders of a string with their values
+func formatParams(value string, count int, params map[string]string) string {
+	if _, ok := params["n"]; !ok {
+		value = strings.ReplaceAll(value, "{n}", strconv.Itoa(count))
+	}
+	return replaceParams(value, params)
+}
+
+// replaceParams replaces the {name} placeholders of a string with their values,
+// placeholders without a value are left as they are
+func replaceParams(value string, params map[string]string) string {
+	if len(params) == 0 {
+		return value
+	}
+
+	// A single pass, so values that look like placeholders aren't replaced in turn
+	pairs := make([]string, 0, 2*len(params))
+	for name, param := range params {
+		pairs = append(pairs, "{"+name+"}", param)
+	}
+	return strings.NewReplacer(pairs...).Replace(value)
+}
@@ -0,0 +1,114 @@
+package i18n
+
+import (
+	"sort"
+	"strings"
+)
+
+// ValidationResult lists, per language, the keys that differ from the default language
+type ValidationResult struct {
+	DefaultLang string
+	Missing     map[string][]string // keys of the default language a language doesn't have, shown in the fallback language
+	Extra       map[string][]string // keys a language has that the default language doesn't, never shown
+}
+
+// Complete reports whether every language has the keys of the default language
+func (r *ValidationResult) Complete() bool {
+	return len(r.Missing) == 0
+}
+
+// Languages returns the codes of the languages with missing or extra keys, sorted
+func (r *ValidationResult) Languages() []string {
+	seen := make(map[string]bool)
+	for langCode := range r.Missing {
+		seen[langCode] = true
+	}
+	for langCode := range r.Extra {
+		seen[langCode] = true
+	}
+	return sortedKeys(seen)
+}
+
+// Validate compares the keys of every language with those of the default language and logs the differences.
+// Plural forms other than "other" are left out, languages use different sets of them.
+func (lm *LanguageManager) Validate() *ValidationResult {
+	lm.mu.RLock()
+	languages, defaultLang := lm.languages, lm.defaultLang
+	lm.mu.RUnlock()
+
+	result := &ValidationResult{
+		DefaultLang: defaultLang,
+		Missing:     make(map[string][]string),
+		Extra:       make(map[string][]string),
+	}
+	defaultKeys := comparableKeys(languages[defaultLang])
+
+	for langCode, langStrings := range languages {
+		if langCode == defaultLang {
+			continue
+		}
+		keys := comparableKeys(langStrings)
+
+		for key := range defaultKeys {
+			if !keys[key] {
+				result.Missing[langCode] = append(result.Missing[langCode], key)
+			}
+		}
+		for key := range keys {
+			if !defaultKeys[key] {
+				result.Extra[langCode] = append(result.Extra[langCode], key)
+			}
+		}
+		sort.Strings(result.Missing[langCode])
+		sort.Strings(result.Extra[langCode])
+	}
+
+	for _, langCode := range result.Languages() {
+		if missing := result.Missing[langCode]; len(missing) > 0 {
+			lm.logger.Warn("Language %s is missing %d keys: %s", langCode, len(missing), strings.Join(missing, ", "))
+		}
+		if extra := result.Extra[langCode]; len(extra) > 0 {
+			lm.logger.Warn("Language %s has %d keys unknown to %s: %s", langCode, len(extra), defaultLang, strings.Join(extra, ", "))
+		}
+	}
+	return result
+}
+
+// comparableKeys returns the keys of a language's strings, without the optional plural forms
+func comparableKeys(langStrings map[string]string) map[string]bool {
+	keys := make(map[string]bool, len(langStrings))
+	for key := range langStrings {
+		if _, form, ok := cutLast(key, "."); ok && form != pluralOther && isPluralCategory(form) {
+			continue
+		}
+		keys[key] = true
+	}
+	return keys
+}
+
+// cutLast slices a string around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// isPluralCategory reports whether a key suffix is a CLDR plural category
+func isPluralCategory(form string) bool {
+	switch form {
+	case pluralZero, pluralOne, pluralTwo, pluralFew, pluralMany, pluralOther:
+		return true
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a set, sorted
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,120 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// StartWatcher reloads a language file whenever it changes on disk, so strings can be edited without a restart.
+// Call StopWatcher to stop watching.
+func (lm *LanguageManager) StartWatcher() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create language file watcher: %w", err)
+	}
+
+	// Watch the directory rather than the files, editors often replace a file instead of writing to it
+	if err := watcher.Add(lm.languagesPath); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch languages directory: %w", err)
+	}
+
+	lm.watcher = watcher
+	lm.watchDone = make(chan struct{})
+	go lm.watch(watcher, lm.watchDone)
+
+	lm.logger.Info("Watching %s for language file changes", lm.languagesPath)
+	return nil
+}
+
+// StopWatcher stops reloading language files on changes and waits for a reload in progress to finish
+func (lm *LanguageManager) StopWatcher() error {
+	if lm.watcher == nil {
+		return nil
+	}
+
+	err := lm.watcher.Close()
+	<-lm.watchDone
+	lm.watcher = nil
+	return err
+}
+
+// watch reloads the language files named by the watcher's events until the watcher is closed
+func (lm *LanguageManager) watch(watcher *fsnotify.Watcher, done chan struct{}) {
+	defer close(done)
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			// Temporary files of AddOrUpdateString don't end with .json, the rename onto the file does
+			if !strings.HasSuffix(event.Name, ".json") || !event.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			lm.reloadLanguageFile(event.Name)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			lm.logger.Error("Language file watcher error: %v", err)
+		}
+	}
+}
+
+// reloadLanguageFile replaces the strings of a language with the content of its file.
+// The previous strings are kept when the file can't be read or parsed, e.g. while it is being edited.
+func (lm *LanguageManager) reloadLanguageFile(langPath string) {
+	lm.fileMu.Lock()
+	defer lm.fileMu.Unlock()
+
+	langData, err := os.ReadFile(langPath)
+	if err != nil {
+		lm.logger.Error("Failed to read changed language file %s, keeping the previous strings: %v", langPath, err)
+		return
+	}
+
+	// Saving truncates the file first, the next event has the content
+	if len(langData) == 0 {
+		return
+	}
+
+	var langStrings map[string]string
+	if err := json.Unmarshal(langData, &langStrings); err != nil {
+		lm.logger.Error("Failed to parse changed language file %s, keeping the previous strings: %v", langPath, err)
+		return
+	}
+
+	langCode := fileLanguageCode(filepath.Base(langPath))
+
+	lm.mu.Lock()
+	changed := countChangedKeys(lm.languages[langCode], langStrings)
+	lm.languages[langCode] = langStrings
+	lm.mu.Unlock()
+
+	if changed > 0 {
+		lm.logger.Info("Reloaded language file %s: %d strings changed", langPath, changed)
+	}
+}
+
+// countChangedKeys counts the keys added, removed or given a different value between two sets of strings
+func countChangedKeys(previous, current map[string]string) int {
+	changed := 0
+	for key, value := range current {
+		if old, ok := previous[key]; !ok || old != value {
+			changed++
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			changed++
+		}
+	}
+	return changed
+}
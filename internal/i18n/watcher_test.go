@@ -0,0 +1,54 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadLanguageFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"changed file", `{"greeting": "Hi"}`, "Hi"},
+		{"invalid file keeps the previous strings", `{"greeting": "Hi"`, "Hello"},
+		{"truncated file keeps the previous strings", "", "Hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lm := newTestManager(t, "en", map[string]map[string]string{"en": {"greeting": "Hello"}})
+			path := filepath.Join(lm.languagesPath, "en.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			lm.reloadLanguageFile(path)
+			if got := lm.GetString("en", "greeting"); got != tt.want {
+				t.Errorf("GetString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatcherReloadsChangedFile(t *testing.T) {
+	lm := newTestManager(t, "en", map[string]map[string]string{"en": {"greeting": "Hello"}})
+	if err := lm.StartWatcher(); err != nil {
+		t.Fatal(err)
+	}
+	defer lm.StopWatcher()
+
+	if err := os.WriteFile(filepath.Join(lm.languagesPath, "en.json"), []byte(`{"greeting": "Hi"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for lm.GetString("en", "greeting") != "Hi" {
+		if time.Now().After(deadline) {
+			t.Fatalf("GetString() = %q after the file changed, want %q", lm.GetString("en", "greeting"), "Hi")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxPlatforms bounds the platform label values, links to any site can be sent and each value is a new series
+const maxPlatforms = 50
+
+// otherPlatform labels the platforms seen after maxPlatforms others, unknownPlatform the links without a host
+const (
+	otherPlatform   = "other"
+	unknownPlatform = "unknown"
+)
+
+// Buckets of the duration histograms, in seconds
+var (
+	downloadBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800}
+	uploadBuckets   = []float64{0.5, 1, 2, 5, 10, 30, 60, 120, 300}
+)
+
+// Collector counts downloads, rate limit rejections and download and upload durations, and writes them in the
+// Prometheus text format. A nil collector records nothing, so metrics cost nothing while they are disabled.
+type Collector struct {
+	mu               sync.Mutex
+	platforms        map[string]bool        // platform label values in use
+	downloads        map[downloadKey]uint64 // downloads that ended
+	rateLimited      map[string]uint64      // rejected requests by rate limit category
+	downloadDuration map[string]*histogram  // successful downloads by platform
+	uploadDuration   map[string]*histogram  // downloads all files of which were sent to Telegram, by platform
+}
+
+// downloadKey are the labels of the downloads counter
+type downloadKey struct {
+	status   string
+	platform string
+}
+
+// histogram counts observations per bucket, the last count being those above every bucket
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+}
+
+// New creates a collector without any observation
+func New() *Collector {
+	return &Collector{
+		platforms:        make(map[string]bool),
+		downloads:        make(map[downloadKey]uint64),
+		rateLimited:      make(map[string]uint64),
+		downloadDuration: make(map[string]*histogram),
+		uploadDuration:   make(map[string]*histogram),
+	}
+}
+
+// DownloadFinished counts a download that ended with a status such as completed, failed or cancelled
+func (c *Collector) DownloadFinished(platform, status string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.downloads[downloadKey{status: status, platform: c.platform(platform)}]++
+}
+
+// RateLimited counts a request refused by the rate limit of a category
+func (c *Collector) RateLimited(category string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rateLimited[category]++
+}
+
+// ObserveDownload records how long a successful download from a platform took, retries included
+func (c *Collector) ObserveDownload(platform string, duration time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	observe(c.downloadDuration, c.platform(platform), downloadBuckets, duration)
+}
+
+// ObserveUpload records how long sending the files of a download from a platform to Telegram took, for downloads
+// all files of which were sent
+func (c *Collector) ObserveUpload(platform string, duration time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	observe(c.uploadDuration, c.platform(platform), uploadBuckets, duration)
+}
+
+// platform returns the label value of a platform, once maxPlatforms are in use new ones are counted as other.
+// The caller must hold the lock.
+func (c *Collector) platform(platform string) string {
+	if platform == "" {
+		return unknownPlatform
+	}
+	if !c.platforms[platform] {
+		if len(c.platforms) >= maxPlatforms {
+			return otherPlatform
+		}
+		c.platforms[platform] = true
+	}
+	return platform
+}
+
+// observe adds a duration to the histogram of a label value, creating it on first use
+func observe(histograms map[string]*histogram, label string, buckets []float64, duration time.Duration) {
+	h, ok := histograms[label]
+	if !ok {
+		h = &histogram{buckets: buckets, counts: make([]uint64, len(buckets)+1)}
+		histograms[label] = h
+	}
+
+	seconds := duration.Seconds()
+	i := sort.SearchFloat64s(h.buckets, seconds)
+	h.counts[i]++
+	h.sum += seconds
+}
+
+// WritePrometheus writes the metrics in the Prometheus text format, series sorted by their labels
+func (c *Collector) WritePrometheus(w io.Writer) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP bot_downloads_total Downloads that ended, by status and platform.\n# TYPE bot_downloads_total counter\n")
+	keys := make([]downloadKey, 0, len(c.downloads))
+	for key := range c.downloads {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].platform != keys[j].platform {
+			return keys[i].platform < keys[j].platform
+		}
+		return keys[i].status < keys[j].status
+	})
+	for _, key := range keys {
+		fmt.Fprintf(w, "bot_downloads_total{platform=%q,status=%q} %d\n", key.platform, key.status, c.downloads[key])
+	}
+
+	fmt.Fprintf(w, "# HELP bot_rate_limit_rejections_total Requests refused by the rate limit, by category.\n# TYPE bot_rate_limit_rejections_total counter\n")
+	for _, category := range sortedKeys(c.rateLimited) {
+		fmt.Fprintf(w, "bot_rate_limit_rejections_total{category=%q} %d\n", category, c.rateLimited[category])
+	}
+
+	writeHistograms(w, "bot_download_duration_seconds", "Duration of successful downloads, retries included.", c.downloadDuration)
+	writeHistograms(w, "bot_upload_duration_seconds", "Duration of sending all files of a download to Telegram.", c.uploadDuration)
+}
+
+// writeHistograms writes the histograms of a metric, one per platform, with cumulative bucket counts
+func writeHistograms(w io.Writer, name, help string, histograms map[string]*histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for _, platform := range sortedKeys(histograms) {
+		h := histograms[platform]
+		var count uint64
+		for i, bucket := range h.buckets {
+			count += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket{platform=%q,le=%q} %d\n", name, platform, strconv.FormatFloat(bucket, 'g', -1, 64), count)
+		}
+		count += h.counts[len(h.buckets)]
+		fmt.Fprintf(w, "%s_bucket{platform=%q,le=\"+Inf\"} %d\n", name, platform, count)
+		fmt.Fprintf(w, "%s_sum{platform=%q} %g\n", name, platform, h.sum)
+		fmt.Fprintf(w, "%s_count{platform=%q} %d\n", name, platform, count)
+	}
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
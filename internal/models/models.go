@@ -1,6 +1,8 @@
 package models
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -17,6 +19,17 @@ type User struct {
 	LastActivity     time.Time          `bson:"last_activity" json:"last_activity"`
 	RequestCount     int                `bson:"request_count" json:"request_count"`
 	RateLimitReset   time.Time          `bson:"rate_limit_reset" json:"rate_limit_reset"`
+	IncludeMetadata  bool               `bson:"include_metadata" json:"include_metadata"` // send info JSON and description with downloads
+	ZipPlaylists     bool               `bson:"zip_playlists" json:"zip_playlists"`       // bundle playlist downloads into zip archives
+	SendAsDocument   bool               `bson:"send_as_document" json:"send_as_document"` // send videos as documents to keep the original quality
+	DownloadMode     string             `bson:"download_mode,omitempty" json:"download_mode,omitempty"` // what links are downloaded as, video or audio, defaults to video
+	NotifyOnReady    bool               `bson:"notify_on_ready" json:"notify_on_ready"` // send buttons to fetch finished downloads instead of the files
+	AudioFormat      string             `bson:"audio_format,omitempty" json:"audio_format,omitempty"`   // format of extracted audio, defaults to mp3
+	AudioBitrate     string             `bson:"audio_bitrate,omitempty" json:"audio_bitrate,omitempty"` // bitrate of extracted audio, empty keeps yt-dlp's default
+	BurnLanguage     string             `bson:"burn_language,omitempty" json:"burn_language,omitempty"` // subtitles burned into the video, defaults to the caption language
+	FileLanguage     string             `bson:"file_language,omitempty" json:"file_language,omitempty"` // subtitle file sent separately, defaults to the caption language
+	TranslateSubtitles bool             `bson:"translate_subtitles" json:"translate_subtitles"` // machine-translate subtitles when a video has none in the user's languages
+	QuietMode        bool               `bson:"quiet_mode" json:"quiet_mode"` // one summary once all downloads finished instead of a message per download
 }
 
 // NewUser creates a new user with default values
@@ -33,14 +46,37 @@ func NewUser(chatID int64) *User {
 	}
 }
 
+// SubtitleBurnLanguage returns the language of the subtitles burned into videos
+func (u *User) SubtitleBurnLanguage() string {
+	if u.BurnLanguage != "" {
+		return u.BurnLanguage
+	}
+	return u.CaptionLanguage
+}
+
+// SubtitleFileLanguage returns the language of the subtitle file sent with videos
+func (u *User) SubtitleFileLanguage() string {
+	if u.FileLanguage != "" {
+		return u.FileLanguage
+	}
+	return u.CaptionLanguage
+}
+
 // DownloadRequest represents a video download request
 type DownloadRequest struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	ChatID      int64              `bson:"chat_id" json:"chat_id"`
 	URL         string             `bson:"url" json:"url"`
-	Status      string             `bson:"status" json:"status"` // pending, processing, completed, failed
+	Status      string             `bson:"status" json:"status"` // pending, processing, completed, failed, cancelled
+	Source      string             `bson:"source,omitempty" json:"source,omitempty"` // download, audio, thumb
+	AudioTrack  string             `bson:"audio_track,omitempty" json:"audio_track,omitempty"` // audio language, "all" for every track, empty for the default track
+	ClipStart   int                `bson:"clip_start,omitempty" json:"clip_start,omitempty"` // start of the clip to download in seconds
+	ClipEnd     int                `bson:"clip_end,omitempty" json:"clip_end,omitempty"` // end of the clip to download in seconds, zero for the whole video
+	MaxHeight   int                `bson:"max_height,omitempty" json:"max_height,omitempty"` // highest video height to download, zero for the best available
+	MessageID   int                `bson:"message_id,omitempty" json:"message_id,omitempty"` // the user's message asking for the download, zero for buttons
 	RetryCount  int                `bson:"retry_count" json:"retry_count"`
 	ErrorReason string             `bson:"error_reason,omitempty" json:"error_reason,omitempty"`
+	CorrelationID string           `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"` // short ID shown to the user on failure and added to the logs
 	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
 	CompletedAt time.Time          `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
@@ -52,25 +88,70 @@ func NewDownloadRequest(chatID int64, url string) *DownloadRequest {
 		ChatID:     chatID,
 		URL:        url,
 		Status:     "pending",
+		Source:     "download",
 		RetryCount: 0,
+		CorrelationID: NewCorrelationID(),
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
 }
 
+// NewCorrelationID returns a short random ID that ties a download's logs to what its user reports, e.g. "a1b2c3d4"
+func NewCorrelationID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
 // DownloadResult represents the result of a video download
 type DownloadResult struct {
 	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	RequestID       primitive.ObjectID `bson:"request_id" json:"request_id"`
 	ChatID          int64              `bson:"chat_id" json:"chat_id"`
+	URL             string             `bson:"url" json:"url"`
 	VideoPath       string             `bson:"video_path" json:"video_path"`
 	VideoWithSubPath string            `bson:"video_with_sub_path" json:"video_with_sub_path"`
 	AudioPath       string             `bson:"audio_path" json:"audio_path"`
 	SubtitlePath    string             `bson:"subtitle_path" json:"subtitle_path"`
+	ThumbnailPath   string             `bson:"thumbnail_path" json:"thumbnail_path"`
+	InfoJSONPath    string             `bson:"info_json_path,omitempty" json:"info_json_path,omitempty"`
+	DescriptionPath string             `bson:"description_path,omitempty" json:"description_path,omitempty"`
 	HasSubtitle     bool               `bson:"has_subtitle" json:"has_subtitle"`
+	SubtitleSource  string             `bson:"subtitle_source,omitempty" json:"subtitle_source,omitempty"` // uploaded, automatic or translated, empty when unknown
+	SubtitleFrom    string             `bson:"subtitle_from,omitempty" json:"subtitle_from,omitempty"` // language a translated subtitle was translated from
 	FileSize        int64              `bson:"file_size" json:"file_size"`
 	Duration        int                `bson:"duration" json:"duration"`
 	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	AudioTracks     []AudioTrack       `bson:"audio_tracks,omitempty" json:"audio_tracks,omitempty"` // separate files of every audio language
+	FilesExpired    bool               `bson:"files_expired,omitempty" json:"files_expired,omitempty"` // the files were removed from disk, only the file IDs are left
+	Metadata        *VideoMetadata     `bson:"metadata,omitempty" json:"metadata,omitempty"` // the video's ID, title and uploader, nil when unknown
+
+	// Telegram file IDs of the sent files, used to resend without re-uploading
+	VideoFileID        string `bson:"video_file_id,omitempty" json:"video_file_id,omitempty"`
+	VideoWithSubFileID string `bson:"video_with_sub_file_id,omitempty" json:"video_with_sub_file_id,omitempty"`
+	AudioFileID        string `bson:"audio_file_id,omitempty" json:"audio_file_id,omitempty"`
+	SubtitleFileID     string `bson:"subtitle_file_id,omitempty" json:"subtitle_file_id,omitempty"`
+	ThumbnailFileID    string `bson:"thumbnail_file_id,omitempty" json:"thumbnail_file_id,omitempty"`
+}
+
+// VideoMetadata describes a downloaded video as its site reports it
+type VideoMetadata struct {
+	ID       string `bson:"id,omitempty" json:"id,omitempty"`
+	Title    string `bson:"title,omitempty" json:"title,omitempty"`
+	Uploader string `bson:"uploader,omitempty" json:"uploader,omitempty"`
+}
+
+// AudioTrack is an audio track of a download in one language
+type AudioTrack struct {
+	Language string `bson:"language" json:"language"`
+	Path     string `bson:"path" json:"path"`
+}
+
+// HasFileIDs reports whether the result can be resent from Telegram's servers
+func (r *DownloadResult) HasFileIDs() bool {
+	return r.VideoFileID != "" || r.VideoWithSubFileID != "" || r.AudioFileID != ""
 }
 
 // SupportedLanguage represents a supported language for the bot interface and captions
@@ -78,6 +159,7 @@ type SupportedLanguage struct {
 	Code        string `bson:"code" json:"code"`
 	Name        string `bson:"name" json:"name"`
 	NativeName  string `bson:"native_name" json:"native_name"`
+	Flag        string `bson:"flag" json:"flag"` // shown next to the native name on the language buttons
 	IsAvailable bool   `bson:"is_available" json:"is_available"`
 }
 
@@ -88,24 +170,49 @@ func GetSupportedLanguages() []SupportedLanguage {
 			Code:        "ar",
 			Name:        "Arabic",
 			NativeName:  "العربية",
+			Flag:        "🇸🇦",
 			IsAvailable: true,
 		},
 		{
 			Code:        "en",
 			Name:        "English",
 			NativeName:  "English",
+			Flag:        "🇬🇧",
 			IsAvailable: true,
 		},
 		{
 			Code:        "de",
 			Name:        "German",
 			NativeName:  "Deutsch",
+			Flag:        "🇩🇪",
 			IsAvailable: true,
 		},
 		{
 			Code:        "fr",
 			Name:        "French",
 			NativeName:  "Français",
+			Flag:        "🇫🇷",
+			IsAvailable: true,
+		},
+		{
+			Code:        "es",
+			Name:        "Spanish",
+			NativeName:  "Español",
+			Flag:        "🇪🇸",
+			IsAvailable: true,
+		},
+		{
+			Code:        "tr",
+			Name:        "Turkish",
+			NativeName:  "Türkçe",
+			Flag:        "🇹🇷",
+			IsAvailable: true,
+		},
+		{
+			Code:        "ru",
+			Name:        "Russian",
+			NativeName:  "Русский",
+			Flag:        "🇷🇺",
 			IsAvailable: true,
 		},
 	}
@@ -137,6 +244,7 @@ type ErrorLog struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	ChatID    int64              `bson:"chat_id,omitempty" json:"chat_id,omitempty"`
 	RequestID primitive.ObjectID `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	CorrelationID string         `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
 	Level     string             `bson:"level" json:"level"`
 	Message   string             `bson:"message" json:"message"`
 	Error     string             `bson:"error" json:"error"`
@@ -166,3 +274,28 @@ func (e *ErrorLog) WithRequestID(requestID primitive.ObjectID) *ErrorLog {
 	e.RequestID = requestID
 	return e
 }
+
+// WithCorrelationID adds the correlation ID of a download request to the error log
+func (e *ErrorLog) WithCorrelationID(correlationID string) *ErrorLog {
+	e.CorrelationID = correlationID
+	return e
+}
+
+// AuditLog records an action taken by an admin, audit logs are never updated or deleted
+type AuditLog struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AdminID   int64              `bson:"admin_id" json:"admin_id"` // chat ID of the admin who took the action
+	Action    string             `bson:"action" json:"action"`
+	Target    string             `bson:"target,omitempty" json:"target,omitempty"` // what the action applied to, e.g. a request ID or chat ID
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// NewAuditLog creates a new audit log entry
+func NewAuditLog(adminID int64, action, target string) *AuditLog {
+	return &AuditLog{
+		AdminID:   adminID,
+		Action:    action,
+		Target:    target,
+		CreatedAt: time.Now(),
+	}
+}
@@ -2,24 +2,105 @@ package utils
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os/exec"
 	"strings"
+	"time"
 )
 
-// RunCommand executes a shell command and returns the output
+// commandWaitDelay is how long a cancelled command's output is still read after its process group was killed
+const commandWaitDelay = 5 * time.Second
+
+// RunCommand executes a shell command and returns the output, or the error output when it fails.
+//
+// Deprecated: the command line is split by SplitCommand, use RunCommandContext which takes the arguments separately
+// and can be cancelled.
 func RunCommand(command string) (string, error) {
-	parts := strings.Split(command, " ")
-	cmd := exec.Command(parts[0], parts[1:]...)
-	
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
-	
-	err := cmd.Run()
+	parts, err := SplitCommand(command)
 	if err != nil {
-		return stderr.String(), err
+		return "", err
+	}
+
+	stdout, stderr, err := RunCommandContext(context.Background(), parts[0], parts[1:]...)
+	if err != nil {
+		return stderr, err
+	}
+	return stdout, nil
+}
+
+// RunCommandContext executes a command and returns its output and error output.
+// When the context is cancelled or times out, the command and the processes it started are killed.
+func RunCommandContext(ctx context.Context, command string, args ...string) (stdout string, stderr string, err error) {
+	cmd := exec.CommandContext(ctx, command, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	cmd.WaitDelay = commandWaitDelay
+
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	err = cmd.Run()
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		err = fmt.Errorf("%s: %w", command, ctxErr)
+	}
+	return outBuf.String(), errBuf.String(), err
+}
+
+// SplitCommand splits a command line into arguments at unquoted whitespace.
+// Single quotes keep their content as is, double quotes and backslashes escape as in a POSIX shell.
+func SplitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range command {
+		switch {
+		case escaped:
+			if quote == '"' && r != '"' && r != '\\' && r != '$' && r != '`' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if escaped {
+		return nil, errors.New("command ends with an unfinished escape")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("command has an unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
 	}
-	
-	return out.String(), nil
+	return args, nil
 }
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{"yt-dlp --version", []string{"yt-dlp", "--version"}, false},
+		{"  ffmpeg \t -i  in.mp4\nout.mp4 ", []string{"ffmpeg", "-i", "in.mp4", "out.mp4"}, false},
+		{`ffmpeg -i "my video.mp4"`, []string{"ffmpeg", "-i", "my video.mp4"}, false},
+		{`echo 'single $HOME \n'`, []string{"echo", `single $HOME \n`}, false},
+		{`echo "a \"b\" \$c \d"`, []string{"echo", `a "b" $c \d`}, false},
+		{`echo my\ file`, []string{"echo", "my file"}, false},
+		{`echo "" ''`, []string{"echo", "", ""}, false},
+		{`echo a"b c"d`, []string{"echo", "ab cd"}, false},
+		{`echo "unterminated`, nil, true},
+		{`echo 'unterminated`, nil, true},
+		{`echo trailing\`, nil, true},
+		{"", nil, true},
+		{"   ", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := SplitCommand(tt.command)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SplitCommand(%q) error = %v, wantErr %v", tt.command, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitCommand(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
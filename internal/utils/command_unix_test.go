@@ -0,0 +1,35 @@
+//go:build unix
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunCommandContextOutput(t *testing.T) {
+	stdout, stderr, err := RunCommandContext(context.Background(), "sh", "-c", "echo out; echo err >&2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != "out\n" || stderr != "err\n" {
+		t.Errorf("RunCommandContext() = %q, %q, want %q, %q", stdout, stderr, "out\n", "err\n")
+	}
+}
+
+func TestRunCommandContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The child holds the output open, it has to be killed with its parent for the command to return
+	start := time.Now()
+	_, _, err := RunCommandContext(ctx, "sh", "-c", "sleep 30 & sleep 30")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunCommandContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed >= commandWaitDelay {
+		t.Errorf("returned after %s, the processes weren't killed", elapsed)
+	}
+}
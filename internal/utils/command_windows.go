@@ -0,0 +1,16 @@
+//go:build windows
+
+package utils
+
+import "os/exec"
+
+// setProcessGroup does nothing on Windows, processes have no groups to kill
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills a command, the processes it started keep running on Windows
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
@@ -1,20 +1,30 @@
 package utils
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// versionPattern matches the dotted version number in the version output of yt-dlp, ffmpeg and aria2c,
+// e.g. "2023.10.13", "ffmpeg version n6.1-..." or "aria2 version 1.36.0"
+var versionPattern = regexp.MustCompile(`(\d+(?:\.\d+)+)`)
+
 // DependencyChecker checks required external dependencies
 type DependencyChecker struct {
-	dependencies    map[string][]string // map dep -> version check command args
-	DependencyPaths map[string]string   // Exported: Changed to uppercase 'D'
+	dependencies       map[string][]string // map dep -> version check command args
+	DependencyPaths    map[string]string   // Exported: Changed to uppercase 'D'
+	DependencyVersions map[string]string   // versions parsed from the version command, empty when unknown
+	minVersions        map[string]string   // oldest version accepted per dependency
 }
 
 // NewDependencyChecker creates a new dependency checker with commands to check dependencies
@@ -26,10 +36,22 @@ func NewDependencyChecker() *DependencyChecker {
 			"ffmpeg":  {"ffmpeg", "-version"},
 			"ffprobe": {"ffprobe", "-version"}, // Added ffprobe as a dependency to check
 		},
-		DependencyPaths: make(map[string]string), // Initialize the new map, use exported name
+		DependencyPaths:    make(map[string]string), // Initialize the new map, use exported name
+		DependencyVersions: make(map[string]string),
+		minVersions:        make(map[string]string),
 	}
 }
 
+// WithMinVersions sets the oldest accepted version per dependency, dependencies without one accept any version
+func (dc *DependencyChecker) WithMinVersions(minVersions map[string]string) *DependencyChecker {
+	for dep, version := range minVersions {
+		if version != "" {
+			dc.minVersions[dep] = version
+		}
+	}
+	return dc
+}
+
 // CheckDependencies checks if dependencies are installed by verifying binary exists and command runs
 // It now also populates the DependencyPaths map.
 func (dc *DependencyChecker) CheckDependencies() (map[string]bool, error) {
@@ -46,13 +68,23 @@ func (dc *DependencyChecker) CheckDependencies() (map[string]bool, error) {
 	}
 
 	for dep, args := range dc.dependencies {
-		ok, path, err := dc.checkDependency(args) // Modified to return path
+		ok, path, version, err := dc.checkDependency(args) // Modified to return path
+		if ok {
+			dc.DependencyVersions[dep] = version
+			if err = dc.checkMinVersion(dep, version); err != nil {
+				ok = false
+			}
+		}
 		results[dep] = ok
 		if ok {
 			dc.DependencyPaths[dep] = path // Store the found path, use exported name
 		}
 		if err != nil || !ok {
-			missing = append(missing, dep)
+			if errors.Is(err, ErrVersionTooOld) {
+				missing = append(missing, err.Error())
+			} else {
+				missing = append(missing, dep)
+			}
 		}
 	}
 
@@ -69,11 +101,63 @@ func (dc *DependencyChecker) GetDependencyPaths() map[string]string {
 	return dc.DependencyPaths
 }
 
+// GetDependencyVersions returns the map of parsed dependency versions.
+func (dc *DependencyChecker) GetDependencyVersions() map[string]string {
+	return dc.DependencyVersions
+}
+
+// ErrVersionTooOld is returned when an installed dependency is older than its configured minimum
+var ErrVersionTooOld = errors.New("version too old")
+
+// checkMinVersion checks a dependency's version against its configured minimum.
+// Versions that can't be parsed, such as ffmpeg git builds, are accepted.
+func (dc *DependencyChecker) checkMinVersion(dep string, version string) error {
+	minVersion := dc.minVersions[dep]
+	if minVersion == "" || version == "" {
+		return nil
+	}
+
+	if compareVersions(version, minVersion) < 0 {
+		return fmt.Errorf("%w: %s %s too old, need >= %s", ErrVersionTooOld, dep, version, minVersion)
+	}
+	return nil
+}
+
+// parseVersion extracts the dotted version number from the output of a version command
+func parseVersion(output string) string {
+	// Only look at the first line, ffmpeg lists the versions of its libraries below it
+	firstLine := strings.SplitN(strings.TrimSpace(output), "\n", 2)[0]
+	return versionPattern.FindString(firstLine)
+}
+
+// compareVersions compares dotted version numbers component by component, returning -1, 0 or 1.
+// Missing components count as 0, so "6.1" equals "6.1.0".
+func compareVersions(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aNum, bNum int
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+		if aNum != bNum {
+			if aNum < bNum {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
 // checkDependency runs the version command to verify dependency presence with timeout context
-// It now returns the absolute path of the found binary.
-func (dc *DependencyChecker) checkDependency(args []string) (bool, string, error) { // Modified return signature
+// It now returns the absolute path of the found binary and its parsed version.
+func (dc *DependencyChecker) checkDependency(args []string) (bool, string, string, error) { // Modified return signature
 	if len(args) == 0 {
-		return false, "", errors.New("no command specified")
+		return false, "", "", errors.New("no command specified")
 	}
 
 	binary := args[0]
@@ -108,7 +192,7 @@ func (dc *DependencyChecker) checkDependency(args []string) (bool, string, error
 	if foundPath == "" {
 		path, err := exec.LookPath(binary)
 		if err != nil {
-			return false, "", fmt.Errorf("binary %s not found in PATH", binary)
+			return false, "", "", fmt.Errorf("binary %s not found in PATH", binary)
 		}
 		foundPath = path
 	}
@@ -122,10 +206,10 @@ func (dc *DependencyChecker) checkDependency(args []string) (bool, string, error
 	if err != nil {
 		// On Windows, if the command runs but the output indicates an error (e.g., specific exit codes),
 		// we might need more nuanced checks. For now, rely on `CombinedOutput` error.
-		return false, "", fmt.Errorf("command failed: %w, output: %s", err, string(output))
+		return false, "", "", fmt.Errorf("command failed: %w, output: %s", err, string(output))
 	}
 
-	return true, foundPath, nil // Return the found path
+	return true, foundPath, parseVersion(string(output)), nil // Return the found path
 }
 
 // InstallDependencies installs missing dependencies based on OS/distro
@@ -172,23 +256,36 @@ func (dc *DependencyChecker) InstallDependencies() error {
 			return nil
 		}
 
-		distro, err := detectLinuxDistro()
+		distros, err := detectLinuxDistro()
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Detected Linux distro: %s\n", distro)
+		fmt.Printf("Detected Linux distro: %s\n", strings.Join(distros, ", "))
+
+		// Use the package manager of the distro, or of the distro it is derived from
+		manager := ""
+		for _, distro := range distros {
+			if manager = linuxPackageManager(distro); manager != "" {
+				break
+			}
+		}
 
-		switch distro {
-		case "debian", "ubuntu","kali":
+		switch manager {
+		case "apt":
 			if err := dc.installOnApt(missing); err != nil {
 				return err
 			}
-		case "centos", "fedora", "rhel":
+		case "yum":
 			if err := dc.installOnYum(missing); err != nil {
 				return err
 			}
+		case "apk":
+			// Alpine and Arch package yt-dlp themselves and don't allow system-wide pip installs
+			return dc.installOnApk(missing)
+		case "pacman":
+			return dc.installOnPacman(missing)
 		default:
-			return fmt.Errorf("unsupported Linux distro: %s", distro)
+			return fmt.Errorf("unsupported Linux distro: %s", distros[0])
 		}
 
 		if contains(missing, "yt-dlp") {
@@ -220,9 +317,10 @@ func (dc *DependencyChecker) InstallDependencies() error {
 
 // installOnApt installs packages via apt for Debian/Ubuntu
 func (dc *DependencyChecker) installOnApt(deps []string) error {
+	// apt-get has a stable interface for scripts, apt warns when it isn't used interactively
 	fmt.Println("Updating apt package lists...")
-	if err := runCommand("apt", []string{"update", "-y"}); err != nil {
-		return fmt.Errorf("apt update failed: %w", err)
+	if err := runCommand("apt-get", []string{"update", "-y"}); err != nil {
+		return fmt.Errorf("apt-get update failed: %w", err)
 	}
 
 	for _, dep := range deps {
@@ -233,8 +331,8 @@ func (dc *DependencyChecker) installOnApt(deps []string) error {
 		if pkgName == "" { // Handle cases where mapDepToPkg might return empty for non-system deps
 			continue
 		}
-		fmt.Printf("Installing %s via apt...\n", pkgName)
-		if err := runCommand("apt ", []string{"install", "-y", pkgName}); err != nil {
+		fmt.Printf("Installing %s via apt-get...\n", pkgName)
+		if err := runCommand("apt-get", []string{"install", "-y", pkgName}); err != nil {
 			return fmt.Errorf("failed to install %s: %w", pkgName, err)
 		}
 	}
@@ -266,6 +364,48 @@ func (dc *DependencyChecker) installOnYum(deps []string) error {
 	return nil
 }
 
+// installOnApk installs packages via apk for Alpine
+func (dc *DependencyChecker) installOnApk(deps []string) error {
+	fmt.Println("Updating apk package index...")
+	if err := runCommand("apk", []string{"update"}); err != nil {
+		return fmt.Errorf("apk update failed: %w", err)
+	}
+
+	for _, dep := range deps {
+		pkgName := mapDepToPkg(dep)
+		if pkgName == "" {
+			continue
+		}
+		fmt.Printf("Installing %s via apk...\n", pkgName)
+		if err := runCommand("apk", []string{"add", "--no-cache", pkgName}); err != nil {
+			return fmt.Errorf("failed to install %s: %w", pkgName, err)
+		}
+	}
+
+	return nil
+}
+
+// installOnPacman installs packages via pacman for Arch and Manjaro. The system is upgraded along with the
+// database sync, as Arch doesn't support installing packages of a newer database on an older system.
+func (dc *DependencyChecker) installOnPacman(deps []string) error {
+	var pkgNames []string
+	for _, dep := range deps {
+		if pkgName := mapDepToPkg(dep); pkgName != "" {
+			pkgNames = append(pkgNames, pkgName)
+		}
+	}
+	if len(pkgNames) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Installing %s via pacman...\n", strings.Join(pkgNames, ", "))
+	if err := runCommand("pacman", append([]string{"-Syu", "--noconfirm", "--needed"}, pkgNames...)); err != nil {
+		return fmt.Errorf("failed to install %s: %w", strings.Join(pkgNames, ", "), err)
+	}
+
+	return nil
+}
+
 // installOnBrew installs packages via brew for macOS
 func (dc *DependencyChecker) installOnBrew(deps []string) error {
 	for _, dep := range deps {
@@ -310,7 +450,7 @@ func (dc *DependencyChecker) installOnChocolatey(deps []string) error {
 	// First, check if Chocolatey itself is installed.
 	// We'll use a specific check for choco that doesn't rely on the main checkDependency for simplicity here,
 	// as it's a prerequisite.
-	_, chocoPath, err := dc.checkDependency([]string{"choco", "--version"})
+	_, chocoPath, _, err := dc.checkDependency([]string{"choco", "--version"})
 	if err != nil {
 		fmt.Println("Chocolatey (choco) not found. Please install Chocolatey first from https://chocolatey.org/install.")
 		return errors.New("Chocolatey is not installed or not in PATH")
@@ -337,6 +477,89 @@ func (dc *DependencyChecker) installOnChocolatey(deps []string) error {
 	return nil
 }
 
+// UpdateYtDlp updates yt-dlp to its latest release and returns the versions before and after the update.
+// It tries yt-dlp's self-update first and falls back to pip for installations that can't update themselves,
+// with the Python of the yt-dlp at the configured path so the update lands where the bot runs it from.
+func (dc *DependencyChecker) UpdateYtDlp(ctx context.Context) (string, string, error) {
+	path := dc.DependencyPaths["yt-dlp"]
+	if path == "" {
+		return "", "", errors.New("yt-dlp executable path not found")
+	}
+
+	before := ytDlpVersion(ctx, path)
+
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	output, err := exec.CommandContext(updateCtx, path, "-U").CombinedOutput()
+	if err != nil {
+		// Installations from pip or a package manager refuse to update themselves
+		if pipErr := upgradeYtDlpWithPip(updateCtx, path); pipErr != nil {
+			return before, before, fmt.Errorf("yt-dlp update failed: %v, output: %s, pip: %w", err, strings.TrimSpace(string(output)), pipErr)
+		}
+	}
+
+	return before, ytDlpVersion(ctx, path), nil
+}
+
+// upgradeYtDlpWithPip upgrades the yt-dlp script at path with the pip of the Python running it. Any other pip
+// would install yt-dlp somewhere else and leave the one at path as it was.
+func upgradeYtDlpWithPip(ctx context.Context, path string) error {
+	python, err := scriptInterpreter(path)
+	if err != nil {
+		return err
+	}
+
+	output, err := exec.CommandContext(ctx, python, "-m", "pip", "install", "--upgrade", "yt-dlp").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("command pip failed: %w, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// scriptInterpreter returns the Python interpreter named by the shebang of the script at path, an error when the
+// file isn't a Python script, e.g. a standalone yt-dlp binary
+func scriptInterpreter(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if !strings.HasPrefix(line, "#!") || len(fields) == 0 {
+		return "", fmt.Errorf("%s is not a script", path)
+	}
+
+	// "#!/usr/bin/env python3" names the interpreter to look up in PATH
+	interpreter := fields[0]
+	if filepath.Base(interpreter) == "env" && len(fields) > 1 {
+		if interpreter, err = exec.LookPath(fields[1]); err != nil {
+			return "", err
+		}
+	}
+	if !strings.HasPrefix(filepath.Base(interpreter), "python") {
+		return "", fmt.Errorf("%s is not a Python script", path)
+	}
+	return interpreter, nil
+}
+
+// ytDlpVersion returns the version reported by a yt-dlp binary, or "unknown"
+func ytDlpVersion(ctx context.Context, path string) string {
+	versionCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(versionCtx, path, "--version").Output()
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(output))
+}
+
 // installYtDlpWithPip installs yt-dlp using python3 -m pip
 func (dc *DependencyChecker) installYtDlpWithPip() error {
 	fmt.Println("Installing yt-dlp with pip...")
@@ -360,7 +583,7 @@ func (dc *DependencyChecker) installYtDlpWithPip() error {
 	return nil
 }
 
-// mapDepToPkg maps dependencies to OS package names (for apt, yum, brew)
+// mapDepToPkg maps dependencies to OS package names (for apt, yum, brew, apk, pacman)
 func mapDepToPkg(dep string) string {
 	switch dep {
 	case "aria2c":
@@ -402,23 +625,46 @@ func mapDepToPkgWindows(dep string) string {
 	}
 }
 
-// detectLinuxDistro reads /etc/os-release to detect Linux distro ID
-func detectLinuxDistro() (string, error) {
+// detectLinuxDistro reads /etc/os-release to detect the Linux distro ID,
+// followed by the IDs of the distros it is derived from (ID_LIKE)
+func detectLinuxDistro() ([]string, error) {
 	data, err := os.ReadFile("/etc/os-release")
 	if err != nil {
-		return "", fmt.Errorf("failed to read /etc/os-release: %w", err)
+		return nil, fmt.Errorf("failed to read /etc/os-release: %w", err)
 	}
 
+	var id string
+	var idLike []string
 	lines := strings.Split(string(data), "\n")
 	for _, line := range lines {
 		if strings.HasPrefix(line, "ID=") {
-			id := strings.TrimPrefix(line, "ID=")
-			id = strings.Trim(id, `"`)
-			return strings.ToLower(id), nil
+			id = strings.ToLower(strings.Trim(strings.TrimPrefix(line, "ID="), `"`))
+		}
+		if strings.HasPrefix(line, "ID_LIKE=") {
+			idLike = strings.Fields(strings.ToLower(strings.Trim(strings.TrimPrefix(line, "ID_LIKE="), `"`)))
 		}
 	}
 
-	return "", errors.New("linux distro ID not found in /etc/os-release")
+	if id == "" {
+		return nil, errors.New("linux distro ID not found in /etc/os-release")
+	}
+	return append([]string{id}, idLike...), nil
+}
+
+// linuxPackageManager returns the package manager of a Linux distro ID, or "" if it isn't supported
+func linuxPackageManager(distro string) string {
+	switch distro {
+	case "debian", "ubuntu", "kali":
+		return "apt"
+	case "centos", "fedora", "rhel":
+		return "yum"
+	case "alpine":
+		return "apk"
+	case "arch", "manjaro":
+		return "pacman"
+	default:
+		return ""
+	}
 }
 
 // isTermux checks if the environment is Termux by env var PREFIX
@@ -427,8 +673,11 @@ func isTermux() bool {
 	return strings.Contains(prefix, "com.termux")
 }
 
-// runCommand executes a system command with 1 minute timeout and streams output
-func runCommand(command string, args []string) error {
+// runCommand runs the commands of the package manager installers, tests replace it to see what they would run
+var runCommand = runSystemCommand
+
+// runSystemCommand executes a system command with 1 minute timeout and streams output
+func runSystemCommand(command string, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
 
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallersRunCommandsByName(t *testing.T) {
+	var commands []string
+	runCommand = func(command string, args []string) error {
+		commands = append(commands, command)
+		return nil
+	}
+	t.Cleanup(func() { runCommand = runSystemCommand })
+
+	dc := NewDependencyChecker()
+	deps := []string{"yt-dlp", "aria2c", "ffmpeg"}
+	installers := map[string]func([]string) error{
+		"apt-get": dc.installOnApt,
+		"yum":     dc.installOnYum,
+		"apk":     dc.installOnApk,
+		"pacman":  dc.installOnPacman,
+		"brew":    dc.installOnBrew,
+		"pkg":     dc.installOnPkg,
+	}
+	for want, install := range installers {
+		commands = nil
+		if err := install(deps); err != nil {
+			t.Fatalf("installing with %s: %v", want, err)
+		}
+		if len(commands) == 0 {
+			t.Errorf("installing with %s ran no command", want)
+		}
+		for _, command := range commands {
+			if command != strings.TrimSpace(command) || command != want {
+				t.Errorf("installing with %s ran %q", want, command)
+			}
+		}
+	}
+}
+
+func TestInstallOnPacmanUpgradesWithTheSync(t *testing.T) {
+	var commands [][]string
+	runCommand = func(command string, args []string) error {
+		commands = append(commands, append([]string{command}, args...))
+		return nil
+	}
+	t.Cleanup(func() { runCommand = runSystemCommand })
+
+	if err := NewDependencyChecker().installOnPacman([]string{"yt-dlp", "aria2c", "ffmpeg"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A sync without the upgrade would be a partial upgrade, which Arch doesn't support
+	want := "pacman -Syu --noconfirm --needed yt-dlp aria2 ffmpeg"
+	if len(commands) != 1 || strings.Join(commands[0], " ") != want {
+		t.Errorf("ran %q, want only %q", commands, want)
+	}
+}
+
+func TestScriptInterpreter(t *testing.T) {
+	python3, err := exec.LookPath("python3")
+	if err != nil {
+		python3 = ""
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{"python shebang", "#!/usr/local/bin/python3.11\nimport yt_dlp\n", "/usr/local/bin/python3.11", false},
+		{"python shebang with flags", "#!/usr/bin/python3 -I\n", "/usr/bin/python3", false},
+		{"env shebang", "#!/usr/bin/env python3\n", python3, python3 == ""},
+		{"shell script", "#!/bin/sh\nexec yt-dlp \"$@\"\n", "", true},
+		{"standalone binary", "\x7fELF\x02\x01\x01\x00", "", true},
+		{"empty file", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "yt-dlp")
+			if err := os.WriteFile(path, []byte(tt.content), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := scriptInterpreter(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("scriptInterpreter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("scriptInterpreter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -31,9 +31,9 @@ const (
 
 // EnhancedLogger provides advanced logging functionality
 type EnhancedLogger struct {
-	logger *zap.SugaredLogger
-	config *EnhancedLoggerConfig
-	
+	logger    *zap.SugaredLogger
+	config    *EnhancedLoggerConfig
+	forwarder *LogForwarder // also sends errors elsewhere, nil when not configured
 }
 
 // EnhancedLoggerConfig holds configuration for the enhanced logger
@@ -193,15 +193,29 @@ func (l *EnhancedLogger) Error(format string, args ...interface{}) {
 	if l.config.Enabled {
 		l.logger.Errorf(format, args...)
 	}
+	if l.forwarder != nil {
+		l.forwarder.Forward(LogLevelError, fmt.Sprintf(format, args...))
+	}
 }
 
 // Fatal logs a fatal message and exits
 func (l *EnhancedLogger) Fatal(format string, args ...interface{}) {
+	if l.forwarder != nil {
+		// Send it right away, the process exits before the next batch
+		l.forwarder.Forward(LogLevelFatal, fmt.Sprintf(format, args...))
+		l.forwarder.flush()
+	}
 	if l.config.Enabled {
 		l.logger.Fatalf(format, args...)
 	}
 }
 
+// WithForwarder also sends the errors of the logger and of the loggers derived from it with a forwarder
+func (l *EnhancedLogger) WithForwarder(forwarder *LogForwarder) *EnhancedLogger {
+	l.forwarder = forwarder
+	return l
+}
+
 // With returns a logger with the specified fields added to the context
 func (l *EnhancedLogger) With(fields map[string]interface{}) *EnhancedLogger {
 	if !l.config.Enabled {
@@ -214,8 +228,9 @@ func (l *EnhancedLogger) With(fields map[string]interface{}) *EnhancedLogger {
 	}
 
 	return &EnhancedLogger{
-		logger: l.logger.With(args...),
-		config: l.config,
+		logger:    l.logger.With(args...),
+		config:    l.config,
+		forwarder: l.forwarder,
 	}
 }
 
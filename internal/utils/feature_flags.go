@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrNoFeatureOverrides is returned when turning a feature on or off at runtime without Redis
+var ErrNoFeatureOverrides = errors.New("feature overrides need Redis")
+
+// FeatureFlags reports whether optional features are turned on. Each feature is on or off as configured, unless
+// an admin overrode it at runtime in a Redis hash shared by all instances.
+type FeatureFlags struct {
+	key         string
+	redisClient *redis.Client
+	defaults    func(name string) bool
+	logger      *EnhancedLogger
+	cache       *redisCache[map[string]bool]
+}
+
+// NewFeatureFlags creates feature flags that fall back to defaults for the features without an override in the
+// given Redis hash. Without Redis or a key, the defaults always apply.
+func NewFeatureFlags(key string, cacheTTL int, redisClient *redis.Client, defaults func(name string) bool, logger *EnhancedLogger) *FeatureFlags {
+	f := &FeatureFlags{
+		key:         key,
+		redisClient: redisClient,
+		defaults:    defaults,
+		logger:      logger,
+	}
+	f.cache = newRedisCache(time.Duration(cacheTTL)*time.Second, f.read)
+	return f
+}
+
+// Enabled reports whether a feature is turned on, reading the overrides from Redis at most once per cache TTL
+func (f *FeatureFlags) Enabled(ctx context.Context, name string) bool {
+	if enabled, ok := f.Overrides(ctx)[name]; ok {
+		return enabled
+	}
+	return f.defaults(name)
+}
+
+// Overrides returns the features turned on or off at runtime
+func (f *FeatureFlags) Overrides(ctx context.Context) map[string]bool {
+	if f.redisClient == nil || f.key == "" {
+		return nil
+	}
+
+	overrides, err := f.cache.get(ctx)
+	if err != nil {
+		f.logger.Error("Failed to read feature overrides %s: %v", f.key, err)
+	}
+	return overrides
+}
+
+// read reads the overrides from the Redis hash
+func (f *FeatureFlags) read(ctx context.Context) (map[string]bool, error) {
+	values, err := f.redisClient.HGetAll(ctx, f.key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]bool, len(values))
+	for name, value := range values {
+		overrides[name] = isFlagSet(value)
+	}
+	return overrides, nil
+}
+
+// Set turns a feature on or off on all instances until it is reset. Other instances see the change once their
+// cache TTL is over.
+func (f *FeatureFlags) Set(ctx context.Context, name string, enabled bool) error {
+	if f.redisClient == nil || f.key == "" {
+		return ErrNoFeatureOverrides
+	}
+	if err := f.redisClient.HSet(ctx, f.key, name, strconv.FormatBool(enabled)).Err(); err != nil {
+		return err
+	}
+	f.cache.invalidate()
+	return nil
+}
+
+// Reset removes the override of a feature, so it is on or off as configured again
+func (f *FeatureFlags) Reset(ctx context.Context, name string) error {
+	if f.redisClient == nil || f.key == "" {
+		return ErrNoFeatureOverrides
+	}
+	if err := f.redisClient.HDel(ctx, f.key, name).Err(); err != nil {
+		return err
+	}
+	f.cache.invalidate()
+	return nil
+}
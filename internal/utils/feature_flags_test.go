@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// countingHook counts the commands a Redis client processes
+type countingHook struct {
+	commands int32
+}
+
+func (h *countingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	atomic.AddInt32(&h.commands, 1)
+	return ctx, nil
+}
+
+func (h *countingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *countingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *countingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+// newUnreachableRedis returns a client of a Redis server that refuses connections, and counts its commands
+func newUnreachableRedis(t *testing.T) (*redis.Client, *countingHook) {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	hook := &countingHook{}
+	client.AddHook(hook)
+	return client, hook
+}
+
+func TestFeatureFlagsCacheFailedReads(t *testing.T) {
+	client, hook := newUnreachableRedis(t)
+	defaults := func(name string) bool { return name == "clips" }
+	flags := NewFeatureFlags("features", 60, client, defaults, newTestLogger(t))
+
+	for i := 0; i < 3; i++ {
+		if !flags.Enabled(context.Background(), "clips") {
+			t.Errorf("clips is off while Redis is unreachable, want its default")
+		}
+		if flags.Enabled(context.Background(), "zip") {
+			t.Errorf("zip is on while Redis is unreachable, want its default")
+		}
+	}
+	if got := atomic.LoadInt32(&hook.commands); got != 1 {
+		t.Errorf("read Redis %d times within the cache TTL, want 1", got)
+	}
+}
+
+func TestFeatureFlagsWithoutRedis(t *testing.T) {
+	flags := NewFeatureFlags("features", 60, nil, func(name string) bool { return true }, newTestLogger(t))
+
+	if !flags.Enabled(context.Background(), "clips") {
+		t.Errorf("clips is off, want its default")
+	}
+	if err := flags.Set(context.Background(), "clips", false); err != ErrNoFeatureOverrides {
+		t.Errorf("Set error = %v, want %v", err, ErrNoFeatureOverrides)
+	}
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// defaultFilename replaces names with nothing left after sanitizing
+	defaultFilename = "file"
+	// maxExtensionLength is the longest suffix kept as an extension when truncating, e.g. ".description"
+	maxExtensionLength = 12
+)
+
+// reservedFilenames can't be used as file names on Windows, whatever their extension
+var reservedFilenames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFilename makes a name, such as a video title, safe to send a file with and to save it under.
+// Path separators, characters reserved on Windows, control and invisible formatting characters and emoji become
+// spaces, runs of spaces are collapsed, and leading and trailing spaces and dots are removed. Names longer than
+// maxLen characters are shortened keeping their extension, 0 keeps any length.
+func SanitizeFilename(name string, maxLen int) string {
+	var b strings.Builder
+	space := false
+	for _, r := range name {
+		if isUnsafeFilenameRune(r) || unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	clean := strings.TrimSpace(b.String())
+
+	base, ext := splitExtension(clean)
+	base = trimFilename(base)
+	if maxLen > 0 && utf8.RuneCountInString(base+ext) > maxLen {
+		keep := maxLen - utf8.RuneCountInString(ext)
+		if keep < 1 {
+			// The extension alone is too long, it is cut like the rest of the name
+			base, ext, keep = trimFilename(clean), "", maxLen
+		}
+		base = trimFilename(string([]rune(base)[:keep]))
+	}
+
+	if base == "" {
+		base = defaultFilename
+	}
+	if reservedFilenames[strings.ToUpper(base)] {
+		base += "_"
+	}
+	return base + ext
+}
+
+// isUnsafeFilenameRune reports whether a character is rejected by some file systems or clients in file names
+func isUnsafeFilenameRune(r rune) bool {
+	switch r {
+	case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+		return true
+	}
+	return r == utf8.RuneError || unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || isEmoji(r)
+}
+
+// trimFilename removes the spaces and dots Windows drops from the ends of names, and leading dots that hide files
+func trimFilename(name string) string {
+	return strings.Trim(name, " .")
+}
+
+// splitExtension splits a file name into its base and its extension, e.g. "My video" and ".mp4".
+// Suffixes with spaces or longer than maxExtensionLength aren't taken as extensions.
+func splitExtension(name string) (string, string) {
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		return name, ""
+	}
+	ext := name[dot:]
+	if len(ext) < 2 || len(ext) > maxExtensionLength || strings.ContainsRune(ext, ' ') {
+		return name, ""
+	}
+	return name[:dot], ext
+}
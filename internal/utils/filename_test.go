@@ -0,0 +1,37 @@
+package utils
+
+import "testing"
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		maxLen int
+		want   string
+	}{
+		{"safe name", "My video.mp4", 0, "My video.mp4"},
+		{"reserved characters", `a/b\c:d*e?f"g<h>i|j.mp4`, 0, "a b c d e f g h i j.mp4"},
+		{"runs of spaces", "  lots   of   spaces  .mp4", 0, "lots of spaces.mp4"},
+		{"control characters", "tab\tnew\nline", 0, "tab new line"},
+		{"invisible formatting", "zero\u200bwidth", 0, "zero width"},
+		{"emoji", "emoji 🎬 title.mp4", 0, "emoji title.mp4"},
+		{"leading dots", "...hidden.mp4", 0, "hidden.mp4"},
+		{"empty", "", 0, "file"},
+		{"nothing left", "???", 0, "file"},
+		{"only an extension", ".mp4", 0, "file.mp4"},
+		{"reserved on Windows", "CON.mp4", 0, "CON_.mp4"},
+		{"reserved in lower case", "nul", 0, "nul_"},
+		{"shortened keeping the extension", "abcdefghij.mp4", 8, "abcd.mp4"},
+		{"shortened by characters", "日本語のタイトル.mp4", 6, "日本.mp4"},
+		{"shortened before a space", "ab cd.mp4", 7, "ab.mp4"},
+		{"extension longer than the limit", "abc.description", 5, "abc.d"},
+		{"within the limit", "short.mp4", 20, "short.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFilename(tt.input, tt.maxLen); got != tt.want {
+				t.Errorf("SanitizeFilename(%q, %d) = %q, want %q", tt.input, tt.maxLen, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateHeader checks that an HTTP header name is a valid token and that its value can't break out of the
+// header line, e.g. "Referer" and "https://example.com/"
+func ValidateHeader(name, value string) error {
+	if name == "" {
+		return fmt.Errorf("empty header name")
+	}
+	for _, r := range name {
+		if !isTokenRune(r) {
+			return fmt.Errorf("invalid character %q in header name %q", r, name)
+		}
+	}
+	if strings.ContainsAny(value, "\r\n\x00") {
+		return fmt.Errorf("header %s has a line break or NUL in its value", name)
+	}
+	return nil
+}
+
+// isTokenRune reports whether a rune may appear in an HTTP token such as a header name (RFC 9110)
+func isTokenRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// KillSwitch reports whether downloads were globally disabled through a Redis flag.
+// The flag is set externally (e.g. redis-cli SET <key> 1) and shared by all instances.
+type KillSwitch struct {
+	key         string
+	redisClient *redis.Client
+	logger      *EnhancedLogger
+	cache       *redisCache[bool]
+}
+
+// NewKillSwitch creates a new kill-switch reading the given Redis key
+func NewKillSwitch(key string, cacheTTL int, redisClient *redis.Client, logger *EnhancedLogger) *KillSwitch {
+	ks := &KillSwitch{
+		key:         key,
+		redisClient: redisClient,
+		logger:      logger,
+	}
+	ks.cache = newRedisCache(time.Duration(cacheTTL)*time.Second, ks.read)
+	ks.cache.changed = ks.logChange
+	return ks
+}
+
+// Active checks whether the kill-switch is on, reading Redis at most once per cache TTL
+func (ks *KillSwitch) Active(ctx context.Context) bool {
+	if ks.redisClient == nil || ks.key == "" {
+		return false
+	}
+
+	active, err := ks.cache.get(ctx)
+	if err != nil {
+		ks.logger.Error("Failed to read kill-switch flag %s: %v", ks.key, err)
+	}
+	return active
+}
+
+// read reads the kill-switch flag, which is off while the key doesn't exist
+func (ks *KillSwitch) read(ctx context.Context) (bool, error) {
+	value, err := ks.redisClient.Get(ctx, ks.key).Result()
+	if err != nil && err != redis.Nil {
+		return false, err
+	}
+	return isFlagSet(value), nil
+}
+
+// logChange logs when the kill-switch was turned on or off
+func (ks *KillSwitch) logChange(previous, current bool) {
+	if current == previous {
+		return
+	}
+	if current {
+		ks.logger.Warn("Kill-switch %s is on, downloads are disabled", ks.key)
+	} else {
+		ks.logger.Info("Kill-switch %s is off, downloads are enabled", ks.key)
+	}
+}
+
+// isFlagSet reports whether a Redis flag value turns the switch on
+func isFlagSet(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "false", "off", "no":
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+func TestKillSwitchCachesFailedReads(t *testing.T) {
+	client, hook := newUnreachableRedis(t)
+	ks := NewKillSwitch("kill_switch", 60, client, newTestLogger(t))
+
+	for i := 0; i < 3; i++ {
+		if ks.Active(context.Background()) {
+			t.Errorf("kill-switch is on while Redis is unreachable, want the last known state")
+		}
+	}
+	if got := atomic.LoadInt32(&hook.commands); got != 1 {
+		t.Errorf("read Redis %d times within the cache TTL, want 1", got)
+	}
+}
+
+func TestIsFlagSet(t *testing.T) {
+	for value, want := range map[string]bool{
+		"":      false,
+		"0":     false,
+		"false": false,
+		" OFF ": false,
+		"no":    false,
+		"1":     true,
+		"true":  true,
+		"on":    true,
+	} {
+		if got := isFlagSet(value); got != want {
+			t.Errorf("isFlagSet(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
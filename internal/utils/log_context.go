@@ -0,0 +1,19 @@
+package utils
+
+import "context"
+
+// loggerKey is the context key of the logger of an operation
+type loggerKey struct{}
+
+// ContextWithLogger returns a context carrying a logger, e.g. one with the correlation ID of a download as a field
+func ContextWithLogger(ctx context.Context, logger *EnhancedLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger carried by a context, or fallback if it carries none
+func LoggerFromContext(ctx context.Context, fallback *EnhancedLogger) *EnhancedLogger {
+	if logger, ok := ctx.Value(loggerKey{}).(*EnhancedLogger); ok && logger != nil {
+		return logger
+	}
+	return fallback
+}
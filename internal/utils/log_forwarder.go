@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxForwardedLines is how many log lines wait for the next batch, later ones are dropped until it is sent
+	maxForwardedLines = 100
+	// maxForwardedLength keeps a batch within the 4096 characters of a Telegram message
+	maxForwardedLength = 4000
+	// maxForwardedLineLength cuts long lines, such as errors with a command's whole output, so others fit too
+	maxForwardedLineLength = 1000
+)
+
+// LogForwarder sends error logs somewhere operators see them right away, such as a Telegram channel.
+// Lines are collected and sent in one batch per interval, so a burst of errors doesn't hit the rate limits of the
+// destination. Logging never waits for a send, and failed sends are dropped without logging them, which would
+// forward them again.
+type LogForwarder struct {
+	send     func(text string) error
+	interval time.Duration
+	mu       sync.Mutex
+	lines    []string
+	dropped  int
+}
+
+// NewLogForwarder creates a forwarder sending batches of log lines with send at most once per interval.
+// Run must be started for anything to be sent.
+func NewLogForwarder(send func(text string) error, interval time.Duration) *LogForwarder {
+	return &LogForwarder{
+		send:     send,
+		interval: interval,
+	}
+}
+
+// Forward queues a log line for the next batch
+func (f *LogForwarder) Forward(level LogLevel, message string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.lines) >= maxForwardedLines {
+		f.dropped++
+		return
+	}
+	line := fmt.Sprintf("[%s] %s %s", strings.ToUpper(string(level)), time.Now().UTC().Format("15:04:05"), message)
+	if len(line) > maxForwardedLineLength {
+		line = strings.ToValidUTF8(line[:maxForwardedLineLength], "") + "..."
+	}
+	f.lines = append(f.lines, line)
+}
+
+// Run sends the queued lines every interval until the context is done, then sends the last ones
+func (f *LogForwarder) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.flush()
+			return
+		case <-ticker.C:
+			f.flush()
+		}
+	}
+}
+
+// flush sends the queued lines as one message, leaving out the lines that don't fit
+func (f *LogForwarder) flush() {
+	f.mu.Lock()
+	lines, dropped := f.lines, f.dropped
+	f.lines, f.dropped = nil, 0
+	f.mu.Unlock()
+
+	if len(lines) == 0 && dropped == 0 {
+		return
+	}
+
+	var text strings.Builder
+	for i, line := range lines {
+		if text.Len()+len(line)+1 > maxForwardedLength {
+			dropped += len(lines) - i
+			break
+		}
+		if text.Len() > 0 {
+			text.WriteString("\n")
+		}
+		text.WriteString(line)
+	}
+	if dropped > 0 {
+		fmt.Fprintf(&text, "\n... and %d more, see the logs", dropped)
+	}
+
+	// A failed send must not be logged as an error, that would forward it again
+	_ = f.send(text.String())
+}
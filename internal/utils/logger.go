@@ -10,9 +10,10 @@ import (
 
 // Logger provides logging functionality
 type Logger struct {
-	enabled bool
-	logFile *os.File
-	logger  *log.Logger
+	enabled   bool
+	logFile   *os.File
+	logger    *log.Logger
+	forwarder *LogForwarder // also sends errors elsewhere, nil when not configured
 }
 
 // NewLogger creates a new logger instance
@@ -56,6 +57,20 @@ func (l *Logger) Error(format string, v ...interface{}) {
 	if l.enabled {
 		l.logger.Printf("[ERROR] "+format, v...)
 	}
+	if l.forwarder != nil {
+		l.forwarder.Forward(LogLevelError, fmt.Sprintf(format, v...))
+	}
+}
+
+// WithForwarder also sends the errors of the logger with a forwarder
+func (l *Logger) WithForwarder(forwarder *LogForwarder) *Logger {
+	l.forwarder = forwarder
+	return l
+}
+
+// Forwarder returns the forwarder errors are also sent with, nil when not configured
+func (l *Logger) Forwarder() *LogForwarder {
+	return l.forwarder
 }
 
 // Warn logs a warning message
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// isEmoji reports whether a rune is an emoji or one of the characters emoji are composed with
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // pictographs, emoticons, transport symbols and regional indicator flags
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // miscellaneous symbols and dingbats
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // arrows and stars such as ⬆ and ⭐
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // tags of subdivision flags
+		return true
+	case r == 0x200D, r == 0x20E3, r == 0xFE0F: // zero width joiner, keycap and emoji presentation selector
+		return true
+	}
+	return false
+}
+
+// StripEmoji removes the emoji of a text, with the space that separated them from the rest of the text
+func StripEmoji(text string) string {
+	out := make([]byte, 0, len(text))
+
+	removed := false
+	for _, r := range text {
+		if isEmoji(r) {
+			removed = true
+			continue
+		}
+		if removed {
+			removed = false
+			// "✅ Done" becomes "Done" and "English 🇬🇧" becomes "English"
+			if r == ' ' {
+				continue
+			}
+			out = bytes.TrimSuffix(out, []byte(" "))
+		}
+		out = utf8.AppendRune(out, r)
+	}
+	if removed {
+		out = bytes.TrimSuffix(out, []byte(" "))
+	}
+	return string(out)
+}
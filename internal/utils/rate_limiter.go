@@ -3,12 +3,25 @@ package utils
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// Rate limit categories, each category is counted separately so exhausting one doesn't block the others
+const (
+	RateLimitDownload = "download" // downloads and other requests that start yt-dlp
+	RateLimitCommand  = "command"  // lightweight commands like /help and /settings
+)
+
+// Rate limiting algorithms
+const (
+	RateLimitSlidingWindow = "sliding_window" // at most requestsMax requests within the time window
+	RateLimitTokenBucket   = "token_bucket"   // bursts up to a capacity, then requests at the refill rate
+)
+
 // RateLimiter provides rate limiting functionality
 type RateLimiter struct {
 	enabled     bool
@@ -19,11 +32,26 @@ type RateLimiter struct {
 	logger      *EnhancedLogger
 	mu          sync.Mutex
 	counters    map[string]counter
+	limits      map[string]limit // limits of categories that don't use the default limit
+	algorithm   string
+	refillRate  float64 // tokens per second of the default limit with the token bucket algorithm
+	burst       int     // capacity of the default limit with the token bucket algorithm
+	buckets     map[string]bucket
+	onRejected  func(category string) // called for every refused request, nil when not set
 }
 
 type counter struct {
 	count     int
 	timestamp time.Time
+	window    time.Duration
+}
+
+// limit is the max number of requests allowed within a time window
+type limit struct {
+	requestsMax int
+	timeWindow  time.Duration
+	refillRate  float64 // token bucket only, 0 refills requestsMax tokens per time window
+	burst       int     // token bucket only, 0 allows a burst of requestsMax
 }
 
 // NewRateLimiter creates a new rate limiter
@@ -36,11 +64,56 @@ func NewRateLimiter(enabled bool, requestsMax int, timeWindow int, userLimit boo
 		redisClient: redisClient,
 		logger:      logger,
 		counters:    make(map[string]counter),
+		limits:      make(map[string]limit),
+		algorithm:   RateLimitSlidingWindow,
+		buckets:     make(map[string]bucket),
 	}
 }
 
-// Allow checks if a request is allowed based on rate limits
-func (rl *RateLimiter) Allow(ctx context.Context, identifier string) (bool, error) {
+// WithTokenBucket switches to the token bucket algorithm. The default limit refills refillRate tokens per second up to
+// burst tokens, zero values derive them from the max requests and time window. Categories with their own limit
+// always derive them.
+func (rl *RateLimiter) WithTokenBucket(refillRate float64, burst int) *RateLimiter {
+	rl.algorithm = RateLimitTokenBucket
+	rl.refillRate = refillRate
+	rl.burst = burst
+	return rl
+}
+
+// WithCategoryLimit sets the limit of a category, categories without one use the default limit
+func (rl *RateLimiter) WithCategoryLimit(category string, requestsMax int, timeWindow int) *RateLimiter {
+	rl.limits[category] = limit{
+		requestsMax: requestsMax,
+		timeWindow:  time.Duration(timeWindow) * time.Second,
+	}
+	return rl
+}
+
+// WithRejectionHook calls fn with the category of every request the rate limit refuses, e.g. to count them
+func (rl *RateLimiter) WithRejectionHook(fn func(category string)) *RateLimiter {
+	rl.onRejected = fn
+	return rl
+}
+
+// categoryLimit returns the limit of a category
+func (rl *RateLimiter) categoryLimit(category string) limit {
+	if l, ok := rl.limits[category]; ok {
+		return l
+	}
+	return limit{requestsMax: rl.requestsMax, timeWindow: rl.timeWindow, refillRate: rl.refillRate, burst: rl.burst}
+}
+
+// Allow checks if a request of a category is allowed based on rate limits
+func (rl *RateLimiter) Allow(ctx context.Context, category string, identifier string) (bool, error) {
+	allowed, err := rl.allow(ctx, category, identifier)
+	if err == nil && !allowed && rl.onRejected != nil {
+		rl.onRejected(category)
+	}
+	return allowed, err
+}
+
+// allow checks a request against the limit of its category with the configured algorithm
+func (rl *RateLimiter) allow(ctx context.Context, category string, identifier string) (bool, error) {
 	if !rl.enabled {
 		return true, nil
 	}
@@ -50,20 +123,31 @@ func (rl *RateLimiter) Allow(ctx context.Context, identifier string) (bool, erro
 		identifier = "global"
 	}
 
+	// Every category has its own counters
+	identifier = category + ":" + identifier
+	l := rl.categoryLimit(category)
+
+	if rl.algorithm == RateLimitTokenBucket {
+		if rl.redisClient != nil {
+			return rl.allowBucketRedis(ctx, identifier, l)
+		}
+		return rl.allowBucketMemory(identifier, l), nil
+	}
+
 	// If Redis is available, use it for distributed rate limiting
 	if rl.redisClient != nil {
-		return rl.allowRedis(ctx, identifier)
+		return rl.allowRedis(ctx, identifier, l)
 	}
 
 	// Otherwise, use in-memory rate limiting
-	return rl.allowMemory(identifier), nil
+	return rl.allowMemory(identifier, l), nil
 }
 
 // allowRedis implements rate limiting using Redis
-func (rl *RateLimiter) allowRedis(ctx context.Context, identifier string) (bool, error) {
+func (rl *RateLimiter) allowRedis(ctx context.Context, identifier string, l limit) (bool, error) {
 	key := fmt.Sprintf("rate_limit:%s", identifier)
 	now := time.Now().Unix()
-	windowStart := now - int64(rl.timeWindow.Seconds())
+	windowStart := now - int64(l.timeWindow.Seconds())
 
 	// Remove counts older than the time window
 	if err := rl.redisClient.ZRemRangeByScore(ctx, key, "0", fmt.Sprintf("%d", windowStart)).Err(); err != nil {
@@ -79,19 +163,21 @@ func (rl *RateLimiter) allowRedis(ctx context.Context, identifier string) (bool,
 	}
 
 	// Check if limit is exceeded
-	if count >= int64(rl.requestsMax) {
-		rl.logger.Warn("Rate limit exceeded for %s: %d requests in %v", identifier, count, rl.timeWindow)
+	if count >= int64(l.requestsMax) {
+		rl.logger.Warn("Rate limit exceeded for %s: %d requests in %v", identifier, count, l.timeWindow)
 		return false, nil
 	}
 
-	// Add current request to the sorted set with score as current timestamp
-	if err := rl.redisClient.ZAdd(ctx, key, &redis.Z{Score: float64(now), Member: fmt.Sprintf("%d", now)}).Err(); err != nil {
+	// Add current request to the sorted set with score as current timestamp. Members of a sorted set are unique,
+	// so requests within the same second need distinct members to be counted separately.
+	member := fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Int63())
+	if err := rl.redisClient.ZAdd(ctx, key, &redis.Z{Score: float64(now), Member: member}).Err(); err != nil {
 		rl.logger.Error("Failed to add rate limit request: %v", err)
 		return true, err // Allow on error
 	}
 
 	// Set expiration on the key to clean up automatically
-	if err := rl.redisClient.Expire(ctx, key, rl.timeWindow).Err(); err != nil {
+	if err := rl.redisClient.Expire(ctx, key, l.timeWindow).Err(); err != nil {
 		rl.logger.Error("Failed to set rate limit expiration: %v", err)
 	}
 
@@ -99,7 +185,7 @@ func (rl *RateLimiter) allowRedis(ctx context.Context, identifier string) (bool,
 }
 
 // allowMemory implements rate limiting using in-memory counters
-func (rl *RateLimiter) allowMemory(identifier string) bool {
+func (rl *RateLimiter) allowMemory(identifier string, l limit) bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
@@ -107,17 +193,18 @@ func (rl *RateLimiter) allowMemory(identifier string) bool {
 	c, exists := rl.counters[identifier]
 
 	// If counter doesn't exist or time window has passed, reset it
-	if !exists || now.Sub(c.timestamp) > rl.timeWindow {
+	if !exists || now.Sub(c.timestamp) > l.timeWindow {
 		rl.counters[identifier] = counter{
 			count:     1,
 			timestamp: now,
+			window:    l.timeWindow,
 		}
 		return true
 	}
 
 	// Check if limit is exceeded
-	if c.count >= rl.requestsMax {
-		rl.logger.Warn("Rate limit exceeded for %s: %d requests in %v", identifier, c.count, rl.timeWindow)
+	if c.count >= l.requestsMax {
+		rl.logger.Warn("Rate limit exceeded for %s: %d requests in %v", identifier, c.count, l.timeWindow)
 		return false
 	}
 
@@ -134,10 +221,15 @@ func (rl *RateLimiter) CleanupExpiredCounters() {
 
 	now := time.Now()
 	for id, c := range rl.counters {
-		if now.Sub(c.timestamp) > rl.timeWindow {
+		if now.Sub(c.timestamp) > c.window {
 			delete(rl.counters, id)
 		}
 	}
+	for id, b := range rl.buckets {
+		if now.Sub(b.last) > b.idle {
+			delete(rl.buckets, id)
+		}
+	}
 }
 
 // StartCleanupScheduler starts a scheduler to clean up expired counters
@@ -146,7 +238,14 @@ func (rl *RateLimiter) StartCleanupScheduler(ctx context.Context) {
 		return // No need to clean up if disabled or using Redis
 	}
 
-	ticker := time.NewTicker(rl.timeWindow)
+	// Clean up as often as the shortest window expires
+	interval := rl.timeWindow
+	for _, l := range rl.limits {
+		if l.timeWindow > 0 && l.timeWindow < interval {
+			interval = l.timeWindow
+		}
+	}
+	ticker := time.NewTicker(interval)
 	go func() {
 		for {
 			select {
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// bucket is the in-memory token bucket of an identifier
+type bucket struct {
+	*TokenBucket
+	idle time.Duration // after this long without requests the bucket is full again and can be dropped
+}
+
+// tokenBucketScript refills a bucket for the time passed since its last request and takes a token if one is left,
+// atomically so instances sharing Redis can't both take the last token.
+// KEYS[1] is the bucket, ARGV are the refill rate per second, the capacity and the current time in milliseconds.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil or last == nil then
+	tokens = capacity
+	last = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - last) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "last", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate * 1000) + 1000)
+return allowed
+`)
+
+// bucketParams returns the refill rate per second and the capacity of a limit
+func bucketParams(l limit) (float64, float64) {
+	capacity := float64(l.burst)
+	if capacity <= 0 {
+		capacity = float64(l.requestsMax)
+	}
+	rate := l.refillRate
+	if rate <= 0 && l.timeWindow > 0 {
+		rate = float64(l.requestsMax) / l.timeWindow.Seconds()
+	}
+	return rate, math.Max(capacity, 1)
+}
+
+// allowBucketRedis implements token bucket rate limiting using Redis
+func (rl *RateLimiter) allowBucketRedis(ctx context.Context, identifier string, l limit) (bool, error) {
+	rate, capacity := bucketParams(l)
+	if rate <= 0 {
+		return true, nil
+	}
+
+	key := fmt.Sprintf("rate_limit_bucket:%s", identifier)
+	allowed, err := tokenBucketScript.Run(ctx, rl.redisClient, []string{key}, rate, capacity, time.Now().UnixMilli()).Int()
+	if err != nil {
+		rl.logger.Error("Failed to take a rate limit token: %v", err)
+		return true, err // Allow on error
+	}
+
+	if allowed == 0 {
+		rl.logger.Warn("Rate limit exceeded for %s: bucket of %.0f tokens refilling %.2f/s is empty", identifier, capacity, rate)
+		return false, nil
+	}
+	return true, nil
+}
+
+// allowBucketMemory implements token bucket rate limiting using in-memory buckets
+func (rl *RateLimiter) allowBucketMemory(identifier string, l limit) bool {
+	rate, capacity := bucketParams(l)
+	if rate <= 0 {
+		return true
+	}
+
+	// Taking the token with the lock held keeps CleanupExpiredCounters from reading a bucket being refilled
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	b, exists := rl.buckets[identifier]
+	if !exists {
+		b = bucket{
+			TokenBucket: NewTokenBucket(rate, int(capacity)),
+			idle:        time.Duration(capacity / rate * float64(time.Second)),
+		}
+		rl.buckets[identifier] = b
+	}
+
+	if !b.Take() {
+		rl.logger.Warn("Rate limit exceeded for %s: bucket of %.0f tokens refilling %.2f/s is empty", identifier, capacity, rate)
+		return false
+	}
+	return true
+}
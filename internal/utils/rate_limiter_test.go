@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+// newTestLogger returns a logger that writes nothing
+func newTestLogger(t testing.TB) *EnhancedLogger {
+	t.Helper()
+
+	logger, err := NewEnhancedLogger(&EnhancedLoggerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger
+}
+
+// allowN makes n requests of a category and identifier, returning how many were allowed
+func allowN(t *testing.T, rl *RateLimiter, category, identifier string, n int) int {
+	t.Helper()
+
+	allowed := 0
+	for i := 0; i < n; i++ {
+		ok, err := rl.Allow(context.Background(), category, identifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestRateLimiterAllowsUpToTheLimit(t *testing.T) {
+	rl := NewRateLimiter(true, 3, 60, true, nil, newTestLogger(t))
+
+	if got := allowN(t, rl, RateLimitDownload, "1", 5); got != 3 {
+		t.Errorf("allowed %d of 5 requests, want 3", got)
+	}
+}
+
+func TestRateLimiterCountsUsersSeparately(t *testing.T) {
+	rl := NewRateLimiter(true, 2, 60, true, nil, newTestLogger(t))
+
+	if got := allowN(t, rl, RateLimitDownload, "1", 3); got != 2 {
+		t.Errorf("user 1: allowed %d of 3 requests, want 2", got)
+	}
+	if got := allowN(t, rl, RateLimitDownload, "2", 3); got != 2 {
+		t.Errorf("user 2: allowed %d of 3 requests, want 2", got)
+	}
+}
+
+func TestRateLimiterGlobalLimit(t *testing.T) {
+	rl := NewRateLimiter(true, 2, 60, false, nil, newTestLogger(t))
+
+	allowN(t, rl, RateLimitDownload, "1", 2)
+	if got := allowN(t, rl, RateLimitDownload, "2", 1); got != 0 {
+		t.Errorf("allowed a request over the global limit")
+	}
+}
+
+func TestRateLimiterCategoriesRunOutIndependently(t *testing.T) {
+	rl := NewRateLimiter(true, 2, 60, true, nil, newTestLogger(t)).WithCategoryLimit(RateLimitCommand, 5, 60)
+
+	if got := allowN(t, rl, RateLimitDownload, "1", 3); got != 2 {
+		t.Errorf("downloads: allowed %d of 3 requests, want 2", got)
+	}
+	if got := allowN(t, rl, RateLimitCommand, "1", 6); got != 5 {
+		t.Errorf("commands after the downloads ran out: allowed %d of 6 requests, want 5", got)
+	}
+	if got := allowN(t, rl, RateLimitDownload, "1", 1); got != 0 {
+		t.Errorf("downloads after the commands ran out: allowed %d requests, want 0", got)
+	}
+	if got := allowN(t, rl, RateLimitDownload, "2", 1); got != 1 {
+		t.Errorf("downloads of another user: allowed %d requests, want 1", got)
+	}
+}
+
+func TestRateLimiterCategoriesRunOutIndependentlyWithTokenBucket(t *testing.T) {
+	rl := NewRateLimiter(true, 2, 3600, true, nil, newTestLogger(t)).
+		WithCategoryLimit(RateLimitCommand, 5, 3600).
+		WithTokenBucket(0, 0)
+
+	if got := allowN(t, rl, RateLimitDownload, "1", 3); got != 2 {
+		t.Errorf("downloads: allowed %d of 3 requests, want 2", got)
+	}
+	if got := allowN(t, rl, RateLimitCommand, "1", 6); got != 5 {
+		t.Errorf("commands after the downloads ran out: allowed %d of 6 requests, want 5", got)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	rl := NewRateLimiter(false, 1, 60, true, nil, newTestLogger(t))
+
+	if got := allowN(t, rl, RateLimitDownload, "1", 10); got != 10 {
+		t.Errorf("allowed %d of 10 requests with the rate limit disabled, want 10", got)
+	}
+}
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	// A refill rate of one token an hour keeps the bucket from refilling during the test
+	rl := NewRateLimiter(true, 10, 60, true, nil, newTestLogger(t)).WithTokenBucket(1.0/3600, 4)
+
+	if got := allowN(t, rl, RateLimitDownload, "1", 6); got != 4 {
+		t.Errorf("allowed %d of 6 requests, want a burst of 4", got)
+	}
+}
+
+func TestRateLimiterRejectionHook(t *testing.T) {
+	var rejected []string
+	hook := func(category string) { rejected = append(rejected, category) }
+
+	rl := NewRateLimiter(true, 2, 60, true, nil, newTestLogger(t)).WithRejectionHook(hook)
+	allowN(t, rl, RateLimitDownload, "1", 3)
+	if len(rejected) != 1 || rejected[0] != RateLimitDownload {
+		t.Errorf("hook called with %v, want one rejection of %s", rejected, RateLimitDownload)
+	}
+
+	// Requests are let through while Redis is down, those aren't rejections
+	client, _ := newUnreachableRedis(t)
+	rejected = nil
+	rl = NewRateLimiter(true, 2, 60, true, client, newTestLogger(t)).WithRejectionHook(hook)
+	allowed, err := rl.Allow(context.Background(), RateLimitDownload, "1")
+	if err == nil || !allowed {
+		t.Errorf("Allow() = %v, %v, want the request allowed with the Redis error", allowed, err)
+	}
+	if len(rejected) != 0 {
+		t.Errorf("hook called with %v for a failed Redis request", rejected)
+	}
+}
+
+// benchmarkRateLimiter measures the requests of many users against the in-memory limits
+func benchmarkRateLimiter(b *testing.B, rl *RateLimiter) {
+	ctx := context.Background()
+	identifiers := make([]string, 1000)
+	for i := range identifiers {
+		identifiers[i] = strconv.Itoa(i)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			rl.Allow(ctx, RateLimitDownload, identifiers[i%len(identifiers)])
+			i++
+		}
+	})
+}
+
+func BenchmarkRateLimiterSlidingWindow(b *testing.B) {
+	benchmarkRateLimiter(b, NewRateLimiter(true, 10, 60, true, nil, newTestLogger(b)))
+}
+
+func BenchmarkRateLimiterTokenBucket(b *testing.B) {
+	benchmarkRateLimiter(b, NewRateLimiter(true, 10, 60, true, nil, newTestLogger(b)).WithTokenBucket(0, 0))
+}
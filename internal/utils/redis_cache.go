@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// redisCache holds a value read from Redis, reading it again at most once per TTL
+type redisCache[T any] struct {
+	ttl  time.Duration
+	read func(ctx context.Context) (T, error)
+	// changed is called with the previous and the new value after each successful read, if set
+	changed func(previous, current T)
+
+	mu        sync.Mutex
+	value     T
+	checkedAt time.Time
+}
+
+// newRedisCache creates a cache of the value returned by read
+func newRedisCache[T any](ttl time.Duration, read func(ctx context.Context) (T, error)) *redisCache[T] {
+	return &redisCache[T]{ttl: ttl, read: read}
+}
+
+// get returns the cached value, or reads it when the cache expired. When the read fails, it returns the last
+// known value along with the error and keeps it until the next check, so a Redis outage doesn't flip the value.
+func (c *redisCache[T]) get(ctx context.Context) (T, error) {
+	c.mu.Lock()
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.ttl {
+		defer c.mu.Unlock()
+		return c.value, nil
+	}
+	c.mu.Unlock()
+
+	// Read Redis without the lock, so a slow Redis doesn't hold up the checks served from the cache
+	value, err := c.read(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checkedAt = time.Now()
+	if err != nil {
+		return c.value, err
+	}
+	if c.changed != nil {
+		c.changed(c.value, value)
+	}
+	c.value = value
+	return c.value, nil
+}
+
+// invalidate makes the next get read the value again
+func (c *redisCache[T]) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkedAt = time.Time{}
+}
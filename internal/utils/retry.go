@@ -2,6 +2,7 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -13,6 +14,12 @@ type RetryOptions struct {
 	MaxWait     time.Duration
 	Multiplier  float64
 	Logger      *EnhancedLogger
+	OnRetry     func(attempt int, wait time.Duration, err error) // called before waiting for each retry with the retry number from 1, may be nil
+	IsRetryable func(err error) bool                             // reports whether retrying can fix an error, nil retries every error not wrapped with Permanent
+
+	// ResetAfterSuccess makes RetryForever start over from InitialWait after a success instead of keeping the
+	// backoff reached by earlier failures
+	ResetAfterSuccess bool
 }
 
 // DefaultRetryOptions returns default retry options
@@ -55,9 +62,64 @@ func (o *RetryOptions) WithMultiplier(multiplier float64) *RetryOptions {
 	return o
 }
 
+// WithOnRetry sets the function called before each retry
+func (o *RetryOptions) WithOnRetry(onRetry func(attempt int, wait time.Duration, err error)) *RetryOptions {
+	o.OnRetry = onRetry
+	return o
+}
+
+// WithIsRetryable sets the function that tells errors worth retrying from permanent ones
+func (o *RetryOptions) WithIsRetryable(isRetryable func(err error) bool) *RetryOptions {
+	o.IsRetryable = isRetryable
+	return o
+}
+
+// WithResetAfterSuccess sets whether RetryForever resets the backoff after a success
+func (o *RetryOptions) WithResetAfterSuccess(reset bool) *RetryOptions {
+	o.ResetAfterSuccess = reset
+	return o
+}
+
 // RetryFunc is a function that can be retried
 type RetryFunc func() error
 
+// permanentError marks an error that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps an error so that RetryWithContext returns it right away instead of retrying
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// permanent reports whether retrying can't fix an error, either because it was wrapped with Permanent or because
+// IsRetryable rejects it, and returns the error to give up with
+func (o *RetryOptions) permanent(err error) (bool, error) {
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return true, permanent.err
+	}
+	if o.IsRetryable != nil && !o.IsRetryable(err) {
+		if o.Logger != nil {
+			o.Logger.Info("Not retrying permanent error: %v", err)
+		}
+		return true, err
+	}
+	return false, nil
+}
+
 // RetryWithContext retries a function with exponential backoff
 func RetryWithContext(ctx context.Context, fn RetryFunc, options *RetryOptions) error {
 	if options == nil {
@@ -74,6 +136,11 @@ func RetryWithContext(ctx context.Context, fn RetryFunc, options *RetryOptions)
 			return nil // Success
 		}
 
+		// Don't retry errors that can't be fixed by trying again
+		if ok, permanentErr := options.permanent(err); ok {
+			return permanentErr
+		}
+
 		// Check if we've reached max retries
 		if attempt == options.MaxRetries {
 			if options.Logger != nil {
@@ -98,6 +165,9 @@ func RetryWithContext(ctx context.Context, fn RetryFunc, options *RetryOptions)
 			options.Logger.Warn("Retry attempt %d/%d after error: %v (waiting %v before next attempt)",
 				attempt+1, options.MaxRetries, err, wait)
 		}
+		if options.OnRetry != nil {
+			options.OnRetry(attempt+1, wait, err)
+		}
 
 		// Wait before next attempt with exponential backoff
 		select {
@@ -136,6 +206,11 @@ func RetryWithContextAndResult[T any](ctx context.Context, fn func() (T, error),
 			return result, nil // Success
 		}
 
+		// Don't retry errors that can't be fixed by trying again
+		if ok, permanentErr := options.permanent(err); ok {
+			return result, permanentErr
+		}
+
 		// Check if we've reached max retries
 		if attempt == options.MaxRetries {
 			if options.Logger != nil {
@@ -160,6 +235,9 @@ func RetryWithContextAndResult[T any](ctx context.Context, fn func() (T, error),
 			options.Logger.Warn("Retry attempt %d/%d after error: %v (waiting %v before next attempt)",
 				attempt+1, options.MaxRetries, err, wait)
 		}
+		if options.OnRetry != nil {
+			options.OnRetry(attempt+1, wait, err)
+		}
 
 		// Wait before next attempt with exponential backoff
 		select {
@@ -180,3 +258,56 @@ func RetryWithContextAndResult[T any](ctx context.Context, fn func() (T, error),
 	// This should never happen due to the return in the loop, but just in case
 	return result, err
 }
+
+// RetryForever runs a function until the context is done, for long-running loops such as reconnecting to a database.
+// The function is expected to block while it works and return when it has to be run again. Errors are retried with
+// exponential backoff without a limit on the number of retries, MaxRetries is ignored. After a success the function
+// runs again right away. It returns the context's error, or the error of the function wrapped with Permanent or
+// rejected by IsRetryable.
+func RetryForever(ctx context.Context, fn RetryFunc, options *RetryOptions) error {
+	if options == nil {
+		options = DefaultRetryOptions()
+	}
+
+	wait := options.InitialWait
+	attempt := 0
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := fn()
+		if err == nil {
+			// Without a reset the backoff stays where earlier failures left it
+			if options.ResetAfterSuccess {
+				wait = options.InitialWait
+				attempt = 0
+			}
+			continue
+		}
+
+		// Don't retry errors that can't be fixed by trying again
+		if ok, permanentErr := options.permanent(err); ok {
+			return permanentErr
+		}
+
+		attempt++
+		if options.Logger != nil {
+			options.Logger.Warn("Retry attempt %d after error: %v (waiting %v before next attempt)", attempt, err, wait)
+		}
+		if options.OnRetry != nil {
+			options.OnRetry(attempt, wait, err)
+		}
+
+		select {
+		case <-time.After(wait):
+			wait = time.Duration(float64(wait) * options.Multiplier)
+			if wait > options.MaxWait {
+				wait = options.MaxWait
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
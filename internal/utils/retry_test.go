@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// runForever runs RetryForever over fn's results in turn, stopping once they are used up, and returns the waits
+// before the retries
+func runForever(t *testing.T, results []error, reset bool) []time.Duration {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var waits []time.Duration
+	options := &RetryOptions{
+		InitialWait:       time.Millisecond,
+		MaxWait:           time.Second,
+		Multiplier:        2,
+		ResetAfterSuccess: reset,
+		OnRetry:           func(attempt int, wait time.Duration, err error) { waits = append(waits, wait) },
+	}
+	calls := 0
+	err := RetryForever(ctx, func() error {
+		if calls == len(results) {
+			cancel()
+			return nil
+		}
+		calls++
+		return results[calls-1]
+	}, options)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RetryForever() = %v, want %v", err, context.Canceled)
+	}
+	return waits
+}
+
+func TestRetryForeverResetsAfterSuccess(t *testing.T) {
+	failure := errors.New("connection lost")
+	results := []error{failure, failure, nil, failure, failure}
+
+	want := []time.Duration{time.Millisecond, 2 * time.Millisecond, time.Millisecond, 2 * time.Millisecond}
+	if got := runForever(t, results, true); !reflect.DeepEqual(got, want) {
+		t.Errorf("waits with reset = %v, want %v", got, want)
+	}
+
+	want = []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 8 * time.Millisecond}
+	if got := runForever(t, results, false); !reflect.DeepEqual(got, want) {
+		t.Errorf("waits without reset = %v, want %v", got, want)
+	}
+}
+
+func TestRetryForeverStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	options := DefaultRetryOptions().WithInitialWait(time.Hour)
+	start := time.Now()
+	err := RetryForever(ctx, func() error { return errors.New("connection lost") }, options)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RetryForever() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned after %s, the wait for the next retry wasn't cut short", elapsed)
+	}
+}
+
+func TestRetryForeverReturnsPermanentErrors(t *testing.T) {
+	failure := errors.New("invalid credentials")
+	calls := 0
+	err := RetryForever(context.Background(), func() error {
+		calls++
+		return Permanent(failure)
+	}, DefaultRetryOptions())
+	if err != failure {
+		t.Errorf("RetryForever() = %v, want %v", err, failure)
+	}
+	if calls != 1 {
+		t.Errorf("called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenBucket limits the aggregate rate of an operation shared by many goroutines.
+// Low-priority callers only get a token while part of the burst is left for high-priority callers.
+type TokenBucket struct {
+	rate    float64 // tokens added per second, 0 or less means unlimited
+	burst   float64
+	reserve float64 // tokens kept back for high-priority callers
+	tokens  float64
+	last    time.Time
+	mu      sync.Mutex
+}
+
+// NewTokenBucket creates a new token bucket refilling rate tokens per second up to burst tokens
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucket{
+		rate:    rate,
+		burst:   float64(burst),
+		reserve: float64(burst / 4),
+		tokens:  float64(burst),
+		last:    time.Now(),
+	}
+}
+
+// Allow takes a token for a low-priority operation without waiting, returning false if it should be skipped
+func (b *TokenBucket) Allow() bool {
+	if b.rate <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens < 1+b.reserve {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Take takes a token without waiting and without keeping any back, returning false if none is left
+func (b *TokenBucket) Take() bool {
+	if b.rate <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Wait blocks until a token is available for a high-priority operation or the context is done
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if b.rate <= 0 {
+		return nil
+	}
+
+	for {
+		b.mu.Lock()
+		b.refill()
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// refill adds the tokens earned since the last refill, must be called with the lock held
+func (b *TokenBucket) refill() {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	// A refill rate of one token an hour keeps the bucket from refilling during the test
+	b := NewTokenBucket(1.0/3600, 4)
+
+	for i := 0; i < 4; i++ {
+		if !b.Take() {
+			t.Fatalf("token %d of a burst of 4 refused", i+1)
+		}
+	}
+	if b.Take() {
+		t.Errorf("took a token from an empty bucket")
+	}
+}
+
+func TestTokenBucketAllowKeepsReserve(t *testing.T) {
+	b := NewTokenBucket(1.0/3600, 4)
+
+	// One token of a burst of 4 is kept back for high-priority callers
+	allowed := 0
+	for i := 0; i < 4; i++ {
+		if b.Allow() {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("allowed %d low-priority operations, want 3", allowed)
+	}
+	if !b.Take() {
+		t.Errorf("the reserved token was taken by a low-priority operation")
+	}
+}
+
+func TestTokenBucketRefills(t *testing.T) {
+	b := NewTokenBucket(100, 1)
+
+	if !b.Take() {
+		t.Fatal("first token refused")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Wait(ctx); err != nil {
+		t.Errorf("waiting for a refill: %v", err)
+	}
+}
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	b := NewTokenBucket(0, 1)
+
+	for i := 0; i < 100; i++ {
+		if !b.Take() || !b.Allow() {
+			t.Fatal("an unlimited bucket refused a token")
+		}
+	}
+}
+
+func BenchmarkTokenBucketTake(b *testing.B) {
+	bucket := NewTokenBucket(1e9, 1000)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			bucket.Take()
+		}
+	})
+}
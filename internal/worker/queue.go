@@ -0,0 +1,227 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+)
+
+// ErrQueueFull is returned when a job is enqueued while the queue is at capacity
+var ErrQueueFull = errors.New("download queue is full")
+
+// ErrQueueStopped is returned when a job is enqueued after the queue was stopped
+var ErrQueueStopped = errors.New("download queue is stopped")
+
+// maxBypassed is how many higher priority jobs can be queued ahead of a waiting job, after that it keeps its place
+// so a steady stream of cheap jobs can't keep an expensive one waiting forever
+const maxBypassed = 10
+
+// Job is a unit of work run by the worker pool
+type Job struct {
+	ID       string
+	ChatID   int64
+	Priority int // jobs with a higher priority run first, equal priorities run in the order enqueued
+	Run      func(ctx context.Context)
+	bypassed int // higher priority jobs queued ahead of this one while it was waiting
+}
+
+// Stats is the load of a queue at one point in time
+type Stats struct {
+	Waiting int // jobs waiting for a worker
+	Running int // jobs being run by a worker
+	Workers int // size of the worker pool
+}
+
+// Queue runs jobs on a bounded pool of workers, highest priority first
+type Queue struct {
+	ready     chan struct{} // one token per waiting job
+	capacity  int
+	workers   int
+	logger    *utils.Logger
+	mu        sync.Mutex
+	waiting   []*Job          // jobs not picked up by a worker yet, in the order they will run
+	cancelled map[string]bool // waiting jobs that must be skipped
+	busy      int
+	stopped   bool
+}
+
+// NewQueue creates a new queue with the given number of workers and room for capacity waiting jobs
+func NewQueue(workers, capacity int, logger *utils.Logger) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &Queue{
+		ready:     make(chan struct{}, capacity),
+		capacity:  capacity,
+		workers:   workers,
+		logger:    logger,
+		cancelled: make(map[string]bool),
+	}
+}
+
+// Start starts the workers, which stop once the context is done
+func (q *Queue) Start(ctx context.Context) {
+	q.logger.Info("Starting %d download workers", q.workers)
+	for i := 0; i < q.workers; i++ {
+		go q.work(ctx)
+	}
+}
+
+// Enqueue adds a job to the queue and returns how many jobs will run before it starts, 0 meaning right away
+func (q *Queue) Enqueue(job *Job) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopped {
+		return 0, ErrQueueStopped
+	}
+	if len(q.waiting) >= q.capacity {
+		return 0, ErrQueueFull
+	}
+
+	// Insert after every job with the same or a higher priority, and after the jobs bypassed too often already
+	index := len(q.waiting)
+	for index > 0 {
+		ahead := q.waiting[index-1]
+		if ahead.Priority >= job.Priority || ahead.bypassed >= maxBypassed {
+			break
+		}
+		index--
+	}
+	for _, bypassed := range q.waiting[index:] {
+		bypassed.bypassed++
+	}
+	q.waiting = append(q.waiting, nil)
+	copy(q.waiting[index+1:], q.waiting[index:])
+	q.waiting[index] = job
+	q.ready <- struct{}{}
+
+	// Jobs ahead of this one that idle workers can't pick up right away
+	position := index + 1 - (q.workers - q.busy)
+	if position < 0 {
+		position = 0
+	}
+	return position, nil
+}
+
+// Stats returns how many jobs are waiting and running
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Cancelled jobs stay in the queue until a worker skips them
+	return Stats{
+		Waiting: len(q.waiting) - len(q.cancelled),
+		Running: q.busy,
+		Workers: q.workers,
+	}
+}
+
+// CancelLatest removes the most recently enqueued waiting job of a chat and returns its ID
+func (q *Queue) CancelLatest(chatID int64) (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i := len(q.waiting) - 1; i >= 0; i-- {
+		job := q.waiting[i]
+		if job.ChatID == chatID && !q.cancelled[job.ID] {
+			q.cancelled[job.ID] = true
+			return job.ID, true
+		}
+	}
+	return "", false
+}
+
+// CancelChat removes every waiting job of a chat and returns them
+func (q *Queue) CancelChat(chatID int64) []*Job {
+	return q.cancelWaiting(func(job *Job) bool { return job.ChatID == chatID })
+}
+
+// CancelAll removes every waiting job and returns them
+func (q *Queue) CancelAll() []*Job {
+	return q.cancelWaiting(func(job *Job) bool { return true })
+}
+
+// Stop refuses new jobs and removes every waiting job, returning them so they can be run after a restart.
+// Running jobs are left to finish, or to stop once the context given to Start is done.
+func (q *Queue) Stop() []*Job {
+	q.mu.Lock()
+	q.stopped = true
+	q.mu.Unlock()
+
+	return q.cancelWaiting(func(job *Job) bool { return true })
+}
+
+// cancelWaiting marks the waiting jobs that match as cancelled and returns them
+func (q *Queue) cancelWaiting(match func(job *Job) bool) []*Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Cancelled jobs stay in the queue until a worker skips them, so each ready token is still consumed
+	var jobs []*Job
+	for _, job := range q.waiting {
+		if match(job) && !q.cancelled[job.ID] {
+			q.cancelled[job.ID] = true
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// work runs jobs until the context is done
+func (q *Queue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.ready:
+			job, ok := q.next()
+			if !ok {
+				q.logger.Info("Skipping cancelled job %s", job.ID)
+				continue
+			}
+
+			q.run(ctx, job)
+		}
+	}
+}
+
+// run runs a job and frees its worker. Jobs are expected to recover from their own panics to clean up after
+// themselves, a panic reaching the worker is only logged so it doesn't crash the bot or lose the worker.
+func (q *Queue) run(ctx context.Context, job *Job) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			q.logger.Error("Job %s panicked: %v\n%s", job.ID, recovered, debug.Stack())
+		}
+
+		q.mu.Lock()
+		q.busy--
+		q.mu.Unlock()
+	}()
+
+	job.Run(ctx)
+}
+
+// next takes the first waiting job for a worker, returning false if it was cancelled while waiting
+func (q *Queue) next() (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job := q.waiting[0]
+	q.waiting = q.waiting[1:]
+
+	if q.cancelled[job.ID] {
+		delete(q.cancelled, job.ID)
+		return job, false
+	}
+
+	q.busy++
+	return job, true
+}
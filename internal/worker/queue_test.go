@@ -0,0 +1,209 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohammedteir/telegram-video-downloader-bot/internal/utils"
+)
+
+// newTestQueue creates a queue logging nowhere
+func newTestQueue(t *testing.T, workers, capacity int) *Queue {
+	t.Helper()
+
+	logger, err := utils.NewLogger(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewQueue(workers, capacity, logger)
+}
+
+// waitingIDs returns the IDs of the waiting jobs in the order they will run
+func waitingIDs(q *Queue) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids := make([]string, len(q.waiting))
+	for i, job := range q.waiting {
+		ids[i] = job.ID
+	}
+	return ids
+}
+
+func TestQueueRunsSmallJobBeforeLargeOne(t *testing.T) {
+	q := newTestQueue(t, 1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Keep the only worker busy until both jobs are queued
+	release := make(chan struct{})
+	started := make(chan struct{})
+	if _, err := q.Enqueue(&Job{ID: "blocker", Run: func(ctx context.Context) {
+		close(started)
+		<-release
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	q.Start(ctx)
+	<-started
+
+	var mu sync.Mutex
+	var order []string
+	var done sync.WaitGroup
+	record := func(id string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			mu.Lock()
+			order = append(order, id)
+			mu.Unlock()
+			done.Done()
+		}
+	}
+	done.Add(2)
+	if _, err := q.Enqueue(&Job{ID: "large", Priority: -60, Run: record("large")}); err != nil {
+		t.Fatal(err)
+	}
+	position, err := q.Enqueue(&Job{ID: "small", Priority: -1, Run: record("small")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position != 1 {
+		t.Errorf("small job position = %d, want 1 behind the running job", position)
+	}
+
+	close(release)
+	done.Wait()
+	if want := []string{"small", "large"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("jobs ran in order %v, want %v", order, want)
+	}
+}
+
+func TestQueueLimitsHowOftenAJobIsBypassed(t *testing.T) {
+	q := newTestQueue(t, 1, 100)
+
+	if _, err := q.Enqueue(&Job{ID: "large", Priority: -60}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxBypassed+2; i++ {
+		if _, err := q.Enqueue(&Job{ID: fmt.Sprintf("small%d", i), Priority: -1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var want []string
+	for i := 0; i < maxBypassed; i++ {
+		want = append(want, fmt.Sprintf("small%d", i))
+	}
+	want = append(want, "large", fmt.Sprintf("small%d", maxBypassed), fmt.Sprintf("small%d", maxBypassed+1))
+	if got := waitingIDs(q); !reflect.DeepEqual(got, want) {
+		t.Errorf("waiting jobs = %v, want %v", got, want)
+	}
+}
+
+func TestQueueKeepsOrderOfEqualPriorities(t *testing.T) {
+	q := newTestQueue(t, 1, 10)
+
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := q.Enqueue(&Job{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := waitingIDs(q), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("waiting jobs = %v, want %v", got, want)
+	}
+}
+
+func TestQueueSurvivesPanickingJob(t *testing.T) {
+	q := newTestQueue(t, 1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	// A downloader stub that panics, the only worker must recover and run the next job
+	download := func() { panic("downloader crashed") }
+	if _, err := q.Enqueue(&Job{ID: "panics", Run: func(ctx context.Context) { download() }}); err != nil {
+		t.Fatal(err)
+	}
+	ran := make(chan struct{})
+	if _, err := q.Enqueue(&Job{ID: "next", Run: func(ctx context.Context) { close(ran) }}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("the job after the panic didn't run")
+	}
+	waitFor(t, func() bool { return q.Stats().Running == 0 })
+}
+
+func TestQueueStopReturnsWaitingJobs(t *testing.T) {
+	q := newTestQueue(t, 1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The running job is left to finish, only the waiting ones are returned
+	release := make(chan struct{})
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	if _, err := q.Enqueue(&Job{ID: "running", Run: func(ctx context.Context) {
+		close(started)
+		<-release
+		close(finished)
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	q.Start(ctx)
+	<-started
+
+	ran := make(chan string, 2)
+	for _, id := range []string{"a", "b"} {
+		if _, err := q.Enqueue(&Job{ID: id, Run: func(ctx context.Context) { ran <- id }}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []string
+	for _, job := range q.Stop() {
+		ids = append(ids, job.ID)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Stop() returned jobs %v, want %v", ids, want)
+	}
+	if _, err := q.Enqueue(&Job{ID: "late", Run: func(ctx context.Context) { ran <- "late" }}); err != ErrQueueStopped {
+		t.Errorf("Enqueue after Stop = %v, want %v", err, ErrQueueStopped)
+	}
+	if stats := q.Stats(); stats.Waiting != 0 || stats.Running != 1 {
+		t.Errorf("Stats() after Stop = %+v, want no waiting and 1 running job", stats)
+	}
+
+	close(release)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("the running job didn't finish after Stop")
+	}
+	// The worker skips the returned jobs instead of running them
+	waitFor(t, func() bool { return len(waitingIDs(q)) == 0 && q.Stats().Running == 0 })
+	select {
+	case id := <-ran:
+		t.Errorf("job %s ran after Stop", id)
+	default:
+	}
+}
+
+// waitFor polls a condition until it holds or a second has passed
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}